@@ -0,0 +1,159 @@
+// Copyright 2019 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+
+	"github.com/prometheus/prometheus/config"
+	"github.com/prometheus/prometheus/discovery"
+	sd_config "github.com/prometheus/prometheus/discovery/config"
+	"github.com/prometheus/prometheus/discovery/targetgroup"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/pkg/relabel"
+	"github.com/prometheus/prometheus/scrape"
+)
+
+type sdCheckResult struct {
+	Provider         string          `json:"provider"`
+	Source           string          `json:"source"`
+	DiscoveredLabels labels.Labels   `json:"discoveredLabels"`
+	RelabelSteps     []sdRelabelStep `json:"relabelSteps,omitempty"`
+	Labels           labels.Labels   `json:"labels"`
+	Error            string          `json:"error,omitempty"`
+}
+
+// sdRelabelStep holds the labels of a target after applying a single
+// relabel configuration. The labels are nil if the target was dropped.
+type sdRelabelStep struct {
+	Action relabel.Action `json:"action"`
+	Labels labels.Labels  `json:"labels"`
+}
+
+// CheckSD performs service discovery for the given job name and reports the results.
+func CheckSD(sdConfigFile, sdJobName string, sdTimeout time.Duration) int {
+	logger := level.NewFilter(log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr)), level.AllowInfo())
+
+	cfg, err := config.LoadFile(sdConfigFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Cannot load config", err)
+		return 2
+	}
+
+	var scrapeConfig *config.ScrapeConfig
+	jobs := []string{}
+	jobMatched := false
+	for _, v := range cfg.ScrapeConfigs {
+		jobs = append(jobs, v.JobName)
+		if v.JobName == sdJobName {
+			jobMatched = true
+			scrapeConfig = v
+			break
+		}
+	}
+
+	if !jobMatched {
+		fmt.Fprintf(os.Stderr, "Job %s not found. Select one of:\n", sdJobName)
+		for _, job := range jobs {
+			fmt.Fprintf(os.Stderr, "\t%s\n", job)
+		}
+		return 1
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	m := discovery.NewManager(ctx, logger)
+	go m.Run()
+	if err := m.ApplyConfig(map[string]sd_config.ServiceDiscoveryConfig{sdJobName: scrapeConfig.ServiceDiscoveryConfig}); err != nil {
+		fmt.Fprintln(os.Stderr, "Cannot start service discovery", err)
+		return 2
+	}
+
+	// Discoverers don't signal when they are done, so give them the whole
+	// timeout to report their targets.
+	time.Sleep(sdTimeout)
+
+	results := getSDCheckResult(m.DiscoveredGroups()[sdJobName], scrapeConfig)
+	res, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Could not marshal result json:", err)
+		return 2
+	}
+
+	fmt.Printf("%s\n", res)
+	return 0
+}
+
+// getSDCheckResult applies the relabeling of the scrape configuration to
+// the target groups discovered by each provider, keeping the intermediate
+// label sets.
+func getSDCheckResult(providerGroups map[string][]*targetgroup.Group, scrapeConfig *config.ScrapeConfig) []sdCheckResult {
+	providers := make([]string, 0, len(providerGroups))
+	for p := range providerGroups {
+		providers = append(providers, p)
+	}
+	sort.Strings(providers)
+
+	sdCheckResults := []sdCheckResult{}
+	for _, provider := range providers {
+		for _, tg := range providerGroups[provider] {
+			for _, target := range tg.Targets {
+				lbls := make([]labels.Label, 0, len(target)+len(tg.Labels))
+				for name, value := range target {
+					lbls = append(lbls, labels.Label{Name: string(name), Value: string(value)})
+				}
+				for name, value := range tg.Labels {
+					if _, ok := target[name]; !ok {
+						lbls = append(lbls, labels.Label{Name: string(name), Value: string(value)})
+					}
+				}
+
+				result := sdCheckResult{
+					Provider: provider,
+					Source:   tg.Source,
+				}
+				res, orig, err := scrape.PopulateLabels(labels.New(lbls...), scrapeConfig)
+				if err != nil {
+					result.Error = err.Error()
+					orig = labels.New(lbls...)
+				}
+				result.DiscoveredLabels = orig
+				result.Labels = res
+
+				// Replay the relabeling one step at a time, starting from the
+				// labels PopulateLabels passed to the relabel configurations.
+				lset := orig
+				for _, rc := range scrapeConfig.RelabelConfigs {
+					if lset == nil {
+						break
+					}
+					lset = relabel.Process(lset, rc)
+					result.RelabelSteps = append(result.RelabelSteps, sdRelabelStep{Action: rc.Action, Labels: lset})
+				}
+
+				sdCheckResults = append(sdCheckResults, result)
+			}
+		}
+	}
+	return sdCheckResults
+}
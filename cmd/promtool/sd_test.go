@@ -0,0 +1,116 @@
+// Copyright 2019 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/prometheus/common/model"
+
+	"github.com/prometheus/prometheus/config"
+	"github.com/prometheus/prometheus/discovery/targetgroup"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/pkg/relabel"
+	"github.com/prometheus/prometheus/util/testutil"
+)
+
+func TestSDCheckResult(t *testing.T) {
+	providerGroups := map[string][]*targetgroup.Group{
+		"static/0": {{
+			Source: "0",
+			Targets: []model.LabelSet{
+				{"__address__": "localhost:8080", "foo": "bar"},
+				{"__address__": "localhost:8081", "drop": "true"},
+			},
+			Labels: model.LabelSet{"foo": "baz", "group": "a"},
+		}},
+	}
+
+	scrapeConfig := &config.ScrapeConfig{
+		JobName:     "test",
+		MetricsPath: "/metrics",
+		Scheme:      "http",
+		RelabelConfigs: []*relabel.Config{{
+			SourceLabels: model.LabelNames{"drop"},
+			Regex:        relabel.MustNewRegexp("true"),
+			Action:       relabel.Drop,
+		}, {
+			SourceLabels: model.LabelNames{"foo"},
+			Regex:        relabel.MustNewRegexp("(.*)"),
+			Separator:    ";",
+			TargetLabel:  "newfoo",
+			Replacement:  "$1",
+			Action:       relabel.Replace,
+		}},
+	}
+
+	kept := labels.FromStrings(
+		"__address__", "localhost:8080",
+		"__metrics_path__", "/metrics",
+		"__scheme__", "http",
+		"foo", "bar",
+		"group", "a",
+		"job", "test",
+	)
+	keptRelabeled := labels.FromStrings(
+		"__address__", "localhost:8080",
+		"__metrics_path__", "/metrics",
+		"__scheme__", "http",
+		"foo", "bar",
+		"group", "a",
+		"job", "test",
+		"newfoo", "bar",
+	)
+	dropped := labels.FromStrings(
+		"__address__", "localhost:8081",
+		"__metrics_path__", "/metrics",
+		"__scheme__", "http",
+		"drop", "true",
+		"foo", "baz",
+		"group", "a",
+		"job", "test",
+	)
+
+	expected := []sdCheckResult{
+		{
+			Provider:         "static/0",
+			Source:           "0",
+			DiscoveredLabels: kept,
+			RelabelSteps: []sdRelabelStep{
+				{Action: relabel.Drop, Labels: kept},
+				{Action: relabel.Replace, Labels: keptRelabeled},
+			},
+			Labels: labels.FromStrings(
+				"foo", "bar",
+				"group", "a",
+				"instance", "localhost:8080",
+				"job", "test",
+				"newfoo", "bar",
+				"__address__", "localhost:8080",
+				"__metrics_path__", "/metrics",
+				"__scheme__", "http",
+			),
+		},
+		{
+			Provider:         "static/0",
+			Source:           "0",
+			DiscoveredLabels: dropped,
+			RelabelSteps: []sdRelabelStep{
+				{Action: relabel.Drop, Labels: nil},
+			},
+		},
+	}
+
+	testutil.Equals(t, expected, getSDCheckResult(providerGroups, scrapeConfig))
+}
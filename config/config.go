@@ -22,6 +22,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/alecthomas/units"
 	"github.com/prometheus/prometheus/pkg/relabel"
 
 	config_util "github.com/prometheus/common/config"
@@ -82,9 +83,10 @@ var (
 	DefaultScrapeConfig = ScrapeConfig{
 		// ScrapeTimeout and ScrapeInterval default to the
 		// configured globals.
-		MetricsPath: "/metrics",
-		Scheme:      "http",
-		HonorLabels: false,
+		MetricsPath:     "/metrics",
+		Scheme:          "http",
+		HonorLabels:     false,
+		HonorTimestamps: true,
 	}
 
 	// DefaultAlertmanagerConfig is the default alertmanager configuration.
@@ -112,8 +114,7 @@ var (
 		Capacity:          100 * 100,
 		BatchSendDeadline: model.Duration(5 * time.Second),
 
-		// Max number of times to retry a batch on recoverable errors.
-		MaxRetries: 3,
+		// On recoverable errors, backoff exponentially.
 		MinBackoff: model.Duration(30 * time.Millisecond),
 		MaxBackoff: model.Duration(100 * time.Millisecond),
 	}
@@ -148,6 +149,8 @@ func resolveFilepaths(baseDir string, cfg *Config) {
 		return fp
 	}
 
+	cfg.GlobalConfig.QueryLogFile = join(cfg.GlobalConfig.QueryLogFile)
+
 	for i, rf := range cfg.RuleFiles {
 		cfg.RuleFiles[i] = join(rf)
 	}
@@ -158,39 +161,13 @@ func resolveFilepaths(baseDir string, cfg *Config) {
 		scfg.TLSConfig.CertFile = join(scfg.TLSConfig.CertFile)
 		scfg.TLSConfig.KeyFile = join(scfg.TLSConfig.KeyFile)
 	}
-	sdPaths := func(cfg *sd_config.ServiceDiscoveryConfig) {
-		for _, kcfg := range cfg.KubernetesSDConfigs {
-			kcfg.BearerTokenFile = join(kcfg.BearerTokenFile)
-			kcfg.TLSConfig.CAFile = join(kcfg.TLSConfig.CAFile)
-			kcfg.TLSConfig.CertFile = join(kcfg.TLSConfig.CertFile)
-			kcfg.TLSConfig.KeyFile = join(kcfg.TLSConfig.KeyFile)
-		}
-		for _, mcfg := range cfg.MarathonSDConfigs {
-			mcfg.AuthTokenFile = join(mcfg.AuthTokenFile)
-			mcfg.HTTPClientConfig.BearerTokenFile = join(mcfg.HTTPClientConfig.BearerTokenFile)
-			mcfg.HTTPClientConfig.TLSConfig.CAFile = join(mcfg.HTTPClientConfig.TLSConfig.CAFile)
-			mcfg.HTTPClientConfig.TLSConfig.CertFile = join(mcfg.HTTPClientConfig.TLSConfig.CertFile)
-			mcfg.HTTPClientConfig.TLSConfig.KeyFile = join(mcfg.HTTPClientConfig.TLSConfig.KeyFile)
-		}
-		for _, consulcfg := range cfg.ConsulSDConfigs {
-			consulcfg.TLSConfig.CAFile = join(consulcfg.TLSConfig.CAFile)
-			consulcfg.TLSConfig.CertFile = join(consulcfg.TLSConfig.CertFile)
-			consulcfg.TLSConfig.KeyFile = join(consulcfg.TLSConfig.KeyFile)
-		}
-		for _, filecfg := range cfg.FileSDConfigs {
-			for i, fn := range filecfg.Files {
-				filecfg.Files[i] = join(fn)
-			}
-		}
-	}
-
 	for _, cfg := range cfg.ScrapeConfigs {
 		clientPaths(&cfg.HTTPClientConfig)
-		sdPaths(&cfg.ServiceDiscoveryConfig)
+		cfg.ServiceDiscoveryConfig.SetDirectory(baseDir)
 	}
 	for _, cfg := range cfg.AlertingConfig.AlertmanagerConfigs {
 		clientPaths(&cfg.HTTPClientConfig)
-		sdPaths(&cfg.ServiceDiscoveryConfig)
+		cfg.ServiceDiscoveryConfig.SetDirectory(baseDir)
 	}
 }
 
@@ -245,6 +222,9 @@ func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
 				scfg.ScrapeTimeout = c.GlobalConfig.ScrapeTimeout
 			}
 		}
+		if scfg.BodySizeLimit == 0 {
+			scfg.BodySizeLimit = c.GlobalConfig.BodySizeLimit
+		}
 
 		if _, ok := jobNames[scfg.JobName]; ok {
 			return fmt.Errorf("found multiple scrape configs with job name %q", scfg.JobName)
@@ -275,6 +255,11 @@ type GlobalConfig struct {
 	EvaluationInterval model.Duration `yaml:"evaluation_interval,omitempty"`
 	// The labels to add to any timeseries that this Prometheus instance scrapes.
 	ExternalLabels model.LabelSet `yaml:"external_labels,omitempty"`
+	// File to which PromQL queries are logged.
+	QueryLogFile string `yaml:"query_log_file,omitempty"`
+	// An uncompressed response body larger than this many bytes will cause the
+	// scrape to fail. 0 means no limit.
+	BodySizeLimit ByteSize `yaml:"body_size_limit,omitempty"`
 }
 
 // UnmarshalYAML implements the yaml.Unmarshaler interface.
@@ -314,7 +299,9 @@ func (c *GlobalConfig) isZero() bool {
 	return c.ExternalLabels == nil &&
 		c.ScrapeInterval == 0 &&
 		c.ScrapeTimeout == 0 &&
-		c.EvaluationInterval == 0
+		c.EvaluationInterval == 0 &&
+		c.QueryLogFile == "" &&
+		c.BodySizeLimit == 0
 }
 
 // ScrapeConfig configures a scraping unit for Prometheus.
@@ -323,6 +310,8 @@ type ScrapeConfig struct {
 	JobName string `yaml:"job_name"`
 	// Indicator whether the scraped metrics should remain unmodified.
 	HonorLabels bool `yaml:"honor_labels,omitempty"`
+	// Indicator whether the scraped timestamps should be respected.
+	HonorTimestamps bool `yaml:"honor_timestamps"`
 	// A set of query parameters with which the target is scraped.
 	Params url.Values `yaml:"params,omitempty"`
 	// How frequently to scrape the targets of this scrape config.
@@ -333,8 +322,23 @@ type ScrapeConfig struct {
 	MetricsPath string `yaml:"metrics_path,omitempty"`
 	// The URL scheme with which to fetch metrics from targets.
 	Scheme string `yaml:"scheme,omitempty"`
+	// An uncompressed response body larger than this many bytes will cause the
+	// scrape to fail. 0 means no limit.
+	BodySizeLimit ByteSize `yaml:"body_size_limit,omitempty"`
+	// The protocols to negotiate when scraping, in order of preference.
+	// Defaults to DefaultScrapeProtocols if empty.
+	ScrapeProtocols []ScrapeProtocol `yaml:"scrape_protocols,omitempty"`
 	// More than this many samples post metric-relabelling will cause the scrape to fail.
 	SampleLimit uint `yaml:"sample_limit,omitempty"`
+	// More than this many targets after the target relabelling will cause the
+	// scrapes of all targets of the job to fail.
+	TargetLimit uint `yaml:"target_limit,omitempty"`
+	// More than this many labels post metric-relabelling will cause the scrape to fail.
+	LabelLimit uint `yaml:"label_limit,omitempty"`
+	// More than this label name length post metric-relabelling will cause the scrape to fail.
+	LabelNameLengthLimit uint `yaml:"label_name_length_limit,omitempty"`
+	// More than this label value length post metric-relabelling will cause the scrape to fail.
+	LabelValueLengthLimit uint `yaml:"label_value_length_limit,omitempty"`
 
 	// We cannot do proper Go type embedding below as the parser will then parse
 	// values arbitrarily into the overflow maps of further-down types.
@@ -352,7 +356,7 @@ type ScrapeConfig struct {
 func (c *ScrapeConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	*c = DefaultScrapeConfig
 	type plain ScrapeConfig
-	err := unmarshal((*plain)(c))
+	err := sd_config.UnmarshalYAMLWithInlineConfigs((*plain)(c), unmarshal)
 	if err != nil {
 		return err
 	}
@@ -374,6 +378,17 @@ func (c *ScrapeConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
 		return err
 	}
 
+	seenProtocols := map[ScrapeProtocol]struct{}{}
+	for _, sp := range c.ScrapeProtocols {
+		if err := sp.Validate(); err != nil {
+			return err
+		}
+		if _, ok := seenProtocols[sp]; ok {
+			return fmt.Errorf("duplicated protocol in scrape_protocols, got %v", c.ScrapeProtocols)
+		}
+		seenProtocols[sp] = struct{}{}
+	}
+
 	// Check for users putting URLs in target groups.
 	if len(c.RelabelConfigs) == 0 {
 		for _, tg := range c.ServiceDiscoveryConfig.StaticConfigs {
@@ -405,6 +420,70 @@ func (c *ScrapeConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	return nil
 }
 
+// MarshalYAML implements the yaml.Marshaler interface.
+func (c *ScrapeConfig) MarshalYAML() (interface{}, error) {
+	type plain ScrapeConfig
+	return sd_config.MarshalYAMLWithInlineConfigs((*plain)(c))
+}
+
+// ScrapeProtocol is a protocol in which metrics can be scraped.
+type ScrapeProtocol string
+
+// The supported scrape protocols.
+const (
+	PrometheusProto ScrapeProtocol = "PrometheusProto"
+	PrometheusText  ScrapeProtocol = "PrometheusText0.0.4"
+	OpenMetricsText ScrapeProtocol = "OpenMetricsText0.0.1"
+)
+
+var (
+	// ScrapeProtocolsHeaders maps the scrape protocols to the media types
+	// requested for them in the Accept header.
+	ScrapeProtocolsHeaders = map[ScrapeProtocol]string{
+		PrometheusProto: "application/vnd.google.protobuf;proto=io.prometheus.client.MetricFamily;encoding=delimited",
+		PrometheusText:  "text/plain;version=0.0.4",
+		OpenMetricsText: "application/openmetrics-text;version=0.0.1",
+	}
+
+	// DefaultScrapeProtocols are the protocols negotiated if a scrape
+	// config does not set any.
+	DefaultScrapeProtocols = []ScrapeProtocol{OpenMetricsText, PrometheusText}
+)
+
+// Validate returns an error if the protocol is not supported.
+func (p ScrapeProtocol) Validate() error {
+	if _, ok := ScrapeProtocolsHeaders[p]; !ok {
+		return fmt.Errorf("unknown scrape protocol %q, supported: %s, %s, %s", p, PrometheusProto, PrometheusText, OpenMetricsText)
+	}
+	return nil
+}
+
+// ByteSize is a number of bytes. In YAML it is written with a base-2 unit,
+// e.g. "512KB" or "10MB".
+type ByteSize units.Base2Bytes
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+func (b *ByteSize) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	v, err := units.ParseBase2Bytes(s)
+	if err != nil {
+		return fmt.Errorf("invalid byte size %q: %s", s, err)
+	}
+	if v < 0 {
+		return fmt.Errorf("invalid byte size %q: must not be negative", s)
+	}
+	*b = ByteSize(v)
+	return nil
+}
+
+// MarshalYAML implements the yaml.Marshaler interface.
+func (b ByteSize) MarshalYAML() (interface{}, error) {
+	return units.Base2Bytes(b).String(), nil
+}
+
 // AlertingConfig configures alerting and alertmanager related configs.
 type AlertingConfig struct {
 	AlertRelabelConfigs []*relabel.Config     `yaml:"alert_relabel_configs,omitempty"`
@@ -452,7 +531,7 @@ type AlertmanagerConfig struct {
 func (c *AlertmanagerConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	*c = DefaultAlertmanagerConfig
 	type plain AlertmanagerConfig
-	if err := unmarshal((*plain)(c)); err != nil {
+	if err := sd_config.UnmarshalYAMLWithInlineConfigs((*plain)(c), unmarshal); err != nil {
 		return err
 	}
 
@@ -496,6 +575,12 @@ func (c *AlertmanagerConfig) UnmarshalYAML(unmarshal func(interface{}) error) er
 	return nil
 }
 
+// MarshalYAML implements the yaml.Marshaler interface.
+func (c *AlertmanagerConfig) MarshalYAML() (interface{}, error) {
+	type plain AlertmanagerConfig
+	return sd_config.MarshalYAMLWithInlineConfigs((*plain)(c))
+}
+
 // CheckTargetAddress checks if target address is valid.
 func CheckTargetAddress(address model.LabelValue) error {
 	// For now check for a URL, we may want to expand this later.
@@ -554,7 +639,7 @@ func (c *RemoteWriteConfig) UnmarshalYAML(unmarshal func(interface{}) error) err
 // QueueConfig is the configuration for the queue used to write to remote
 // storage.
 type QueueConfig struct {
-	// Number of samples to buffer per shard before we start dropping them.
+	// Number of samples to buffer per shard before we block reading of the WAL.
 	Capacity int `yaml:"capacity,omitempty"`
 
 	// Max number of shards, i.e. amount of concurrency.
@@ -569,7 +654,8 @@ type QueueConfig struct {
 	// Maximum time sample will wait in buffer.
 	BatchSendDeadline model.Duration `yaml:"batch_send_deadline,omitempty"`
 
-	// Max number of times to retry a batch on recoverable errors.
+	// Deprecated: batches are retried on recoverable errors until they are
+	// sent, as their samples are read from the WAL.
 	MaxRetries int `yaml:"max_retries,omitempty"`
 
 	// On recoverable errors, backoff exponentially.
@@ -29,9 +29,13 @@ import (
 	"github.com/prometheus/prometheus/discovery/consul"
 	"github.com/prometheus/prometheus/discovery/dns"
 	"github.com/prometheus/prometheus/discovery/ec2"
+	"github.com/prometheus/prometheus/discovery/eureka"
 	"github.com/prometheus/prometheus/discovery/file"
+	"github.com/prometheus/prometheus/discovery/http"
 	"github.com/prometheus/prometheus/discovery/kubernetes"
 	"github.com/prometheus/prometheus/discovery/marathon"
+	"github.com/prometheus/prometheus/discovery/moby"
+	"github.com/prometheus/prometheus/discovery/nomad"
 	"github.com/prometheus/prometheus/discovery/openstack"
 	"github.com/prometheus/prometheus/discovery/targetgroup"
 	"github.com/prometheus/prometheus/discovery/triton"
@@ -57,6 +61,7 @@ var expectedConf = &Config{
 		ScrapeInterval:     model.Duration(15 * time.Second),
 		ScrapeTimeout:      DefaultGlobalConfig.ScrapeTimeout,
 		EvaluationInterval: model.Duration(30 * time.Second),
+		QueryLogFile:       filepath.FromSlash("testdata/query.log"),
 
 		ExternalLabels: model.LabelSet{
 			"monitor": "codelab",
@@ -107,7 +112,8 @@ var expectedConf = &Config{
 
 	ScrapeConfigs: []*ScrapeConfig{
 		{
-			JobName: "prometheus",
+			JobName:         "prometheus",
+			HonorTimestamps: true,
 
 			HonorLabels:    true,
 			ScrapeInterval: model.Duration(15 * time.Second),
@@ -135,12 +141,12 @@ var expectedConf = &Config{
 					},
 				},
 
-				FileSDConfigs: []*file.SDConfig{
-					{
+				Configs: []interface{}{
+					&file.SDConfig{
 						Files:           []string{"testdata/foo/*.slow.json", "testdata/foo/*.slow.yml", "testdata/single/file.yml"},
 						RefreshInterval: model.Duration(10 * time.Minute),
 					},
-					{
+					&file.SDConfig{
 						Files:           []string{"testdata/bar/*.yaml"},
 						RefreshInterval: model.Duration(5 * time.Minute),
 					},
@@ -179,11 +185,18 @@ var expectedConf = &Config{
 		},
 		{
 
-			JobName: "service-x",
+			JobName:         "service-x",
+			HonorTimestamps: false,
 
-			ScrapeInterval: model.Duration(50 * time.Second),
-			ScrapeTimeout:  model.Duration(5 * time.Second),
-			SampleLimit:    1000,
+			ScrapeInterval:        model.Duration(50 * time.Second),
+			ScrapeTimeout:         model.Duration(5 * time.Second),
+			BodySizeLimit:         10 * 1024 * 1024,
+			ScrapeProtocols:       []ScrapeProtocol{PrometheusProto, OpenMetricsText},
+			SampleLimit:           1000,
+			TargetLimit:           35,
+			LabelLimit:            35,
+			LabelNameLengthLimit:  210,
+			LabelValueLengthLimit: 210,
 
 			HTTPClientConfig: config_util.HTTPClientConfig{
 				BasicAuth: &config_util.BasicAuth{
@@ -195,8 +208,8 @@ var expectedConf = &Config{
 			Scheme:      "https",
 
 			ServiceDiscoveryConfig: sd_config.ServiceDiscoveryConfig{
-				DNSSDConfigs: []*dns.SDConfig{
-					{
+				Configs: []interface{}{
+					&dns.SDConfig{
 						Names: []string{
 							"first.dns.address.domain.com",
 							"second.dns.address.domain.com",
@@ -204,7 +217,7 @@ var expectedConf = &Config{
 						RefreshInterval: model.Duration(15 * time.Second),
 						Type:            "SRV",
 					},
-					{
+					&dns.SDConfig{
 						Names: []string{
 							"first.dns.address.domain.com",
 						},
@@ -268,7 +281,8 @@ var expectedConf = &Config{
 			},
 		},
 		{
-			JobName: "service-y",
+			JobName:         "service-y",
+			HonorTimestamps: true,
 
 			ScrapeInterval: model.Duration(15 * time.Second),
 			ScrapeTimeout:  DefaultGlobalConfig.ScrapeTimeout,
@@ -277,13 +291,17 @@ var expectedConf = &Config{
 			Scheme:      DefaultScrapeConfig.Scheme,
 
 			ServiceDiscoveryConfig: sd_config.ServiceDiscoveryConfig{
-				ConsulSDConfigs: []*consul.SDConfig{
-					{
+				Configs: []interface{}{
+					&consul.SDConfig{
 						Server:          "localhost:1234",
 						Token:           "mysecret",
 						Services:        []string{"nginx", "cache", "mysql"},
-						ServiceTag:      "canary",
+						ServiceTags:     []string{"canary", "v1"},
 						NodeMeta:        map[string]string{"rack": "123"},
+						Namespace:       "team-a",
+						Partition:       "eu",
+						Filter:          `Service.Meta.version == "1.0"`,
+						PassingOnly:     true,
 						TagSeparator:    consul.DefaultSDConfig.TagSeparator,
 						Scheme:          "https",
 						RefreshInterval: consul.DefaultSDConfig.RefreshInterval,
@@ -310,7 +328,8 @@ var expectedConf = &Config{
 			},
 		},
 		{
-			JobName: "service-z",
+			JobName:         "service-z",
+			HonorTimestamps: true,
 
 			ScrapeInterval: model.Duration(15 * time.Second),
 			ScrapeTimeout:  model.Duration(10 * time.Second),
@@ -328,7 +347,8 @@ var expectedConf = &Config{
 			},
 		},
 		{
-			JobName: "service-kubernetes",
+			JobName:         "service-kubernetes",
+			HonorTimestamps: true,
 
 			ScrapeInterval: model.Duration(15 * time.Second),
 			ScrapeTimeout:  DefaultGlobalConfig.ScrapeTimeout,
@@ -337,8 +357,8 @@ var expectedConf = &Config{
 			Scheme:      DefaultScrapeConfig.Scheme,
 
 			ServiceDiscoveryConfig: sd_config.ServiceDiscoveryConfig{
-				KubernetesSDConfigs: []*kubernetes.SDConfig{
-					{
+				Configs: []interface{}{
+					&kubernetes.SDConfig{
 						APIServer: kubernetesSDHostURL(),
 						Role:      kubernetes.RoleEndpoint,
 						BasicAuth: &config_util.BasicAuth{
@@ -351,7 +371,8 @@ var expectedConf = &Config{
 			},
 		},
 		{
-			JobName: "service-kubernetes-namespaces",
+			JobName:         "service-kubernetes-namespaces",
+			HonorTimestamps: true,
 
 			ScrapeInterval: model.Duration(15 * time.Second),
 			ScrapeTimeout:  DefaultGlobalConfig.ScrapeTimeout,
@@ -360,8 +381,8 @@ var expectedConf = &Config{
 			Scheme:      DefaultScrapeConfig.Scheme,
 
 			ServiceDiscoveryConfig: sd_config.ServiceDiscoveryConfig{
-				KubernetesSDConfigs: []*kubernetes.SDConfig{
-					{
+				Configs: []interface{}{
+					&kubernetes.SDConfig{
 						APIServer: kubernetesSDHostURL(),
 						Role:      kubernetes.RoleEndpoint,
 						NamespaceDiscovery: kubernetes.NamespaceDiscovery{
@@ -374,7 +395,8 @@ var expectedConf = &Config{
 			},
 		},
 		{
-			JobName: "service-marathon",
+			JobName:         "service-kubernetes-endpointslice",
+			HonorTimestamps: true,
 
 			ScrapeInterval: model.Duration(15 * time.Second),
 			ScrapeTimeout:  DefaultGlobalConfig.ScrapeTimeout,
@@ -383,8 +405,40 @@ var expectedConf = &Config{
 			Scheme:      DefaultScrapeConfig.Scheme,
 
 			ServiceDiscoveryConfig: sd_config.ServiceDiscoveryConfig{
-				MarathonSDConfigs: []*marathon.SDConfig{
-					{
+				Configs: []interface{}{
+					&kubernetes.SDConfig{
+						APIServer: kubernetesSDHostURL(),
+						Role:      kubernetes.RoleEndpointSlice,
+						NamespaceDiscovery: kubernetes.NamespaceDiscovery{
+							IncludeOwnNamespace: true,
+						},
+						Selectors: []kubernetes.SelectorConfig{
+							{
+								Role:  kubernetes.RoleEndpointSlice,
+								Label: "app=test",
+							},
+							{
+								Role:  kubernetes.RolePod,
+								Field: "spec.nodeName=node1",
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			JobName:         "service-marathon",
+			HonorTimestamps: true,
+
+			ScrapeInterval: model.Duration(15 * time.Second),
+			ScrapeTimeout:  DefaultGlobalConfig.ScrapeTimeout,
+
+			MetricsPath: DefaultScrapeConfig.MetricsPath,
+			Scheme:      DefaultScrapeConfig.Scheme,
+
+			ServiceDiscoveryConfig: sd_config.ServiceDiscoveryConfig{
+				Configs: []interface{}{
+					&marathon.SDConfig{
 						Servers: []string{
 							"https://marathon.example.com:443",
 						},
@@ -401,7 +455,8 @@ var expectedConf = &Config{
 			},
 		},
 		{
-			JobName: "service-ec2",
+			JobName:         "service-http",
+			HonorTimestamps: true,
 
 			ScrapeInterval: model.Duration(15 * time.Second),
 			ScrapeTimeout:  DefaultGlobalConfig.ScrapeTimeout,
@@ -410,8 +465,124 @@ var expectedConf = &Config{
 			Scheme:      DefaultScrapeConfig.Scheme,
 
 			ServiceDiscoveryConfig: sd_config.ServiceDiscoveryConfig{
-				EC2SDConfigs: []*ec2.SDConfig{
-					{
+				Configs: []interface{}{
+					&http.SDConfig{
+						URL:             "https://sd.example.com/targets",
+						RefreshInterval: model.Duration(30 * time.Second),
+						HTTPClientConfig: config_util.HTTPClientConfig{
+							BearerTokenFile: filepath.FromSlash("testdata/valid_token_file"),
+						},
+					},
+				},
+			},
+		},
+		{
+			JobName:         "service-docker",
+			HonorTimestamps: true,
+
+			ScrapeInterval: model.Duration(15 * time.Second),
+			ScrapeTimeout:  DefaultGlobalConfig.ScrapeTimeout,
+
+			MetricsPath: DefaultScrapeConfig.MetricsPath,
+			Scheme:      DefaultScrapeConfig.Scheme,
+
+			ServiceDiscoveryConfig: sd_config.ServiceDiscoveryConfig{
+				Configs: []interface{}{
+					&moby.DockerSDConfig{
+						Host:               "unix:///var/run/docker.sock",
+						Port:               80,
+						HostNetworkingHost: "localhost",
+						RefreshInterval:    model.Duration(60 * time.Second),
+					},
+				},
+			},
+		},
+		{
+			JobName:         "service-dockerswarm",
+			HonorTimestamps: true,
+
+			ScrapeInterval: model.Duration(15 * time.Second),
+			ScrapeTimeout:  DefaultGlobalConfig.ScrapeTimeout,
+
+			MetricsPath: DefaultScrapeConfig.MetricsPath,
+			Scheme:      DefaultScrapeConfig.Scheme,
+
+			ServiceDiscoveryConfig: sd_config.ServiceDiscoveryConfig{
+				Configs: []interface{}{
+					&moby.DockerSwarmSDConfig{
+						Host:            "https://swarm.example.com:2376",
+						Role:            moby.RoleTasks,
+						Port:            80,
+						RefreshInterval: model.Duration(60 * time.Second),
+						HTTPClientConfig: config_util.HTTPClientConfig{
+							TLSConfig: config_util.TLSConfig{
+								CAFile: filepath.FromSlash("testdata/valid_ca_file"),
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			JobName:         "service-eureka",
+			HonorTimestamps: true,
+
+			ScrapeInterval: model.Duration(15 * time.Second),
+			ScrapeTimeout:  DefaultGlobalConfig.ScrapeTimeout,
+
+			MetricsPath: DefaultScrapeConfig.MetricsPath,
+			Scheme:      DefaultScrapeConfig.Scheme,
+
+			ServiceDiscoveryConfig: sd_config.ServiceDiscoveryConfig{
+				Configs: []interface{}{
+					&eureka.SDConfig{
+						Server:          "http://eureka.example.com:8761/eureka",
+						RefreshInterval: model.Duration(30 * time.Second),
+					},
+				},
+			},
+		},
+		{
+			JobName:         "service-nomad",
+			HonorTimestamps: true,
+
+			ScrapeInterval: model.Duration(15 * time.Second),
+			ScrapeTimeout:  DefaultGlobalConfig.ScrapeTimeout,
+
+			MetricsPath: DefaultScrapeConfig.MetricsPath,
+			Scheme:      DefaultScrapeConfig.Scheme,
+
+			ServiceDiscoveryConfig: sd_config.ServiceDiscoveryConfig{
+				Configs: []interface{}{
+					&nomad.SDConfig{
+						Server:          "http://nomad.example.com:4646",
+						Namespace:       "batch",
+						Region:          "global",
+						AllowStale:      true,
+						TagSeparator:    ",",
+						RefreshInterval: model.Duration(60 * time.Second),
+						HTTPClientConfig: config_util.HTTPClientConfig{
+							TLSConfig: config_util.TLSConfig{
+								CAFile: filepath.FromSlash("testdata/valid_ca_file"),
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			JobName:         "service-ec2",
+			HonorTimestamps: true,
+
+			ScrapeInterval: model.Duration(15 * time.Second),
+			ScrapeTimeout:  DefaultGlobalConfig.ScrapeTimeout,
+
+			MetricsPath: DefaultScrapeConfig.MetricsPath,
+			Scheme:      DefaultScrapeConfig.Scheme,
+
+			ServiceDiscoveryConfig: sd_config.ServiceDiscoveryConfig{
+				Configs: []interface{}{
+					&ec2.SDConfig{
 						Region:          "us-east-1",
 						AccessKey:       "access",
 						SecretKey:       "mysecret",
@@ -433,7 +604,8 @@ var expectedConf = &Config{
 			},
 		},
 		{
-			JobName: "service-azure",
+			JobName:         "service-azure",
+			HonorTimestamps: true,
 
 			ScrapeInterval: model.Duration(15 * time.Second),
 			ScrapeTimeout:  DefaultGlobalConfig.ScrapeTimeout,
@@ -442,8 +614,8 @@ var expectedConf = &Config{
 			Scheme:      DefaultScrapeConfig.Scheme,
 
 			ServiceDiscoveryConfig: sd_config.ServiceDiscoveryConfig{
-				AzureSDConfigs: []*azure.SDConfig{
-					{
+				Configs: []interface{}{
+					&azure.SDConfig{
 						Environment:          "AzurePublicCloud",
 						SubscriptionID:       "11AAAA11-A11A-111A-A111-1111A1111A11",
 						TenantID:             "BBBB222B-B2B2-2B22-B222-2BB2222BB2B2",
@@ -457,7 +629,8 @@ var expectedConf = &Config{
 			},
 		},
 		{
-			JobName: "service-nerve",
+			JobName:         "service-nerve",
+			HonorTimestamps: true,
 
 			ScrapeInterval: model.Duration(15 * time.Second),
 			ScrapeTimeout:  DefaultGlobalConfig.ScrapeTimeout,
@@ -466,8 +639,8 @@ var expectedConf = &Config{
 			Scheme:      DefaultScrapeConfig.Scheme,
 
 			ServiceDiscoveryConfig: sd_config.ServiceDiscoveryConfig{
-				NerveSDConfigs: []*zookeeper.NerveSDConfig{
-					{
+				Configs: []interface{}{
+					&zookeeper.NerveSDConfig{
 						Servers: []string{"localhost"},
 						Paths:   []string{"/monitoring"},
 						Timeout: model.Duration(10 * time.Second),
@@ -476,7 +649,8 @@ var expectedConf = &Config{
 			},
 		},
 		{
-			JobName: "0123service-xxx",
+			JobName:         "0123service-xxx",
+			HonorTimestamps: true,
 
 			ScrapeInterval: model.Duration(15 * time.Second),
 			ScrapeTimeout:  DefaultGlobalConfig.ScrapeTimeout,
@@ -496,7 +670,8 @@ var expectedConf = &Config{
 			},
 		},
 		{
-			JobName: "測試",
+			JobName:         "測試",
+			HonorTimestamps: true,
 
 			ScrapeInterval: model.Duration(15 * time.Second),
 			ScrapeTimeout:  DefaultGlobalConfig.ScrapeTimeout,
@@ -516,7 +691,8 @@ var expectedConf = &Config{
 			},
 		},
 		{
-			JobName: "service-triton",
+			JobName:         "service-triton",
+			HonorTimestamps: true,
 
 			ScrapeInterval: model.Duration(15 * time.Second),
 			ScrapeTimeout:  DefaultGlobalConfig.ScrapeTimeout,
@@ -525,8 +701,8 @@ var expectedConf = &Config{
 			Scheme:      DefaultScrapeConfig.Scheme,
 
 			ServiceDiscoveryConfig: sd_config.ServiceDiscoveryConfig{
-				TritonSDConfigs: []*triton.SDConfig{
-					{
+				Configs: []interface{}{
+					&triton.SDConfig{
 
 						Account:         "testAccount",
 						DNSSuffix:       "triton.example.com",
@@ -543,7 +719,8 @@ var expectedConf = &Config{
 			},
 		},
 		{
-			JobName: "service-openstack",
+			JobName:         "service-openstack",
+			HonorTimestamps: true,
 
 			ScrapeInterval: model.Duration(15 * time.Second),
 			ScrapeTimeout:  DefaultGlobalConfig.ScrapeTimeout,
@@ -552,8 +729,8 @@ var expectedConf = &Config{
 			Scheme:      DefaultScrapeConfig.Scheme,
 
 			ServiceDiscoveryConfig: sd_config.ServiceDiscoveryConfig{
-				OpenstackSDConfigs: []*openstack.SDConfig{
-					{
+				Configs: []interface{}{
+					&openstack.SDConfig{
 						Role:            "instance",
 						Region:          "RegionOne",
 						Port:            80,
@@ -712,6 +889,48 @@ var expectedErrors = []struct {
 	}, {
 		filename: "kubernetes_bearertoken_basicauth.bad.yml",
 		errMsg:   "at most one of basic_auth, bearer_token & bearer_token_file must be configured",
+	}, {
+		filename: "kubernetes_selectors_duplicated_role.bad.yml",
+		errMsg:   "duplicated selector role: pod",
+	}, {
+		filename: "kubernetes_selectors_incorrect_role.bad.yml",
+		errMsg:   "pod role supports only pod selectors",
+	}, {
+		filename: "kubernetes_selectors_endpoints.bad.yml",
+		errMsg:   "endpoints role supports only pod, service, endpoints selectors",
+	}, {
+		filename: "kubernetes_selectors_bad_label.bad.yml",
+		errMsg:   "unable to parse requirement",
+	}, {
+		filename: "docker_no_host.bad.yml",
+		errMsg:   "docker_sd: host missing",
+	}, {
+		filename: "dockerswarm_bad_role.bad.yml",
+		errMsg:   `unknown Docker Swarm SD role "containers"`,
+	}, {
+		filename: "dockerswarm_no_role.bad.yml",
+		errMsg:   "dockerswarm_sd: role missing (one of: services, tasks, nodes)",
+	}, {
+		filename: "dns_bad_nameserver.bad.yml",
+		errMsg:   `invalid DNS-SD nameserver "10.0.0.1:dns": invalid port`,
+	}, {
+		filename: "dns_resolve_srv_targets.bad.yml",
+		errMsg:   "resolve_srv_targets is only supported for SRV records",
+	}, {
+		filename: "eureka_no_server.bad.yml",
+		errMsg:   "eureka_sd: empty or null eureka server",
+	}, {
+		filename: "eureka_invalid_server.bad.yml",
+		errMsg:   "eureka_sd: invalid eureka server URL",
+	}, {
+		filename: "nomad_invalid_server.bad.yml",
+		errMsg:   "nomad_sd: invalid server URL",
+	}, {
+		filename: "http_url_bad_scheme.bad.yml",
+		errMsg:   "http_sd: URL scheme must be 'http' or 'https'",
+	}, {
+		filename: "http_url_no_host.bad.yml",
+		errMsg:   "http_sd: host is missing in URL",
 	}, {
 		filename: "marathon_no_servers.bad.yml",
 		errMsg:   "marathon_sd: must contain at least one Marathon server",
@@ -810,6 +1029,18 @@ var expectedErrors = []struct {
 		filename: "empty_static_config.bad.yml",
 		errMsg:   "empty or null section in static_configs",
 	},
+	{
+		filename: "body_size_limit.bad.yml",
+		errMsg:   `invalid byte size "10 apples"`,
+	},
+	{
+		filename: "scrape_protocols_unknown.bad.yml",
+		errMsg:   `unknown scrape protocol "prometheusproto"`,
+	},
+	{
+		filename: "scrape_protocols_duplicated.bad.yml",
+		errMsg:   `duplicated protocol in scrape_protocols`,
+	},
 }
 
 func TestBadConfigs(t *testing.T) {
@@ -852,6 +1083,21 @@ func TestEmptyGlobalBlock(t *testing.T) {
 	testutil.Equals(t, exp, *c)
 }
 
+func TestGlobalBodySizeLimit(t *testing.T) {
+	c, err := Load(`
+global:
+  body_size_limit: 1MB
+scrape_configs:
+- job_name: default
+- job_name: override
+  body_size_limit: 512KB
+`)
+	testutil.Ok(t, err)
+	testutil.Equals(t, ByteSize(1024*1024), c.GlobalConfig.BodySizeLimit)
+	testutil.Equals(t, ByteSize(1024*1024), c.ScrapeConfigs[0].BodySizeLimit)
+	testutil.Equals(t, ByteSize(512*1024), c.ScrapeConfigs[1].BodySizeLimit)
+}
+
 func kubernetesSDHostURL() config_util.URL {
 	tURL, _ := url.Parse("https://localhost:1234")
 	return config_util.URL{URL: tURL}
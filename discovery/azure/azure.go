@@ -32,6 +32,8 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	config_util "github.com/prometheus/common/config"
 	"github.com/prometheus/common/model"
+	sd_config "github.com/prometheus/prometheus/discovery/config"
+	"github.com/prometheus/prometheus/discovery/refresh"
 	"github.com/prometheus/prometheus/discovery/targetgroup"
 	"github.com/prometheus/prometheus/util/strutil"
 )
@@ -126,6 +128,9 @@ func (c *SDConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
 }
 
 func init() {
+	sd_config.RegisterSD("azure_sd_configs", &SDConfig{}, func(cfg interface{}, logger log.Logger) (sd_config.Discoverer, error) {
+		return NewDiscovery(cfg.(*SDConfig), logger), nil
+	})
 	prometheus.MustRegister(azureSDRefreshDuration)
 	prometheus.MustRegister(azureSDRefreshFailuresCount)
 }
@@ -137,6 +142,8 @@ type Discovery struct {
 	interval time.Duration
 	port     int
 	logger   log.Logger
+
+	refresh.ErrorReporter
 }
 
 // NewDiscovery returns a new AzureDiscovery which periodically refreshes its targets.
@@ -165,6 +172,7 @@ func (d *Discovery) Run(ctx context.Context, ch chan<- []*targetgroup.Group) {
 		}
 
 		tg, err := d.refresh()
+		d.ReportRefresh("", err)
 		if err != nil {
 			level.Error(d.logger).Log("msg", "Unable to refresh during Azure discovery", "err", err)
 		} else {
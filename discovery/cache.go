@@ -0,0 +1,128 @@
+// Copyright 2019 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package discovery
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+
+	"github.com/prometheus/common/model"
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/prometheus/prometheus/discovery/targetgroup"
+)
+
+// staleLabel is set on target groups restored from the cache until their
+// provider sent its first update.
+const staleLabel = model.MetaLabelPrefix + "discovery_stale"
+
+// cachedGroup is the on-disk representation of a target group. Unlike the
+// JSON encoding of targetgroup.Group it includes the source.
+type cachedGroup struct {
+	Source  string           `json:"source"`
+	Targets []model.LabelSet `json:"targets"`
+	Labels  model.LabelSet   `json:"labels,omitempty"`
+}
+
+// providerCacheKey identifies a provider across restarts by its type and
+// configuration. Provider names depend on the order of the configuration
+// and can't be used for this.
+func providerCacheKey(typ string, cfg interface{}) (string, error) {
+	b, err := yaml.Marshal(cfg)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	h.Write([]byte(typ))
+	h.Write([]byte{0})
+	h.Write(b)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// loadCache reads the target groups of all providers from the file. A
+// missing file results in an empty cache.
+func loadCache(filename string) (map[string][]*targetgroup.Group, error) {
+	cache := map[string][]*targetgroup.Group{}
+
+	b, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return cache, nil
+	}
+	if err != nil {
+		return cache, err
+	}
+
+	var stored map[string][]cachedGroup
+	if err := json.Unmarshal(b, &stored); err != nil {
+		return cache, err
+	}
+	for key, cgs := range stored {
+		tgs := make([]*targetgroup.Group, 0, len(cgs))
+		for _, cg := range cgs {
+			tgs = append(tgs, &targetgroup.Group{
+				Source:  cg.Source,
+				Targets: cg.Targets,
+				Labels:  cg.Labels,
+			})
+		}
+		cache[key] = tgs
+	}
+	return cache, nil
+}
+
+// writeCache atomically replaces the file with the given target groups.
+func writeCache(filename string, cache map[string][]*targetgroup.Group) error {
+	stored := make(map[string][]cachedGroup, len(cache))
+	for key, tgs := range cache {
+		cgs := make([]cachedGroup, 0, len(tgs))
+		for _, tg := range tgs {
+			cgs = append(cgs, cachedGroup{
+				Source:  tg.Source,
+				Targets: tg.Targets,
+				Labels:  tg.Labels,
+			})
+		}
+		stored[key] = cgs
+	}
+
+	b, err := json.Marshal(stored)
+	if err != nil {
+		return err
+	}
+	tmp := filename + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0666); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filename)
+}
+
+// staleGroups returns copies of the target groups carrying the stale label.
+func staleGroups(tgs []*targetgroup.Group) map[string]*targetgroup.Group {
+	res := make(map[string]*targetgroup.Group, len(tgs))
+	for _, tg := range tgs {
+		lset := model.LabelSet{staleLabel: "true"}
+		for ln, lv := range tg.Labels {
+			lset[ln] = lv
+		}
+		res[tg.Source] = &targetgroup.Group{
+			Source:  tg.Source,
+			Targets: tg.Targets,
+			Labels:  lset,
+		}
+	}
+	return res
+}
@@ -15,106 +15,28 @@ package config
 
 import (
 	"fmt"
+	"path/filepath"
+
+	config_util "github.com/prometheus/common/config"
 
-	"github.com/prometheus/prometheus/discovery/azure"
-	"github.com/prometheus/prometheus/discovery/consul"
-	"github.com/prometheus/prometheus/discovery/dns"
-	"github.com/prometheus/prometheus/discovery/ec2"
-	"github.com/prometheus/prometheus/discovery/file"
-	"github.com/prometheus/prometheus/discovery/gce"
-	"github.com/prometheus/prometheus/discovery/kubernetes"
-	"github.com/prometheus/prometheus/discovery/marathon"
-	"github.com/prometheus/prometheus/discovery/openstack"
 	"github.com/prometheus/prometheus/discovery/targetgroup"
-	"github.com/prometheus/prometheus/discovery/triton"
-	"github.com/prometheus/prometheus/discovery/zookeeper"
 )
 
 // ServiceDiscoveryConfig configures lists of different service discovery mechanisms.
 type ServiceDiscoveryConfig struct {
 	// List of labeled target groups for this job.
 	StaticConfigs []*targetgroup.Group `yaml:"static_configs,omitempty"`
-	// List of DNS service discovery configurations.
-	DNSSDConfigs []*dns.SDConfig `yaml:"dns_sd_configs,omitempty"`
-	// List of file service discovery configurations.
-	FileSDConfigs []*file.SDConfig `yaml:"file_sd_configs,omitempty"`
-	// List of Consul service discovery configurations.
-	ConsulSDConfigs []*consul.SDConfig `yaml:"consul_sd_configs,omitempty"`
-	// List of Serverset service discovery configurations.
-	ServersetSDConfigs []*zookeeper.ServersetSDConfig `yaml:"serverset_sd_configs,omitempty"`
-	// NerveSDConfigs is a list of Nerve service discovery configurations.
-	NerveSDConfigs []*zookeeper.NerveSDConfig `yaml:"nerve_sd_configs,omitempty"`
-	// MarathonSDConfigs is a list of Marathon service discovery configurations.
-	MarathonSDConfigs []*marathon.SDConfig `yaml:"marathon_sd_configs,omitempty"`
-	// List of Kubernetes service discovery configurations.
-	KubernetesSDConfigs []*kubernetes.SDConfig `yaml:"kubernetes_sd_configs,omitempty"`
-	// List of GCE service discovery configurations.
-	GCESDConfigs []*gce.SDConfig `yaml:"gce_sd_configs,omitempty"`
-	// List of EC2 service discovery configurations.
-	EC2SDConfigs []*ec2.SDConfig `yaml:"ec2_sd_configs,omitempty"`
-	// List of OpenStack service discovery configurations.
-	OpenstackSDConfigs []*openstack.SDConfig `yaml:"openstack_sd_configs,omitempty"`
-	// List of Azure service discovery configurations.
-	AzureSDConfigs []*azure.SDConfig `yaml:"azure_sd_configs,omitempty"`
-	// List of Triton service discovery configurations.
-	TritonSDConfigs []*triton.SDConfig `yaml:"triton_sd_configs,omitempty"`
+	// Configurations of the registered service discovery mechanisms. They
+	// are unmarshalled from and marshalled to the lists under the keys the
+	// mechanisms were registered with.
+	Configs []interface{} `yaml:"-"`
 }
 
 // Validate validates the ServiceDiscoveryConfig.
 func (c *ServiceDiscoveryConfig) Validate() error {
-	for _, cfg := range c.AzureSDConfigs {
-		if cfg == nil {
-			return fmt.Errorf("empty or null section in azure_sd_configs")
-		}
-	}
-	for _, cfg := range c.ConsulSDConfigs {
-		if cfg == nil {
-			return fmt.Errorf("empty or null section in consul_sd_configs")
-		}
-	}
-	for _, cfg := range c.DNSSDConfigs {
-		if cfg == nil {
-			return fmt.Errorf("empty or null section in dns_sd_configs")
-		}
-	}
-	for _, cfg := range c.EC2SDConfigs {
-		if cfg == nil {
-			return fmt.Errorf("empty or null section in ec2_sd_configs")
-		}
-	}
-	for _, cfg := range c.FileSDConfigs {
-		if cfg == nil {
-			return fmt.Errorf("empty or null section in file_sd_configs")
-		}
-	}
-	for _, cfg := range c.GCESDConfigs {
-		if cfg == nil {
-			return fmt.Errorf("empty or null section in gce_sd_configs")
-		}
-	}
-	for _, cfg := range c.KubernetesSDConfigs {
-		if cfg == nil {
-			return fmt.Errorf("empty or null section in kubernetes_sd_configs")
-		}
-	}
-	for _, cfg := range c.MarathonSDConfigs {
+	for _, cfg := range c.Configs {
 		if cfg == nil {
-			return fmt.Errorf("empty or null section in marathon_sd_configs")
-		}
-	}
-	for _, cfg := range c.NerveSDConfigs {
-		if cfg == nil {
-			return fmt.Errorf("empty or null section in nerve_sd_configs")
-		}
-	}
-	for _, cfg := range c.OpenstackSDConfigs {
-		if cfg == nil {
-			return fmt.Errorf("empty or null section in openstack_sd_configs")
-		}
-	}
-	for _, cfg := range c.ServersetSDConfigs {
-		if cfg == nil {
-			return fmt.Errorf("empty or null section in serverset_sd_configs")
+			return fmt.Errorf("empty or null service discovery config")
 		}
 	}
 	for _, cfg := range c.StaticConfigs {
@@ -124,3 +46,42 @@ func (c *ServiceDiscoveryConfig) Validate() error {
 	}
 	return nil
 }
+
+// DirectorySetter is implemented by service discovery configurations holding
+// file paths, which are relative to the directory of the configuration file.
+type DirectorySetter interface {
+	// SetDirectory joins the relative paths of the configuration with dir.
+	SetDirectory(dir string)
+}
+
+// SetDirectory joins the relative paths of all configurations with dir.
+func (c *ServiceDiscoveryConfig) SetDirectory(dir string) {
+	for _, cfg := range c.Configs {
+		if v, ok := cfg.(DirectorySetter); ok {
+			v.SetDirectory(dir)
+		}
+	}
+}
+
+// JoinDir joins dir and path if path is relative.
+func JoinDir(dir, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+// SetTLSConfigDirectory joins the relative paths of the TLS configuration
+// with dir.
+func SetTLSConfigDirectory(cfg *config_util.TLSConfig, dir string) {
+	cfg.CAFile = JoinDir(dir, cfg.CAFile)
+	cfg.CertFile = JoinDir(dir, cfg.CertFile)
+	cfg.KeyFile = JoinDir(dir, cfg.KeyFile)
+}
+
+// SetHTTPClientConfigDirectory joins the relative paths of the HTTP client
+// configuration with dir.
+func SetHTTPClientConfigDirectory(cfg *config_util.HTTPClientConfig, dir string) {
+	cfg.BearerTokenFile = JoinDir(dir, cfg.BearerTokenFile)
+	SetTLSConfigDirectory(&cfg.TLSConfig, dir)
+}
@@ -0,0 +1,216 @@
+// Copyright 2019 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/go-kit/kit/log"
+	"gopkg.in/yaml.v2"
+
+	"github.com/prometheus/prometheus/discovery/targetgroup"
+)
+
+// Discoverer provides information about target groups. It is the same as
+// discovery.Discoverer, which can't be used here as the discovery package
+// imports this one.
+type Discoverer interface {
+	Run(ctx context.Context, up chan<- []*targetgroup.Group)
+}
+
+// NewDiscovererFunc creates a Discoverer for a configuration of the type it
+// was registered with.
+type NewDiscovererFunc func(cfg interface{}, logger log.Logger) (Discoverer, error)
+
+// registration holds a registered service discovery mechanism.
+type registration struct {
+	key           string
+	name          string
+	typ           reflect.Type
+	newDiscoverer NewDiscovererFunc
+}
+
+var (
+	registrations     = map[string]*registration{}
+	registrationTypes = map[reflect.Type]*registration{}
+)
+
+const sdConfigsSuffix = "_sd_configs"
+
+// RegisterSD registers a service discovery mechanism. The key is the YAML key
+// of the list of its configurations in scrape and Alertmanager configs and
+// must end in "_sd_configs". The configuration type is taken from cfg, which
+// must be a pointer to a struct, and newDiscoverer is called with values of
+// that type.
+//
+// RegisterSD is meant to be called from the init function of the package
+// implementing the mechanism. It panics if the key or the configuration type
+// is registered already.
+func RegisterSD(key string, cfg interface{}, newDiscoverer NewDiscovererFunc) {
+	if !strings.HasSuffix(key, sdConfigsSuffix) || key == sdConfigsSuffix {
+		panic(fmt.Sprintf("discovery: invalid key %q, it must end in %q", key, sdConfigsSuffix))
+	}
+	t := reflect.TypeOf(cfg)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		panic(fmt.Sprintf("discovery: configuration for %q must be a pointer to a struct, got %T", key, cfg))
+	}
+	if newDiscoverer == nil {
+		panic(fmt.Sprintf("discovery: no constructor for %q", key))
+	}
+	if _, ok := registrations[key]; ok {
+		panic(fmt.Sprintf("discovery: key %q registered twice", key))
+	}
+	if r, ok := registrationTypes[t]; ok {
+		panic(fmt.Sprintf("discovery: configuration type %s registered already for %q", t, r.key))
+	}
+
+	r := &registration{
+		key:           key,
+		name:          strings.TrimSuffix(key, sdConfigsSuffix),
+		typ:           t,
+		newDiscoverer: newDiscoverer,
+	}
+	registrations[key] = r
+	registrationTypes[t] = r
+}
+
+// NewDiscoverer creates the Discoverer for a configuration of a registered
+// service discovery mechanism.
+func NewDiscoverer(cfg interface{}, logger log.Logger) (Discoverer, error) {
+	r, ok := registrationTypes[reflect.TypeOf(cfg)]
+	if !ok {
+		return nil, fmt.Errorf("unregistered service discovery configuration type %T", cfg)
+	}
+	return r.newDiscoverer(cfg, log.With(logger, "discovery", r.name))
+}
+
+// sortedRegistrations returns the registered mechanisms ordered by key.
+func sortedRegistrations() []*registration {
+	res := make([]*registration, 0, len(registrations))
+	for _, r := range registrations {
+		res = append(res, r)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].key < res[j].key })
+	return res
+}
+
+// inlineConfigsType returns a struct type inlining t and holding a list field
+// for the configurations of each of the given mechanisms.
+func inlineConfigsType(t reflect.Type, regs []*registration) reflect.Type {
+	fields := make([]reflect.StructField, 0, len(regs)+1)
+	fields = append(fields, reflect.StructField{
+		Name: "Config",
+		Type: t,
+		Tag:  `yaml:",inline"`,
+	})
+	for i, r := range regs {
+		fields = append(fields, reflect.StructField{
+			Name: fmt.Sprintf("SDConfigs%d", i),
+			Type: reflect.SliceOf(r.typ),
+			Tag:  reflect.StructTag(fmt.Sprintf(`yaml:"%s,omitempty"`, r.key)),
+		})
+	}
+	return reflect.StructOf(fields)
+}
+
+// serviceDiscoveryConfig returns the ServiceDiscoveryConfig field of the
+// struct v points to.
+func serviceDiscoveryConfig(v reflect.Value) (*ServiceDiscoveryConfig, error) {
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("expected a pointer to a struct, got %s", v.Type())
+	}
+	v = v.Elem()
+	sdType := reflect.TypeOf(ServiceDiscoveryConfig{})
+	for i := 0; i < v.NumField(); i++ {
+		if v.Field(i).Type() == sdType {
+			return v.Field(i).Addr().Interface().(*ServiceDiscoveryConfig), nil
+		}
+	}
+	return nil, fmt.Errorf("%s has no ServiceDiscoveryConfig field", v.Type())
+}
+
+// UnmarshalYAMLWithInlineConfigs unmarshals into out, a pointer to a struct
+// with an inlined ServiceDiscoveryConfig field, accepting the lists of
+// configurations of all registered mechanisms next to the fields of the
+// struct. It is meant to be called by the UnmarshalYAML methods of such
+// structs with a pointer to their plain type.
+func UnmarshalYAMLWithInlineConfigs(out interface{}, unmarshal func(interface{}) error) error {
+	outVal := reflect.ValueOf(out)
+	sdc, err := serviceDiscoveryConfig(outVal)
+	if err != nil {
+		return err
+	}
+
+	regs := sortedRegistrations()
+	v := reflect.New(inlineConfigsType(outVal.Elem().Type(), regs)).Elem()
+	// Keep the defaults already set in out.
+	v.Field(0).Set(outVal.Elem())
+	if err := unmarshal(v.Addr().Interface()); err != nil {
+		// Report errors against the type of out instead of the generated one.
+		if e, ok := err.(*yaml.TypeError); ok {
+			for i, msg := range e.Errors {
+				e.Errors[i] = strings.Replace(msg, v.Type().String(), outVal.Elem().Type().String(), -1)
+			}
+		}
+		return err
+	}
+	outVal.Elem().Set(v.Field(0))
+
+	sdc.Configs = nil
+	for i, r := range regs {
+		cfgs := v.Field(i + 1)
+		for j := 0; j < cfgs.Len(); j++ {
+			cfg := cfgs.Index(j)
+			if cfg.IsNil() {
+				return fmt.Errorf("empty or null section in %s", r.key)
+			}
+			sdc.Configs = append(sdc.Configs, cfg.Interface())
+		}
+	}
+	return nil
+}
+
+// MarshalYAMLWithInlineConfigs returns a value for in, a pointer to a struct
+// with an inlined ServiceDiscoveryConfig field, which marshals the
+// configurations of registered mechanisms as their lists next to the fields
+// of the struct. It is meant to be called by the MarshalYAML methods of such
+// structs with a pointer to their plain type.
+func MarshalYAMLWithInlineConfigs(in interface{}) (interface{}, error) {
+	inVal := reflect.ValueOf(in)
+	sdc, err := serviceDiscoveryConfig(inVal)
+	if err != nil {
+		return nil, err
+	}
+
+	regs := sortedRegistrations()
+	fields := make(map[string]int, len(regs))
+	for i, r := range regs {
+		fields[r.key] = i + 1
+	}
+	v := reflect.New(inlineConfigsType(inVal.Elem().Type(), regs)).Elem()
+	v.Field(0).Set(inVal.Elem())
+	for _, cfg := range sdc.Configs {
+		r, ok := registrationTypes[reflect.TypeOf(cfg)]
+		if !ok {
+			return nil, fmt.Errorf("unregistered service discovery configuration type %T", cfg)
+		}
+		f := v.Field(fields[r.key])
+		f.Set(reflect.Append(f, reflect.ValueOf(cfg)))
+	}
+	return v.Interface(), nil
+}
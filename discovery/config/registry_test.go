@@ -0,0 +1,108 @@
+// Copyright 2019 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"gopkg.in/yaml.v2"
+
+	"github.com/prometheus/prometheus/discovery/targetgroup"
+	"github.com/prometheus/prometheus/util/testutil"
+)
+
+type customSDConfig struct {
+	Server string `yaml:"server"`
+}
+
+type customDiscovery struct {
+	cfg *customSDConfig
+}
+
+func (d *customDiscovery) Run(ctx context.Context, ch chan<- []*targetgroup.Group) {}
+
+func init() {
+	RegisterSD("custom_sd_configs", &customSDConfig{}, func(cfg interface{}, logger log.Logger) (Discoverer, error) {
+		return &customDiscovery{cfg: cfg.(*customSDConfig)}, nil
+	})
+}
+
+// jobConfig inlines a ServiceDiscoveryConfig like the scrape config does.
+type jobConfig struct {
+	Name                   string                 `yaml:"name"`
+	ServiceDiscoveryConfig ServiceDiscoveryConfig `yaml:",inline"`
+}
+
+func (c *jobConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain jobConfig
+	return UnmarshalYAMLWithInlineConfigs((*plain)(c), unmarshal)
+}
+
+func (c *jobConfig) MarshalYAML() (interface{}, error) {
+	type plain jobConfig
+	return MarshalYAMLWithInlineConfigs((*plain)(c))
+}
+
+func TestInlineConfigs(t *testing.T) {
+	in := `name: test
+static_configs:
+- targets:
+  - localhost:9090
+custom_sd_configs:
+- server: a
+- server: b
+`
+	var c jobConfig
+	testutil.Ok(t, yaml.UnmarshalStrict([]byte(in), &c))
+	testutil.Equals(t, "test", c.Name)
+	testutil.Equals(t, 1, len(c.ServiceDiscoveryConfig.StaticConfigs))
+	testutil.Equals(t, []interface{}{
+		&customSDConfig{Server: "a"},
+		&customSDConfig{Server: "b"},
+	}, c.ServiceDiscoveryConfig.Configs)
+
+	out, err := yaml.Marshal(&c)
+	testutil.Ok(t, err)
+	testutil.Equals(t, in, string(out))
+
+	d, err := NewDiscoverer(c.ServiceDiscoveryConfig.Configs[1], log.NewNopLogger())
+	testutil.Ok(t, err)
+	testutil.Equals(t, "b", d.(*customDiscovery).cfg.Server)
+}
+
+func TestInlineConfigsErrors(t *testing.T) {
+	var c jobConfig
+	err := yaml.UnmarshalStrict([]byte("name: test\nunknown_sd_configs: []\n"), &c)
+	testutil.NotOk(t, err, "unknown mechanism accepted")
+	testutil.Assert(t, strings.Contains(err.Error(), "field unknown_sd_configs not found in type config.plain"), "unexpected error: %s", err)
+
+	err = yaml.UnmarshalStrict([]byte("name: test\ncustom_sd_configs:\n- \n"), &c)
+	testutil.NotOk(t, err, "null configuration accepted")
+	testutil.Equals(t, "empty or null section in custom_sd_configs", err.Error())
+
+	_, err = NewDiscoverer(&jobConfig{}, log.NewNopLogger())
+	testutil.NotOk(t, err, "discoverer created for unregistered type")
+}
+
+func TestRegisterSDTwice(t *testing.T) {
+	defer func() {
+		testutil.Assert(t, recover() != nil, "registering a key twice didn't panic")
+	}()
+	RegisterSD("custom_sd_configs", &jobConfig{}, func(cfg interface{}, logger log.Logger) (Discoverer, error) {
+		return nil, nil
+	})
+}
@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
@@ -29,6 +30,8 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	config_util "github.com/prometheus/common/config"
 	"github.com/prometheus/common/model"
+	sd_config "github.com/prometheus/prometheus/discovery/config"
+	"github.com/prometheus/prometheus/discovery/refresh"
 	"github.com/prometheus/prometheus/discovery/targetgroup"
 	"github.com/prometheus/prometheus/util/strutil"
 )
@@ -59,6 +62,12 @@ const (
 	taggedAddressesLabel = model.MetaLabelPrefix + "consul_tagged_address_"
 	// serviceIDLabel is the name of the label containing the service ID.
 	serviceIDLabel = model.MetaLabelPrefix + "consul_service_id"
+	// healthLabel is the name of the label containing the aggregated health status of the target.
+	healthLabel = model.MetaLabelPrefix + "consul_health"
+	// namespaceLabel is the name of the label containing the Consul namespace.
+	namespaceLabel = model.MetaLabelPrefix + "consul_namespace"
+	// partitionLabel is the name of the label containing the Consul admin partition.
+	partitionLabel = model.MetaLabelPrefix + "consul_partition"
 
 	// Constants for instrumentation.
 	namespace = "prometheus"
@@ -100,6 +109,10 @@ type SDConfig struct {
 	Username     string             `yaml:"username,omitempty"`
 	Password     config_util.Secret `yaml:"password,omitempty"`
 
+	// Namespaces and admin partitions are only supported in Consul Enterprise.
+	Namespace string `yaml:"namespace,omitempty"`
+	Partition string `yaml:"partition,omitempty"`
+
 	// See https://www.consul.io/docs/internals/consensus.html#consistency-modes,
 	// stale reads are a lot cheaper and are a necessity if you have >5k targets.
 	AllowStale bool `yaml:"allow_stale"`
@@ -113,11 +126,19 @@ type SDConfig struct {
 	// The list of services for which targets are discovered.
 	// Defaults to all services if empty.
 	Services []string `yaml:"services,omitempty"`
-	// An optional tag used to filter instances inside a service. A single tag is supported
-	// here to match the Consul API.
+	// An optional tag used to filter instances inside a service.
+	// Deprecated: use ServiceTags instead.
 	ServiceTag string `yaml:"tag,omitempty"`
+	// An optional list of tags used to filter instances inside a service. Instances
+	// must carry all tags of the list.
+	ServiceTags []string `yaml:"tags,omitempty"`
 	// Desired node metadata.
 	NodeMeta map[string]string `yaml:"node_meta,omitempty"`
+	// Consul filter expression used to filter the catalog results.
+	// See https://www.consul.io/api/features/filtering.html
+	Filter string `yaml:"filter,omitempty"`
+	// Only discover instances whose health checks are all passing.
+	PassingOnly bool `yaml:"passing_only"`
 
 	TLSConfig config_util.TLSConfig `yaml:"tls_config,omitempty"`
 }
@@ -136,13 +157,22 @@ func (c *SDConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	return nil
 }
 
+// SetDirectory joins the relative paths of the configuration with dir. It
+// implements the discovery/config.DirectorySetter interface.
+func (c *SDConfig) SetDirectory(dir string) {
+	sd_config.SetTLSConfigDirectory(&c.TLSConfig, dir)
+}
+
 func init() {
+	sd_config.RegisterSD("consul_sd_configs", &SDConfig{}, func(cfg interface{}, logger log.Logger) (sd_config.Discoverer, error) {
+		return NewDiscovery(cfg.(*SDConfig), logger)
+	})
 	prometheus.MustRegister(rpcFailuresCount)
 	prometheus.MustRegister(rpcDuration)
 
 	// Initialize metric vectors.
-	rpcDuration.WithLabelValues("catalog", "service")
 	rpcDuration.WithLabelValues("catalog", "services")
+	rpcDuration.WithLabelValues("health", "service")
 }
 
 // Discovery retrieves target information from a Consul server
@@ -152,12 +182,17 @@ type Discovery struct {
 	clientDatacenter string
 	tagSeparator     string
 	watchedServices  []string // Set of services which will be discovered.
-	watchedTag       string   // A tag used to filter instances of a service.
+	watchedTags      []string // Tags used to filter instances of a service.
 	watchedNodeMeta  map[string]string
+	namespace        string
+	partition        string
+	passingOnly      bool
 	allowStale       bool
 	refreshInterval  time.Duration
 	finalizer        func()
 	logger           log.Logger
+
+	refresh.ErrorReporter
 }
 
 // NewDiscovery returns a new Discovery for the given config.
@@ -179,7 +214,7 @@ func NewDiscovery(conf *SDConfig, logger log.Logger) (*Discovery, error) {
 		),
 	}
 	wrapper := &http.Client{
-		Transport: transport,
+		Transport: newQueryParamsRoundTripper(conf, transport),
 		Timeout:   35 * time.Second,
 	}
 
@@ -198,12 +233,19 @@ func NewDiscovery(conf *SDConfig, logger log.Logger) (*Discovery, error) {
 	if err != nil {
 		return nil, err
 	}
+	tags := append([]string(nil), conf.ServiceTags...)
+	if conf.ServiceTag != "" {
+		tags = append(tags, conf.ServiceTag)
+	}
 	cd := &Discovery{
 		client:           client,
 		tagSeparator:     conf.TagSeparator,
 		watchedServices:  conf.Services,
-		watchedTag:       conf.ServiceTag,
+		watchedTags:      tags,
 		watchedNodeMeta:  conf.NodeMeta,
+		namespace:        conf.Namespace,
+		partition:        conf.Partition,
+		passingOnly:      conf.PassingOnly,
 		allowStale:       conf.AllowStale,
 		refreshInterval:  time.Duration(conf.RefreshInterval),
 		clientDatacenter: conf.Datacenter,
@@ -213,6 +255,48 @@ func NewDiscovery(conf *SDConfig, logger log.Logger) (*Discovery, error) {
 	return cd, nil
 }
 
+// queryParamsRoundTripper adds query parameters to catalog and health requests.
+// The Consul API client does not support filter expressions, namespaces and
+// admin partitions yet, so they are set on the outgoing requests instead.
+type queryParamsRoundTripper struct {
+	params url.Values
+	rt     http.RoundTripper
+}
+
+func newQueryParamsRoundTripper(conf *SDConfig, rt http.RoundTripper) http.RoundTripper {
+	params := url.Values{}
+	if conf.Filter != "" {
+		params.Set("filter", conf.Filter)
+	}
+	if conf.Namespace != "" {
+		params.Set("ns", conf.Namespace)
+	}
+	if conf.Partition != "" {
+		params.Set("partition", conf.Partition)
+	}
+	if len(params) == 0 {
+		return rt
+	}
+	return &queryParamsRoundTripper{params: params, rt: rt}
+}
+
+func (rt *queryParamsRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !strings.HasPrefix(req.URL.Path, "/v1/catalog/") && !strings.HasPrefix(req.URL.Path, "/v1/health/") {
+		return rt.rt.RoundTrip(req)
+	}
+	// The request must not be modified, so update a copy of it.
+	r := new(http.Request)
+	*r = *req
+	u := *req.URL
+	q := u.Query()
+	for k, vs := range rt.params {
+		q[k] = vs
+	}
+	u.RawQuery = q.Encode()
+	r.URL = &u
+	return rt.rt.RoundTrip(r)
+}
+
 // shouldWatch returns whether the service of the given name should be watched.
 func (d *Discovery) shouldWatch(name string, tags []string) bool {
 	return d.shouldWatchFromName(name) && d.shouldWatchFromTags(tags)
@@ -237,17 +321,21 @@ func (d *Discovery) shouldWatchFromName(name string) bool {
 // This gets called when the user doesn't specify a list of services in order to avoid watching
 // *all* services. Details in https://github.com/prometheus/prometheus/pull/3814
 func (d *Discovery) shouldWatchFromTags(tags []string) bool {
-	// If there's no fixed set of watched tags, we watch everything.
-	if d.watchedTag == "" {
-		return true
-	}
+	return hasAllTags(tags, d.watchedTags)
+}
 
-	for _, tag := range tags {
-		if d.watchedTag == tag {
-			return true
+// hasAllTags returns whether all wanted tags are contained in tags.
+func hasAllTags(tags, wanted []string) bool {
+wantedLoop:
+	for _, wtag := range wanted {
+		for _, tag := range tags {
+			if wtag == tag {
+				continue wantedLoop
+			}
 		}
+		return false
 	}
-	return false
+	return true
 }
 
 // Get the local datacenter if not specified.
@@ -306,7 +394,7 @@ func (d *Discovery) Run(ctx context.Context, ch chan<- []*targetgroup.Group) {
 	}
 	d.initialize(ctx)
 
-	if len(d.watchedServices) == 0 || d.watchedTag != "" {
+	if len(d.watchedServices) == 0 || len(d.watchedTags) != 0 {
 		// We need to watch the catalog.
 		ticker := time.NewTicker(d.refreshInterval)
 
@@ -339,7 +427,7 @@ func (d *Discovery) Run(ctx context.Context, ch chan<- []*targetgroup.Group) {
 // entire list of services.
 func (d *Discovery) watchServices(ctx context.Context, ch chan<- []*targetgroup.Group, lastIndex *uint64, services map[string]func()) error {
 	catalog := d.client.Catalog()
-	level.Debug(d.logger).Log("msg", "Watching services", "tag", d.watchedTag)
+	level.Debug(d.logger).Log("msg", "Watching services", "tags", strings.Join(d.watchedTags, ","))
 
 	t0 := time.Now()
 	srvs, meta, err := catalog.Services(&consul.QueryOptions{
@@ -351,6 +439,7 @@ func (d *Discovery) watchServices(ctx context.Context, ch chan<- []*targetgroup.
 	elapsed := time.Since(t0)
 	rpcDuration.WithLabelValues("catalog", "services").Observe(elapsed.Seconds())
 
+	d.ReportRefresh("", err)
 	if err != nil {
 		level.Error(d.logger).Log("msg", "Error refreshing service list", "err", err)
 		rpcFailuresCount.Inc()
@@ -387,6 +476,8 @@ func (d *Discovery) watchServices(ctx context.Context, ch chan<- []*targetgroup.
 			// Call the watch cancellation function.
 			cancel()
 			delete(services, name)
+			// Errors of the service don't matter anymore.
+			d.ReportRefresh(name, nil)
 
 			// Send clearing target group.
 			select {
@@ -402,7 +493,7 @@ func (d *Discovery) watchServices(ctx context.Context, ch chan<- []*targetgroup.
 // consulService contains data belonging to the same service.
 type consulService struct {
 	name         string
-	tag          string
+	tags         []string
 	labels       model.LabelSet
 	discovery    *Discovery
 	client       *consul.Client
@@ -416,7 +507,7 @@ func (d *Discovery) watchService(ctx context.Context, ch chan<- []*targetgroup.G
 		discovery: d,
 		client:    d.client,
 		name:      name,
-		tag:       d.watchedTag,
+		tags:      d.watchedTags,
 		labels: model.LabelSet{
 			serviceLabel:    model.LabelValue(name),
 			datacenterLabel: model.LabelValue(d.clientDatacenter),
@@ -424,18 +515,24 @@ func (d *Discovery) watchService(ctx context.Context, ch chan<- []*targetgroup.G
 		tagSeparator: d.tagSeparator,
 		logger:       d.logger,
 	}
+	if d.namespace != "" {
+		srv.labels[namespaceLabel] = model.LabelValue(d.namespace)
+	}
+	if d.partition != "" {
+		srv.labels[partitionLabel] = model.LabelValue(d.partition)
+	}
 
 	go func() {
 		ticker := time.NewTicker(d.refreshInterval)
 		var lastIndex uint64
-		catalog := srv.client.Catalog()
+		health := srv.client.Health()
 		for {
 			select {
 			case <-ctx.Done():
 				ticker.Stop()
 				return
 			default:
-				srv.watch(ctx, ch, catalog, &lastIndex)
+				srv.watch(ctx, ch, health, &lastIndex)
 				<-ticker.C
 			}
 		}
@@ -443,18 +540,25 @@ func (d *Discovery) watchService(ctx context.Context, ch chan<- []*targetgroup.G
 }
 
 // Get updates for a service.
-func (srv *consulService) watch(ctx context.Context, ch chan<- []*targetgroup.Group, catalog *consul.Catalog, lastIndex *uint64) error {
-	level.Debug(srv.logger).Log("msg", "Watching service", "service", srv.name, "tag", srv.tag)
+func (srv *consulService) watch(ctx context.Context, ch chan<- []*targetgroup.Group, health *consul.Health, lastIndex *uint64) error {
+	level.Debug(srv.logger).Log("msg", "Watching service", "service", srv.name, "tags", strings.Join(srv.tags, ","))
+
+	// The Consul API only allows to filter for a single tag, further tags
+	// are checked below.
+	var tag string
+	if len(srv.tags) > 0 {
+		tag = srv.tags[0]
+	}
 
 	t0 := time.Now()
-	nodes, meta, err := catalog.Service(srv.name, srv.tag, &consul.QueryOptions{
+	entries, meta, err := health.Service(srv.name, tag, srv.discovery.passingOnly, &consul.QueryOptions{
 		WaitIndex:  *lastIndex,
 		WaitTime:   watchTimeout,
 		AllowStale: srv.discovery.allowStale,
 		NodeMeta:   srv.discovery.watchedNodeMeta,
 	})
 	elapsed := time.Since(t0)
-	rpcDuration.WithLabelValues("catalog", "service").Observe(elapsed.Seconds())
+	rpcDuration.WithLabelValues("health", "service").Observe(elapsed.Seconds())
 
 	// Check the context before in order to exit early.
 	select {
@@ -464,8 +568,9 @@ func (srv *consulService) watch(ctx context.Context, ch chan<- []*targetgroup.Gr
 		// Continue.
 	}
 
+	srv.discovery.ReportRefresh(srv.name, err)
 	if err != nil {
-		level.Error(srv.logger).Log("msg", "Error refreshing service", "service", srv.name, "tag", srv.tag, "err", err)
+		level.Error(srv.logger).Log("msg", "Error refreshing service", "service", srv.name, "tags", strings.Join(srv.tags, ","), "err", err)
 		rpcFailuresCount.Inc()
 		time.Sleep(retryInterval)
 		return err
@@ -479,22 +584,26 @@ func (srv *consulService) watch(ctx context.Context, ch chan<- []*targetgroup.Gr
 	tgroup := targetgroup.Group{
 		Source:  srv.name,
 		Labels:  srv.labels,
-		Targets: make([]model.LabelSet, 0, len(nodes)),
+		Targets: make([]model.LabelSet, 0, len(entries)),
 	}
 
-	for _, node := range nodes {
+	for _, entry := range entries {
+		node, service := entry.Node, entry.Service
+		if !hasAllTags(service.Tags, srv.tags) {
+			continue
+		}
 
 		// We surround the separated list with the separator as well. This way regular expressions
 		// in relabeling rules don't have to consider tag positions.
-		var tags = srv.tagSeparator + strings.Join(node.ServiceTags, srv.tagSeparator) + srv.tagSeparator
+		var tags = srv.tagSeparator + strings.Join(service.Tags, srv.tagSeparator) + srv.tagSeparator
 
 		// If the service address is not empty it should be used instead of the node address
 		// since the service may be registered remotely through a different node.
 		var addr string
-		if node.ServiceAddress != "" {
-			addr = net.JoinHostPort(node.ServiceAddress, fmt.Sprintf("%d", node.ServicePort))
+		if service.Address != "" {
+			addr = net.JoinHostPort(service.Address, fmt.Sprintf("%d", service.Port))
 		} else {
-			addr = net.JoinHostPort(node.Address, fmt.Sprintf("%d", node.ServicePort))
+			addr = net.JoinHostPort(node.Address, fmt.Sprintf("%d", service.Port))
 		}
 
 		labels := model.LabelSet{
@@ -502,24 +611,25 @@ func (srv *consulService) watch(ctx context.Context, ch chan<- []*targetgroup.Gr
 			addressLabel:        model.LabelValue(node.Address),
 			nodeLabel:           model.LabelValue(node.Node),
 			tagsLabel:           model.LabelValue(tags),
-			serviceAddressLabel: model.LabelValue(node.ServiceAddress),
-			servicePortLabel:    model.LabelValue(strconv.Itoa(node.ServicePort)),
-			serviceIDLabel:      model.LabelValue(node.ServiceID),
+			serviceAddressLabel: model.LabelValue(service.Address),
+			servicePortLabel:    model.LabelValue(strconv.Itoa(service.Port)),
+			serviceIDLabel:      model.LabelValue(service.ID),
+			healthLabel:         model.LabelValue(entry.Checks.AggregatedStatus()),
 		}
 
 		// Add all key/value pairs from the node's metadata as their own labels.
-		for k, v := range node.NodeMeta {
+		for k, v := range node.Meta {
 			name := strutil.SanitizeLabelName(k)
 			labels[metaDataLabel+model.LabelName(name)] = model.LabelValue(v)
 		}
 
 		// Add all key/value pairs from the service's metadata as their own labels.
-		for k, v := range node.ServiceMeta {
+		for k, v := range service.Meta {
 			name := strutil.SanitizeLabelName(k)
 			labels[serviceMetaDataLabel+model.LabelName(name)] = model.LabelValue(v)
 		}
 
-		// Add all key/value pairs from the service's tagged addresses as their own labels.
+		// Add all key/value pairs from the node's tagged addresses as their own labels.
 		for k, v := range node.TaggedAddresses {
 			name := strutil.SanitizeLabelName(k)
 			labels[taggedAddressesLabel+model.LabelName(name)] = model.LabelValue(v)
@@ -68,6 +68,27 @@ func TestConfiguredServiceWithTag(t *testing.T) {
 	}
 }
 
+func TestConfiguredServiceWithTags(t *testing.T) {
+	conf := &SDConfig{
+		Services:    []string{"configuredServiceName"},
+		ServiceTags: []string{"http", "v1"},
+	}
+	consulDiscovery, err := NewDiscovery(conf, nil)
+
+	if err != nil {
+		t.Errorf("Unexpected error when initializing discovery %v", err)
+	}
+	if consulDiscovery.shouldWatch("configuredServiceName", []string{"http"}) {
+		t.Errorf("Expected service %s to not be watched with only tag %s", "configuredServiceName", "http")
+	}
+	if !consulDiscovery.shouldWatch("configuredServiceName", []string{"v1", "foo", "http"}) {
+		t.Errorf("Expected service %s to be watched with tags %s", "configuredServiceName", "http, v1")
+	}
+	if consulDiscovery.shouldWatch("nonConfiguredServiceName", []string{"http", "v1"}) {
+		t.Errorf("Expected service %s to not be watched with tags %s", "nonConfiguredServiceName", "http, v1")
+	}
+}
+
 func TestNonConfiguredService(t *testing.T) {
 	conf := &SDConfig{}
 	consulDiscovery, err := NewDiscovery(conf, nil)
@@ -83,19 +104,31 @@ func TestNonConfiguredService(t *testing.T) {
 const (
 	AgentAnswer       = `{"Config": {"Datacenter": "test-dc"}}`
 	ServiceTestAnswer = `[{
-"ID": "b78c2e48-5ef3-1814-31b8-0d880f50471e",
-"Node": "node1",
-"Address": "1.1.1.1",
-"Datacenter": "test-dc",
-"TaggedAddresses": {"lan":"192.168.10.10","wan":"10.0.10.10"},
-"NodeMeta": {"rack_name": "2304"},
-"ServiceID": "test",
-"ServiceName": "test",
-"ServiceMeta": {"version":"1.0.0","environment":"stagging"},
-"ServiceTags": ["tag1"],
-"ServicePort": 3341,
-"CreateIndex": 1,
-"ModifyIndex": 1
+"Node": {
+	"ID": "b78c2e48-5ef3-1814-31b8-0d880f50471e",
+	"Node": "node1",
+	"Address": "1.1.1.1",
+	"Datacenter": "test-dc",
+	"TaggedAddresses": {"lan":"192.168.10.10","wan":"10.0.10.10"},
+	"Meta": {"rack_name": "2304"},
+	"CreateIndex": 1,
+	"ModifyIndex": 1
+},
+"Service": {
+	"ID": "test",
+	"Service": "test",
+	"Tags": ["tag1"],
+	"Meta": {"version":"1.0.0","environment":"stagging"},
+	"Port": 3341,
+	"CreateIndex": 1,
+	"ModifyIndex": 1
+},
+"Checks": [{
+	"Node": "node1",
+	"CheckID": "serfHealth",
+	"Name": "Serf Health Status",
+	"Status": "passing"
+}]
 }]`
 	ServicesTestAnswer = `{"test": ["tag1"], "other": ["tag2"]}`
 )
@@ -107,11 +140,15 @@ func newServer(t *testing.T) (*httptest.Server, *SDConfig) {
 		switch r.URL.String() {
 		case "/v1/agent/self":
 			response = AgentAnswer
-		case "/v1/catalog/service/test?node-meta=rack_name%3A2304&stale=&tag=tag1&wait=30000ms":
+		case "/v1/health/service/test?node-meta=rack_name%3A2304&stale=&tag=tag1&wait=30000ms":
+			response = ServiceTestAnswer
+		case "/v1/health/service/test?wait=30000ms":
 			response = ServiceTestAnswer
-		case "/v1/catalog/service/test?wait=30000ms":
+		case "/v1/health/service/test?filter=NodeMeta.rack_name+%3D%3D+%222304%22&ns=ns1&partition=part1&passing=1&wait=30000ms":
 			response = ServiceTestAnswer
-		case "/v1/catalog/service/other?wait=30000ms":
+		case "/v1/health/service/test?tag=tag1&wait=30000ms":
+			response = ServiceTestAnswer
+		case "/v1/health/service/other?wait=30000ms":
 			response = `[]`
 		case "/v1/catalog/services?node-meta=rack_name%3A2304&stale=&wait=30000ms":
 			response = ServicesTestAnswer
@@ -155,6 +192,7 @@ func checkOneTarget(t *testing.T, tg []*targetgroup.Group) {
 	if target.Source == "test" {
 		// test service should have one node.
 		testutil.Assert(t, len(target.Targets) > 0, "Test service should have one node")
+		testutil.Equals(t, "passing", string(target.Targets[0]["__meta_consul_health"]))
 	}
 }
 
@@ -207,3 +245,56 @@ func TestAllOptions(t *testing.T) {
 	checkOneTarget(t, <-ch)
 	cancel()
 }
+
+// Watch the test service with a filter expression in a namespace and partition,
+// only discovering passing instances.
+func TestFilterOptions(t *testing.T) {
+	stub, config := newServer(t)
+	defer stub.Close()
+
+	config.Services = []string{"test"}
+	config.Filter = `NodeMeta.rack_name == "2304"`
+	config.Namespace = "ns1"
+	config.Partition = "part1"
+	config.PassingOnly = true
+
+	d := newDiscovery(t, config)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := make(chan []*targetgroup.Group)
+	go d.Run(ctx, ch)
+	tgs := <-ch
+	checkOneTarget(t, tgs)
+	testutil.Equals(t, "ns1", string(tgs[0].Labels["__meta_consul_namespace"]))
+	testutil.Equals(t, "part1", string(tgs[0].Labels["__meta_consul_partition"]))
+	cancel()
+}
+
+// Watch the test service with multiple tags, of which the test instance
+// only carries one.
+func TestMultipleTags(t *testing.T) {
+	stub, config := newServer(t)
+	defer stub.Close()
+
+	config.Services = []string{"test"}
+	config.ServiceTags = []string{"tag1", "tag2"}
+
+	d := newDiscovery(t, config)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := make(chan []*targetgroup.Group)
+	srv := &consulService{
+		discovery:    d,
+		client:       d.client,
+		name:         "test",
+		tags:         d.watchedTags,
+		tagSeparator: d.tagSeparator,
+		logger:       d.logger,
+	}
+	var lastIndex uint64
+	go srv.watch(ctx, ch, d.client.Health(), &lastIndex)
+	tgs := <-ch
+	testutil.Equals(t, 1, len(tgs))
+	testutil.Equals(t, 0, len(tgs[0].Targets))
+	cancel()
+}
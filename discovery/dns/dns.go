@@ -17,6 +17,7 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -26,13 +27,27 @@ import (
 	"github.com/miekg/dns"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/model"
+	sd_config "github.com/prometheus/prometheus/discovery/config"
+	"github.com/prometheus/prometheus/discovery/refresh"
 	"github.com/prometheus/prometheus/discovery/targetgroup"
 )
 
 const (
 	resolvConf = "/etc/resolv.conf"
 
-	dnsNameLabel = model.MetaLabelPrefix + "dns_name"
+	dnsNameLabel            = model.MetaLabelPrefix + "dns_name"
+	dnsTTLLabel             = model.MetaLabelPrefix + "dns_record_ttl"
+	dnsSrvRecordPrefix      = model.MetaLabelPrefix + "dns_srv_record_"
+	dnsSrvRecordTargetLabel = dnsSrvRecordPrefix + "target"
+	dnsSrvRecordPortLabel   = dnsSrvRecordPrefix + "port"
+	dnsSrvRecordPriority    = dnsSrvRecordPrefix + "priority"
+	dnsSrvRecordWeight      = dnsSrvRecordPrefix + "weight"
+	dnsMxRecordTargetLabel  = model.MetaLabelPrefix + "dns_mx_record_target"
+	dnsNsRecordTargetLabel  = model.MetaLabelPrefix + "dns_ns_record_target"
+
+	// defaultNameserverPort is the port used for configured nameservers
+	// without an explicit port.
+	defaultNameserverPort = "53"
 
 	// Constants for instrumentation.
 	namespace = "prometheus"
@@ -65,6 +80,12 @@ type SDConfig struct {
 	RefreshInterval model.Duration `yaml:"refresh_interval,omitempty"`
 	Type            string         `yaml:"type"`
 	Port            int            `yaml:"port"` // Ignored for SRV records
+	// Nameservers overrides the servers of /etc/resolv.conf. Search
+	// domains are not applied to queries sent to them.
+	Nameservers []string `yaml:"nameservers,omitempty"`
+	// ResolveSRVTargets makes SRV lookups return the addresses of the
+	// record targets instead of their host names.
+	ResolveSRVTargets bool `yaml:"resolve_srv_targets,omitempty"`
 }
 
 // UnmarshalYAML implements the yaml.Unmarshaler interface.
@@ -80,17 +101,44 @@ func (c *SDConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	}
 	switch strings.ToUpper(c.Type) {
 	case "SRV":
-	case "A", "AAAA":
+	case "A", "AAAA", "MX", "NS":
 		if c.Port == 0 {
 			return fmt.Errorf("a port is required in DNS-SD configs for all record types except SRV")
 		}
+		if c.ResolveSRVTargets {
+			return fmt.Errorf("resolve_srv_targets is only supported for SRV records")
+		}
 	default:
 		return fmt.Errorf("invalid DNS-SD records type %s", c.Type)
 	}
+	for _, ns := range c.Nameservers {
+		if err := validateNameserver(ns); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateNameserver checks that the nameserver is a host with an optional port.
+func validateNameserver(ns string) error {
+	host, port, err := net.SplitHostPort(ns)
+	if err != nil {
+		// No port given, which is valid.
+		host, port = ns, defaultNameserverPort
+	}
+	if host == "" {
+		return fmt.Errorf("invalid DNS-SD nameserver %q: missing host", ns)
+	}
+	if p, err := strconv.Atoi(port); err != nil || p <= 0 || p > 65535 {
+		return fmt.Errorf("invalid DNS-SD nameserver %q: invalid port", ns)
+	}
 	return nil
 }
 
 func init() {
+	sd_config.RegisterSD("dns_sd_configs", &SDConfig{}, func(cfg interface{}, logger log.Logger) (sd_config.Discoverer, error) {
+		return NewDiscovery(*cfg.(*SDConfig), logger), nil
+	})
 	prometheus.MustRegister(dnsSDLookupFailuresCount)
 	prometheus.MustRegister(dnsSDLookupsCount)
 }
@@ -100,10 +148,14 @@ func init() {
 type Discovery struct {
 	names []string
 
-	interval time.Duration
-	port     int
-	qtype    uint16
-	logger   log.Logger
+	interval          time.Duration
+	port              int
+	qtype             uint16
+	nameservers       []string
+	resolveSRVTargets bool
+	logger            log.Logger
+
+	refresh.ErrorReporter
 }
 
 // NewDiscovery returns a new Discovery which periodically refreshes its targets.
@@ -118,16 +170,48 @@ func NewDiscovery(conf SDConfig, logger log.Logger) *Discovery {
 		qtype = dns.TypeA
 	case "AAAA":
 		qtype = dns.TypeAAAA
+	case "MX":
+		qtype = dns.TypeMX
+	case "NS":
+		qtype = dns.TypeNS
 	case "SRV":
 		qtype = dns.TypeSRV
 	}
+
+	nameservers := make([]string, 0, len(conf.Nameservers))
+	for _, ns := range conf.Nameservers {
+		if _, _, err := net.SplitHostPort(ns); err != nil {
+			ns = net.JoinHostPort(ns, defaultNameserverPort)
+		}
+		nameservers = append(nameservers, ns)
+	}
+
 	return &Discovery{
-		names:    conf.Names,
-		interval: time.Duration(conf.RefreshInterval),
-		qtype:    qtype,
-		port:     conf.Port,
-		logger:   logger,
+		names:             conf.Names,
+		interval:          time.Duration(conf.RefreshInterval),
+		qtype:             qtype,
+		port:              conf.Port,
+		nameservers:       nameservers,
+		resolveSRVTargets: conf.ResolveSRVTargets,
+		logger:            logger,
+	}
+}
+
+// clientConfig returns the resolver configuration used for lookups. It is
+// read from resolv.conf on every call unless nameservers are configured.
+func (d *Discovery) clientConfig() (*dns.ClientConfig, error) {
+	if len(d.nameservers) > 0 {
+		return &dns.ClientConfig{
+			Servers: d.nameservers,
+			Port:    defaultNameserverPort,
+			Ndots:   1,
+		}, nil
+	}
+	conf, err := dns.ClientConfigFromFile(resolvConf)
+	if err != nil {
+		return nil, fmt.Errorf("could not load resolv.conf: %s", err)
 	}
+	return conf, nil
 }
 
 // Run implements the Discoverer interface.
@@ -154,7 +238,9 @@ func (d *Discovery) refreshAll(ctx context.Context, ch chan<- []*targetgroup.Gro
 	wg.Add(len(d.names))
 	for _, name := range d.names {
 		go func(n string) {
-			if err := d.refresh(ctx, n, ch); err != nil {
+			err := d.refresh(ctx, n, ch)
+			d.ReportRefresh(n, err)
+			if err != nil {
 				level.Error(d.logger).Log("msg", "Error refreshing DNS targets", "err", err)
 			}
 			wg.Done()
@@ -165,7 +251,12 @@ func (d *Discovery) refreshAll(ctx context.Context, ch chan<- []*targetgroup.Gro
 }
 
 func (d *Discovery) refresh(ctx context.Context, name string, ch chan<- []*targetgroup.Group) error {
-	response, err := lookupWithSearchPath(name, d.qtype, d.logger)
+	conf, err := d.clientConfig()
+	if err != nil {
+		dnsSDLookupFailuresCount.Inc()
+		return err
+	}
+	response, err := lookupWithSearchPath(name, d.qtype, conf, d.logger)
 	dnsSDLookupsCount.Inc()
 	if err != nil {
 		dnsSDLookupFailuresCount.Inc()
@@ -178,25 +269,54 @@ func (d *Discovery) refresh(ctx context.Context, name string, ch chan<- []*targe
 	}
 
 	for _, record := range response.Answer {
-		target := model.LabelValue("")
+		labels := model.LabelSet{
+			dnsNameLabel: model.LabelValue(name),
+			dnsTTLLabel:  model.LabelValue(strconv.FormatUint(uint64(record.Header().Ttl), 10)),
+		}
 		switch addr := record.(type) {
 		case *dns.SRV:
 			// Remove the final dot from rooted DNS names to make them look more usual.
-			addr.Target = strings.TrimRight(addr.Target, ".")
-
-			target = hostPort(addr.Target, int(addr.Port))
+			srvTarget := strings.TrimRight(addr.Target, ".")
+
+			hosts := []string{srvTarget}
+			if d.resolveSRVTargets {
+				hosts, err = d.resolveSRVTarget(addr.Target, response.Extra, conf)
+				if err != nil {
+					return err
+				}
+			}
+			labels[dnsSrvRecordTargetLabel] = model.LabelValue(srvTarget)
+			labels[dnsSrvRecordPortLabel] = model.LabelValue(strconv.Itoa(int(addr.Port)))
+			labels[dnsSrvRecordPriority] = model.LabelValue(strconv.Itoa(int(addr.Priority)))
+			labels[dnsSrvRecordWeight] = model.LabelValue(strconv.Itoa(int(addr.Weight)))
+
+			for _, h := range hosts {
+				target := labels.Clone()
+				target[model.AddressLabel] = hostPort(h, int(addr.Port))
+				tg.Targets = append(tg.Targets, target)
+			}
+			continue
 		case *dns.A:
-			target = hostPort(addr.A.String(), d.port)
+			labels[model.AddressLabel] = hostPort(addr.A.String(), d.port)
 		case *dns.AAAA:
-			target = hostPort(addr.AAAA.String(), d.port)
+			labels[model.AddressLabel] = hostPort(addr.AAAA.String(), d.port)
+		case *dns.MX:
+			mx := strings.TrimRight(addr.Mx, ".")
+			labels[model.AddressLabel] = hostPort(mx, d.port)
+			labels[dnsMxRecordTargetLabel] = model.LabelValue(mx)
+		case *dns.NS:
+			ns := strings.TrimRight(addr.Ns, ".")
+			labels[model.AddressLabel] = hostPort(ns, d.port)
+			labels[dnsNsRecordTargetLabel] = model.LabelValue(ns)
+		case *dns.CNAME:
+			// Lookups of aliases return the CNAME record along with the
+			// records of the requested type.
+			continue
 		default:
-			level.Warn(d.logger).Log("msg", "Invalid SRV record", "record", record)
+			level.Warn(d.logger).Log("msg", "Invalid record", "record", record)
 			continue
 		}
-		tg.Targets = append(tg.Targets, model.LabelSet{
-			model.AddressLabel: target,
-			dnsNameLabel:       model.LabelValue(name),
-		})
+		tg.Targets = append(tg.Targets, labels)
 	}
 
 	tg.Source = name
@@ -209,6 +329,45 @@ func (d *Discovery) refresh(ctx context.Context, name string, ch chan<- []*targe
 	return nil
 }
 
+// resolveSRVTarget returns the IP addresses of the target of an SRV record.
+// Addresses from the additional section of the SRV response are used if
+// present, otherwise the target's A and AAAA records are looked up.
+func (d *Discovery) resolveSRVTarget(target string, extra []dns.RR, conf *dns.ClientConfig) ([]string, error) {
+	var addrs []string
+	for _, rr := range extra {
+		if !strings.EqualFold(rr.Header().Name, dns.Fqdn(target)) {
+			continue
+		}
+		switch a := rr.(type) {
+		case *dns.A:
+			addrs = append(addrs, a.A.String())
+		case *dns.AAAA:
+			addrs = append(addrs, a.AAAA.String())
+		}
+	}
+	if len(addrs) > 0 {
+		return addrs, nil
+	}
+
+	for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		response, err := lookupWithSearchPath(dns.Fqdn(target), qtype, conf, d.logger)
+		dnsSDLookupsCount.Inc()
+		if err != nil {
+			dnsSDLookupFailuresCount.Inc()
+			return nil, err
+		}
+		for _, rr := range response.Answer {
+			switch a := rr.(type) {
+			case *dns.A:
+				addrs = append(addrs, a.A.String())
+			case *dns.AAAA:
+				addrs = append(addrs, a.AAAA.String())
+			}
+		}
+	}
+	return addrs, nil
+}
+
 // lookupWithSearchPath tries to get an answer for various permutations of
 // the given name, appending the system-configured search path as necessary.
 //
@@ -236,12 +395,7 @@ func (d *Discovery) refresh(ctx context.Context, name string, ch chan<- []*targe
 // error will be generic-looking, because trying to return all the errors
 // returned by the combination of all name permutations and servers is a
 // nightmare.
-func lookupWithSearchPath(name string, qtype uint16, logger log.Logger) (*dns.Msg, error) {
-	conf, err := dns.ClientConfigFromFile(resolvConf)
-	if err != nil {
-		return nil, fmt.Errorf("could not load resolv.conf: %s", err)
-	}
-
+func lookupWithSearchPath(name string, qtype uint16, conf *dns.ClientConfig, logger log.Logger) (*dns.Msg, error) {
 	allResponsesValid := true
 
 	for _, lname := range conf.NameList(name) {
@@ -288,7 +442,11 @@ func lookupFromAnyServer(name string, qtype uint16, conf *dns.ClientConfig, logg
 	client := &dns.Client{}
 
 	for _, server := range conf.Servers {
-		servAddr := net.JoinHostPort(server, conf.Port)
+		// Configured nameservers already carry a port.
+		servAddr := server
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			servAddr = net.JoinHostPort(server, conf.Port)
+		}
 		msg, err := askServerForName(name, qtype, client, servAddr, true)
 		if err != nil {
 			level.Warn(logger).Log("msg", "DNS resolution failed", "server", server, "name", name, "err", err)
@@ -0,0 +1,270 @@
+// Copyright 2019 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dns
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/prometheus/common/model"
+
+	"github.com/prometheus/prometheus/discovery/targetgroup"
+	"github.com/prometheus/prometheus/util/testutil"
+)
+
+// testZone holds the records served by the test DNS server.
+var testZone = []string{
+	"_web._tcp.example.com. 300 IN SRV 10 20 8080 web1.example.com.",
+	"_web._tcp.example.com. 300 IN SRV 20 30 8081 web2.example.com.",
+	"web1.example.com. 60 IN A 192.0.2.1",
+	"web2.example.com. 60 IN A 192.0.2.2",
+	"web2.example.com. 60 IN AAAA 2001:db8::2",
+	"example.com. 3600 IN MX 10 mail.example.com.",
+	"example.com. 3600 IN NS ns1.example.com.",
+}
+
+// startTestServer starts a DNS server on a random local port answering
+// from testZone. SRV answers carry the A record of web1 as additional
+// record, so that only web2 has to be looked up when resolving targets.
+func startTestServer(t *testing.T) (string, func()) {
+	records := map[string]map[uint16][]dns.RR{}
+	for _, z := range testZone {
+		rr, err := dns.NewRR(z)
+		testutil.Ok(t, err)
+		h := rr.Header()
+		if records[h.Name] == nil {
+			records[h.Name] = map[uint16][]dns.RR{}
+		}
+		records[h.Name][h.Rrtype] = append(records[h.Name][h.Rrtype], rr)
+	}
+
+	handler := dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		m := &dns.Msg{}
+		m.SetReply(r)
+
+		q := r.Question[0]
+		byType, ok := records[q.Name]
+		if !ok {
+			m.Rcode = dns.RcodeNameError
+		}
+		m.Answer = byType[q.Qtype]
+		if q.Qtype == dns.TypeSRV {
+			m.Extra = records["web1.example.com."][dns.TypeA]
+		}
+		w.WriteMsg(m)
+	})
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	testutil.Ok(t, err)
+
+	started := make(chan struct{})
+	srv := &dns.Server{PacketConn: pc, Handler: handler, NotifyStartedFunc: func() { close(started) }}
+	go srv.ActivateAndServe()
+	<-started
+
+	return pc.LocalAddr().String(), func() { srv.Shutdown() }
+}
+
+func TestDNS(t *testing.T) {
+	addr, stop := startTestServer(t)
+	defer stop()
+
+	testCases := []struct {
+		name     string
+		config   SDConfig
+		expected []*targetgroup.Group
+	}{
+		{
+			name: "SRV record",
+			config: SDConfig{
+				Names: []string{"_web._tcp.example.com."},
+				Type:  "SRV",
+			},
+			expected: []*targetgroup.Group{
+				{
+					Source: "_web._tcp.example.com.",
+					Targets: []model.LabelSet{
+						{
+							"__address__":                    "web1.example.com:8080",
+							"__meta_dns_name":                "_web._tcp.example.com.",
+							"__meta_dns_record_ttl":          "300",
+							"__meta_dns_srv_record_target":   "web1.example.com",
+							"__meta_dns_srv_record_port":     "8080",
+							"__meta_dns_srv_record_priority": "10",
+							"__meta_dns_srv_record_weight":   "20",
+						},
+						{
+							"__address__":                    "web2.example.com:8081",
+							"__meta_dns_name":                "_web._tcp.example.com.",
+							"__meta_dns_record_ttl":          "300",
+							"__meta_dns_srv_record_target":   "web2.example.com",
+							"__meta_dns_srv_record_port":     "8081",
+							"__meta_dns_srv_record_priority": "20",
+							"__meta_dns_srv_record_weight":   "30",
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "SRV record with resolved targets",
+			config: SDConfig{
+				Names:             []string{"_web._tcp.example.com."},
+				Type:              "SRV",
+				ResolveSRVTargets: true,
+			},
+			expected: []*targetgroup.Group{
+				{
+					Source: "_web._tcp.example.com.",
+					Targets: []model.LabelSet{
+						{
+							"__address__":                    "192.0.2.1:8080",
+							"__meta_dns_name":                "_web._tcp.example.com.",
+							"__meta_dns_record_ttl":          "300",
+							"__meta_dns_srv_record_target":   "web1.example.com",
+							"__meta_dns_srv_record_port":     "8080",
+							"__meta_dns_srv_record_priority": "10",
+							"__meta_dns_srv_record_weight":   "20",
+						},
+						{
+							"__address__":                    "192.0.2.2:8081",
+							"__meta_dns_name":                "_web._tcp.example.com.",
+							"__meta_dns_record_ttl":          "300",
+							"__meta_dns_srv_record_target":   "web2.example.com",
+							"__meta_dns_srv_record_port":     "8081",
+							"__meta_dns_srv_record_priority": "20",
+							"__meta_dns_srv_record_weight":   "30",
+						},
+						{
+							"__address__":                    "[2001:db8::2]:8081",
+							"__meta_dns_name":                "_web._tcp.example.com.",
+							"__meta_dns_record_ttl":          "300",
+							"__meta_dns_srv_record_target":   "web2.example.com",
+							"__meta_dns_srv_record_port":     "8081",
+							"__meta_dns_srv_record_priority": "20",
+							"__meta_dns_srv_record_weight":   "30",
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "A record",
+			config: SDConfig{
+				Names: []string{"web1.example.com."},
+				Type:  "A",
+				Port:  80,
+			},
+			expected: []*targetgroup.Group{
+				{
+					Source: "web1.example.com.",
+					Targets: []model.LabelSet{
+						{
+							"__address__":           "192.0.2.1:80",
+							"__meta_dns_name":       "web1.example.com.",
+							"__meta_dns_record_ttl": "60",
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "MX record",
+			config: SDConfig{
+				Names: []string{"example.com."},
+				Type:  "MX",
+				Port:  25,
+			},
+			expected: []*targetgroup.Group{
+				{
+					Source: "example.com.",
+					Targets: []model.LabelSet{
+						{
+							"__address__":                 "mail.example.com:25",
+							"__meta_dns_name":             "example.com.",
+							"__meta_dns_record_ttl":       "3600",
+							"__meta_dns_mx_record_target": "mail.example.com",
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "NS record",
+			config: SDConfig{
+				Names: []string{"example.com."},
+				Type:  "NS",
+				Port:  53,
+			},
+			expected: []*targetgroup.Group{
+				{
+					Source: "example.com.",
+					Targets: []model.LabelSet{
+						{
+							"__address__":                 "ns1.example.com:53",
+							"__meta_dns_name":             "example.com.",
+							"__meta_dns_record_ttl":       "3600",
+							"__meta_dns_ns_record_target": "ns1.example.com",
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "unknown name",
+			config: SDConfig{
+				Names: []string{"unknown.example.com."},
+				Type:  "A",
+				Port:  80,
+			},
+			expected: []*targetgroup.Group{
+				{
+					Source: "unknown.example.com.",
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.config.Nameservers = []string{addr}
+			sd := NewDiscovery(tc.config, nil)
+
+			ch := make(chan []*targetgroup.Group, 1)
+			testutil.Ok(t, sd.refresh(context.Background(), tc.config.Names[0], ch))
+			testutil.Equals(t, tc.expected, <-ch)
+		})
+	}
+}
+
+func TestDNSNoNameserverAnswers(t *testing.T) {
+	// Nothing listens on the port of a closed connection.
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	testutil.Ok(t, err)
+	addr := pc.LocalAddr().String()
+	pc.Close()
+
+	sd := NewDiscovery(SDConfig{
+		Names:       []string{"web1.example.com."},
+		Type:        "A",
+		Port:        80,
+		Nameservers: []string{addr},
+	}, nil)
+
+	ch := make(chan []*targetgroup.Group, 1)
+	testutil.NotOk(t, sd.refresh(context.Background(), "web1.example.com.", ch), "")
+	testutil.Equals(t, 0, len(ch))
+}
@@ -32,6 +32,8 @@ import (
 
 	"github.com/aws/aws-sdk-go/service/ec2"
 	config_util "github.com/prometheus/common/config"
+	sd_config "github.com/prometheus/prometheus/discovery/config"
+	"github.com/prometheus/prometheus/discovery/refresh"
 	"github.com/prometheus/prometheus/discovery/targetgroup"
 	"github.com/prometheus/prometheus/util/strutil"
 )
@@ -121,6 +123,9 @@ func (c *SDConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
 }
 
 func init() {
+	sd_config.RegisterSD("ec2_sd_configs", &SDConfig{}, func(cfg interface{}, logger log.Logger) (sd_config.Discoverer, error) {
+		return NewDiscovery(cfg.(*SDConfig), logger), nil
+	})
 	prometheus.MustRegister(ec2SDRefreshFailuresCount)
 	prometheus.MustRegister(ec2SDRefreshDuration)
 }
@@ -135,6 +140,8 @@ type Discovery struct {
 	port     int
 	filters  []*Filter
 	logger   log.Logger
+
+	refresh.ErrorReporter
 }
 
 // NewDiscovery returns a new EC2Discovery which periodically refreshes its targets.
@@ -168,6 +175,7 @@ func (d *Discovery) Run(ctx context.Context, ch chan<- []*targetgroup.Group) {
 
 	// Get an initial set right away.
 	tg, err := d.refresh()
+	d.ReportRefresh("", err)
 	if err != nil {
 		level.Error(d.logger).Log("msg", "Refresh failed", "err", err)
 	} else {
@@ -182,6 +190,7 @@ func (d *Discovery) Run(ctx context.Context, ch chan<- []*targetgroup.Group) {
 		select {
 		case <-ticker.C:
 			tg, err := d.refresh()
+			d.ReportRefresh("", err)
 			if err != nil {
 				level.Error(d.logger).Log("msg", "Refresh failed", "err", err)
 				continue
@@ -0,0 +1,137 @@
+// Copyright 2019 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eureka
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+const appListPath string = "/apps"
+
+// Applications is the list of applications registered in Eureka.
+type Applications struct {
+	VersionsDelta int           `xml:"versions__delta"`
+	AppsHashcode  string        `xml:"apps__hashcode"`
+	Applications  []Application `xml:"application"`
+}
+
+// Application describes an application registered in Eureka.
+type Application struct {
+	Name      string     `xml:"name"`
+	Instances []Instance `xml:"instance"`
+}
+
+// Port describes a port of an instance and whether it is enabled.
+type Port struct {
+	Port    int  `xml:",chardata"`
+	Enabled bool `xml:"enabled,attr"`
+}
+
+// Instance describes a single instance of an application.
+type Instance struct {
+	HostName                      string          `xml:"hostName"`
+	HomePageURL                   string          `xml:"homePageUrl"`
+	StatusPageURL                 string          `xml:"statusPageUrl"`
+	HealthCheckURL                string          `xml:"healthCheckUrl"`
+	App                           string          `xml:"app"`
+	IPAddr                        string          `xml:"ipAddr"`
+	VipAddress                    string          `xml:"vipAddress"`
+	SecureVipAddress              string          `xml:"secureVipAddress"`
+	Status                        string          `xml:"status"`
+	Port                          *Port           `xml:"port"`
+	SecurePort                    *Port           `xml:"securePort"`
+	DataCenterInfo                *DataCenterInfo `xml:"dataCenterInfo"`
+	Metadata                      *MetaData       `xml:"metadata"`
+	IsCoordinatingDiscoveryServer bool            `xml:"isCoordinatingDiscoveryServer"`
+	ActionType                    string          `xml:"actionType"`
+	CountryID                     int             `xml:"countryId"`
+	InstanceID                    string          `xml:"instanceId"`
+}
+
+// DataCenterInfo describes the data center an instance runs in.
+type DataCenterInfo struct {
+	Name     string    `xml:"name"`
+	Class    string    `xml:"class,attr"`
+	Metadata *MetaData `xml:"metadata"`
+}
+
+// MetaData holds the free-form metadata of an instance or data center.
+type MetaData struct {
+	Map   map[string]string
+	Class string
+}
+
+// UnmarshalXML implements the xml.Unmarshaler interface. Eureka encodes
+// metadata entries as elements named after their keys.
+func (s *MetaData) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	s.Map = make(map[string]string)
+	for _, attr := range start.Attr {
+		if attr.Name.Local == "class" {
+			s.Class = attr.Value
+		}
+	}
+
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := t.(type) {
+		case xml.StartElement:
+			var value string
+			if err := d.DecodeElement(&value, &t); err != nil {
+				return err
+			}
+			s.Map[t.Name.Local] = value
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+func fetchApps(ctx context.Context, server string, client *http.Client) (*Applications, error) {
+	url := server + appListPath
+
+	request, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	request = request.WithContext(ctx)
+	request.Header.Add("Accept", "application/xml")
+
+	resp, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+	}()
+
+	if resp.StatusCode/100 != 2 {
+		return nil, errors.Errorf("non 2xx status '%d' response during eureka service discovery", resp.StatusCode)
+	}
+
+	var apps Applications
+	if err := xml.NewDecoder(resp.Body).Decode(&apps); err != nil {
+		return nil, errors.Wrapf(err, "decoding applications from %q", url)
+	}
+	return &apps, nil
+}
@@ -0,0 +1,277 @@
+// Copyright 2019 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eureka
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	config_util "github.com/prometheus/common/config"
+	"github.com/prometheus/common/model"
+
+	sd_config "github.com/prometheus/prometheus/discovery/config"
+	"github.com/prometheus/prometheus/discovery/refresh"
+	"github.com/prometheus/prometheus/discovery/targetgroup"
+	"github.com/prometheus/prometheus/util/strutil"
+)
+
+const (
+	// metaLabelPrefix is the meta prefix used for all meta labels in this discovery.
+	metaLabelPrefix = model.MetaLabelPrefix + "eureka_"
+	// metaAppInstanceLabel is the prefix for the labels of an application instance.
+	metaAppInstanceLabel = metaLabelPrefix + "app_instance_"
+
+	appNameLabel                        = metaLabelPrefix + "app_name"
+	appInstanceHostNameLabel            = metaAppInstanceLabel + "hostname"
+	appInstanceHomePageURLLabel         = metaAppInstanceLabel + "homepage_url"
+	appInstanceStatusPageURLLabel       = metaAppInstanceLabel + "statuspage_url"
+	appInstanceHealthCheckURLLabel      = metaAppInstanceLabel + "healthcheck_url"
+	appInstanceIPAddrLabel              = metaAppInstanceLabel + "ip_addr"
+	appInstanceVipAddressLabel          = metaAppInstanceLabel + "vip_address"
+	appInstanceSecureVipAddressLabel    = metaAppInstanceLabel + "secure_vip_address"
+	appInstanceStatusLabel              = metaAppInstanceLabel + "status"
+	appInstancePortLabel                = metaAppInstanceLabel + "port"
+	appInstancePortEnabledLabel         = metaAppInstanceLabel + "port_enabled"
+	appInstanceSecurePortLabel          = metaAppInstanceLabel + "secure_port"
+	appInstanceSecurePortEnabledLabel   = metaAppInstanceLabel + "secure_port_enabled"
+	appInstanceDataCenterInfoNameLabel  = metaAppInstanceLabel + "datacenterinfo_name"
+	appInstanceDataCenterInfoMetaPrefix = metaAppInstanceLabel + "datacenterinfo_metadata_"
+	appInstanceCountryIDLabel           = metaAppInstanceLabel + "country_id"
+	appInstanceIDLabel                  = metaAppInstanceLabel + "id"
+	appInstanceMetadataPrefix           = metaAppInstanceLabel + "metadata_"
+
+	// Constants for instrumentation.
+	namespace = "prometheus"
+)
+
+var (
+	// DefaultSDConfig is the default Eureka SD configuration.
+	DefaultSDConfig = SDConfig{
+		RefreshInterval: model.Duration(30 * time.Second),
+	}
+
+	refreshFailuresCount = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "sd_eureka_refresh_failures_total",
+			Help:      "The number of Eureka-SD refresh failures.",
+		})
+	refreshDuration = prometheus.NewSummary(
+		prometheus.SummaryOpts{
+			Namespace: namespace,
+			Name:      "sd_eureka_refresh_duration_seconds",
+			Help:      "The duration of a Eureka-SD refresh in seconds.",
+		})
+)
+
+func init() {
+	sd_config.RegisterSD("eureka_sd_configs", &SDConfig{}, func(cfg interface{}, logger log.Logger) (sd_config.Discoverer, error) {
+		return NewDiscovery(cfg.(*SDConfig), logger)
+	})
+	prometheus.MustRegister(refreshFailuresCount)
+	prometheus.MustRegister(refreshDuration)
+}
+
+// SDConfig is the configuration for applications running on Eureka.
+type SDConfig struct {
+	Server           string                       `yaml:"server,omitempty"`
+	HTTPClientConfig config_util.HTTPClientConfig `yaml:",inline"`
+	RefreshInterval  model.Duration               `yaml:"refresh_interval,omitempty"`
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+func (c *SDConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultSDConfig
+	type plain SDConfig
+	err := unmarshal((*plain)(c))
+	if err != nil {
+		return err
+	}
+	if len(c.Server) == 0 {
+		return fmt.Errorf("eureka_sd: empty or null eureka server")
+	}
+	u, err := url.Parse(c.Server)
+	if err != nil {
+		return err
+	}
+	if len(u.Scheme) == 0 || len(u.Host) == 0 {
+		return fmt.Errorf("eureka_sd: invalid eureka server URL")
+	}
+	if c.RefreshInterval <= 0 {
+		return fmt.Errorf("eureka_sd: refresh_interval must be positive")
+	}
+	return c.HTTPClientConfig.Validate()
+}
+
+// SetDirectory joins the relative paths of the configuration with dir. It
+// implements the discovery/config.DirectorySetter interface.
+func (c *SDConfig) SetDirectory(dir string) {
+	sd_config.SetHTTPClientConfigDirectory(&c.HTTPClientConfig, dir)
+}
+
+// Discovery provides service discovery based on a Eureka instance.
+type Discovery struct {
+	client          *http.Client
+	server          string
+	refreshInterval time.Duration
+	logger          log.Logger
+
+	refresh.ErrorReporter
+}
+
+// NewDiscovery creates a new Eureka discovery for the given config.
+func NewDiscovery(conf *SDConfig, logger log.Logger) (*Discovery, error) {
+	if logger == nil {
+		logger = log.NewNopLogger()
+	}
+
+	rt, err := config_util.NewRoundTripperFromConfig(conf.HTTPClientConfig, "eureka_sd")
+	if err != nil {
+		return nil, err
+	}
+
+	return &Discovery{
+		client:          &http.Client{Transport: rt},
+		server:          conf.Server,
+		refreshInterval: time.Duration(conf.RefreshInterval),
+		logger:          logger,
+	}, nil
+}
+
+// Run implements the Discoverer interface.
+func (d *Discovery) Run(ctx context.Context, ch chan<- []*targetgroup.Group) {
+	err := d.updateServices(ctx, ch)
+	d.ReportRefresh("", err)
+	if err != nil {
+		level.Error(d.logger).Log("msg", "Error while updating services", "err", err)
+	}
+
+	ticker := time.NewTicker(d.refreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := d.updateServices(ctx, ch)
+			d.ReportRefresh("", err)
+			if err != nil {
+				level.Error(d.logger).Log("msg", "Error while updating services", "err", err)
+			}
+		}
+	}
+}
+
+func (d *Discovery) updateServices(ctx context.Context, ch chan<- []*targetgroup.Group) (err error) {
+	t0 := time.Now()
+	defer func() {
+		refreshDuration.Observe(time.Since(t0).Seconds())
+		if err != nil {
+			refreshFailuresCount.Inc()
+		}
+	}()
+
+	apps, err := fetchApps(ctx, d.server, d.client)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case ch <- []*targetgroup.Group{targetsForApps(apps)}:
+	}
+	return nil
+}
+
+// targetsForApps returns a single target group holding all instances of
+// the given applications. Using a single group makes vanished instances
+// disappear on the next refresh.
+func targetsForApps(apps *Applications) *targetgroup.Group {
+	tg := &targetgroup.Group{
+		Source: "eureka",
+	}
+
+	// Gather info about the app's 'instances'. Each instance is considered a task.
+	for _, app := range apps.Applications {
+		for _, t := range app.Instances {
+			var targetAddress string
+			if t.Port != nil {
+				targetAddress = net.JoinHostPort(t.HostName, strconv.Itoa(t.Port.Port))
+			} else {
+				targetAddress = net.JoinHostPort(t.HostName, "80")
+			}
+
+			target := model.LabelSet{
+				model.AddressLabel: lv(targetAddress),
+
+				appNameLabel:                     lv(app.Name),
+				appInstanceHostNameLabel:         lv(t.HostName),
+				appInstanceHomePageURLLabel:      lv(t.HomePageURL),
+				appInstanceStatusPageURLLabel:    lv(t.StatusPageURL),
+				appInstanceHealthCheckURLLabel:   lv(t.HealthCheckURL),
+				appInstanceIPAddrLabel:           lv(t.IPAddr),
+				appInstanceVipAddressLabel:       lv(t.VipAddress),
+				appInstanceSecureVipAddressLabel: lv(t.SecureVipAddress),
+				appInstanceStatusLabel:           lv(t.Status),
+				appInstanceCountryIDLabel:        lv(strconv.Itoa(t.CountryID)),
+				appInstanceIDLabel:               lv(t.InstanceID),
+			}
+
+			if t.Port != nil {
+				target[appInstancePortLabel] = lv(strconv.Itoa(t.Port.Port))
+				target[appInstancePortEnabledLabel] = lv(strconv.FormatBool(t.Port.Enabled))
+			}
+
+			if t.SecurePort != nil {
+				target[appInstanceSecurePortLabel] = lv(strconv.Itoa(t.SecurePort.Port))
+				target[appInstanceSecurePortEnabledLabel] = lv(strconv.FormatBool(t.SecurePort.Enabled))
+			}
+
+			if t.DataCenterInfo != nil {
+				target[appInstanceDataCenterInfoNameLabel] = lv(t.DataCenterInfo.Name)
+
+				if t.DataCenterInfo.Metadata != nil {
+					for k, v := range t.DataCenterInfo.Metadata.Map {
+						ln := strutil.SanitizeLabelName(k)
+						target[model.LabelName(appInstanceDataCenterInfoMetaPrefix+ln)] = lv(v)
+					}
+				}
+			}
+
+			if t.Metadata != nil {
+				for k, v := range t.Metadata.Map {
+					ln := strutil.SanitizeLabelName(k)
+					target[model.LabelName(appInstanceMetadataPrefix+ln)] = lv(v)
+				}
+			}
+
+			tg.Targets = append(tg.Targets, target)
+		}
+	}
+	return tg
+}
+
+func lv(s string) model.LabelValue {
+	return model.LabelValue(s)
+}
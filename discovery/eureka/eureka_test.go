@@ -0,0 +1,190 @@
+// Copyright 2019 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eureka
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/common/model"
+
+	"github.com/prometheus/prometheus/discovery/targetgroup"
+	"github.com/prometheus/prometheus/util/testutil"
+)
+
+func testUpdateServices(respHandler http.HandlerFunc) ([]*targetgroup.Group, error) {
+	// Create a test server with mock HTTP handler.
+	ts := httptest.NewServer(respHandler)
+	defer ts.Close()
+
+	conf := SDConfig{
+		Server:          ts.URL,
+		RefreshInterval: DefaultSDConfig.RefreshInterval,
+	}
+
+	md, err := NewDiscovery(&conf, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan []*targetgroup.Group, 1)
+	if err := md.updateServices(context.Background(), ch); err != nil {
+		return nil, err
+	}
+	return <-ch, nil
+}
+
+func TestEurekaSDHandleError(t *testing.T) {
+	var (
+		errTesting  = "non 2xx status '500' response during eureka service discovery"
+		respHandler = func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusInternalServerError)
+			io.WriteString(w, ``)
+		}
+	)
+	tgs, err := testUpdateServices(respHandler)
+
+	testutil.NotOk(t, err, "")
+	testutil.Equals(t, errTesting, err.Error())
+	testutil.Equals(t, 0, len(tgs))
+}
+
+func TestEurekaSDEmptyList(t *testing.T) {
+	var (
+		appsXML = `<applications>
+<versions__delta>1</versions__delta>
+<apps__hashcode/>
+</applications>`
+		respHandler = func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusOK)
+			io.WriteString(w, appsXML)
+		}
+	)
+	tgs, err := testUpdateServices(respHandler)
+	testutil.Ok(t, err)
+	testutil.Equals(t, 1, len(tgs))
+	testutil.Equals(t, 0, len(tgs[0].Targets))
+}
+
+func TestEurekaSDSendGroup(t *testing.T) {
+	var (
+		appsXML = `<applications>
+  <versions__delta>1</versions__delta>
+  <apps__hashcode>UP_4_</apps__hashcode>
+  <application>
+    <name>CONFIG-SERVICE</name>
+    <instance>
+      <instanceId>config-service001.test.com:config-service:8080</instanceId>
+      <hostName>config-service001.test.com</hostName>
+      <app>CONFIG-SERVICE</app>
+      <ipAddr>192.133.83.31</ipAddr>
+      <status>UP</status>
+      <overriddenstatus>UNKNOWN</overriddenstatus>
+      <port enabled="true">8080</port>
+      <securePort enabled="false">8080</securePort>
+      <countryId>1</countryId>
+      <dataCenterInfo class="com.netflix.appinfo.InstanceInfo$DefaultDataCenterInfo">
+        <name>MyOwn</name>
+      </dataCenterInfo>
+      <metadata>
+        <project>test-project</project>
+        <management.port>8080</management.port>
+      </metadata>
+      <homePageUrl>http://config-service001.test.com:8080/</homePageUrl>
+      <statusPageUrl>http://config-service001.test.com:8080/info</statusPageUrl>
+      <healthCheckUrl>http://config-service001.test.com 8080/health</healthCheckUrl>
+      <vipAddress>config-service</vipAddress>
+      <isCoordinatingDiscoveryServer>false</isCoordinatingDiscoveryServer>
+      <lastUpdatedTimestamp>1596003469304</lastUpdatedTimestamp>
+      <lastDirtyTimestamp>1596003469304</lastDirtyTimestamp>
+      <actionType>MODIFIED</actionType>
+    </instance>
+  </application>
+  <application>
+    <name>META-SERVICE</name>
+    <instance>
+      <instanceId>meta-service002.test.com:meta-service:8080</instanceId>
+      <hostName>meta-service002.test.com</hostName>
+      <app>META-SERVICE</app>
+      <ipAddr>192.133.87.237</ipAddr>
+      <status>UP</status>
+      <overriddenstatus>UNKNOWN</overriddenstatus>
+      <port enabled="true">8080</port>
+      <securePort enabled="false">443</securePort>
+      <countryId>1</countryId>
+      <dataCenterInfo class="com.netflix.appinfo.AmazonInfo">
+        <name>Amazon</name>
+        <metadata>
+          <availability-zone>us-east-1a</availability-zone>
+          <instance-id>i-0123456789</instance-id>
+        </metadata>
+      </dataCenterInfo>
+      <homePageUrl>http://meta-service002.test.com:8080/</homePageUrl>
+      <statusPageUrl>http://meta-service002.test.com:8080/info</statusPageUrl>
+      <healthCheckUrl>http://meta-service002.test.com:8080/health</healthCheckUrl>
+      <vipAddress>meta-service</vipAddress>
+      <secureVipAddress>meta-service</secureVipAddress>
+      <isCoordinatingDiscoveryServer>false</isCoordinatingDiscoveryServer>
+      <actionType>MODIFIED</actionType>
+    </instance>
+  </application>
+</applications>`
+		respHandler = func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusOK)
+			io.WriteString(w, appsXML)
+		}
+	)
+
+	tgs, err := testUpdateServices(respHandler)
+	testutil.Ok(t, err)
+	testutil.Equals(t, 1, len(tgs))
+
+	tg := tgs[0]
+	testutil.Equals(t, "eureka", tg.Source)
+	testutil.Equals(t, 2, len(tg.Targets))
+
+	testutil.Equals(t, model.LabelSet{
+		"__address__":                                         "config-service001.test.com:8080",
+		"__meta_eureka_app_name":                              "CONFIG-SERVICE",
+		"__meta_eureka_app_instance_hostname":                 "config-service001.test.com",
+		"__meta_eureka_app_instance_homepage_url":             "http://config-service001.test.com:8080/",
+		"__meta_eureka_app_instance_statuspage_url":           "http://config-service001.test.com:8080/info",
+		"__meta_eureka_app_instance_healthcheck_url":          "http://config-service001.test.com 8080/health",
+		"__meta_eureka_app_instance_ip_addr":                  "192.133.83.31",
+		"__meta_eureka_app_instance_vip_address":              "config-service",
+		"__meta_eureka_app_instance_secure_vip_address":       "",
+		"__meta_eureka_app_instance_status":                   "UP",
+		"__meta_eureka_app_instance_port":                     "8080",
+		"__meta_eureka_app_instance_port_enabled":             "true",
+		"__meta_eureka_app_instance_secure_port":              "8080",
+		"__meta_eureka_app_instance_secure_port_enabled":      "false",
+		"__meta_eureka_app_instance_country_id":               "1",
+		"__meta_eureka_app_instance_id":                       "config-service001.test.com:config-service:8080",
+		"__meta_eureka_app_instance_datacenterinfo_name":      "MyOwn",
+		"__meta_eureka_app_instance_metadata_project":         "test-project",
+		"__meta_eureka_app_instance_metadata_management_port": "8080",
+	}, tg.Targets[0])
+
+	testutil.Equals(t, model.LabelValue("meta-service002.test.com:8080"), tg.Targets[1][model.AddressLabel])
+	testutil.Equals(t, model.LabelValue("Amazon"), tg.Targets[1]["__meta_eureka_app_instance_datacenterinfo_name"])
+	testutil.Equals(t, model.LabelValue("us-east-1a"), tg.Targets[1]["__meta_eureka_app_instance_datacenterinfo_metadata_availability_zone"])
+	testutil.Equals(t, model.LabelValue("i-0123456789"), tg.Targets[1]["__meta_eureka_app_instance_datacenterinfo_metadata_instance_id"])
+	testutil.Equals(t, model.LabelValue("443"), tg.Targets[1]["__meta_eureka_app_instance_secure_port"])
+}
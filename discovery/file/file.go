@@ -30,6 +30,8 @@ import (
 	"github.com/go-kit/kit/log/level"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/model"
+	sd_config "github.com/prometheus/prometheus/discovery/config"
+	"github.com/prometheus/prometheus/discovery/refresh"
 	"github.com/prometheus/prometheus/discovery/targetgroup"
 	"gopkg.in/fsnotify/fsnotify.v1"
 	"gopkg.in/yaml.v2"
@@ -69,6 +71,14 @@ func (c *SDConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	return nil
 }
 
+// SetDirectory joins the relative paths of the configuration with dir. It
+// implements the discovery/config.DirectorySetter interface.
+func (c *SDConfig) SetDirectory(dir string) {
+	for i, fn := range c.Files {
+		c.Files[i] = sd_config.JoinDir(dir, fn)
+	}
+}
+
 const fileSDFilepathLabel = model.MetaLabelPrefix + "filepath"
 
 // TimestampCollector is a Custom Collector for Timestamps of the files.
@@ -146,6 +156,9 @@ var (
 )
 
 func init() {
+	sd_config.RegisterSD("file_sd_configs", &SDConfig{}, func(cfg interface{}, logger log.Logger) (sd_config.Discoverer, error) {
+		return NewDiscovery(cfg.(*SDConfig), logger), nil
+	})
 	prometheus.MustRegister(fileSDScanDuration)
 	prometheus.MustRegister(fileSDReadErrorsCount)
 	prometheus.MustRegister(fileSDTimeStamp)
@@ -166,6 +179,8 @@ type Discovery struct {
 	// This is used to detect deleted target groups.
 	lastRefresh map[string]int
 	logger      log.Logger
+
+	refresh.ErrorReporter
 }
 
 // NewDiscovery returns a new file discovery for the given paths.
@@ -315,6 +330,7 @@ func (d *Discovery) refresh(ctx context.Context, ch chan<- []*targetgroup.Group)
 	ref := map[string]int{}
 	for _, p := range d.listFiles() {
 		tgroups, err := d.readFile(p)
+		d.ReportRefresh(p, err)
 		if err != nil {
 			fileSDReadErrorsCount.Inc()
 
@@ -337,6 +353,10 @@ func (d *Discovery) refresh(ctx context.Context, ch chan<- []*targetgroup.Group)
 		if !ok || n > m {
 			level.Debug(d.logger).Log("msg", "file_sd refresh found file that should be removed", "file", f)
 			d.deleteTimestamp(f)
+			if !ok {
+				// Errors reading the removed file don't matter anymore.
+				d.ReportRefresh(f, nil)
+			}
 			for i := m; i < n; i++ {
 				select {
 				case ch <- []*targetgroup.Group{{Source: fileSource(f, i)}}:
@@ -385,12 +405,10 @@ func (d *Discovery) readFile(filename string) ([]*targetgroup.Group, error) {
 		panic(fmt.Errorf("discovery.File.readFile: unhandled file extension %q", ext))
 	}
 
+	if err := ValidateTargetGroups(targetGroups); err != nil {
+		return nil, err
+	}
 	for i, tg := range targetGroups {
-		if tg == nil {
-			err = errors.New("nil target group item found")
-			return nil, err
-		}
-
 		tg.Source = fileSource(filename, i)
 		if tg.Labels == nil {
 			tg.Labels = model.LabelSet{}
@@ -403,6 +421,17 @@ func (d *Discovery) readFile(filename string) ([]*targetgroup.Group, error) {
 	return targetGroups, nil
 }
 
+// ValidateTargetGroups checks a list of target groups decoded from the file SD
+// format. It is shared with other mechanisms using the same format.
+func ValidateTargetGroups(tgs []*targetgroup.Group) error {
+	for _, tg := range tgs {
+		if tg == nil {
+			return errors.New("nil target group item found")
+		}
+	}
+	return nil
+}
+
 // fileSource returns a source ID for the i-th target group in the file.
 func fileSource(filename string, i int) string {
 	return fmt.Sprintf("%s:%d", filename, i)
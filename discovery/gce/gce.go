@@ -28,6 +28,8 @@ import (
 	"golang.org/x/oauth2/google"
 	compute "google.golang.org/api/compute/v1"
 
+	sd_config "github.com/prometheus/prometheus/discovery/config"
+	"github.com/prometheus/prometheus/discovery/refresh"
 	"github.com/prometheus/prometheus/discovery/targetgroup"
 	"github.com/prometheus/prometheus/util/strutil"
 )
@@ -105,6 +107,9 @@ func (c *SDConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
 }
 
 func init() {
+	sd_config.RegisterSD("gce_sd_configs", &SDConfig{}, func(cfg interface{}, logger log.Logger) (sd_config.Discoverer, error) {
+		return NewDiscovery(*cfg.(*SDConfig), logger)
+	})
 	prometheus.MustRegister(gceSDRefreshFailuresCount)
 	prometheus.MustRegister(gceSDRefreshDuration)
 }
@@ -122,6 +127,8 @@ type Discovery struct {
 	port         int
 	tagSeparator string
 	logger       log.Logger
+
+	refresh.ErrorReporter
 }
 
 // NewDiscovery returns a new Discovery which periodically refreshes its targets.
@@ -155,6 +162,7 @@ func NewDiscovery(conf SDConfig, logger log.Logger) (*Discovery, error) {
 func (d *Discovery) Run(ctx context.Context, ch chan<- []*targetgroup.Group) {
 	// Get an initial set right away.
 	tg, err := d.refresh()
+	d.ReportRefresh("", err)
 	if err != nil {
 		level.Error(d.logger).Log("msg", "Refresh failed", "err", err)
 	} else {
@@ -171,6 +179,7 @@ func (d *Discovery) Run(ctx context.Context, ch chan<- []*targetgroup.Group) {
 		select {
 		case <-ticker.C:
 			tg, err := d.refresh()
+			d.ReportRefresh("", err)
 			if err != nil {
 				level.Error(d.logger).Log("msg", "Refresh failed", "err", err)
 				continue
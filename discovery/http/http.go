@@ -0,0 +1,276 @@
+// Copyright 2019 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	config_util "github.com/prometheus/common/config"
+	"github.com/prometheus/common/model"
+
+	sd_config "github.com/prometheus/prometheus/discovery/config"
+	"github.com/prometheus/prometheus/discovery/file"
+	"github.com/prometheus/prometheus/discovery/refresh"
+	"github.com/prometheus/prometheus/discovery/targetgroup"
+)
+
+const (
+	// urlLabel is the name of the label holding the URL the target group
+	// was discovered from.
+	urlLabel = model.MetaLabelPrefix + "url"
+
+	// Constants for instrumentation.
+	namespace = "prometheus"
+)
+
+var (
+	// DefaultSDConfig is the default HTTP SD configuration.
+	DefaultSDConfig = SDConfig{
+		RefreshInterval: model.Duration(60 * time.Second),
+	}
+
+	matchContentType = regexp.MustCompile(`^(?i:application\/json(;\s*charset=("utf-8"|utf-8))?)$`)
+
+	refreshFailuresCount = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "sd_http_refresh_failures_total",
+			Help:      "The number of HTTP-SD refresh failures.",
+		})
+	refreshDuration = prometheus.NewSummary(
+		prometheus.SummaryOpts{
+			Namespace: namespace,
+			Name:      "sd_http_refresh_duration_seconds",
+			Help:      "The duration of a HTTP-SD refresh in seconds.",
+		})
+)
+
+func init() {
+	sd_config.RegisterSD("http_sd_configs", &SDConfig{}, func(cfg interface{}, logger log.Logger) (sd_config.Discoverer, error) {
+		return NewDiscovery(cfg.(*SDConfig), logger)
+	})
+	prometheus.MustRegister(refreshFailuresCount)
+	prometheus.MustRegister(refreshDuration)
+}
+
+// SDConfig is the configuration for HTTP based discovery.
+type SDConfig struct {
+	HTTPClientConfig config_util.HTTPClientConfig `yaml:",inline"`
+	RefreshInterval  model.Duration               `yaml:"refresh_interval,omitempty"`
+	URL              string                       `yaml:"url"`
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+func (c *SDConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultSDConfig
+	type plain SDConfig
+	err := unmarshal((*plain)(c))
+	if err != nil {
+		return err
+	}
+	if c.URL == "" {
+		return fmt.Errorf("http_sd: URL is missing")
+	}
+	parsedURL, err := url.Parse(c.URL)
+	if err != nil {
+		return err
+	}
+	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
+		return fmt.Errorf("http_sd: URL scheme must be 'http' or 'https'")
+	}
+	if parsedURL.Host == "" {
+		return fmt.Errorf("http_sd: host is missing in URL")
+	}
+	if c.RefreshInterval <= 0 {
+		return fmt.Errorf("http_sd: refresh_interval must be positive")
+	}
+	return c.HTTPClientConfig.Validate()
+}
+
+// SetDirectory joins the relative paths of the configuration with dir. It
+// implements the discovery/config.DirectorySetter interface.
+func (c *SDConfig) SetDirectory(dir string) {
+	sd_config.SetHTTPClientConfigDirectory(&c.HTTPClientConfig, dir)
+}
+
+// Discovery provides service discovery functionality based
+// on HTTP endpoints that return target groups in JSON format.
+type Discovery struct {
+	url             string
+	client          *http.Client
+	refreshInterval time.Duration
+	logger          log.Logger
+
+	// etag is the entity tag of the last successful response. It is sent
+	// back to the server to avoid refetching unchanged target groups.
+	etag string
+	// lastLength is the number of target groups sent on the last refresh,
+	// used to clear target groups that disappeared.
+	lastLength int
+
+	refresh.ErrorReporter
+}
+
+// NewDiscovery returns a new HTTP discovery for the given config.
+func NewDiscovery(conf *SDConfig, logger log.Logger) (*Discovery, error) {
+	if logger == nil {
+		logger = log.NewNopLogger()
+	}
+
+	rt, err := config_util.NewRoundTripperFromConfig(conf.HTTPClientConfig, "http_sd")
+	if err != nil {
+		return nil, err
+	}
+
+	return &Discovery{
+		url:             conf.URL,
+		client:          &http.Client{Transport: rt},
+		refreshInterval: time.Duration(conf.RefreshInterval),
+		logger:          logger,
+	}, nil
+}
+
+// Run implements the Discoverer interface.
+func (d *Discovery) Run(ctx context.Context, ch chan<- []*targetgroup.Group) {
+	err := d.refresh(ctx, ch)
+	d.ReportRefresh("", err)
+	if err != nil {
+		level.Error(d.logger).Log("msg", "Unable to refresh target groups", "err", err)
+	}
+
+	ticker := time.NewTicker(d.refreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := d.refresh(ctx, ch)
+			d.ReportRefresh("", err)
+			if err != nil {
+				level.Error(d.logger).Log("msg", "Unable to refresh target groups", "err", err)
+			}
+		}
+	}
+}
+
+// refresh fetches the target groups and sends them to the channel unless
+// the server reported them as unchanged.
+func (d *Discovery) refresh(ctx context.Context, ch chan<- []*targetgroup.Group) (err error) {
+	t0 := time.Now()
+	defer func() {
+		refreshDuration.Observe(time.Since(t0).Seconds())
+		if err != nil {
+			refreshFailuresCount.Inc()
+		}
+	}()
+
+	tgs, modified, err := d.fetch(ctx)
+	if err != nil || !modified {
+		return err
+	}
+
+	// Clear target groups which were present on the last refresh
+	// but are gone now.
+	for i := len(tgs); i < d.lastLength; i++ {
+		tgs = append(tgs, &targetgroup.Group{Source: urlSource(d.url, i)})
+	}
+	d.lastLength = len(tgs)
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case ch <- tgs:
+	}
+	return nil
+}
+
+// fetch requests the target groups from the configured URL. The returned
+// boolean is false if they have not changed since the last fetch.
+func (d *Discovery) fetch(ctx context.Context) ([]*targetgroup.Group, bool, error) {
+	req, err := http.NewRequest("GET", d.url, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/json")
+	if d.etag != "" {
+		req.Header.Set("If-None-Match", d.etag)
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer func() {
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+	}()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotModified:
+		if d.etag != "" {
+			return nil, false, nil
+		}
+		fallthrough
+	default:
+		return nil, false, errors.Errorf("server returned HTTP status %s", resp.Status)
+	}
+
+	if ct := resp.Header.Get("Content-Type"); !matchContentType.MatchString(ct) {
+		return nil, false, errors.Errorf("unsupported content type %q", ct)
+	}
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var tgs []*targetgroup.Group
+	if err := json.Unmarshal(b, &tgs); err != nil {
+		return nil, false, err
+	}
+	if err := file.ValidateTargetGroups(tgs); err != nil {
+		return nil, false, err
+	}
+	for i, tg := range tgs {
+		tg.Source = urlSource(d.url, i)
+		if tg.Labels == nil {
+			tg.Labels = model.LabelSet{}
+		}
+		tg.Labels[urlLabel] = model.LabelValue(d.url)
+	}
+
+	d.etag = resp.Header.Get("ETag")
+	return tgs, true, nil
+}
+
+// urlSource returns a source ID for the i-th target group of the URL.
+func urlSource(u string, i int) string {
+	return fmt.Sprintf("%s:%d", u, i)
+}
@@ -0,0 +1,162 @@
+// Copyright 2019 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/common/model"
+
+	"github.com/prometheus/prometheus/discovery/targetgroup"
+	"github.com/prometheus/prometheus/util/testutil"
+)
+
+func newTestDiscovery(t *testing.T, url string) *Discovery {
+	d, err := NewDiscovery(&SDConfig{URL: url, RefreshInterval: DefaultSDConfig.RefreshInterval}, nil)
+	testutil.Ok(t, err)
+	return d
+}
+
+// runRefresh runs a single refresh and returns the sent target groups, if any.
+func runRefresh(t *testing.T, d *Discovery) ([]*targetgroup.Group, error) {
+	ch := make(chan []*targetgroup.Group, 1)
+	err := d.refresh(context.Background(), ch)
+	select {
+	case tgs := <-ch:
+		return tgs, err
+	default:
+		return nil, err
+	}
+}
+
+func TestHTTPValidRefresh(t *testing.T) {
+	body := `[{"labels": {"k": "v"}, "targets": ["127.0.0.1:9090", "127.0.0.1:9091"]}, {"targets": ["127.0.0.1:9092"]}]`
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, body)
+	}))
+	defer ts.Close()
+
+	d := newTestDiscovery(t, ts.URL)
+	tgs, err := runRefresh(t, d)
+	testutil.Ok(t, err)
+
+	expected := []*targetgroup.Group{
+		{
+			Targets: []model.LabelSet{
+				{model.AddressLabel: "127.0.0.1:9090"},
+				{model.AddressLabel: "127.0.0.1:9091"},
+			},
+			Labels: model.LabelSet{
+				"k":      "v",
+				urlLabel: model.LabelValue(ts.URL),
+			},
+			Source: urlSource(ts.URL, 0),
+		},
+		{
+			Targets: []model.LabelSet{
+				{model.AddressLabel: "127.0.0.1:9092"},
+			},
+			Labels: model.LabelSet{
+				urlLabel: model.LabelValue(ts.URL),
+			},
+			Source: urlSource(ts.URL, 1),
+		},
+	}
+	testutil.Equals(t, expected, tgs)
+
+	// Removed target groups must be cleared.
+	body = `[{"targets": ["127.0.0.1:9093"]}]`
+	tgs, err = runRefresh(t, d)
+	testutil.Ok(t, err)
+	testutil.Equals(t, 2, len(tgs))
+	testutil.Equals(t, []model.LabelSet{{model.AddressLabel: "127.0.0.1:9093"}}, tgs[0].Targets)
+	testutil.Equals(t, &targetgroup.Group{Source: urlSource(ts.URL, 1)}, tgs[1])
+}
+
+func TestHTTPETag(t *testing.T) {
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprint(w, `[{"targets": ["127.0.0.1:9090"]}]`)
+	}))
+	defer ts.Close()
+
+	d := newTestDiscovery(t, ts.URL)
+	tgs, err := runRefresh(t, d)
+	testutil.Ok(t, err)
+	testutil.Equals(t, 1, len(tgs))
+
+	// Unchanged target groups are not sent again.
+	tgs, err = runRefresh(t, d)
+	testutil.Ok(t, err)
+	testutil.Assert(t, tgs == nil, "unexpected target groups %v", tgs)
+	testutil.Equals(t, 2, requests)
+}
+
+func TestHTTPInvalidResponse(t *testing.T) {
+	for _, tc := range []struct {
+		status      int
+		contentType string
+		body        string
+		err         string
+	}{
+		{
+			status:      http.StatusInternalServerError,
+			contentType: "application/json",
+			body:        `[]`,
+			err:         "server returned HTTP status 500 Internal Server Error",
+		},
+		{
+			status:      http.StatusOK,
+			contentType: "text/plain",
+			body:        `[]`,
+			err:         `unsupported content type "text/plain"`,
+		},
+		{
+			status:      http.StatusOK,
+			contentType: "application/json",
+			body:        `[null]`,
+			err:         "nil target group item found",
+		},
+		{
+			status:      http.StatusOK,
+			contentType: "application/json",
+			body:        `[{"targets": ["127.0.0.1:9090"], "unknown": "field"}]`,
+			err:         `json: unknown field "unknown"`,
+		},
+	} {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", tc.contentType)
+			w.WriteHeader(tc.status)
+			fmt.Fprint(w, tc.body)
+		}))
+
+		tgs, err := runRefresh(t, newTestDiscovery(t, ts.URL))
+		ts.Close()
+		testutil.NotOk(t, err, "")
+		testutil.Equals(t, tc.err, err.Error())
+		testutil.Assert(t, tgs == nil, "unexpected target groups %v", tgs)
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright 2019 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package install registers all service discovery mechanisms shipped with
+// Prometheus. Programs using the discovery manager import it for its side
+// effects:
+//
+//	import _ "github.com/prometheus/prometheus/discovery/install"
+package install
+
+import (
+	_ "github.com/prometheus/prometheus/discovery/azure"      // register azure
+	_ "github.com/prometheus/prometheus/discovery/consul"     // register consul
+	_ "github.com/prometheus/prometheus/discovery/dns"        // register dns
+	_ "github.com/prometheus/prometheus/discovery/ec2"        // register ec2
+	_ "github.com/prometheus/prometheus/discovery/eureka"     // register eureka
+	_ "github.com/prometheus/prometheus/discovery/file"       // register file
+	_ "github.com/prometheus/prometheus/discovery/gce"        // register gce
+	_ "github.com/prometheus/prometheus/discovery/http"       // register http
+	_ "github.com/prometheus/prometheus/discovery/kubernetes" // register kubernetes
+	_ "github.com/prometheus/prometheus/discovery/marathon"   // register marathon
+	_ "github.com/prometheus/prometheus/discovery/moby"       // register moby
+	_ "github.com/prometheus/prometheus/discovery/nomad"      // register nomad
+	_ "github.com/prometheus/prometheus/discovery/openstack"  // register openstack
+	_ "github.com/prometheus/prometheus/discovery/triton"     // register triton
+	_ "github.com/prometheus/prometheus/discovery/zookeeper"  // register zookeeper
+)
@@ -0,0 +1,459 @@
+// Copyright 2019 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/discovery/targetgroup"
+	"github.com/prometheus/prometheus/util/strutil"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// endpointSliceGVR identifies the EndpointSlice resource on the API server.
+var endpointSliceGVR = schema.GroupVersionResource{
+	Group:    "discovery.k8s.io",
+	Version:  "v1beta1",
+	Resource: "endpointslices",
+}
+
+// endpointSliceServiceLabel is the label linking an EndpointSlice to its service.
+const endpointSliceServiceLabel = "kubernetes.io/service-name"
+
+// The EndpointSlice API is not part of the vendored Kubernetes API types.
+// EndpointSlices are thus watched through the dynamic client and converted
+// to the following types mirroring discovery.k8s.io/v1beta1.
+
+type endpointSlice struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	AddressType string                  `json:"addressType"`
+	Endpoints   []endpointSliceEndpoint `json:"endpoints"`
+	Ports       []endpointSlicePort     `json:"ports"`
+}
+
+type endpointSliceEndpoint struct {
+	Addresses  []string                `json:"addresses"`
+	Conditions endpointSliceConditions `json:"conditions"`
+	Hostname   *string                 `json:"hostname,omitempty"`
+	TargetRef  *apiv1.ObjectReference  `json:"targetRef,omitempty"`
+	Topology   map[string]string       `json:"topology,omitempty"`
+	Zone       *string                 `json:"zone,omitempty"`
+}
+
+type endpointSliceConditions struct {
+	Ready       *bool `json:"ready,omitempty"`
+	Serving     *bool `json:"serving,omitempty"`
+	Terminating *bool `json:"terminating,omitempty"`
+}
+
+type endpointSlicePort struct {
+	Name        *string         `json:"name,omitempty"`
+	Protocol    *apiv1.Protocol `json:"protocol,omitempty"`
+	Port        *int32          `json:"port,omitempty"`
+	AppProtocol *string         `json:"appProtocol,omitempty"`
+}
+
+// EndpointSlice discovers new endpoint targets.
+type EndpointSlice struct {
+	logger log.Logger
+
+	endpointSliceInf cache.SharedInformer
+	serviceInf       cache.SharedInformer
+	podInf           cache.SharedInformer
+
+	podStore           cache.Store
+	endpointSliceStore cache.Store
+	serviceStore       cache.Store
+
+	queue *workqueue.Type
+}
+
+// NewEndpointSlice returns a new endpointslice discovery. The eps informer
+// must hold *unstructured.Unstructured EndpointSlice objects.
+func NewEndpointSlice(l log.Logger, svc, eps, pod cache.SharedInformer) *EndpointSlice {
+	if l == nil {
+		l = log.NewNopLogger()
+	}
+	e := &EndpointSlice{
+		logger:             l,
+		endpointSliceInf:   eps,
+		endpointSliceStore: eps.GetStore(),
+		serviceInf:         svc,
+		serviceStore:       svc.GetStore(),
+		podInf:             pod,
+		podStore:           pod.GetStore(),
+		queue:              workqueue.NewNamed("endpointSlice"),
+	}
+
+	e.endpointSliceInf.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(o interface{}) {
+			eventCount.WithLabelValues("endpointslice", "add").Inc()
+			e.enqueue(o)
+		},
+		UpdateFunc: func(_, o interface{}) {
+			eventCount.WithLabelValues("endpointslice", "update").Inc()
+			e.enqueue(o)
+		},
+		DeleteFunc: func(o interface{}) {
+			eventCount.WithLabelValues("endpointslice", "delete").Inc()
+			e.enqueue(o)
+		},
+	})
+
+	serviceUpdate := func(o interface{}) {
+		svc, err := convertToService(o)
+		if err != nil {
+			level.Error(e.logger).Log("msg", "converting to Service object failed", "err", err)
+			return
+		}
+
+		// A service may own several EndpointSlices, which are only linked
+		// to it by a label.
+		for _, obj := range e.endpointSliceStore.List() {
+			eps, err := convertToEndpointSlice(obj)
+			if err != nil {
+				level.Error(e.logger).Log("msg", "converting to EndpointSlice object failed", "err", err)
+				continue
+			}
+			if eps.Namespace == svc.Namespace && eps.Labels[endpointSliceServiceLabel] == svc.Name {
+				e.enqueue(obj)
+			}
+		}
+	}
+	e.serviceInf.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(o interface{}) {
+			eventCount.WithLabelValues("service", "add").Inc()
+			serviceUpdate(o)
+		},
+		UpdateFunc: func(_, o interface{}) {
+			eventCount.WithLabelValues("service", "update").Inc()
+			serviceUpdate(o)
+		},
+		DeleteFunc: func(o interface{}) {
+			eventCount.WithLabelValues("service", "delete").Inc()
+			serviceUpdate(o)
+		},
+	})
+
+	return e
+}
+
+func (e *EndpointSlice) enqueue(obj interface{}) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return
+	}
+
+	e.queue.Add(key)
+}
+
+// Run implements the Discoverer interface.
+func (e *EndpointSlice) Run(ctx context.Context, ch chan<- []*targetgroup.Group) {
+	defer e.queue.ShutDown()
+
+	if !cache.WaitForCacheSync(ctx.Done(), e.endpointSliceInf.HasSynced, e.serviceInf.HasSynced, e.podInf.HasSynced) {
+		level.Error(e.logger).Log("msg", "endpointslice informer unable to sync cache")
+		return
+	}
+
+	go func() {
+		for e.process(ctx, ch) {
+		}
+	}()
+
+	// Block until the target provider is explicitly canceled.
+	<-ctx.Done()
+}
+
+func (e *EndpointSlice) process(ctx context.Context, ch chan<- []*targetgroup.Group) bool {
+	keyObj, quit := e.queue.Get()
+	if quit {
+		return false
+	}
+	defer e.queue.Done(keyObj)
+	key := keyObj.(string)
+
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		level.Error(e.logger).Log("msg", "splitting key failed", "key", key)
+		return true
+	}
+
+	o, exists, err := e.endpointSliceStore.GetByKey(key)
+	if err != nil {
+		level.Error(e.logger).Log("msg", "getting object from store failed", "key", key)
+		return true
+	}
+	if !exists {
+		send(ctx, e.logger, RoleEndpointSlice, ch, &targetgroup.Group{Source: endpointSliceSourceFromNamespaceAndName(namespace, name)})
+		return true
+	}
+	eps, err := convertToEndpointSlice(o)
+	if err != nil {
+		level.Error(e.logger).Log("msg", "converting to EndpointSlice object failed", "err", err)
+		return true
+	}
+	send(ctx, e.logger, RoleEndpointSlice, ch, e.buildEndpointSlice(eps))
+	return true
+}
+
+func convertToEndpointSlice(o interface{}) (*endpointSlice, error) {
+	u, ok := o.(*unstructured.Unstructured)
+	if !ok {
+		return nil, fmt.Errorf("received unexpected object: %v", o)
+	}
+	var eps endpointSlice
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.UnstructuredContent(), &eps); err != nil {
+		return nil, err
+	}
+	return &eps, nil
+}
+
+func endpointSliceSource(ep *endpointSlice) string {
+	return endpointSliceSourceFromNamespaceAndName(ep.Namespace, ep.Name)
+}
+
+func endpointSliceSourceFromNamespaceAndName(namespace, name string) string {
+	return "endpointslice/" + namespace + "/" + name
+}
+
+const (
+	endpointSliceNameLabel                          = metaLabelPrefix + "endpointslice_name"
+	endpointSliceAddressTypeLabel                   = metaLabelPrefix + "endpointslice_address_type"
+	endpointSlicePortNameLabel                      = metaLabelPrefix + "endpointslice_port_name"
+	endpointSlicePortProtocolLabel                  = metaLabelPrefix + "endpointslice_port_protocol"
+	endpointSlicePortLabel                          = metaLabelPrefix + "endpointslice_port"
+	endpointSlicePortAppProtocolLabel               = metaLabelPrefix + "endpointslice_port_app_protocol"
+	endpointSliceEndpointConditionsReadyLabel       = metaLabelPrefix + "endpointslice_endpoint_conditions_ready"
+	endpointSliceEndpointConditionsServingLabel     = metaLabelPrefix + "endpointslice_endpoint_conditions_serving"
+	endpointSliceEndpointConditionsTerminatingLabel = metaLabelPrefix + "endpointslice_endpoint_conditions_terminating"
+	endpointSliceEndpointHostnameLabel              = metaLabelPrefix + "endpointslice_endpoint_hostname"
+	endpointSliceEndpointZoneLabel                  = metaLabelPrefix + "endpointslice_endpoint_zone"
+	endpointSliceAddressTargetKindLabel             = metaLabelPrefix + "endpointslice_address_target_kind"
+	endpointSliceAddressTargetNameLabel             = metaLabelPrefix + "endpointslice_address_target_name"
+	endpointSliceEndpointTopologyLabelPrefix        = metaLabelPrefix + "endpointslice_endpoint_topology_"
+	endpointSliceEndpointTopologyLabelPresentPrefix = metaLabelPrefix + "endpointslice_endpoint_topology_present_"
+)
+
+// zoneTopologyKey is the topology key holding the zone of an endpoint.
+const zoneTopologyKey = "topology.kubernetes.io/zone"
+
+func (e *EndpointSlice) buildEndpointSlice(eps *endpointSlice) *targetgroup.Group {
+	tg := &targetgroup.Group{
+		Source: endpointSliceSource(eps),
+	}
+	tg.Labels = model.LabelSet{
+		namespaceLabel:                lv(eps.Namespace),
+		endpointSliceNameLabel:        lv(eps.Name),
+		endpointSliceAddressTypeLabel: lv(eps.AddressType),
+	}
+	e.addServiceLabels(eps, tg)
+
+	type podEntry struct {
+		pod          *apiv1.Pod
+		servicePorts []endpointSlicePort
+	}
+	seenPods := map[string]*podEntry{}
+
+	add := func(addr string, ep endpointSliceEndpoint, port endpointSlicePort) {
+		a := addr
+		if port.Port != nil {
+			a = net.JoinHostPort(addr, strconv.FormatUint(uint64(*port.Port), 10))
+		}
+
+		target := model.LabelSet{
+			model.AddressLabel: lv(a),
+		}
+
+		if port.Name != nil {
+			target[endpointSlicePortNameLabel] = lv(*port.Name)
+		}
+		if port.Protocol != nil {
+			target[endpointSlicePortProtocolLabel] = lv(string(*port.Protocol))
+		}
+		if port.Port != nil {
+			target[endpointSlicePortLabel] = lv(strconv.FormatUint(uint64(*port.Port), 10))
+		}
+		if port.AppProtocol != nil {
+			target[endpointSlicePortAppProtocolLabel] = lv(*port.AppProtocol)
+		}
+
+		if ep.Conditions.Ready != nil {
+			target[endpointSliceEndpointConditionsReadyLabel] = lv(strconv.FormatBool(*ep.Conditions.Ready))
+		}
+		if ep.Conditions.Serving != nil {
+			target[endpointSliceEndpointConditionsServingLabel] = lv(strconv.FormatBool(*ep.Conditions.Serving))
+		}
+		if ep.Conditions.Terminating != nil {
+			target[endpointSliceEndpointConditionsTerminatingLabel] = lv(strconv.FormatBool(*ep.Conditions.Terminating))
+		}
+
+		if ep.Hostname != nil {
+			target[endpointSliceEndpointHostnameLabel] = lv(*ep.Hostname)
+		}
+
+		if ep.TargetRef != nil {
+			target[model.LabelName(endpointSliceAddressTargetKindLabel)] = lv(ep.TargetRef.Kind)
+			target[model.LabelName(endpointSliceAddressTargetNameLabel)] = lv(ep.TargetRef.Name)
+		}
+
+		for k, v := range ep.Topology {
+			ln := strutil.SanitizeLabelName(k)
+			target[model.LabelName(endpointSliceEndpointTopologyLabelPrefix+ln)] = lv(v)
+			target[model.LabelName(endpointSliceEndpointTopologyLabelPresentPrefix+ln)] = lv("true")
+		}
+		if ep.Zone != nil {
+			target[endpointSliceEndpointZoneLabel] = lv(*ep.Zone)
+		} else if zone, ok := ep.Topology[zoneTopologyKey]; ok {
+			target[endpointSliceEndpointZoneLabel] = lv(zone)
+		}
+
+		pod := e.resolvePodRef(ep.TargetRef)
+		if pod == nil {
+			// This target is not a Pod, so don't continue with Pod specific logic.
+			tg.Targets = append(tg.Targets, target)
+			return
+		}
+		s := pod.Namespace + "/" + pod.Name
+
+		sp, ok := seenPods[s]
+		if !ok {
+			sp = &podEntry{pod: pod}
+			seenPods[s] = sp
+		}
+
+		// Attach standard pod labels.
+		target = target.Merge(podLabels(pod))
+
+		// Attach potential container port labels matching the endpoint port.
+		for _, c := range pod.Spec.Containers {
+			for _, cport := range c.Ports {
+				if port.Port == nil {
+					continue
+				}
+				if *port.Port == cport.ContainerPort {
+					ports := strconv.FormatUint(uint64(*port.Port), 10)
+
+					target[podContainerNameLabel] = lv(c.Name)
+					target[podContainerPortNameLabel] = lv(cport.Name)
+					target[podContainerPortNumberLabel] = lv(ports)
+					target[podContainerPortProtocolLabel] = lv(string(cport.Protocol))
+					break
+				}
+			}
+		}
+
+		// Add service port so we know that we have already generated a target
+		// for it.
+		sp.servicePorts = append(sp.servicePorts, port)
+		tg.Targets = append(tg.Targets, target)
+	}
+
+	for _, ep := range eps.Endpoints {
+		for _, port := range eps.Ports {
+			for _, addr := range ep.Addresses {
+				add(addr, ep, port)
+			}
+		}
+	}
+
+	// For all seen pods, check all container ports. If they were not covered
+	// by one of the service endpoints, generate targets for them.
+	for _, pe := range seenPods {
+		for _, c := range pe.pod.Spec.Containers {
+			for _, cport := range c.Ports {
+				hasSeenPort := func() bool {
+					for _, eport := range pe.servicePorts {
+						if eport.Port != nil && cport.ContainerPort == *eport.Port {
+							return true
+						}
+					}
+					return false
+				}
+				if hasSeenPort() {
+					continue
+				}
+
+				a := net.JoinHostPort(pe.pod.Status.PodIP, strconv.FormatUint(uint64(cport.ContainerPort), 10))
+				ports := strconv.FormatUint(uint64(cport.ContainerPort), 10)
+
+				target := model.LabelSet{
+					model.AddressLabel:            lv(a),
+					podContainerNameLabel:         lv(c.Name),
+					podContainerPortNameLabel:     lv(cport.Name),
+					podContainerPortNumberLabel:   lv(ports),
+					podContainerPortProtocolLabel: lv(string(cport.Protocol)),
+				}
+				tg.Targets = append(tg.Targets, target.Merge(podLabels(pe.pod)))
+			}
+		}
+	}
+
+	return tg
+}
+
+func (e *EndpointSlice) resolvePodRef(ref *apiv1.ObjectReference) *apiv1.Pod {
+	if ref == nil || ref.Kind != "Pod" {
+		return nil
+	}
+	p := &apiv1.Pod{}
+	p.Namespace = ref.Namespace
+	p.Name = ref.Name
+
+	obj, exists, err := e.podStore.Get(p)
+	if err != nil {
+		level.Error(e.logger).Log("msg", "resolving pod ref failed", "err", err)
+		return nil
+	}
+	if !exists {
+		return nil
+	}
+	return obj.(*apiv1.Pod)
+}
+
+func (e *EndpointSlice) addServiceLabels(eps *endpointSlice, tg *targetgroup.Group) {
+	name, ok := eps.Labels[endpointSliceServiceLabel]
+	if !ok {
+		return
+	}
+	svc := &apiv1.Service{}
+	svc.Namespace = eps.Namespace
+	svc.Name = name
+
+	obj, exists, err := e.serviceStore.Get(svc)
+	if err != nil {
+		level.Error(e.logger).Log("msg", "retrieving service failed", "err", err)
+		return
+	}
+	if !exists {
+		return
+	}
+	svc = obj.(*apiv1.Service)
+
+	tg.Labels = tg.Labels.Merge(serviceLabels(svc))
+}
@@ -0,0 +1,312 @@
+// Copyright 2019 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/discovery/targetgroup"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func makeEndpointSlice() *unstructured.Unstructured {
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "discovery.k8s.io/v1beta1",
+			"kind":       "EndpointSlice",
+			"metadata": map[string]interface{}{
+				"name":      "testendpoints",
+				"namespace": "default",
+				"labels": map[string]interface{}{
+					"kubernetes.io/service-name": "testendpoints",
+				},
+			},
+			"addressType": "IPv4",
+			"endpoints": []interface{}{
+				map[string]interface{}{
+					"addresses": []interface{}{"1.2.3.4"},
+					"conditions": map[string]interface{}{
+						"ready": true,
+					},
+					"hostname": "testendpoint1",
+					"topology": map[string]interface{}{
+						"topology.kubernetes.io/zone": "us-east-1a",
+					},
+				},
+				map[string]interface{}{
+					"addresses": []interface{}{"2.3.4.5"},
+					"conditions": map[string]interface{}{
+						"ready":       true,
+						"serving":     true,
+						"terminating": false,
+					},
+				},
+				map[string]interface{}{
+					"addresses": []interface{}{"3.4.5.6"},
+					"conditions": map[string]interface{}{
+						"ready":       false,
+						"serving":     true,
+						"terminating": true,
+					},
+				},
+			},
+			"ports": []interface{}{
+				map[string]interface{}{
+					"name":        "testport",
+					"port":        int64(9000),
+					"protocol":    "TCP",
+					"appProtocol": "http",
+				},
+			},
+		},
+	}
+}
+
+func makeEndpointSliceTargets() []model.LabelSet {
+	return []model.LabelSet{
+		{
+			"__address__": "1.2.3.4:9000",
+			"__meta_kubernetes_endpointslice_endpoint_conditions_ready":                             "true",
+			"__meta_kubernetes_endpointslice_endpoint_hostname":                                     "testendpoint1",
+			"__meta_kubernetes_endpointslice_endpoint_topology_topology_kubernetes_io_zone":         "us-east-1a",
+			"__meta_kubernetes_endpointslice_endpoint_topology_present_topology_kubernetes_io_zone": "true",
+			"__meta_kubernetes_endpointslice_endpoint_zone":                                         "us-east-1a",
+			"__meta_kubernetes_endpointslice_port":                                                  "9000",
+			"__meta_kubernetes_endpointslice_port_app_protocol":                                     "http",
+			"__meta_kubernetes_endpointslice_port_name":                                             "testport",
+			"__meta_kubernetes_endpointslice_port_protocol":                                         "TCP",
+		},
+		{
+			"__address__": "2.3.4.5:9000",
+			"__meta_kubernetes_endpointslice_endpoint_conditions_ready":       "true",
+			"__meta_kubernetes_endpointslice_endpoint_conditions_serving":     "true",
+			"__meta_kubernetes_endpointslice_endpoint_conditions_terminating": "false",
+			"__meta_kubernetes_endpointslice_port":                            "9000",
+			"__meta_kubernetes_endpointslice_port_app_protocol":               "http",
+			"__meta_kubernetes_endpointslice_port_name":                       "testport",
+			"__meta_kubernetes_endpointslice_port_protocol":                   "TCP",
+		},
+		{
+			"__address__": "3.4.5.6:9000",
+			"__meta_kubernetes_endpointslice_endpoint_conditions_ready":       "false",
+			"__meta_kubernetes_endpointslice_endpoint_conditions_serving":     "true",
+			"__meta_kubernetes_endpointslice_endpoint_conditions_terminating": "true",
+			"__meta_kubernetes_endpointslice_port":                            "9000",
+			"__meta_kubernetes_endpointslice_port_app_protocol":               "http",
+			"__meta_kubernetes_endpointslice_port_name":                       "testport",
+			"__meta_kubernetes_endpointslice_port_protocol":                   "TCP",
+		},
+	}
+}
+
+func TestEndpointSliceDiscoveryBeforeRun(t *testing.T) {
+	n, _, _ := makeDiscovery(RoleEndpointSlice, NamespaceDiscovery{}, makeEndpointSlice())
+
+	k8sDiscoveryTest{
+		discovery:        n,
+		expectedMaxItems: 1,
+		expectedRes: map[string]*targetgroup.Group{
+			"endpointslice/default/testendpoints": {
+				Targets: makeEndpointSliceTargets(),
+				Labels: model.LabelSet{
+					"__meta_kubernetes_endpointslice_address_type": "IPv4",
+					"__meta_kubernetes_endpointslice_name":         "testendpoints",
+					"__meta_kubernetes_namespace":                  "default",
+				},
+				Source: "endpointslice/default/testendpoints",
+			},
+		},
+	}.Run(t)
+}
+
+func TestEndpointSliceDiscoveryAdd(t *testing.T) {
+	obj := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "testpod",
+			Namespace: "default",
+			UID:       types.UID("deadbeef"),
+		},
+		Spec: v1.PodSpec{
+			NodeName: "testnode",
+			Containers: []v1.Container{
+				{
+					Name: "c1",
+					Ports: []v1.ContainerPort{
+						{
+							Name:          "mainport",
+							ContainerPort: 9000,
+							Protocol:      v1.ProtocolTCP,
+						},
+					},
+				},
+				{
+					Name: "c2",
+					Ports: []v1.ContainerPort{
+						{
+							Name:          "sideport",
+							ContainerPort: 9001,
+							Protocol:      v1.ProtocolTCP,
+						},
+					},
+				},
+			},
+		},
+		Status: v1.PodStatus{
+			HostIP: "2.3.4.5",
+			PodIP:  "1.2.3.4",
+		},
+	}
+	n, _, w := makeDiscovery(RoleEndpointSlice, NamespaceDiscovery{}, obj)
+
+	k8sDiscoveryTest{
+		discovery: n,
+		afterStart: func() {
+			obj := &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"apiVersion": "discovery.k8s.io/v1beta1",
+					"kind":       "EndpointSlice",
+					"metadata": map[string]interface{}{
+						"name":      "testendpoints",
+						"namespace": "default",
+					},
+					"addressType": "IPv4",
+					"endpoints": []interface{}{
+						map[string]interface{}{
+							"addresses": []interface{}{"4.3.2.1"},
+							"targetRef": map[string]interface{}{
+								"kind":      "Pod",
+								"name":      "testpod",
+								"namespace": "default",
+							},
+							"conditions": map[string]interface{}{
+								"ready": false,
+							},
+						},
+					},
+					"ports": []interface{}{
+						map[string]interface{}{
+							"name":     "testport",
+							"port":     int64(9000),
+							"protocol": "TCP",
+						},
+					},
+				},
+			}
+			w.EndpointSlices().Add(obj)
+		},
+		expectedMaxItems: 1,
+		expectedRes: map[string]*targetgroup.Group{
+			"endpointslice/default/testendpoints": {
+				Targets: []model.LabelSet{
+					{
+						"__address__": "4.3.2.1:9000",
+						"__meta_kubernetes_endpointslice_address_target_kind":       "Pod",
+						"__meta_kubernetes_endpointslice_address_target_name":       "testpod",
+						"__meta_kubernetes_endpointslice_endpoint_conditions_ready": "false",
+						"__meta_kubernetes_endpointslice_port":                      "9000",
+						"__meta_kubernetes_endpointslice_port_name":                 "testport",
+						"__meta_kubernetes_endpointslice_port_protocol":             "TCP",
+						"__meta_kubernetes_pod_container_name":                      "c1",
+						"__meta_kubernetes_pod_container_port_name":                 "mainport",
+						"__meta_kubernetes_pod_container_port_number":               "9000",
+						"__meta_kubernetes_pod_container_port_protocol":             "TCP",
+						"__meta_kubernetes_pod_host_ip":                             "2.3.4.5",
+						"__meta_kubernetes_pod_ip":                                  "1.2.3.4",
+						"__meta_kubernetes_pod_name":                                "testpod",
+						"__meta_kubernetes_pod_node_name":                           "testnode",
+						"__meta_kubernetes_pod_phase":                               "",
+						"__meta_kubernetes_pod_ready":                               "unknown",
+						"__meta_kubernetes_pod_uid":                                 "deadbeef",
+					},
+					{
+						"__address__":                                   "1.2.3.4:9001",
+						"__meta_kubernetes_pod_container_name":          "c2",
+						"__meta_kubernetes_pod_container_port_name":     "sideport",
+						"__meta_kubernetes_pod_container_port_number":   "9001",
+						"__meta_kubernetes_pod_container_port_protocol": "TCP",
+						"__meta_kubernetes_pod_host_ip":                 "2.3.4.5",
+						"__meta_kubernetes_pod_ip":                      "1.2.3.4",
+						"__meta_kubernetes_pod_name":                    "testpod",
+						"__meta_kubernetes_pod_node_name":               "testnode",
+						"__meta_kubernetes_pod_phase":                   "",
+						"__meta_kubernetes_pod_ready":                   "unknown",
+						"__meta_kubernetes_pod_uid":                     "deadbeef",
+					},
+				},
+				Labels: model.LabelSet{
+					"__meta_kubernetes_endpointslice_address_type": "IPv4",
+					"__meta_kubernetes_endpointslice_name":         "testendpoints",
+					"__meta_kubernetes_namespace":                  "default",
+				},
+				Source: "endpointslice/default/testendpoints",
+			},
+		},
+	}.Run(t)
+}
+
+func TestEndpointSliceDiscoveryDelete(t *testing.T) {
+	n, _, w := makeDiscovery(RoleEndpointSlice, NamespaceDiscovery{}, makeEndpointSlice())
+
+	k8sDiscoveryTest{
+		discovery: n,
+		afterStart: func() {
+			w.EndpointSlices().Delete(makeEndpointSlice())
+		},
+		expectedMaxItems: 2,
+		expectedRes: map[string]*targetgroup.Group{
+			"endpointslice/default/testendpoints": {
+				Source: "endpointslice/default/testendpoints",
+			},
+		},
+	}.Run(t)
+}
+
+func TestEndpointSliceDiscoveryWithService(t *testing.T) {
+	n, c, w := makeDiscovery(RoleEndpointSlice, NamespaceDiscovery{}, makeEndpointSlice())
+
+	k8sDiscoveryTest{
+		discovery: n,
+		beforeRun: func() {
+			obj := &v1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "testendpoints",
+					Namespace: "default",
+					Labels: map[string]string{
+						"app": "test",
+					},
+				},
+			}
+			c.CoreV1().Services(obj.Namespace).Create(obj)
+			w.Services().Add(obj)
+		},
+		expectedMaxItems: 1,
+		expectedRes: map[string]*targetgroup.Group{
+			"endpointslice/default/testendpoints": {
+				Targets: makeEndpointSliceTargets(),
+				Labels: model.LabelSet{
+					"__meta_kubernetes_endpointslice_address_type": "IPv4",
+					"__meta_kubernetes_endpointslice_name":         "testendpoints",
+					"__meta_kubernetes_namespace":                  "default",
+					"__meta_kubernetes_service_label_app":          "test",
+					"__meta_kubernetes_service_name":               "testendpoints",
+				},
+				Source: "endpointslice/default/testendpoints",
+			},
+		},
+	}.Run(t)
+}
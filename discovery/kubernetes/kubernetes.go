@@ -17,6 +17,7 @@ import (
 	"context"
 	"fmt"
 	"io/ioutil"
+	"strings"
 	"sync"
 	"time"
 
@@ -25,13 +26,18 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	config_util "github.com/prometheus/common/config"
 	"github.com/prometheus/common/model"
+	sd_config "github.com/prometheus/prometheus/discovery/config"
 	"github.com/prometheus/prometheus/discovery/targetgroup"
 
 	apiv1 "k8s.io/api/core/v1"
 	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
@@ -64,11 +70,12 @@ type Role string
 
 // The valid options for Role.
 const (
-	RoleNode     Role = "node"
-	RolePod      Role = "pod"
-	RoleService  Role = "service"
-	RoleEndpoint Role = "endpoints"
-	RoleIngress  Role = "ingress"
+	RoleNode          Role = "node"
+	RolePod           Role = "pod"
+	RoleService       Role = "service"
+	RoleEndpoint      Role = "endpoints"
+	RoleEndpointSlice Role = "endpointslice"
+	RoleIngress       Role = "ingress"
 )
 
 // UnmarshalYAML implements the yaml.Unmarshaler interface.
@@ -77,7 +84,7 @@ func (c *Role) UnmarshalYAML(unmarshal func(interface{}) error) error {
 		return err
 	}
 	switch *c {
-	case RoleNode, RolePod, RoleService, RoleEndpoint, RoleIngress:
+	case RoleNode, RolePod, RoleService, RoleEndpoint, RoleEndpointSlice, RoleIngress:
 		return nil
 	default:
 		return fmt.Errorf("unknown Kubernetes SD role %q", *c)
@@ -93,6 +100,25 @@ type SDConfig struct {
 	BearerTokenFile    string                 `yaml:"bearer_token_file,omitempty"`
 	TLSConfig          config_util.TLSConfig  `yaml:"tls_config,omitempty"`
 	NamespaceDiscovery NamespaceDiscovery     `yaml:"namespaces,omitempty"`
+	Selectors          []SelectorConfig       `yaml:"selectors,omitempty"`
+}
+
+// SelectorConfig restricts the objects of a resource watched for a role
+// by label and field selectors.
+type SelectorConfig struct {
+	Role  Role   `yaml:"role,omitempty"`
+	Label string `yaml:"label,omitempty"`
+	Field string `yaml:"field,omitempty"`
+}
+
+// allowedSelectors holds the resources selectors may be set for per role.
+var allowedSelectors = map[Role][]string{
+	RolePod:           {string(RolePod)},
+	RoleService:       {string(RoleService)},
+	RoleEndpoint:      {string(RolePod), string(RoleService), string(RoleEndpoint)},
+	RoleEndpointSlice: {string(RolePod), string(RoleService), string(RoleEndpointSlice)},
+	RoleNode:          {string(RoleNode)},
+	RoleIngress:       {string(RoleIngress)},
 }
 
 // UnmarshalYAML implements the yaml.Unmarshaler interface.
@@ -104,7 +130,7 @@ func (c *SDConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
 		return err
 	}
 	if c.Role == "" {
-		return fmt.Errorf("role missing (one of: pod, service, endpoints, node, ingress)")
+		return fmt.Errorf("role missing (one of: pod, service, endpoints, endpointslice, node, ingress)")
 	}
 	if len(c.BearerToken) > 0 && len(c.BearerTokenFile) > 0 {
 		return fmt.Errorf("at most one of bearer_token & bearer_token_file must be configured")
@@ -117,13 +143,47 @@ func (c *SDConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
 			c.TLSConfig.CAFile != "" || c.TLSConfig.CertFile != "" || c.TLSConfig.KeyFile != "") {
 		return fmt.Errorf("to use custom authentication please provide the 'api_server' URL explicitly")
 	}
+
+	foundSelectorRoles := make(map[Role]struct{})
+	for _, selector := range c.Selectors {
+		if _, ok := foundSelectorRoles[selector.Role]; ok {
+			return fmt.Errorf("duplicated selector role: %s", selector.Role)
+		}
+		foundSelectorRoles[selector.Role] = struct{}{}
+
+		allowed := false
+		for _, role := range allowedSelectors[c.Role] {
+			if role == string(selector.Role) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("%s role supports only %s selectors", c.Role, strings.Join(allowedSelectors[c.Role], ", "))
+		}
+
+		if _, err := fields.ParseSelector(selector.Field); err != nil {
+			return err
+		}
+		if _, err := labels.Parse(selector.Label); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// SetDirectory joins the relative paths of the configuration with dir. It
+// implements the discovery/config.DirectorySetter interface.
+func (c *SDConfig) SetDirectory(dir string) {
+	c.BearerTokenFile = sd_config.JoinDir(dir, c.BearerTokenFile)
+	sd_config.SetTLSConfigDirectory(&c.TLSConfig, dir)
+}
+
 // NamespaceDiscovery is the configuration for discovering
 // Kubernetes namespaces.
 type NamespaceDiscovery struct {
-	Names []string `yaml:"names"`
+	IncludeOwnNamespace bool     `yaml:"own_namespace"`
+	Names               []string `yaml:"names"`
 }
 
 // UnmarshalYAML implements the yaml.Unmarshaler interface.
@@ -134,10 +194,13 @@ func (c *NamespaceDiscovery) UnmarshalYAML(unmarshal func(interface{}) error) er
 }
 
 func init() {
+	sd_config.RegisterSD("kubernetes_sd_configs", &SDConfig{}, func(cfg interface{}, logger log.Logger) (sd_config.Discoverer, error) {
+		return New(logger, cfg.(*SDConfig))
+	})
 	prometheus.MustRegister(eventCount)
 
 	// Initialize metric vectors.
-	for _, role := range []string{"endpoints", "node", "pod", "service", "ingress"} {
+	for _, role := range []string{"endpointslice", "endpoints", "node", "pod", "service", "ingress"} {
 		for _, evt := range []string{"add", "delete", "update"} {
 			eventCount.WithLabelValues(role, evt)
 		}
@@ -165,20 +228,72 @@ type discoverer interface {
 type Discovery struct {
 	sync.RWMutex
 	client             kubernetes.Interface
+	dynamicClient      dynamic.Interface
 	role               Role
 	logger             log.Logger
 	namespaceDiscovery *NamespaceDiscovery
+	ownNamespace       string
+	selectors          roleSelector
 	discoverers        []discoverer
 }
 
 func (d *Discovery) getNamespaces() []string {
 	namespaces := d.namespaceDiscovery.Names
-	if len(namespaces) == 0 {
-		namespaces = []string{apiv1.NamespaceAll}
+	if len(namespaces) == 0 && !d.namespaceDiscovery.IncludeOwnNamespace {
+		return []string{apiv1.NamespaceAll}
+	}
+	if d.namespaceDiscovery.IncludeOwnNamespace {
+		namespaces = append(append([]string{}, namespaces...), d.ownNamespace)
 	}
 	return namespaces
 }
 
+// ownNamespaceFile holds the namespace of the service account Prometheus
+// runs with inside a pod.
+const ownNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+type resourceSelector struct {
+	label string
+	field string
+}
+
+type roleSelector struct {
+	node          resourceSelector
+	pod           resourceSelector
+	service       resourceSelector
+	endpoints     resourceSelector
+	endpointslice resourceSelector
+	ingress       resourceSelector
+}
+
+func mapSelector(rawSelector []SelectorConfig) roleSelector {
+	rs := roleSelector{}
+	for _, resourceSelectorRaw := range rawSelector {
+		s := resourceSelector{label: resourceSelectorRaw.Label, field: resourceSelectorRaw.Field}
+		switch resourceSelectorRaw.Role {
+		case RoleEndpoint:
+			rs.endpoints = s
+		case RoleEndpointSlice:
+			rs.endpointslice = s
+		case RoleIngress:
+			rs.ingress = s
+		case RoleNode:
+			rs.node = s
+		case RolePod:
+			rs.pod = s
+		case RoleService:
+			rs.service = s
+		}
+	}
+	return rs
+}
+
+// apply restricts the list and watch options to the selected objects.
+func (s resourceSelector) apply(options *metav1.ListOptions) {
+	options.LabelSelector = s.label
+	options.FieldSelector = s.field
+}
+
 // New creates a new Kubernetes discovery for the given role.
 func New(l log.Logger, conf *SDConfig) (*Discovery, error) {
 	if l == nil {
@@ -244,11 +359,31 @@ func New(l log.Logger, conf *SDConfig) (*Discovery, error) {
 	if err != nil {
 		return nil, err
 	}
+	dc, err := dynamic.NewForConfig(kcfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var ownNamespace string
+	if conf.NamespaceDiscovery.IncludeOwnNamespace {
+		b, err := ioutil.ReadFile(ownNamespaceFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not determine the pod's namespace: %s", err)
+		}
+		ownNamespace = strings.TrimSpace(string(b))
+		if ownNamespace == "" {
+			return nil, fmt.Errorf("could not read own namespace name (empty file)")
+		}
+	}
+
 	return &Discovery{
 		client:             c,
+		dynamicClient:      dc,
 		logger:             l,
 		role:               conf.Role,
 		namespaceDiscovery: &conf.NamespaceDiscovery,
+		ownNamespace:       ownNamespace,
+		selectors:          mapSelector(conf.Selectors),
 		discoverers:        make([]discoverer, 0),
 	}, nil
 }
@@ -261,32 +396,84 @@ func (d *Discovery) Run(ctx context.Context, ch chan<- []*targetgroup.Group) {
 	namespaces := d.getNamespaces()
 
 	switch d.role {
+	case RoleEndpointSlice:
+		for _, namespace := range namespaces {
+			e := d.dynamicClient.Resource(endpointSliceGVR).Namespace(namespace)
+			elw := &cache.ListWatch{
+				ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+					d.selectors.endpointslice.apply(&options)
+					return e.List(options)
+				},
+				WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+					d.selectors.endpointslice.apply(&options)
+					return e.Watch(options)
+				},
+			}
+			s := d.client.CoreV1().Services(namespace)
+			slw := &cache.ListWatch{
+				ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+					d.selectors.service.apply(&options)
+					return s.List(options)
+				},
+				WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+					d.selectors.service.apply(&options)
+					return s.Watch(options)
+				},
+			}
+			p := d.client.CoreV1().Pods(namespace)
+			plw := &cache.ListWatch{
+				ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+					d.selectors.pod.apply(&options)
+					return p.List(options)
+				},
+				WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+					d.selectors.pod.apply(&options)
+					return p.Watch(options)
+				},
+			}
+			eps := NewEndpointSlice(
+				log.With(d.logger, "role", "endpointslice"),
+				cache.NewSharedInformer(slw, &apiv1.Service{}, resyncPeriod),
+				cache.NewSharedInformer(elw, &unstructured.Unstructured{}, resyncPeriod),
+				cache.NewSharedInformer(plw, &apiv1.Pod{}, resyncPeriod),
+			)
+			d.discoverers = append(d.discoverers, eps)
+			go eps.endpointSliceInf.Run(ctx.Done())
+			go eps.serviceInf.Run(ctx.Done())
+			go eps.podInf.Run(ctx.Done())
+		}
 	case RoleEndpoint:
 		for _, namespace := range namespaces {
 			e := d.client.CoreV1().Endpoints(namespace)
 			elw := &cache.ListWatch{
 				ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+					d.selectors.endpoints.apply(&options)
 					return e.List(options)
 				},
 				WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+					d.selectors.endpoints.apply(&options)
 					return e.Watch(options)
 				},
 			}
 			s := d.client.CoreV1().Services(namespace)
 			slw := &cache.ListWatch{
 				ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+					d.selectors.service.apply(&options)
 					return s.List(options)
 				},
 				WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+					d.selectors.service.apply(&options)
 					return s.Watch(options)
 				},
 			}
 			p := d.client.CoreV1().Pods(namespace)
 			plw := &cache.ListWatch{
 				ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+					d.selectors.pod.apply(&options)
 					return p.List(options)
 				},
 				WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+					d.selectors.pod.apply(&options)
 					return p.Watch(options)
 				},
 			}
@@ -306,9 +493,11 @@ func (d *Discovery) Run(ctx context.Context, ch chan<- []*targetgroup.Group) {
 			p := d.client.CoreV1().Pods(namespace)
 			plw := &cache.ListWatch{
 				ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+					d.selectors.pod.apply(&options)
 					return p.List(options)
 				},
 				WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+					d.selectors.pod.apply(&options)
 					return p.Watch(options)
 				},
 			}
@@ -324,9 +513,11 @@ func (d *Discovery) Run(ctx context.Context, ch chan<- []*targetgroup.Group) {
 			s := d.client.CoreV1().Services(namespace)
 			slw := &cache.ListWatch{
 				ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+					d.selectors.service.apply(&options)
 					return s.List(options)
 				},
 				WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+					d.selectors.service.apply(&options)
 					return s.Watch(options)
 				},
 			}
@@ -342,9 +533,11 @@ func (d *Discovery) Run(ctx context.Context, ch chan<- []*targetgroup.Group) {
 			i := d.client.ExtensionsV1beta1().Ingresses(namespace)
 			ilw := &cache.ListWatch{
 				ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+					d.selectors.ingress.apply(&options)
 					return i.List(options)
 				},
 				WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+					d.selectors.ingress.apply(&options)
 					return i.Watch(options)
 				},
 			}
@@ -358,9 +551,11 @@ func (d *Discovery) Run(ctx context.Context, ch chan<- []*targetgroup.Group) {
 	case RoleNode:
 		nlw := &cache.ListWatch{
 			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				d.selectors.node.apply(&options)
 				return d.client.CoreV1().Nodes().List(options)
 			},
 			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				d.selectors.node.apply(&options)
 				return d.client.CoreV1().Nodes().Watch(options)
 			},
 		}
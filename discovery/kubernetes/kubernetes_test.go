@@ -23,9 +23,11 @@ import (
 	"github.com/go-kit/kit/log"
 	"github.com/prometheus/prometheus/discovery/targetgroup"
 	"github.com/prometheus/prometheus/util/testutil"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/watch"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/fake"
 	k8stesting "k8s.io/client-go/testing"
@@ -62,6 +64,10 @@ func (wf *watcherFactory) Endpoints() *watch.FakeWatcher {
 	return wf.watchFor(schema.GroupVersionResource{Group: "", Version: "v1", Resource: "endpoints"})
 }
 
+func (wf *watcherFactory) EndpointSlices() *watch.FakeWatcher {
+	return wf.watchFor(endpointSliceGVR)
+}
+
 func (wf *watcherFactory) Services() *watch.FakeWatcher {
 	return wf.watchFor(schema.GroupVersionResource{Group: "", Version: "v1", Resource: "services"})
 }
@@ -71,8 +77,19 @@ func (wf *watcherFactory) Pods() *watch.FakeWatcher {
 }
 
 // makeDiscovery creates a kubernetes.Discovery instance for testing.
+// Unstructured objects are served by the dynamic client, all others by the
+// typed clientset.
 func makeDiscovery(role Role, nsDiscovery NamespaceDiscovery, objects ...runtime.Object) (*Discovery, kubernetes.Interface, *watcherFactory) {
-	clientset := fake.NewSimpleClientset(objects...)
+	var typed, dynamic []runtime.Object
+	for _, o := range objects {
+		if _, ok := o.(*unstructured.Unstructured); ok {
+			dynamic = append(dynamic, o)
+		} else {
+			typed = append(typed, o)
+		}
+	}
+	clientset := fake.NewSimpleClientset(typed...)
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), dynamic...)
 	// Current client-go we are using does not support push event on
 	// Add/Update/Create, so we need to emit event manually.
 	// See https://github.com/kubernetes/kubernetes/issues/54075.
@@ -80,12 +97,15 @@ func makeDiscovery(role Role, nsDiscovery NamespaceDiscovery, objects ...runtime
 	wf := &watcherFactory{
 		watchers: make(map[schema.GroupVersionResource]*watch.FakeWatcher),
 	}
-	clientset.PrependWatchReactor("*", func(action k8stesting.Action) (handled bool, ret watch.Interface, err error) {
+	watchReactor := func(action k8stesting.Action) (handled bool, ret watch.Interface, err error) {
 		gvr := action.GetResource()
 		return true, wf.watchFor(gvr), nil
-	})
+	}
+	clientset.PrependWatchReactor("*", watchReactor)
+	dynamicClient.PrependWatchReactor("*", watchReactor)
 	return &Discovery{
 		client:             clientset,
+		dynamicClient:      dynamicClient,
 		logger:             log.NewNopLogger(),
 		role:               role,
 		namespaceDiscovery: &nsDiscovery,
@@ -188,6 +208,16 @@ func requireTargetGroups(t *testing.T, expected, res map[string]*targetgroup.Gro
 	testutil.Equals(t, string(b1), string(b2))
 }
 
+func TestGetNamespaces(t *testing.T) {
+	n, _, _ := makeDiscovery(RolePod, NamespaceDiscovery{})
+	testutil.Equals(t, []string{""}, n.getNamespaces())
+
+	n, _, _ = makeDiscovery(RolePod, NamespaceDiscovery{Names: []string{"ns1"}, IncludeOwnNamespace: true})
+	n.ownNamespace = "own-ns"
+	testutil.Equals(t, []string{"ns1", "own-ns"}, n.getNamespaces())
+	testutil.Equals(t, []string{"ns1"}, n.namespaceDiscovery.Names)
+}
+
 type hasSynced interface {
 	// hasSynced returns true if all informers synced.
 	// This is only used in testing to determine when discoverer synced to
@@ -198,6 +228,7 @@ type hasSynced interface {
 var _ hasSynced = &Discovery{}
 var _ hasSynced = &Node{}
 var _ hasSynced = &Endpoints{}
+var _ hasSynced = &EndpointSlice{}
 var _ hasSynced = &Ingress{}
 var _ hasSynced = &Pod{}
 var _ hasSynced = &Service{}
@@ -223,6 +254,10 @@ func (e *Endpoints) hasSynced() bool {
 	return e.endpointsInf.HasSynced() && e.serviceInf.HasSynced() && e.podInf.HasSynced()
 }
 
+func (e *EndpointSlice) hasSynced() bool {
+	return e.endpointSliceInf.HasSynced() && e.serviceInf.HasSynced() && e.podInf.HasSynced()
+}
+
 func (i *Ingress) hasSynced() bool {
 	return i.informer.HasSynced()
 }
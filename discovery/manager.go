@@ -17,6 +17,7 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"sort"
 	"sync"
 	"time"
 
@@ -26,18 +27,6 @@ import (
 
 	sd_config "github.com/prometheus/prometheus/discovery/config"
 	"github.com/prometheus/prometheus/discovery/targetgroup"
-
-	"github.com/prometheus/prometheus/discovery/azure"
-	"github.com/prometheus/prometheus/discovery/consul"
-	"github.com/prometheus/prometheus/discovery/dns"
-	"github.com/prometheus/prometheus/discovery/ec2"
-	"github.com/prometheus/prometheus/discovery/file"
-	"github.com/prometheus/prometheus/discovery/gce"
-	"github.com/prometheus/prometheus/discovery/kubernetes"
-	"github.com/prometheus/prometheus/discovery/marathon"
-	"github.com/prometheus/prometheus/discovery/openstack"
-	"github.com/prometheus/prometheus/discovery/triton"
-	"github.com/prometheus/prometheus/discovery/zookeeper"
 )
 
 var (
@@ -76,10 +65,39 @@ var (
 		},
 		[]string{"name"},
 	)
+	providerLastUpdate = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "prometheus_sd_provider_last_update_timestamp_seconds",
+			Help: "Timestamp of the last update received from the SD provider.",
+		},
+		[]string{"name", "provider"},
+	)
+	providerFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "prometheus_sd_provider_refresh_failures_total",
+			Help: "Total number of refresh failures reported by the SD provider.",
+		},
+		[]string{"name", "provider"},
+	)
+	providerTargetGroups = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "prometheus_sd_provider_target_groups",
+			Help: "Current number of target groups discovered by the SD provider.",
+		},
+		[]string{"name", "provider"},
+	)
+	providerTargets = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "prometheus_sd_provider_targets",
+			Help: "Current number of targets discovered by the SD provider.",
+		},
+		[]string{"name", "provider"},
+	)
 )
 
 func init() {
 	prometheus.MustRegister(failedConfigs, discoveredTargets, receivedUpdates, delayedUpdates, sentUpdates)
+	prometheus.MustRegister(providerLastUpdate, providerFailures, providerTargetGroups, providerTargets)
 }
 
 // Discoverer provides information about target groups. It maintains a set
@@ -98,6 +116,16 @@ type Discoverer interface {
 	Run(ctx context.Context, up chan<- []*targetgroup.Group)
 }
 
+// ErrorReporter is implemented by Discoverers which report failed refreshes.
+// The manager sets the error handler before running the Discoverer.
+type ErrorReporter interface {
+	// SetErrorHandler sets the function called with the outcome of every
+	// refresh. Discoverers refreshing parts of their targets independently,
+	// e.g. one service or file at a time, pass the refreshed unit. The error
+	// is nil if the refresh succeeded.
+	SetErrorHandler(func(unit string, err error))
+}
+
 type poolKey struct {
 	setName  string
 	provider string
@@ -109,6 +137,41 @@ type provider struct {
 	d      Discoverer
 	subs   []string
 	config interface{}
+	// cacheKey identifies the provider's target groups in the cache.
+	cacheKey string
+
+	// The fields below are protected by the manager's lock.
+	lastUpdate    time.Time
+	lastErrorTime time.Time
+	// failures holds the last error of each refresh unit which failed
+	// since its last successful refresh.
+	failures map[string]refreshFailure
+	// refreshed is set once the provider refreshed successfully.
+	refreshed bool
+}
+
+type refreshFailure struct {
+	err  error
+	time time.Time
+}
+
+// ProviderStatus describes the state of a discovery provider.
+type ProviderStatus struct {
+	// Name is the name of the provider.
+	Name string
+	// Subs are the names of the target sets using the provider.
+	Subs []string
+	// LastUpdate is the time of the last update received from the provider.
+	LastUpdate time.Time
+	// LastError is the error of the last failed refresh of the refresh
+	// units which didn't succeed since.
+	LastError error
+	// LastErrorTime is the time of the last failed refresh.
+	LastErrorTime time.Time
+	// TargetGroups and Targets are the number of discovered target groups
+	// and targets.
+	TargetGroups int
+	Targets      int
 }
 
 // NewManager is the Discovery Manager constructor.
@@ -120,6 +183,7 @@ func NewManager(ctx context.Context, logger log.Logger, options ...func(*Manager
 		logger:         logger,
 		syncCh:         make(chan map[string][]*targetgroup.Group),
 		targets:        make(map[poolKey]map[string]*targetgroup.Group),
+		stale:          make(map[poolKey]map[string]struct{}),
 		discoverCancel: []context.CancelFunc{},
 		ctx:            ctx,
 		updatert:       5 * time.Second,
@@ -128,6 +192,13 @@ func NewManager(ctx context.Context, logger log.Logger, options ...func(*Manager
 	for _, option := range options {
 		option(mgr)
 	}
+	if mgr.cacheFile != "" {
+		cache, err := loadCache(mgr.cacheFile)
+		if err != nil {
+			level.Error(logger).Log("msg", "Failed to load cached target groups", "file", mgr.cacheFile, "err", err)
+		}
+		mgr.cache = cache
+	}
 	return mgr
 }
 
@@ -140,6 +211,18 @@ func Name(n string) func(*Manager) {
 	}
 }
 
+// CacheFile makes the manager persist the target groups of its providers to
+// the given file. On startup, each persisted target group is sent until the
+// provider it belongs to sends a group with the same source, or sends an
+// update after its first successful refresh.
+func CacheFile(filename string) func(*Manager) {
+	return func(m *Manager) {
+		m.mtx.Lock()
+		defer m.mtx.Unlock()
+		m.cacheFile = filename
+	}
+}
+
 // Manager maintains a set of discovery providers and sends each update to a map channel.
 // Targets are grouped by the target set name.
 type Manager struct {
@@ -163,6 +246,19 @@ type Manager struct {
 
 	// The triggerSend channel signals to the manager that new updates have been received from providers.
 	triggerSend chan struct{}
+
+	// cacheFile is the file the target groups are persisted to. Caching
+	// is disabled if it is empty.
+	cacheFile string
+	// cache holds the last target groups of each provider by cache key.
+	cache      map[string][]*targetgroup.Group
+	cacheDirty bool
+	// stale holds the sources of the target groups restored from the cache
+	// by pool, until their provider sends them again.
+	stale map[poolKey]map[string]struct{}
+	// cacheRestored is set once the cached target groups were restored by
+	// the first configuration. Later configurations don't restore them.
+	cacheRestored bool
 }
 
 // Run starts the background processing
@@ -195,6 +291,10 @@ func (m *Manager) ApplyConfig(cfg map[string]sd_config.ServiceDiscoveryConfig) e
 		m.registerProviders(scfg, name)
 		discoveredTargets.WithLabelValues(m.name, name).Set(0)
 	}
+	if !m.cacheRestored {
+		m.restoreCachedGroups()
+		m.cacheRestored = true
+	}
 	for _, prov := range m.providers {
 		m.startProvider(m.ctx, prov)
 	}
@@ -202,6 +302,33 @@ func (m *Manager) ApplyConfig(cfg map[string]sd_config.ServiceDiscoveryConfig) e
 	return nil
 }
 
+// restoreCachedGroups sets the cached target groups of all providers as
+// stale targets. It must be called with the lock held.
+func (m *Manager) restoreCachedGroups() {
+	var restored bool
+	for _, p := range m.providers {
+		tgs, ok := m.cache[p.cacheKey]
+		if !ok || p.cacheKey == "" {
+			continue
+		}
+		for _, s := range p.subs {
+			pk := poolKey{setName: s, provider: p.name}
+			m.targets[pk] = staleGroups(tgs)
+			m.stale[pk] = make(map[string]struct{}, len(tgs))
+			for _, tg := range tgs {
+				m.stale[pk][tg.Source] = struct{}{}
+			}
+		}
+		restored = true
+	}
+	if restored {
+		select {
+		case m.triggerSend <- struct{}{}:
+		default:
+		}
+	}
+}
+
 // StartCustomProvider is used for sdtool. Only use this if you know what you're doing.
 func (m *Manager) StartCustomProvider(ctx context.Context, name string, worker Discoverer) {
 	p := &provider{
@@ -220,6 +347,15 @@ func (m *Manager) startProvider(ctx context.Context, p *provider) {
 
 	m.discoverCancel = append(m.discoverCancel, cancel)
 
+	if r, ok := p.d.(ErrorReporter); ok {
+		r.SetErrorHandler(func(unit string, err error) {
+			// Ignore errors of providers replaced by a config reload.
+			if ctx.Err() == nil {
+				m.providerRefreshed(p, unit, err)
+			}
+		})
+	}
+
 	go p.d.Run(ctx, updates)
 	go m.updater(ctx, p, updates)
 }
@@ -239,6 +375,9 @@ func (m *Manager) updater(ctx context.Context, p *provider, updates chan []*targ
 			for _, s := range p.subs {
 				m.updateGroup(poolKey{setName: s, provider: p.name}, tgs)
 			}
+			m.dropStaleGroups(p)
+			m.updateCache(p)
+			m.providerUpdated(p)
 
 			select {
 			case m.triggerSend <- struct{}{}:
@@ -272,15 +411,142 @@ func (m *Manager) sender() {
 				}
 			default:
 			}
+			m.writeCache()
 		}
 	}
 }
 
+// providerUpdated records a successful update of the provider.
+func (m *Manager) providerUpdated(p *provider) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	p.lastUpdate = time.Now()
+	providerLastUpdate.WithLabelValues(m.name, p.name).Set(float64(p.lastUpdate.UnixNano()) / 1e9)
+
+	groups, targets := m.countTargets(p)
+	providerTargetGroups.WithLabelValues(m.name, p.name).Set(float64(groups))
+	providerTargets.WithLabelValues(m.name, p.name).Set(float64(targets))
+}
+
+// providerRefreshed records the outcome of a refresh of a unit of the
+// provider.
+func (m *Manager) providerRefreshed(p *provider, unit string, err error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if err == nil {
+		delete(p.failures, unit)
+		p.refreshed = true
+		return
+	}
+	if p.failures == nil {
+		p.failures = map[string]refreshFailure{}
+	}
+	p.lastErrorTime = time.Now()
+	p.failures[unit] = refreshFailure{err: err, time: p.lastErrorTime}
+	providerFailures.WithLabelValues(m.name, p.name).Inc()
+}
+
+// lastError returns the error of the last failed refresh of the units of
+// the provider which are still failing. It must be called with the lock held.
+func (p *provider) lastError() error {
+	var last refreshFailure
+	for _, f := range p.failures {
+		if last.err == nil || f.time.After(last.time) {
+			last = f
+		}
+	}
+	return last.err
+}
+
+// countTargets returns the number of target groups and targets of the
+// provider. It must be called with the lock held.
+func (m *Manager) countTargets(p *provider) (groups, targets int) {
+	if len(p.subs) == 0 {
+		return 0, 0
+	}
+	// All subscribers share the target groups of the provider.
+	for _, tg := range m.targets[poolKey{setName: p.subs[0], provider: p.name}] {
+		groups++
+		targets += len(tg.Targets)
+	}
+	return groups, targets
+}
+
+// ProviderStatus returns the status of all providers sorted by name.
+func (m *Manager) ProviderStatus() []ProviderStatus {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+
+	res := make([]ProviderStatus, 0, len(m.providers))
+	for _, p := range m.providers {
+		st := ProviderStatus{
+			Name:          p.name,
+			Subs:          append([]string(nil), p.subs...),
+			LastUpdate:    p.lastUpdate,
+			LastError:     p.lastError(),
+			LastErrorTime: p.lastErrorTime,
+		}
+		st.TargetGroups, st.Targets = m.countTargets(p)
+		res = append(res, st)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res
+}
+
+// updateCache stores the current target groups of the provider in the cache.
+func (m *Manager) updateCache(p *provider) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if m.cacheFile == "" || p.cacheKey == "" || len(p.subs) == 0 {
+		return
+	}
+	// All subscribers share the target groups of the provider.
+	tsets := m.targets[poolKey{setName: p.subs[0], provider: p.name}]
+	tgs := make([]*targetgroup.Group, 0, len(tsets))
+	for _, tg := range tsets {
+		tgs = append(tgs, tg)
+	}
+	m.cache[p.cacheKey] = tgs
+	m.cacheDirty = true
+}
+
+// writeCache persists the cached target groups of the current providers
+// if they changed since the last write.
+func (m *Manager) writeCache() {
+	m.mtx.Lock()
+	if !m.cacheDirty {
+		m.mtx.Unlock()
+		return
+	}
+	cache := make(map[string][]*targetgroup.Group, len(m.providers))
+	for _, p := range m.providers {
+		if tgs, ok := m.cache[p.cacheKey]; ok && p.cacheKey != "" {
+			cache[p.cacheKey] = tgs
+		}
+	}
+	m.cacheDirty = false
+	m.mtx.Unlock()
+
+	if err := writeCache(m.cacheFile, cache); err != nil {
+		level.Error(m.logger).Log("msg", "Failed to persist target groups", "file", m.cacheFile, "err", err)
+	}
+}
+
 func (m *Manager) cancelDiscoverers() {
 	for _, c := range m.discoverCancel {
 		c()
 	}
+	for _, p := range m.providers {
+		providerLastUpdate.DeleteLabelValues(m.name, p.name)
+		providerFailures.DeleteLabelValues(m.name, p.name)
+		providerTargetGroups.DeleteLabelValues(m.name, p.name)
+		providerTargets.DeleteLabelValues(m.name, p.name)
+	}
 	m.targets = make(map[poolKey]map[string]*targetgroup.Group)
+	m.stale = make(map[poolKey]map[string]struct{})
 	m.providers = nil
 	m.discoverCancel = nil
 }
@@ -295,10 +561,41 @@ func (m *Manager) updateGroup(poolKey poolKey, tgs []*targetgroup.Group) {
 				m.targets[poolKey] = make(map[string]*targetgroup.Group)
 			}
 			m.targets[poolKey][tg.Source] = tg
+
+			// The group replaces the one restored from the cache.
+			if stale, ok := m.stale[poolKey]; ok {
+				delete(stale, tg.Source)
+				if len(stale) == 0 {
+					delete(m.stale, poolKey)
+				}
+			}
 		}
 	}
 }
 
+// dropStaleGroups removes the target groups restored from the cache which
+// the provider didn't send again, once it refreshed successfully. It is
+// called after each update of the provider. Providers which don't report
+// their refreshes refreshed successfully once they sent an update.
+func (m *Manager) dropStaleGroups(p *provider) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if _, ok := p.d.(ErrorReporter); !ok {
+		p.refreshed = true
+	}
+	if !p.refreshed {
+		return
+	}
+	for _, s := range p.subs {
+		pk := poolKey{setName: s, provider: p.name}
+		for source := range m.stale[pk] {
+			delete(m.targets[pk], source)
+		}
+		delete(m.stale, pk)
+	}
+}
+
 func (m *Manager) allGroups() map[string][]*targetgroup.Group {
 	m.mtx.Lock()
 	defer m.mtx.Unlock()
@@ -317,6 +614,28 @@ func (m *Manager) allGroups() map[string][]*targetgroup.Group {
 	return tSets
 }
 
+// DiscoveredGroups returns the target groups last received from each provider
+// before any relabeling, keyed by target set name and provider name. The groups
+// of a provider are sorted by source.
+func (m *Manager) DiscoveredGroups() map[string]map[string][]*targetgroup.Group {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+
+	res := map[string]map[string][]*targetgroup.Group{}
+	for pkey, tsets := range m.targets {
+		if _, ok := res[pkey.setName]; !ok {
+			res[pkey.setName] = map[string][]*targetgroup.Group{}
+		}
+		tgs := make([]*targetgroup.Group, 0, len(tsets))
+		for _, tg := range tsets {
+			tgs = append(tgs, tg)
+		}
+		sort.Slice(tgs, func(i, j int) bool { return tgs[i].Source < tgs[j].Source })
+		res[pkey.setName][pkey.provider] = tgs
+	}
+	return res
+}
+
 func (m *Manager) registerProviders(cfg sd_config.ServiceDiscoveryConfig, setName string) {
 	var added bool
 	add := func(cfg interface{}, newDiscoverer func() (Discoverer, error)) {
@@ -342,68 +661,21 @@ func (m *Manager) registerProviders(cfg sd_config.ServiceDiscoveryConfig, setNam
 			config: cfg,
 			subs:   []string{setName},
 		}
+		if m.cacheFile != "" {
+			key, err := providerCacheKey(t, cfg)
+			if err != nil {
+				level.Warn(m.logger).Log("msg", "Cannot cache target groups of service discovery", "err", err, "type", t)
+			}
+			provider.cacheKey = key
+		}
 		m.providers = append(m.providers, &provider)
 		added = true
 	}
 
-	for _, c := range cfg.DNSSDConfigs {
-		add(c, func() (Discoverer, error) {
-			return dns.NewDiscovery(*c, log.With(m.logger, "discovery", "dns")), nil
-		})
-	}
-	for _, c := range cfg.FileSDConfigs {
-		add(c, func() (Discoverer, error) {
-			return file.NewDiscovery(c, log.With(m.logger, "discovery", "file")), nil
-		})
-	}
-	for _, c := range cfg.ConsulSDConfigs {
-		add(c, func() (Discoverer, error) {
-			return consul.NewDiscovery(c, log.With(m.logger, "discovery", "consul"))
-		})
-	}
-	for _, c := range cfg.MarathonSDConfigs {
-		add(c, func() (Discoverer, error) {
-			return marathon.NewDiscovery(*c, log.With(m.logger, "discovery", "marathon"))
-		})
-	}
-	for _, c := range cfg.KubernetesSDConfigs {
-		add(c, func() (Discoverer, error) {
-			return kubernetes.New(log.With(m.logger, "discovery", "k8s"), c)
-		})
-	}
-	for _, c := range cfg.ServersetSDConfigs {
-		add(c, func() (Discoverer, error) {
-			return zookeeper.NewServersetDiscovery(c, log.With(m.logger, "discovery", "zookeeper"))
-		})
-	}
-	for _, c := range cfg.NerveSDConfigs {
-		add(c, func() (Discoverer, error) {
-			return zookeeper.NewNerveDiscovery(c, log.With(m.logger, "discovery", "nerve"))
-		})
-	}
-	for _, c := range cfg.EC2SDConfigs {
-		add(c, func() (Discoverer, error) {
-			return ec2.NewDiscovery(c, log.With(m.logger, "discovery", "ec2")), nil
-		})
-	}
-	for _, c := range cfg.OpenstackSDConfigs {
-		add(c, func() (Discoverer, error) {
-			return openstack.NewDiscovery(c, log.With(m.logger, "discovery", "openstack"))
-		})
-	}
-	for _, c := range cfg.GCESDConfigs {
-		add(c, func() (Discoverer, error) {
-			return gce.NewDiscovery(*c, log.With(m.logger, "discovery", "gce"))
-		})
-	}
-	for _, c := range cfg.AzureSDConfigs {
-		add(c, func() (Discoverer, error) {
-			return azure.NewDiscovery(c, log.With(m.logger, "discovery", "azure")), nil
-		})
-	}
-	for _, c := range cfg.TritonSDConfigs {
+	for _, c := range cfg.Configs {
+		c := c
 		add(c, func() (Discoverer, error) {
-			return triton.New(log.With(m.logger, "discovery", "triton"), c)
+			return sd_config.NewDiscoverer(c, m.logger)
 		})
 	}
 	if len(cfg.StaticConfigs) > 0 {
@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"reflect"
 	"sort"
 	"strconv"
@@ -25,9 +26,12 @@ import (
 	"time"
 
 	"github.com/go-kit/kit/log"
+	"github.com/pkg/errors"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/config"
 	sd_config "github.com/prometheus/prometheus/discovery/config"
+	"github.com/prometheus/prometheus/discovery/file"
+	"github.com/prometheus/prometheus/discovery/refresh"
 	"github.com/prometheus/prometheus/discovery/targetgroup"
 	"gopkg.in/yaml.v2"
 )
@@ -909,6 +913,49 @@ scrape_configs:
 	}
 }
 
+func TestDiscoveredGroups(t *testing.T) {
+	cfgText := `
+scrape_configs:
+ - job_name: 'prometheus'
+   static_configs:
+   - targets: ["foo:9090"]
+   - targets: ["bar:9090"]
+`
+	cfg := &config.Config{}
+	if err := yaml.UnmarshalStrict([]byte(cfgText), cfg); err != nil {
+		t.Fatalf("Unable to load YAML config cfgYaml: %s", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	discoveryManager := NewManager(ctx, log.NewNopLogger())
+	discoveryManager.updatert = 100 * time.Millisecond
+	go discoveryManager.Run()
+
+	c := make(map[string]sd_config.ServiceDiscoveryConfig)
+	for _, v := range cfg.ScrapeConfigs {
+		c[v.JobName] = v.ServiceDiscoveryConfig
+	}
+	discoveryManager.ApplyConfig(c)
+	<-discoveryManager.SyncCh()
+
+	groups := discoveryManager.DiscoveredGroups()
+	if len(groups) != 1 {
+		t.Fatalf("Invalid number of target sets: expected 1, got %d", len(groups))
+	}
+	tgs, ok := groups["prometheus"]["string/0"]
+	if !ok {
+		t.Fatalf("Provider %q not found for target set %q: %v", "string/0", "prometheus", groups)
+	}
+	if len(tgs) != 2 {
+		t.Fatalf("Invalid number of target groups: expected 2, got %d", len(tgs))
+	}
+	for i, tg := range tgs {
+		if tg.Source != fmt.Sprint(i) {
+			t.Fatalf("Unexpected source of target group %d: %q", i, tg.Source)
+		}
+	}
+}
+
 func TestApplyConfigDoesNotModifyStaticProviderTargets(t *testing.T) {
 	cfgText := `
 scrape_configs:
@@ -1167,3 +1214,255 @@ func (o onceProvider) Run(_ context.Context, ch chan<- []*targetgroup.Group) {
 	}
 	close(ch)
 }
+
+func TestCachedTargetGroups(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sd_cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cacheFile := filepath.Join(dir, "sd_cache.json")
+	targetsFile := filepath.Join(dir, "targets.json")
+	writeTargets := func(target string) {
+		tmp := targetsFile + ".tmp"
+		if err := ioutil.WriteFile(tmp, []byte(`[{"targets": ["`+target+`"]}]`), 0666); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(tmp, targetsFile); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := map[string]sd_config.ServiceDiscoveryConfig{
+		"prometheus": {
+			Configs: []interface{}{&file.SDConfig{
+				Files:           []string{targetsFile},
+				RefreshInterval: model.Duration(50 * time.Millisecond),
+			}},
+		},
+	}
+	start := func() (*Manager, context.CancelFunc) {
+		ctx, cancel := context.WithCancel(context.Background())
+		m := NewManager(ctx, nil, CacheFile(cacheFile))
+		m.updatert = 50 * time.Millisecond
+		go m.Run()
+		m.ApplyConfig(cfg)
+		return m, cancel
+	}
+	// waitForTarget returns the target groups of the first update
+	// containing the target.
+	waitForTarget := func(m *Manager, target string) []*targetgroup.Group {
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case tsets := <-m.SyncCh():
+				for _, tg := range tsets["prometheus"] {
+					for _, lset := range tg.Targets {
+						if lset[model.AddressLabel] == model.LabelValue(target) {
+							return tsets["prometheus"]
+						}
+					}
+				}
+			case <-timeout:
+				t.Fatalf("Target %s wasn't discovered", target)
+			}
+		}
+	}
+
+	// The first run discovers the target and persists it.
+	writeTargets("foo:9090")
+	m, cancel := start()
+	tgs := waitForTarget(m, "foo:9090")
+	if _, ok := tgs[0].Labels[staleLabel]; ok {
+		t.Fatalf("Discovered target group shouldn't be stale: %v", tgs[0])
+	}
+	for i := 0; ; i++ {
+		if _, err := os.Stat(cacheFile); err == nil {
+			break
+		}
+		if i == 100 {
+			t.Fatal("Target groups weren't persisted")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	cancel()
+
+	// After a restart, the cached target is served as stale until the
+	// provider sends its first update.
+	if err := os.Remove(targetsFile); err != nil {
+		t.Fatal(err)
+	}
+	m, cancel = start()
+	defer cancel()
+	tgs = waitForTarget(m, "foo:9090")
+	if len(tgs) != 1 || tgs[0].Labels[staleLabel] != "true" {
+		t.Fatalf("Expected a single stale target group, got %v", tgs)
+	}
+
+	writeTargets("bar:9090")
+	tgs = waitForTarget(m, "bar:9090")
+	if len(tgs) != 1 || len(tgs[0].Targets) != 1 {
+		t.Fatalf("Expected the cached target to be replaced, got %v", tgs)
+	}
+	if _, ok := tgs[0].Labels[staleLabel]; ok {
+		t.Fatalf("Discovered target group shouldn't be stale: %v", tgs[0])
+	}
+
+	// Reloading the configuration doesn't restore the cached targets.
+	for i := 0; ; i++ {
+		m.mtx.RLock()
+		_, ok := m.cache[m.providers[0].cacheKey]
+		m.mtx.RUnlock()
+		if ok {
+			break
+		}
+		if i == 100 {
+			t.Fatal("Target groups weren't cached")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	m.ApplyConfig(cfg)
+	tgs = waitForTarget(m, "bar:9090")
+	if _, ok := tgs[0].Labels[staleLabel]; ok {
+		t.Fatalf("Discovered target group shouldn't be stale after a reload: %v", tgs[0])
+	}
+}
+
+func TestCachedTargetGroupsReplacedBySource(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	m := NewManager(ctx, nil)
+
+	cached := []*targetgroup.Group{
+		{Source: "a", Targets: []model.LabelSet{{model.AddressLabel: "a:9090"}}},
+		{Source: "b", Targets: []model.LabelSet{{model.AddressLabel: "b:9090"}}},
+	}
+	updates := make(chan []*targetgroup.Group)
+	p := &provider{name: "mock", d: &failingProvider{}, subs: []string{"prometheus"}}
+	pk := poolKey{setName: "prometheus", provider: "mock"}
+	m.providers = []*provider{p}
+	m.targets[pk] = staleGroups(cached)
+	m.stale[pk] = map[string]struct{}{"a": {}, "b": {}}
+	go m.updater(ctx, p, updates)
+
+	// send hands the target groups to the updater and returns once they
+	// were handled, which is the case once the next update is received.
+	send := func(tgs ...*targetgroup.Group) {
+		updates <- tgs
+		updates <- nil
+	}
+	groups := func() []*targetgroup.Group {
+		return m.DiscoveredGroups()["prometheus"]["mock"]
+	}
+
+	// Only the group with the same source is replaced.
+	m.providerRefreshed(p, "b", errors.New("refresh failed"))
+	send(&targetgroup.Group{Source: "a", Targets: []model.LabelSet{{model.AddressLabel: "c:9090"}}})
+	tgs := groups()
+	if len(tgs) != 2 {
+		t.Fatalf("Expected 2 target groups, got %v", tgs)
+	}
+	if _, ok := tgs[0].Labels[staleLabel]; ok || tgs[0].Targets[0][model.AddressLabel] != "c:9090" {
+		t.Fatalf("Expected the cached target group to be replaced, got %v", tgs[0])
+	}
+	if tgs[1].Labels[staleLabel] != "true" {
+		t.Fatalf("Expected a stale target group, got %v", tgs[1])
+	}
+
+	// The groups which weren't sent again are dropped with the first update
+	// after a successful refresh.
+	m.providerRefreshed(p, "a", nil)
+	send(&targetgroup.Group{Source: "a", Targets: []model.LabelSet{{model.AddressLabel: "c:9090"}}})
+	if tgs := groups(); len(tgs) != 1 || tgs[0].Source != "a" {
+		t.Fatalf("Expected stale target groups to be dropped, got %v", tgs)
+	}
+}
+
+// failingProvider sends its target groups once. Once fail is closed, it
+// reports an error refreshing unit a and a successful refresh of unit b.
+type failingProvider struct {
+	refresh.ErrorReporter
+
+	tgs        []*targetgroup.Group
+	err        error
+	fail, done chan struct{}
+}
+
+func (f *failingProvider) Run(ctx context.Context, ch chan<- []*targetgroup.Group) {
+	select {
+	case ch <- f.tgs:
+	case <-ctx.Done():
+		return
+	}
+	select {
+	case <-f.fail:
+	case <-ctx.Done():
+		return
+	}
+	f.ReportRefresh("a", f.err)
+	f.ReportRefresh("b", nil)
+	close(f.done)
+	<-ctx.Done()
+}
+
+func TestProviderStatus(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	discoveryManager := NewManager(ctx, log.NewNopLogger())
+	discoveryManager.updatert = 100 * time.Millisecond
+	go discoveryManager.Run()
+
+	p := &failingProvider{
+		tgs: []*targetgroup.Group{
+			{
+				Source:  "tg1",
+				Targets: []model.LabelSet{{"__address__": "foo:9090"}, {"__address__": "bar:9090"}},
+			},
+			{
+				Source:  "tg2",
+				Targets: []model.LabelSet{{"__address__": "baz:9090"}},
+			},
+		},
+		err:  errors.New("refresh failed"),
+		fail: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	discoveryManager.StartCustomProvider(ctx, "failing", p)
+
+	// Only fail once the update has been processed.
+	<-discoveryManager.SyncCh()
+	close(p.fail)
+	select {
+	case <-p.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Provider didn't report its error in time")
+	}
+
+	status := discoveryManager.ProviderStatus()
+	if len(status) != 1 {
+		t.Fatalf("Invalid number of providers: expected 1, got %d", len(status))
+	}
+	st := status[0]
+	if st.Name != "failing" {
+		t.Fatalf("Unexpected provider name %q", st.Name)
+	}
+	if st.TargetGroups != 2 || st.Targets != 3 {
+		t.Fatalf("Unexpected counts: expected 2 target groups and 3 targets, got %d and %d", st.TargetGroups, st.Targets)
+	}
+	if st.LastUpdate.IsZero() {
+		t.Fatal("Last update wasn't recorded")
+	}
+	if st.LastError == nil || st.LastError.Error() != "refresh failed" {
+		t.Fatalf("Unexpected last error: %v", st.LastError)
+	}
+	if st.LastErrorTime.Before(st.LastUpdate) {
+		t.Fatalf("Error time %v is before the last update %v", st.LastErrorTime, st.LastUpdate)
+	}
+
+	// The error is cleared once the failing unit was refreshed.
+	p.ReportRefresh("a", nil)
+	if err := discoveryManager.ProviderStatus()[0].LastError; err != nil {
+		t.Fatalf("Unexpected last error: %v", err)
+	}
+}
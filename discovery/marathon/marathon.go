@@ -30,6 +30,8 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	config_util "github.com/prometheus/common/config"
 	"github.com/prometheus/common/model"
+	sd_config "github.com/prometheus/prometheus/discovery/config"
+	"github.com/prometheus/prometheus/discovery/refresh"
 	"github.com/prometheus/prometheus/discovery/targetgroup"
 	"github.com/prometheus/prometheus/util/strutil"
 )
@@ -110,7 +112,17 @@ func (c *SDConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	return c.HTTPClientConfig.Validate()
 }
 
+// SetDirectory joins the relative paths of the configuration with dir. It
+// implements the discovery/config.DirectorySetter interface.
+func (c *SDConfig) SetDirectory(dir string) {
+	c.AuthTokenFile = sd_config.JoinDir(dir, c.AuthTokenFile)
+	sd_config.SetHTTPClientConfigDirectory(&c.HTTPClientConfig, dir)
+}
+
 func init() {
+	sd_config.RegisterSD("marathon_sd_configs", &SDConfig{}, func(cfg interface{}, logger log.Logger) (sd_config.Discoverer, error) {
+		return NewDiscovery(*cfg.(*SDConfig), logger)
+	})
 	prometheus.MustRegister(refreshFailuresCount)
 	prometheus.MustRegister(refreshDuration)
 }
@@ -125,6 +137,8 @@ type Discovery struct {
 	lastRefresh     map[string]*targetgroup.Group
 	appsClient      AppListClient
 	logger          log.Logger
+
+	refresh.ErrorReporter
 }
 
 // NewDiscovery returns a new Marathon Discovery.
@@ -212,6 +226,7 @@ func (d *Discovery) Run(ctx context.Context, ch chan<- []*targetgroup.Group) {
 			return
 		case <-time.After(d.refreshInterval):
 			err := d.updateServices(ctx, ch)
+			d.ReportRefresh("", err)
 			if err != nil {
 				level.Error(d.logger).Log("msg", "Error while updating services", "err", err)
 			}
@@ -0,0 +1,255 @@
+// Copyright 2019 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package moby
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	config_util "github.com/prometheus/common/config"
+)
+
+// validateHost checks the Docker host of a configuration.
+func validateHost(host string) error {
+	if host == "" {
+		return fmt.Errorf("host missing")
+	}
+	u, err := url.Parse(host)
+	if err != nil {
+		return err
+	}
+	switch u.Scheme {
+	case "unix":
+		if u.Path == "" {
+			return fmt.Errorf("socket path missing in host %q", host)
+		}
+	case "tcp", "http", "https":
+		if u.Host == "" {
+			return fmt.Errorf("address missing in host %q", host)
+		}
+	default:
+		return fmt.Errorf("unsupported scheme %q in host %q, must be one of unix, tcp, http or https", u.Scheme, host)
+	}
+	return nil
+}
+
+// client is a minimal client of the Docker Engine API.
+type client struct {
+	client  *http.Client
+	baseURL string
+}
+
+// newClient returns a client for the Docker daemon listening at the given
+// host. Hosts are either unix sockets (unix:///var/run/docker.sock) or
+// TCP addresses (tcp://, http:// or https://).
+func newClient(host string, cfg config_util.HTTPClientConfig, name string) (*client, error) {
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		rt      http.RoundTripper
+		baseURL string
+	)
+	switch u.Scheme {
+	case "unix":
+		socket := u.Path
+		rt = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		}
+		// The host is ignored by the dialer but required for a valid URL.
+		baseURL = "http://docker"
+
+		// Only the authentication options apply to unix sockets.
+		if len(cfg.BearerToken) > 0 {
+			rt = config_util.NewBearerAuthRoundTripper(cfg.BearerToken, rt)
+		} else if len(cfg.BearerTokenFile) > 0 {
+			rt = config_util.NewBearerAuthFileRoundTripper(cfg.BearerTokenFile, rt)
+		}
+		if cfg.BasicAuth != nil {
+			rt = config_util.NewBasicAuthRoundTripper(cfg.BasicAuth.Username, cfg.BasicAuth.Password, cfg.BasicAuth.PasswordFile, rt)
+		}
+	case "tcp":
+		u.Scheme = "http"
+		fallthrough
+	default:
+		rt, err = config_util.NewRoundTripperFromConfig(cfg, name)
+		if err != nil {
+			return nil, err
+		}
+		baseURL = strings.TrimRight(u.String(), "/")
+	}
+
+	return &client{
+		client:  &http.Client{Transport: rt},
+		baseURL: baseURL,
+	}, nil
+}
+
+// get requests the given API path and decodes the JSON response into v.
+func (c *client) get(ctx context.Context, path string, v interface{}) error {
+	req, err := http.NewRequest("GET", c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("GET %s: server returned HTTP status %s", path, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return errors.Wrapf(err, "GET %s: decode response", path)
+	}
+	return nil
+}
+
+// The following types model the parts of the Docker Engine API responses
+// used for discovery.
+
+type container struct {
+	ID         string `json:"Id"`
+	Names      []string
+	Labels     map[string]string
+	Ports      []containerPort
+	HostConfig struct {
+		NetworkMode string
+	}
+	NetworkSettings struct {
+		Networks map[string]endpointSettings
+	}
+}
+
+type containerPort struct {
+	IP          string
+	PrivatePort uint16
+	PublicPort  uint16
+	Type        string
+}
+
+type endpointSettings struct {
+	NetworkID string
+	IPAddress string
+}
+
+type network struct {
+	ID       string `json:"Id"`
+	Name     string
+	Scope    string
+	Internal bool
+	Ingress  bool
+	Labels   map[string]string
+}
+
+type service struct {
+	ID   string
+	Spec struct {
+		Name   string
+		Labels map[string]string
+		Mode   struct {
+			Replicated *struct{}
+			Global     *struct{}
+		}
+		TaskTemplate struct {
+			ContainerSpec struct {
+				Image    string
+				Hostname string
+			}
+		}
+	}
+	Endpoint struct {
+		Ports      []portConfig
+		VirtualIPs []struct {
+			NetworkID string
+			Addr      string
+		}
+	}
+	UpdateStatus *struct {
+		State string
+	}
+}
+
+type portConfig struct {
+	Name          string
+	Protocol      string
+	TargetPort    uint32
+	PublishedPort uint32
+	PublishMode   string
+}
+
+type task struct {
+	ID           string
+	ServiceID    string
+	NodeID       string
+	Slot         int
+	DesiredState string
+	Status       struct {
+		State           string
+		ContainerStatus *struct {
+			ContainerID string
+		}
+	}
+	NetworksAttachments []struct {
+		Network struct {
+			ID string
+		}
+		Addresses []string
+	}
+}
+
+type node struct {
+	ID   string
+	Spec struct {
+		Labels       map[string]string
+		Role         string
+		Availability string
+	}
+	Description struct {
+		Hostname string
+		Platform struct {
+			Architecture string
+			OS           string
+		}
+		Engine struct {
+			EngineVersion string
+		}
+	}
+	Status struct {
+		State string
+		Addr  string
+	}
+	ManagerStatus *struct {
+		Leader       bool
+		Reachability string
+		Addr         string
+	}
+}
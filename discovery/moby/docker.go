@@ -0,0 +1,226 @@
+// Copyright 2019 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package moby
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	config_util "github.com/prometheus/common/config"
+	"github.com/prometheus/common/model"
+
+	sd_config "github.com/prometheus/prometheus/discovery/config"
+	"github.com/prometheus/prometheus/discovery/targetgroup"
+	"github.com/prometheus/prometheus/util/strutil"
+)
+
+const (
+	dockerLabel                     = model.MetaLabelPrefix + "docker_"
+	dockerLabelContainerPrefix      = dockerLabel + "container_"
+	dockerLabelContainerID          = dockerLabelContainerPrefix + "id"
+	dockerLabelContainerName        = dockerLabelContainerPrefix + "name"
+	dockerLabelContainerNetworkMode = dockerLabelContainerPrefix + "network_mode"
+	dockerLabelContainerLabelPrefix = dockerLabelContainerPrefix + "label_"
+	dockerLabelNetworkIP            = dockerLabel + "network_ip"
+	dockerLabelPortPrefix           = dockerLabel + "port_"
+	dockerLabelPortPrivate          = dockerLabelPortPrefix + "private"
+	dockerLabelPortPublic           = dockerLabelPortPrefix + "public"
+	dockerLabelPortPublicIP         = dockerLabelPortPrefix + "public_ip"
+
+	// Constants for instrumentation.
+	namespace = "prometheus"
+)
+
+var (
+	dockerRefreshFailuresCount = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "sd_docker_refresh_failures_total",
+			Help:      "The number of Docker-SD refresh failures.",
+		})
+	dockerRefreshDuration = prometheus.NewSummary(
+		prometheus.SummaryOpts{
+			Namespace: namespace,
+			Name:      "sd_docker_refresh_duration_seconds",
+			Help:      "The duration of a Docker-SD refresh in seconds.",
+		})
+	// DefaultDockerSDConfig is the default Docker SD configuration.
+	DefaultDockerSDConfig = DockerSDConfig{
+		RefreshInterval:    model.Duration(60 * time.Second),
+		Port:               80,
+		HostNetworkingHost: "localhost",
+	}
+)
+
+func init() {
+	sd_config.RegisterSD("docker_sd_configs", &DockerSDConfig{}, func(cfg interface{}, logger log.Logger) (sd_config.Discoverer, error) {
+		return NewDockerDiscovery(cfg.(*DockerSDConfig), logger)
+	})
+	prometheus.MustRegister(dockerRefreshFailuresCount)
+	prometheus.MustRegister(dockerRefreshDuration)
+}
+
+// DockerSDConfig is the configuration for Docker (non-swarm) based service discovery.
+type DockerSDConfig struct {
+	HTTPClientConfig config_util.HTTPClientConfig `yaml:",inline"`
+
+	Host               string         `yaml:"host"`
+	Port               int            `yaml:"port"`
+	HostNetworkingHost string         `yaml:"host_networking_host"`
+	RefreshInterval    model.Duration `yaml:"refresh_interval,omitempty"`
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+func (c *DockerSDConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultDockerSDConfig
+	type plain DockerSDConfig
+	err := unmarshal((*plain)(c))
+	if err != nil {
+		return err
+	}
+	if err := validateHost(c.Host); err != nil {
+		return fmt.Errorf("docker_sd: %s", err)
+	}
+	if c.RefreshInterval <= 0 {
+		return fmt.Errorf("docker_sd: refresh_interval must be positive")
+	}
+	return c.HTTPClientConfig.Validate()
+}
+
+// SetDirectory joins the relative paths of the configuration with dir. It
+// implements the discovery/config.DirectorySetter interface.
+func (c *DockerSDConfig) SetDirectory(dir string) {
+	sd_config.SetHTTPClientConfigDirectory(&c.HTTPClientConfig, dir)
+}
+
+// DockerDiscovery periodically discovers the containers of a Docker daemon.
+// It implements the Discoverer interface.
+type DockerDiscovery struct {
+	*refresher
+	client             *client
+	port               int
+	hostNetworkingHost string
+}
+
+// NewDockerDiscovery returns a new DockerDiscovery which periodically refreshes its targets.
+func NewDockerDiscovery(conf *DockerSDConfig, logger log.Logger) (*DockerDiscovery, error) {
+	if logger == nil {
+		logger = log.NewNopLogger()
+	}
+
+	c, err := newClient(conf.Host, conf.HTTPClientConfig, "docker_sd")
+	if err != nil {
+		return nil, err
+	}
+
+	d := &DockerDiscovery{
+		client:             c,
+		port:               conf.Port,
+		hostNetworkingHost: conf.HostNetworkingHost,
+	}
+	d.refresher = &refresher{
+		interval: time.Duration(conf.RefreshInterval),
+		logger:   logger,
+		refreshf: d.refresh,
+		failures: dockerRefreshFailuresCount,
+		duration: dockerRefreshDuration,
+	}
+	return d, nil
+}
+
+func (d *DockerDiscovery) refresh(ctx context.Context) ([]*targetgroup.Group, error) {
+	tg := &targetgroup.Group{
+		Source: "Docker",
+	}
+
+	var containers []container
+	if err := d.client.get(ctx, "/containers/json", &containers); err != nil {
+		return nil, fmt.Errorf("error while listing containers: %s", err)
+	}
+
+	networkLabels, err := getNetworksLabels(ctx, d.client, dockerLabel)
+	if err != nil {
+		return nil, fmt.Errorf("error while computing network labels: %s", err)
+	}
+
+	for _, c := range containers {
+		if len(c.Names) == 0 {
+			continue
+		}
+
+		commonLabels := map[string]string{
+			dockerLabelContainerID:          c.ID,
+			dockerLabelContainerName:        c.Names[0],
+			dockerLabelContainerNetworkMode: c.HostConfig.NetworkMode,
+		}
+		for k, v := range c.Labels {
+			commonLabels[dockerLabelContainerLabelPrefix+strutil.SanitizeLabelName(k)] = v
+		}
+
+		for _, n := range c.NetworkSettings.Networks {
+			host := n.IPAddress
+			if c.HostConfig.NetworkMode == "host" {
+				host = d.hostNetworkingHost
+			}
+
+			var added bool
+			for _, p := range c.Ports {
+				if p.Type != "tcp" {
+					continue
+				}
+
+				labels := model.LabelSet{
+					dockerLabelNetworkIP:   model.LabelValue(n.IPAddress),
+					dockerLabelPortPrivate: model.LabelValue(strconv.FormatUint(uint64(p.PrivatePort), 10)),
+				}
+				if p.PublicPort > 0 {
+					labels[dockerLabelPortPublic] = model.LabelValue(strconv.FormatUint(uint64(p.PublicPort), 10))
+					labels[dockerLabelPortPublicIP] = model.LabelValue(p.IP)
+				}
+				addLabels(labels, commonLabels, networkLabels[n.NetworkID])
+
+				labels[model.AddressLabel] = model.LabelValue(net.JoinHostPort(host, strconv.FormatUint(uint64(p.PrivatePort), 10)))
+				tg.Targets = append(tg.Targets, labels)
+				added = true
+			}
+
+			if !added {
+				// Use the default port for containers without exposed ports.
+				labels := model.LabelSet{
+					dockerLabelNetworkIP: model.LabelValue(n.IPAddress),
+				}
+				addLabels(labels, commonLabels, networkLabels[n.NetworkID])
+
+				labels[model.AddressLabel] = model.LabelValue(net.JoinHostPort(host, strconv.Itoa(d.port)))
+				tg.Targets = append(tg.Targets, labels)
+			}
+		}
+	}
+
+	return []*targetgroup.Group{tg}, nil
+}
+
+// addLabels adds the given label maps to the label set.
+func addLabels(ls model.LabelSet, maps ...map[string]string) {
+	for _, m := range maps {
+		for k, v := range m {
+			ls[model.LabelName(k)] = model.LabelValue(v)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2019 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package moby
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/common/model"
+	"gopkg.in/yaml.v2"
+
+	"github.com/prometheus/prometheus/util/testutil"
+)
+
+func TestDockerSDRefresh(t *testing.T) {
+	sdmock := NewSDMock(t, "docker")
+	sdmock.Setup()
+	defer sdmock.ShutdownServer()
+
+	var cfg DockerSDConfig
+	testutil.Ok(t, yaml.Unmarshal([]byte("host: "+sdmock.Endpoint()), &cfg))
+
+	d, err := NewDockerDiscovery(&cfg, nil)
+	testutil.Ok(t, err)
+
+	tgs, err := d.refresh(context.Background())
+	testutil.Ok(t, err)
+	testutil.Equals(t, 1, len(tgs))
+
+	tg := tgs[0]
+	testutil.Equals(t, "Docker", tg.Source)
+	testutil.Equals(t, 3, len(tg.Targets))
+
+	defaultNetworkLabels := model.LabelSet{
+		"__meta_docker_network_id":                               "7189986ab399e144e52a71b7451b4e04e2158c044b4cd2f3ae26fc3a285d3798",
+		"__meta_docker_network_ingress":                          "false",
+		"__meta_docker_network_internal":                         "false",
+		"__meta_docker_network_label_com_docker_compose_network": "default",
+		"__meta_docker_network_label_com_docker_compose_project": "dockersd",
+		"__meta_docker_network_name":                             "dockersd_default",
+		"__meta_docker_network_scope":                            "local",
+	}
+
+	for i, lbls := range []model.LabelSet{
+		defaultNetworkLabels.Merge(model.LabelSet{
+			"__address__":                "172.19.0.2:80",
+			"__meta_docker_container_id": "c301b928faceb1a18fe379f6bc178727ef920bb30b0f9b8592b32b36255a0eca",
+			"__meta_docker_container_label_com_docker_compose_project": "dockersd",
+			"__meta_docker_container_label_com_docker_compose_service": "node",
+			"__meta_docker_container_name":                             "/dockersd_node_1",
+			"__meta_docker_container_network_mode":                     "dockersd_default",
+			"__meta_docker_network_ip":                                 "172.19.0.2",
+		}),
+		defaultNetworkLabels.Merge(model.LabelSet{
+			"__address__":                "172.19.0.3:9090",
+			"__meta_docker_container_id": "c96c4ef3bf2ee0df17cf4a8c72bcb1a45f8a0e2f5b1a4fd9bd5d9aeb1e6f0f21",
+			"__meta_docker_container_label_com_docker_compose_project": "dockersd",
+			"__meta_docker_container_label_com_docker_compose_service": "web",
+			"__meta_docker_container_name":                             "/dockersd_web_1",
+			"__meta_docker_container_network_mode":                     "dockersd_default",
+			"__meta_docker_network_ip":                                 "172.19.0.3",
+			"__meta_docker_port_private":                               "9090",
+			"__meta_docker_port_public":                                "19090",
+			"__meta_docker_port_public_ip":                             "0.0.0.0",
+		}),
+		{
+			"__address__":                          "localhost:80",
+			"__meta_docker_container_id":           "d1a4ac0bb0a5e6a1b09b5e4bbd7bb23a0c3f4f6d0c91e1c6a2ef5e5d6a7b8c9d",
+			"__meta_docker_container_name":         "/dockersd_host_1",
+			"__meta_docker_container_network_mode": "host",
+			"__meta_docker_network_id":             "4bd59e3a5e1e8e4c1d1e0c41c4ec0ce2c9d6cd5c9b0a0c2ef7a0a1e9a7b3c3d2",
+			"__meta_docker_network_ingress":        "false",
+			"__meta_docker_network_internal":       "false",
+			"__meta_docker_network_ip":             "",
+			"__meta_docker_network_name":           "host",
+			"__meta_docker_network_scope":          "local",
+		},
+	} {
+		testutil.Equals(t, lbls, tg.Targets[i])
+	}
+}
+
+func TestDockerSDConfigHost(t *testing.T) {
+	for _, tc := range []struct {
+		host string
+		err  string
+	}{
+		{host: "unix:///var/run/docker.sock"},
+		{host: "tcp://127.0.0.1:2375"},
+		{host: "https://docker.example.com:2376"},
+		{host: "", err: "docker_sd: host missing"},
+		{host: "unix://", err: `docker_sd: socket path missing in host "unix://"`},
+		{host: "ftp://docker.example.com", err: `docker_sd: unsupported scheme "ftp" in host "ftp://docker.example.com", must be one of unix, tcp, http or https`},
+	} {
+		var cfg DockerSDConfig
+		err := yaml.Unmarshal([]byte("host: '"+tc.host+"'"), &cfg)
+		if tc.err == "" {
+			testutil.Ok(t, err)
+			continue
+		}
+		testutil.NotOk(t, err, "")
+		testutil.Equals(t, tc.err, err.Error())
+	}
+}
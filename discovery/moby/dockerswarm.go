@@ -0,0 +1,167 @@
+// Copyright 2019 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package moby
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	config_util "github.com/prometheus/common/config"
+	"github.com/prometheus/common/model"
+
+	sd_config "github.com/prometheus/prometheus/discovery/config"
+	"github.com/prometheus/prometheus/discovery/targetgroup"
+)
+
+const (
+	swarmLabel = model.MetaLabelPrefix + "dockerswarm_"
+)
+
+var (
+	swarmRefreshFailuresCount = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "sd_dockerswarm_refresh_failures_total",
+			Help:      "The number of Docker Swarm-SD refresh failures.",
+		})
+	swarmRefreshDuration = prometheus.NewSummary(
+		prometheus.SummaryOpts{
+			Namespace: namespace,
+			Name:      "sd_dockerswarm_refresh_duration_seconds",
+			Help:      "The duration of a Docker Swarm-SD refresh in seconds.",
+		})
+	// DefaultDockerSwarmSDConfig is the default Docker Swarm SD configuration.
+	DefaultDockerSwarmSDConfig = DockerSwarmSDConfig{
+		RefreshInterval: model.Duration(60 * time.Second),
+		Port:            80,
+	}
+)
+
+func init() {
+	sd_config.RegisterSD("dockerswarm_sd_configs", &DockerSwarmSDConfig{}, func(cfg interface{}, logger log.Logger) (sd_config.Discoverer, error) {
+		return NewDockerSwarmDiscovery(cfg.(*DockerSwarmSDConfig), logger)
+	})
+	prometheus.MustRegister(swarmRefreshFailuresCount)
+	prometheus.MustRegister(swarmRefreshDuration)
+}
+
+// Role is the kind of Swarm objects discovered.
+type Role string
+
+// The valid options for Role.
+const (
+	RoleServices Role = "services"
+	RoleTasks    Role = "tasks"
+	RoleNodes    Role = "nodes"
+)
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+func (c *Role) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if err := unmarshal((*string)(c)); err != nil {
+		return err
+	}
+	switch *c {
+	case RoleServices, RoleTasks, RoleNodes:
+		return nil
+	default:
+		return fmt.Errorf("unknown Docker Swarm SD role %q", *c)
+	}
+}
+
+// DockerSwarmSDConfig is the configuration for Docker Swarm based service discovery.
+type DockerSwarmSDConfig struct {
+	HTTPClientConfig config_util.HTTPClientConfig `yaml:",inline"`
+
+	Host            string         `yaml:"host"`
+	Role            Role           `yaml:"role"`
+	Port            int            `yaml:"port"`
+	RefreshInterval model.Duration `yaml:"refresh_interval,omitempty"`
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+func (c *DockerSwarmSDConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultDockerSwarmSDConfig
+	type plain DockerSwarmSDConfig
+	err := unmarshal((*plain)(c))
+	if err != nil {
+		return err
+	}
+	if err := validateHost(c.Host); err != nil {
+		return fmt.Errorf("dockerswarm_sd: %s", err)
+	}
+	if c.Role == "" {
+		return fmt.Errorf("dockerswarm_sd: role missing (one of: services, tasks, nodes)")
+	}
+	if c.RefreshInterval <= 0 {
+		return fmt.Errorf("dockerswarm_sd: refresh_interval must be positive")
+	}
+	return c.HTTPClientConfig.Validate()
+}
+
+// SetDirectory joins the relative paths of the configuration with dir. It
+// implements the discovery/config.DirectorySetter interface.
+func (c *DockerSwarmSDConfig) SetDirectory(dir string) {
+	sd_config.SetHTTPClientConfigDirectory(&c.HTTPClientConfig, dir)
+}
+
+// DockerSwarmDiscovery periodically discovers the services, tasks or nodes
+// of a Docker Swarm cluster. It implements the Discoverer interface.
+type DockerSwarmDiscovery struct {
+	*refresher
+	client *client
+	role   Role
+	port   int
+}
+
+// NewDockerSwarmDiscovery returns a new DockerSwarmDiscovery which periodically refreshes its targets.
+func NewDockerSwarmDiscovery(conf *DockerSwarmSDConfig, logger log.Logger) (*DockerSwarmDiscovery, error) {
+	if logger == nil {
+		logger = log.NewNopLogger()
+	}
+
+	c, err := newClient(conf.Host, conf.HTTPClientConfig, "dockerswarm_sd")
+	if err != nil {
+		return nil, err
+	}
+
+	d := &DockerSwarmDiscovery{
+		client: c,
+		role:   conf.Role,
+		port:   conf.Port,
+	}
+	d.refresher = &refresher{
+		interval: time.Duration(conf.RefreshInterval),
+		logger:   logger,
+		refreshf: d.refresh,
+		failures: swarmRefreshFailuresCount,
+		duration: swarmRefreshDuration,
+	}
+	return d, nil
+}
+
+func (d *DockerSwarmDiscovery) refresh(ctx context.Context) ([]*targetgroup.Group, error) {
+	switch d.role {
+	case RoleServices:
+		return d.refreshServices(ctx)
+	case RoleTasks:
+		return d.refreshTasks(ctx)
+	case RoleNodes:
+		return d.refreshNodes(ctx)
+	default:
+		panic(fmt.Errorf("unexpected role %s", d.role))
+	}
+}
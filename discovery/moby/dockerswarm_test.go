@@ -0,0 +1,179 @@
+// Copyright 2019 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package moby
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/prometheus/common/model"
+	"gopkg.in/yaml.v2"
+
+	"github.com/prometheus/prometheus/discovery/targetgroup"
+	"github.com/prometheus/prometheus/util/testutil"
+)
+
+var (
+	swarmIngressNetworkLabels = model.LabelSet{
+		"__meta_dockerswarm_network_id":       "qvwhwd6p61k4o0ulsknqb066z",
+		"__meta_dockerswarm_network_ingress":  "true",
+		"__meta_dockerswarm_network_internal": "false",
+		"__meta_dockerswarm_network_name":     "ingress",
+		"__meta_dockerswarm_network_scope":    "swarm",
+	}
+	swarmMonNetworkLabels = model.LabelSet{
+		"__meta_dockerswarm_network_id":                               "npq2closzy836m07eaq1425k3",
+		"__meta_dockerswarm_network_ingress":                          "false",
+		"__meta_dockerswarm_network_internal":                         "false",
+		"__meta_dockerswarm_network_label_com_docker_stack_namespace": "mon",
+		"__meta_dockerswarm_network_name":                             "mon_net",
+		"__meta_dockerswarm_network_scope":                            "swarm",
+	}
+	swarmManagerNodeLabels = model.LabelSet{
+		"__meta_dockerswarm_node_address":               "10.0.232.3",
+		"__meta_dockerswarm_node_availability":          "active",
+		"__meta_dockerswarm_node_engine_version":        "19.03.5",
+		"__meta_dockerswarm_node_hostname":              "master-1",
+		"__meta_dockerswarm_node_id":                    "bvtjl7pnrtg0k88ywialsldpd",
+		"__meta_dockerswarm_node_platform_architecture": "x86_64",
+		"__meta_dockerswarm_node_platform_os":           "linux",
+		"__meta_dockerswarm_node_role":                  "manager",
+		"__meta_dockerswarm_node_status":                "ready",
+	}
+	swarmWorkerNodeLabels = model.LabelSet{
+		"__meta_dockerswarm_node_address":               "10.0.232.4",
+		"__meta_dockerswarm_node_availability":          "active",
+		"__meta_dockerswarm_node_engine_version":        "19.03.5",
+		"__meta_dockerswarm_node_hostname":              "worker-1",
+		"__meta_dockerswarm_node_id":                    "i9woemzxymn1n98o9ufebclgm",
+		"__meta_dockerswarm_node_label_zone":            "eu-1",
+		"__meta_dockerswarm_node_platform_architecture": "x86_64",
+		"__meta_dockerswarm_node_platform_os":           "linux",
+		"__meta_dockerswarm_node_role":                  "worker",
+		"__meta_dockerswarm_node_status":                "ready",
+	}
+	swarmNodeExporterServiceLabels = model.LabelSet{
+		"__meta_dockerswarm_service_id":                               "3qvd7bwfptmoht16t1f7jllb6",
+		"__meta_dockerswarm_service_label_com_docker_stack_namespace": "mon",
+		"__meta_dockerswarm_service_mode":                             "global",
+		"__meta_dockerswarm_service_name":                             "mon_node-exporter",
+		"__meta_dockerswarm_service_task_container_hostname":          "",
+		"__meta_dockerswarm_service_task_container_image":             "prom/node-exporter:v1.0.0",
+	}
+	swarmPrometheusServiceLabels = model.LabelSet{
+		"__meta_dockerswarm_service_id":                               "9bbq7j55tzzz85k2gg52x73rg",
+		"__meta_dockerswarm_service_label_com_docker_stack_namespace": "mon",
+		"__meta_dockerswarm_service_mode":                             "replicated",
+		"__meta_dockerswarm_service_name":                             "mon_prometheus",
+		"__meta_dockerswarm_service_task_container_hostname":          "prometheus",
+		"__meta_dockerswarm_service_task_container_image":             "prom/prometheus:v2.7.1",
+		"__meta_dockerswarm_service_updating_status":                  "completed",
+	}
+)
+
+func swarmRefresh(t *testing.T, role Role) *targetgroup.Group {
+	sdmock := NewSDMock(t, "swarm")
+	sdmock.Setup()
+	defer sdmock.ShutdownServer()
+
+	var cfg DockerSwarmSDConfig
+	testutil.Ok(t, yaml.Unmarshal([]byte(fmt.Sprintf("host: %s\nrole: %s", sdmock.Endpoint(), role)), &cfg))
+
+	d, err := NewDockerSwarmDiscovery(&cfg, nil)
+	testutil.Ok(t, err)
+
+	tgs, err := d.refresh(context.Background())
+	testutil.Ok(t, err)
+	testutil.Equals(t, 1, len(tgs))
+	testutil.Equals(t, "DockerSwarm", tgs[0].Source)
+	return tgs[0]
+}
+
+// merge returns the union of the given label sets.
+func merge(sets ...model.LabelSet) model.LabelSet {
+	ls := model.LabelSet{}
+	for _, s := range sets {
+		ls = ls.Merge(s)
+	}
+	return ls
+}
+
+func TestDockerSwarmSDServicesRefresh(t *testing.T) {
+	tg := swarmRefresh(t, RoleServices)
+
+	testutil.Equals(t, []model.LabelSet{
+		merge(swarmNodeExporterServiceLabels, swarmMonNetworkLabels, model.LabelSet{
+			"__address__": "10.0.1.2:9100",
+			"__meta_dockerswarm_service_endpoint_port_name":         "",
+			"__meta_dockerswarm_service_endpoint_port_publish_mode": "host",
+		}),
+		merge(swarmPrometheusServiceLabels, swarmIngressNetworkLabels, model.LabelSet{
+			"__address__": "10.0.0.7:19090",
+			"__meta_dockerswarm_service_endpoint_port_name":         "web",
+			"__meta_dockerswarm_service_endpoint_port_publish_mode": "ingress",
+		}),
+		merge(swarmPrometheusServiceLabels, swarmMonNetworkLabels, model.LabelSet{
+			"__address__": "10.0.1.3:19090",
+			"__meta_dockerswarm_service_endpoint_port_name":         "web",
+			"__meta_dockerswarm_service_endpoint_port_publish_mode": "ingress",
+		}),
+	}, tg.Targets)
+}
+
+func TestDockerSwarmSDTasksRefresh(t *testing.T) {
+	tg := swarmRefresh(t, RoleTasks)
+
+	nodeExporterTaskLabels := model.LabelSet{
+		"__meta_dockerswarm_task_container_id":  "a137690f7070c536470aeae2d40cd4ecabe59842e5c2fce0cc7596f7d3d85c59",
+		"__meta_dockerswarm_task_desired_state": "running",
+		"__meta_dockerswarm_task_id":            "40wy6ekgchzz38dqjv07qt79w",
+		"__meta_dockerswarm_task_slot":          "0",
+		"__meta_dockerswarm_task_state":         "running",
+	}
+	testutil.Equals(t, []model.LabelSet{
+		merge(nodeExporterTaskLabels, swarmNodeExporterServiceLabels, swarmWorkerNodeLabels, model.LabelSet{
+			"__address__": "10.0.232.4:9100",
+			"__meta_dockerswarm_task_port_publish_mode": "host",
+		}),
+		merge(nodeExporterTaskLabels, swarmNodeExporterServiceLabels, swarmWorkerNodeLabels, swarmMonNetworkLabels, model.LabelSet{
+			"__address__": "10.0.1.4:80",
+		}),
+		merge(swarmPrometheusServiceLabels, swarmManagerNodeLabels, swarmMonNetworkLabels, model.LabelSet{
+			"__address__":                               "10.0.1.5:9090",
+			"__meta_dockerswarm_task_container_id":      "f8e4457895c8ddf4723db4b129b55f904cf2ecde18dbf4a744c85470798813f9",
+			"__meta_dockerswarm_task_desired_state":     "running",
+			"__meta_dockerswarm_task_id":                "7ogolpkgw2d2amnht1fbtm9oq",
+			"__meta_dockerswarm_task_port_publish_mode": "ingress",
+			"__meta_dockerswarm_task_slot":              "1",
+			"__meta_dockerswarm_task_state":             "running",
+		}),
+	}, tg.Targets)
+}
+
+func TestDockerSwarmSDNodesRefresh(t *testing.T) {
+	tg := swarmRefresh(t, RoleNodes)
+
+	testutil.Equals(t, []model.LabelSet{
+		merge(swarmManagerNodeLabels, model.LabelSet{
+			"__address__": "10.0.232.3:80",
+			"__meta_dockerswarm_node_manager_address":      "10.0.232.3:2377",
+			"__meta_dockerswarm_node_manager_leader":       "true",
+			"__meta_dockerswarm_node_manager_reachability": "reachable",
+		}),
+		merge(swarmWorkerNodeLabels, model.LabelSet{
+			"__address__": "10.0.232.4:80",
+		}),
+	}, tg.Targets)
+}
@@ -0,0 +1,80 @@
+// Copyright 2019 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package moby
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/prometheus/util/testutil"
+)
+
+// SDMock is a mock of the Docker Engine API serving the JSON responses
+// found in testdata/<directory>.
+type SDMock struct {
+	t         *testing.T
+	Server    *httptest.Server
+	Mux       *http.ServeMux
+	directory string
+}
+
+// NewSDMock returns a new SDMock.
+func NewSDMock(t *testing.T, directory string) *SDMock {
+	return &SDMock{
+		t:         t,
+		directory: directory,
+	}
+}
+
+// Endpoint returns the URI to the mock server.
+func (m *SDMock) Endpoint() string {
+	return m.Server.URL + "/"
+}
+
+// Setup creates the mock server.
+func (m *SDMock) Setup() {
+	m.Mux = http.NewServeMux()
+	m.Server = httptest.NewServer(m.Mux)
+	m.SetupHandlers()
+}
+
+// ShutdownServer shuts down the mock server.
+func (m *SDMock) ShutdownServer() {
+	m.Server.Close()
+}
+
+// SetupHandlers serves each JSON file of the testdata directory at the API
+// path matching its name, e.g. containers_json.json at /containers/json.
+func (m *SDMock) SetupHandlers() {
+	files, err := filepath.Glob(filepath.Join("testdata", m.directory, "*.json"))
+	testutil.Ok(m.t, err)
+
+	for _, f := range files {
+		b, err := ioutil.ReadFile(f)
+		testutil.Ok(m.t, err)
+
+		path := "/" + strings.Replace(strings.TrimSuffix(filepath.Base(f), ".json"), "_", "/", -1)
+		m.Mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != "GET" {
+				m.t.Errorf("Request method = %v, expected GET", r.Method)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(b)
+		})
+	}
+}
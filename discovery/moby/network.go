@@ -0,0 +1,56 @@
+// Copyright 2019 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package moby
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/prometheus/prometheus/util/strutil"
+)
+
+const (
+	labelNetworkPrefix      = "network_"
+	labelNetworkID          = labelNetworkPrefix + "id"
+	labelNetworkName        = labelNetworkPrefix + "name"
+	labelNetworkScope       = labelNetworkPrefix + "scope"
+	labelNetworkInternal    = labelNetworkPrefix + "internal"
+	labelNetworkIngress     = labelNetworkPrefix + "ingress"
+	labelNetworkLabelPrefix = labelNetworkPrefix + "label_"
+)
+
+// getNetworksLabels returns the meta labels of all networks known to the
+// daemon, indexed by network ID. The label names are prefixed with prefix.
+func getNetworksLabels(ctx context.Context, c *client, prefix string) (map[string]map[string]string, error) {
+	var networks []network
+	if err := c.get(ctx, "/networks", &networks); err != nil {
+		return nil, err
+	}
+
+	labels := make(map[string]map[string]string, len(networks))
+	for _, n := range networks {
+		l := map[string]string{
+			prefix + labelNetworkID:       n.ID,
+			prefix + labelNetworkName:     n.Name,
+			prefix + labelNetworkScope:    n.Scope,
+			prefix + labelNetworkInternal: strconv.FormatBool(n.Internal),
+			prefix + labelNetworkIngress:  strconv.FormatBool(n.Ingress),
+		}
+		for k, v := range n.Labels {
+			l[prefix+labelNetworkLabelPrefix+strutil.SanitizeLabelName(k)] = v
+		}
+		labels[n.ID] = l
+	}
+	return labels, nil
+}
@@ -0,0 +1,101 @@
+// Copyright 2019 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package moby
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/prometheus/common/model"
+
+	"github.com/prometheus/prometheus/discovery/targetgroup"
+	"github.com/prometheus/prometheus/util/strutil"
+)
+
+const (
+	swarmLabelNodePrefix               = swarmLabel + "node_"
+	swarmLabelNodeAddress              = swarmLabelNodePrefix + "address"
+	swarmLabelNodeAvailability         = swarmLabelNodePrefix + "availability"
+	swarmLabelNodeEngineVersion        = swarmLabelNodePrefix + "engine_version"
+	swarmLabelNodeHostname             = swarmLabelNodePrefix + "hostname"
+	swarmLabelNodeID                   = swarmLabelNodePrefix + "id"
+	swarmLabelNodeLabelPrefix          = swarmLabelNodePrefix + "label_"
+	swarmLabelNodeManagerAddr          = swarmLabelNodePrefix + "manager_address"
+	swarmLabelNodeManagerLeader        = swarmLabelNodePrefix + "manager_leader"
+	swarmLabelNodeManagerReachability  = swarmLabelNodePrefix + "manager_reachability"
+	swarmLabelNodePlatformArchitecture = swarmLabelNodePrefix + "platform_architecture"
+	swarmLabelNodePlatformOS           = swarmLabelNodePrefix + "platform_os"
+	swarmLabelNodeRole                 = swarmLabelNodePrefix + "role"
+	swarmLabelNodeStatus               = swarmLabelNodePrefix + "status"
+)
+
+func (d *DockerSwarmDiscovery) refreshNodes(ctx context.Context) ([]*targetgroup.Group, error) {
+	tg := &targetgroup.Group{
+		Source: "DockerSwarm",
+	}
+
+	var nodes []node
+	if err := d.client.get(ctx, "/nodes", &nodes); err != nil {
+		return nil, fmt.Errorf("error while listing swarm nodes: %s", err)
+	}
+
+	for _, n := range nodes {
+		labels := model.LabelSet{}
+		addLabels(labels, nodeLabels(n))
+		if n.ManagerStatus != nil {
+			labels[swarmLabelNodeManagerLeader] = model.LabelValue(strconv.FormatBool(n.ManagerStatus.Leader))
+			labels[swarmLabelNodeManagerReachability] = model.LabelValue(n.ManagerStatus.Reachability)
+			labels[swarmLabelNodeManagerAddr] = model.LabelValue(n.ManagerStatus.Addr)
+		}
+
+		labels[model.AddressLabel] = model.LabelValue(net.JoinHostPort(n.Status.Addr, strconv.Itoa(d.port)))
+		tg.Targets = append(tg.Targets, labels)
+	}
+	return []*targetgroup.Group{tg}, nil
+}
+
+// nodeLabels returns the meta labels describing a node, shared by the
+// nodes and tasks roles.
+func nodeLabels(n node) map[string]string {
+	labels := map[string]string{
+		swarmLabelNodeID:                   n.ID,
+		swarmLabelNodeRole:                 n.Spec.Role,
+		swarmLabelNodeAvailability:         n.Spec.Availability,
+		swarmLabelNodeHostname:             n.Description.Hostname,
+		swarmLabelNodePlatformArchitecture: n.Description.Platform.Architecture,
+		swarmLabelNodePlatformOS:           n.Description.Platform.OS,
+		swarmLabelNodeEngineVersion:        n.Description.Engine.EngineVersion,
+		swarmLabelNodeStatus:               n.Status.State,
+		swarmLabelNodeAddress:              n.Status.Addr,
+	}
+	for k, v := range n.Spec.Labels {
+		labels[swarmLabelNodeLabelPrefix+strutil.SanitizeLabelName(k)] = v
+	}
+	return labels
+}
+
+// getNodesLabels returns the meta labels of all nodes, indexed by node ID.
+func getNodesLabels(ctx context.Context, c *client) (map[string]map[string]string, error) {
+	var nodes []node
+	if err := c.get(ctx, "/nodes", &nodes); err != nil {
+		return nil, fmt.Errorf("error while listing swarm nodes: %s", err)
+	}
+	labels := make(map[string]map[string]string, len(nodes))
+	for _, n := range nodes {
+		labels[n.ID] = nodeLabels(n)
+	}
+	return labels, nil
+}
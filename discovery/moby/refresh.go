@@ -0,0 +1,88 @@
+// Copyright 2019 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package moby
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/prometheus/prometheus/discovery/refresh"
+	"github.com/prometheus/prometheus/discovery/targetgroup"
+)
+
+// refresher periodically calls a refresh function and sends the resulting
+// target groups. It implements the Discoverer interface.
+type refresher struct {
+	interval time.Duration
+	logger   log.Logger
+	refreshf func(ctx context.Context) ([]*targetgroup.Group, error)
+
+	failures prometheus.Counter
+	duration prometheus.Summary
+
+	refresh.ErrorReporter
+}
+
+// Run implements the Discoverer interface.
+func (r *refresher) Run(ctx context.Context, ch chan<- []*targetgroup.Group) {
+	// Get an initial set right away.
+	tgs, err := r.refresh(ctx)
+	r.ReportRefresh("", err)
+	if err != nil {
+		level.Error(r.logger).Log("msg", "Unable to refresh target groups", "err", err)
+	} else {
+		select {
+		case ch <- tgs:
+		case <-ctx.Done():
+			return
+		}
+	}
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			tgs, err := r.refresh(ctx)
+			r.ReportRefresh("", err)
+			if err != nil {
+				level.Error(r.logger).Log("msg", "Unable to refresh target groups", "err", err)
+				continue
+			}
+			select {
+			case ch <- tgs:
+			case <-ctx.Done():
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (r *refresher) refresh(ctx context.Context) (tgs []*targetgroup.Group, err error) {
+	t0 := time.Now()
+	defer func() {
+		r.duration.Observe(time.Since(t0).Seconds())
+		if err != nil {
+			r.failures.Inc()
+		}
+	}()
+	return r.refreshf(ctx)
+}
@@ -0,0 +1,118 @@
+// Copyright 2019 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package moby
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/prometheus/common/model"
+
+	"github.com/prometheus/prometheus/discovery/targetgroup"
+	"github.com/prometheus/prometheus/util/strutil"
+)
+
+const (
+	swarmLabelServicePrefix                  = swarmLabel + "service_"
+	swarmLabelServiceEndpointPortName        = swarmLabelServicePrefix + "endpoint_port_name"
+	swarmLabelServiceEndpointPortPublishMode = swarmLabelServicePrefix + "endpoint_port_publish_mode"
+	swarmLabelServiceID                      = swarmLabelServicePrefix + "id"
+	swarmLabelServiceLabelPrefix             = swarmLabelServicePrefix + "label_"
+	swarmLabelServiceName                    = swarmLabelServicePrefix + "name"
+	swarmLabelServiceMode                    = swarmLabelServicePrefix + "mode"
+	swarmLabelServiceUpdatingStatus          = swarmLabelServicePrefix + "updating_status"
+	swarmLabelServiceTaskPrefix              = swarmLabelServicePrefix + "task_"
+	swarmLabelServiceTaskContainerImage      = swarmLabelServiceTaskPrefix + "container_image"
+	swarmLabelServiceTaskContainerHostname   = swarmLabelServiceTaskPrefix + "container_hostname"
+)
+
+func (d *DockerSwarmDiscovery) refreshServices(ctx context.Context) ([]*targetgroup.Group, error) {
+	tg := &targetgroup.Group{
+		Source: "DockerSwarm",
+	}
+
+	var services []service
+	if err := d.client.get(ctx, "/services", &services); err != nil {
+		return nil, fmt.Errorf("error while listing swarm services: %s", err)
+	}
+
+	networkLabels, err := getNetworksLabels(ctx, d.client, swarmLabel)
+	if err != nil {
+		return nil, fmt.Errorf("error while computing swarm network labels: %s", err)
+	}
+
+	for _, s := range services {
+		commonLabels := serviceLabels(s)
+
+		// Services are reachable through their virtual IP on each of
+		// their networks.
+		for _, vip := range s.Endpoint.VirtualIPs {
+			ip, _, err := net.ParseCIDR(vip.Addr)
+			if err != nil {
+				return nil, fmt.Errorf("error while parsing address %s: %s", vip.Addr, err)
+			}
+
+			var added bool
+			for _, p := range s.Endpoint.Ports {
+				if p.Protocol != "tcp" {
+					continue
+				}
+				labels := model.LabelSet{
+					swarmLabelServiceEndpointPortName:        model.LabelValue(p.Name),
+					swarmLabelServiceEndpointPortPublishMode: model.LabelValue(p.PublishMode),
+				}
+				addLabels(labels, commonLabels, networkLabels[vip.NetworkID])
+
+				labels[model.AddressLabel] = model.LabelValue(net.JoinHostPort(ip.String(), strconv.FormatUint(uint64(p.PublishedPort), 10)))
+				tg.Targets = append(tg.Targets, labels)
+				added = true
+			}
+
+			if !added {
+				labels := model.LabelSet{}
+				addLabels(labels, commonLabels, networkLabels[vip.NetworkID])
+
+				labels[model.AddressLabel] = model.LabelValue(net.JoinHostPort(ip.String(), strconv.Itoa(d.port)))
+				tg.Targets = append(tg.Targets, labels)
+			}
+		}
+	}
+	return []*targetgroup.Group{tg}, nil
+}
+
+// serviceLabels returns the meta labels describing a service, shared by the
+// services and tasks roles.
+func serviceLabels(s service) map[string]string {
+	labels := map[string]string{
+		swarmLabelServiceID:                    s.ID,
+		swarmLabelServiceName:                  s.Spec.Name,
+		swarmLabelServiceTaskContainerHostname: s.Spec.TaskTemplate.ContainerSpec.Hostname,
+		swarmLabelServiceTaskContainerImage:    s.Spec.TaskTemplate.ContainerSpec.Image,
+	}
+	switch {
+	case s.Spec.Mode.Global != nil:
+		labels[swarmLabelServiceMode] = "global"
+	case s.Spec.Mode.Replicated != nil:
+		labels[swarmLabelServiceMode] = "replicated"
+	}
+	if s.UpdateStatus != nil {
+		labels[swarmLabelServiceUpdatingStatus] = s.UpdateStatus.State
+	}
+	for k, v := range s.Spec.Labels {
+		labels[swarmLabelServiceLabelPrefix+strutil.SanitizeLabelName(k)] = v
+	}
+	return labels
+}
@@ -0,0 +1,132 @@
+// Copyright 2019 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package moby
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/prometheus/common/model"
+
+	"github.com/prometheus/prometheus/discovery/targetgroup"
+)
+
+const (
+	swarmLabelTaskPrefix          = swarmLabel + "task_"
+	swarmLabelTaskID              = swarmLabelTaskPrefix + "id"
+	swarmLabelTaskContainerID     = swarmLabelTaskPrefix + "container_id"
+	swarmLabelTaskDesiredState    = swarmLabelTaskPrefix + "desired_state"
+	swarmLabelTaskSlot            = swarmLabelTaskPrefix + "slot"
+	swarmLabelTaskState           = swarmLabelTaskPrefix + "state"
+	swarmLabelTaskPortPublishMode = swarmLabelTaskPrefix + "port_publish_mode"
+)
+
+func (d *DockerSwarmDiscovery) refreshTasks(ctx context.Context) ([]*targetgroup.Group, error) {
+	tg := &targetgroup.Group{
+		Source: "DockerSwarm",
+	}
+
+	var tasks []task
+	if err := d.client.get(ctx, "/tasks", &tasks); err != nil {
+		return nil, fmt.Errorf("error while listing swarm tasks: %s", err)
+	}
+
+	var services []service
+	if err := d.client.get(ctx, "/services", &services); err != nil {
+		return nil, fmt.Errorf("error while listing swarm services: %s", err)
+	}
+	serviceLabelsByID := make(map[string]map[string]string, len(services))
+	servicePorts := make(map[string][]portConfig, len(services))
+	for _, s := range services {
+		serviceLabelsByID[s.ID] = serviceLabels(s)
+		servicePorts[s.ID] = s.Endpoint.Ports
+	}
+
+	nodeLabels, err := getNodesLabels(ctx, d.client)
+	if err != nil {
+		return nil, err
+	}
+
+	networkLabels, err := getNetworksLabels(ctx, d.client, swarmLabel)
+	if err != nil {
+		return nil, fmt.Errorf("error while computing swarm network labels: %s", err)
+	}
+
+	for _, t := range tasks {
+		commonLabels := map[string]string{
+			swarmLabelTaskID:           t.ID,
+			swarmLabelTaskDesiredState: t.DesiredState,
+			swarmLabelTaskState:        t.Status.State,
+			swarmLabelTaskSlot:         strconv.Itoa(t.Slot),
+		}
+		if t.Status.ContainerStatus != nil {
+			commonLabels[swarmLabelTaskContainerID] = t.Status.ContainerStatus.ContainerID
+		}
+
+		// Ports published in host mode are reachable on the node of the task.
+		for _, p := range servicePorts[t.ServiceID] {
+			if p.Protocol != "tcp" || p.PublishMode != "host" {
+				continue
+			}
+			nl, ok := nodeLabels[t.NodeID]
+			if !ok {
+				continue
+			}
+			labels := model.LabelSet{
+				swarmLabelTaskPortPublishMode: model.LabelValue(p.PublishMode),
+			}
+			addLabels(labels, commonLabels, serviceLabelsByID[t.ServiceID], nl)
+
+			labels[model.AddressLabel] = model.LabelValue(net.JoinHostPort(nl[swarmLabelNodeAddress], strconv.FormatUint(uint64(p.PublishedPort), 10)))
+			tg.Targets = append(tg.Targets, labels)
+		}
+
+		// Other ports are reachable on the addresses of the task in each of
+		// its networks.
+		for _, na := range t.NetworksAttachments {
+			for _, addr := range na.Addresses {
+				ip, _, err := net.ParseCIDR(addr)
+				if err != nil {
+					return nil, fmt.Errorf("error while parsing address %s: %s", addr, err)
+				}
+
+				var added bool
+				for _, p := range servicePorts[t.ServiceID] {
+					if p.Protocol != "tcp" || p.PublishMode == "host" {
+						continue
+					}
+					labels := model.LabelSet{
+						swarmLabelTaskPortPublishMode: model.LabelValue(p.PublishMode),
+					}
+					addLabels(labels, commonLabels, serviceLabelsByID[t.ServiceID], nodeLabels[t.NodeID], networkLabels[na.Network.ID])
+
+					labels[model.AddressLabel] = model.LabelValue(net.JoinHostPort(ip.String(), strconv.FormatUint(uint64(p.TargetPort), 10)))
+					tg.Targets = append(tg.Targets, labels)
+					added = true
+				}
+
+				if !added {
+					labels := model.LabelSet{}
+					addLabels(labels, commonLabels, serviceLabelsByID[t.ServiceID], nodeLabels[t.NodeID], networkLabels[na.Network.ID])
+
+					labels[model.AddressLabel] = model.LabelValue(net.JoinHostPort(ip.String(), strconv.Itoa(d.port)))
+					tg.Targets = append(tg.Targets, labels)
+				}
+			}
+		}
+	}
+	return []*targetgroup.Group{tg}, nil
+}
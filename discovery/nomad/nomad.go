@@ -0,0 +1,336 @@
+// Copyright 2019 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nomad
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	config_util "github.com/prometheus/common/config"
+	"github.com/prometheus/common/model"
+
+	sd_config "github.com/prometheus/prometheus/discovery/config"
+	"github.com/prometheus/prometheus/discovery/refresh"
+	"github.com/prometheus/prometheus/discovery/targetgroup"
+)
+
+const (
+	// metaLabelPrefix is the meta prefix used for all meta labels in this discovery.
+	metaLabelPrefix = model.MetaLabelPrefix + "nomad_"
+
+	// serviceLabel is the name of the label containing the service name.
+	serviceLabel = metaLabelPrefix + "service"
+	// serviceIDLabel is the name of the label containing the ID of the
+	// service registration.
+	serviceIDLabel = serviceLabel + "_id"
+	// serviceAddressLabel is the name of the label containing the address
+	// of the service registration.
+	serviceAddressLabel = serviceLabel + "_address"
+	// servicePortLabel is the name of the label containing the port of the
+	// service registration.
+	servicePortLabel = serviceLabel + "_port"
+	// namespaceLabel is the name of the label containing the namespace.
+	namespaceLabel = metaLabelPrefix + "namespace"
+	// datacenterLabel is the name of the label containing the datacenter.
+	datacenterLabel = metaLabelPrefix + "dc"
+	// nodeIDLabel is the name of the label containing the ID of the client
+	// node running the allocation.
+	nodeIDLabel = metaLabelPrefix + "node_id"
+	// jobIDLabel is the name of the label containing the ID of the job.
+	jobIDLabel = metaLabelPrefix + "job_id"
+	// allocIDLabel is the name of the label containing the allocation ID.
+	allocIDLabel = metaLabelPrefix + "alloc_id"
+	// tagsLabel is the name of the label containing the tags assigned to
+	// the service registration.
+	tagsLabel = metaLabelPrefix + "tags"
+
+	// Constants for instrumentation.
+	namespace = "prometheus"
+)
+
+var (
+	// DefaultSDConfig is the default Nomad SD configuration.
+	DefaultSDConfig = SDConfig{
+		AllowStale:      true,
+		Namespace:       "default",
+		RefreshInterval: model.Duration(60 * time.Second),
+		Region:          "global",
+		Server:          "http://localhost:4646",
+		TagSeparator:    ",",
+	}
+
+	refreshFailuresCount = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "sd_nomad_refresh_failures_total",
+			Help:      "The number of Nomad-SD refresh failures.",
+		})
+	refreshDuration = prometheus.NewSummary(
+		prometheus.SummaryOpts{
+			Namespace: namespace,
+			Name:      "sd_nomad_refresh_duration_seconds",
+			Help:      "The duration of a Nomad-SD refresh in seconds.",
+		})
+)
+
+func init() {
+	sd_config.RegisterSD("nomad_sd_configs", &SDConfig{}, func(cfg interface{}, logger log.Logger) (sd_config.Discoverer, error) {
+		return NewDiscovery(cfg.(*SDConfig), logger)
+	})
+	prometheus.MustRegister(refreshFailuresCount)
+	prometheus.MustRegister(refreshDuration)
+}
+
+// SDConfig is the configuration for Nomad service discovery.
+type SDConfig struct {
+	Server           string                       `yaml:"server"`
+	Namespace        string                       `yaml:"namespace,omitempty"`
+	Region           string                       `yaml:"region,omitempty"`
+	AllowStale       bool                         `yaml:"allow_stale"`
+	TagSeparator     string                       `yaml:"tag_separator,omitempty"`
+	RefreshInterval  model.Duration               `yaml:"refresh_interval,omitempty"`
+	HTTPClientConfig config_util.HTTPClientConfig `yaml:",inline"`
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+func (c *SDConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultSDConfig
+	type plain SDConfig
+	err := unmarshal((*plain)(c))
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(c.Server) == "" {
+		return fmt.Errorf("nomad_sd: server must not be empty")
+	}
+	u, err := url.Parse(c.Server)
+	if err != nil {
+		return err
+	}
+	if len(u.Scheme) == 0 || len(u.Host) == 0 {
+		return fmt.Errorf("nomad_sd: invalid server URL")
+	}
+	if c.RefreshInterval <= 0 {
+		return fmt.Errorf("nomad_sd: refresh_interval must be positive")
+	}
+	return c.HTTPClientConfig.Validate()
+}
+
+// SetDirectory joins the relative paths of the configuration with dir. It
+// implements the discovery/config.DirectorySetter interface.
+func (c *SDConfig) SetDirectory(dir string) {
+	sd_config.SetHTTPClientConfigDirectory(&c.HTTPClientConfig, dir)
+}
+
+// serviceListStub is the summary of the services of a namespace as
+// returned by the /v1/services endpoint.
+type serviceListStub struct {
+	Namespace string
+	Services  []struct {
+		ServiceName string
+		Tags        []string
+	}
+}
+
+// serviceRegistration is a single registration of a service as returned
+// by the /v1/service/<name> endpoint.
+type serviceRegistration struct {
+	ID          string
+	ServiceName string
+	Namespace   string
+	NodeID      string
+	Datacenter  string
+	JobID       string
+	AllocID     string
+	Tags        []string
+	Address     string
+	Port        int
+}
+
+// Discovery periodically performs Nomad requests. It implements
+// the Discoverer interface.
+type Discovery struct {
+	client          *http.Client
+	server          string
+	namespace       string
+	region          string
+	allowStale      bool
+	tagSeparator    string
+	refreshInterval time.Duration
+	logger          log.Logger
+
+	refresh.ErrorReporter
+}
+
+// NewDiscovery returns a new Discovery which periodically refreshes its targets.
+func NewDiscovery(conf *SDConfig, logger log.Logger) (*Discovery, error) {
+	if logger == nil {
+		logger = log.NewNopLogger()
+	}
+
+	rt, err := config_util.NewRoundTripperFromConfig(conf.HTTPClientConfig, "nomad_sd")
+	if err != nil {
+		return nil, err
+	}
+
+	return &Discovery{
+		client:          &http.Client{Transport: rt},
+		server:          strings.TrimSuffix(conf.Server, "/"),
+		namespace:       conf.Namespace,
+		region:          conf.Region,
+		allowStale:      conf.AllowStale,
+		tagSeparator:    conf.TagSeparator,
+		refreshInterval: time.Duration(conf.RefreshInterval),
+		logger:          logger,
+	}, nil
+}
+
+// Run implements the Discoverer interface.
+func (d *Discovery) Run(ctx context.Context, ch chan<- []*targetgroup.Group) {
+	err := d.updateServices(ctx, ch)
+	d.ReportRefresh("", err)
+	if err != nil {
+		level.Error(d.logger).Log("msg", "Error while updating services", "err", err)
+	}
+
+	ticker := time.NewTicker(d.refreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := d.updateServices(ctx, ch)
+			d.ReportRefresh("", err)
+			if err != nil {
+				level.Error(d.logger).Log("msg", "Error while updating services", "err", err)
+			}
+		}
+	}
+}
+
+func (d *Discovery) updateServices(ctx context.Context, ch chan<- []*targetgroup.Group) (err error) {
+	t0 := time.Now()
+	defer func() {
+		refreshDuration.Observe(time.Since(t0).Seconds())
+		if err != nil {
+			refreshFailuresCount.Inc()
+		}
+	}()
+
+	tg, err := d.refresh(ctx)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case ch <- []*targetgroup.Group{tg}:
+	}
+	return nil
+}
+
+// refresh returns a single target group holding all service registrations
+// of the configured namespace. Using a single group makes deregistered
+// services disappear on the next refresh.
+func (d *Discovery) refresh(ctx context.Context) (*targetgroup.Group, error) {
+	var stubs []serviceListStub
+	if err := d.get(ctx, "/v1/services", &stubs); err != nil {
+		return nil, err
+	}
+
+	tg := &targetgroup.Group{
+		Source: "nomad",
+	}
+
+	for _, stub := range stubs {
+		for _, service := range stub.Services {
+			var regs []serviceRegistration
+			if err := d.get(ctx, "/v1/service/"+url.PathEscape(service.ServiceName), &regs); err != nil {
+				return nil, err
+			}
+
+			for _, reg := range regs {
+				target := model.LabelSet{
+					model.AddressLabel:  model.LabelValue(net.JoinHostPort(reg.Address, strconv.Itoa(reg.Port))),
+					serviceLabel:        model.LabelValue(reg.ServiceName),
+					serviceIDLabel:      model.LabelValue(reg.ID),
+					serviceAddressLabel: model.LabelValue(reg.Address),
+					servicePortLabel:    model.LabelValue(strconv.Itoa(reg.Port)),
+					namespaceLabel:      model.LabelValue(reg.Namespace),
+					datacenterLabel:     model.LabelValue(reg.Datacenter),
+					nodeIDLabel:         model.LabelValue(reg.NodeID),
+					jobIDLabel:          model.LabelValue(reg.JobID),
+					allocIDLabel:        model.LabelValue(reg.AllocID),
+				}
+
+				// Add tags surrounded by the separator, so that a regular
+				// expression can match a single tag without wildcards.
+				if len(reg.Tags) > 0 {
+					tags := d.tagSeparator + strings.Join(reg.Tags, d.tagSeparator) + d.tagSeparator
+					target[tagsLabel] = model.LabelValue(tags)
+				}
+
+				tg.Targets = append(tg.Targets, target)
+			}
+		}
+	}
+	return tg, nil
+}
+
+// get requests the given path of the Nomad HTTP API and decodes the JSON
+// response into v.
+func (d *Discovery) get(ctx context.Context, path string, v interface{}) error {
+	params := url.Values{}
+	params.Set("namespace", d.namespace)
+	params.Set("region", d.region)
+	if d.allowStale {
+		params.Set("stale", "")
+	}
+
+	req, err := http.NewRequest("GET", d.server+path+"?"+params.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("server returned HTTP status %s for %s", resp.Status, path)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
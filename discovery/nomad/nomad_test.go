@@ -0,0 +1,189 @@
+// Copyright 2019 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nomad
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/common/model"
+
+	"github.com/prometheus/prometheus/discovery/targetgroup"
+	"github.com/prometheus/prometheus/util/testutil"
+)
+
+type nomadSDTestSuite struct {
+	Mock *SDMock
+}
+
+// SDMock is the interface for the Nomad mock.
+type SDMock struct {
+	t      *testing.T
+	Server *httptest.Server
+	Mux    *http.ServeMux
+}
+
+// NewSDMock returns a new SDMock.
+func NewSDMock(t *testing.T) *SDMock {
+	return &SDMock{
+		t: t,
+	}
+}
+
+// Endpoint returns the URI to the mock server.
+func (m *SDMock) Endpoint() string {
+	return m.Server.URL + "/"
+}
+
+// Setup creates the mock server.
+func (m *SDMock) Setup() {
+	m.Mux = http.NewServeMux()
+	m.Server = httptest.NewServer(m.Mux)
+}
+
+// ShutdownServer disables the mock server.
+func (m *SDMock) ShutdownServer() {
+	m.Server.Close()
+}
+
+func (s *nomadSDTestSuite) TearDownSuite() {
+	s.Mock.ShutdownServer()
+}
+
+func (s *nomadSDTestSuite) SetupTest(t *testing.T) {
+	s.Mock = NewSDMock(t)
+	s.Mock.Setup()
+
+	s.Mock.HandleServicesList()
+	s.Mock.HandleServiceHashiCupsGet()
+}
+
+func (m *SDMock) HandleServicesList() {
+	m.Mux.HandleFunc("/v1/services", func(w http.ResponseWriter, r *http.Request) {
+		testutil.Equals(m.t, "default", r.URL.Query().Get("namespace"))
+		testutil.Equals(m.t, "global", r.URL.Query().Get("region"))
+
+		w.Header().Set("content-type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+
+		fmt.Fprint(w, `
+		[
+			{
+				"Namespace": "default",
+				"Services": [
+					{
+						"ServiceName": "hashicups",
+						"Tags": [
+							"metrics"
+						]
+					}
+				]
+			}
+		]`,
+		)
+	})
+}
+
+func (m *SDMock) HandleServiceHashiCupsGet() {
+	m.Mux.HandleFunc("/v1/service/hashicups", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+
+		fmt.Fprint(w, `
+		[
+			{
+				"ID": "_nomad-task-6a1d5f0a-7362-3f5d-9baf-5ed438918e50-group-hashicups-hashicups-hashicups_ui",
+				"ServiceName": "hashicups",
+				"Namespace": "default",
+				"NodeID": "d92bf1e0-d8b7-4ae6-b1e0-e9f1a7ef7cd3",
+				"Datacenter": "dc1",
+				"JobID": "dashboard",
+				"AllocID": "6a1d5f0a-7362-3f5d-9baf-5ed438918e50",
+				"Tags": [
+					"metrics",
+					"web"
+				],
+				"Address": "127.0.0.1",
+				"Port": 30456,
+				"CreateIndex": 242,
+				"ModifyIndex": 242
+			}
+		]`,
+		)
+	})
+}
+
+func TestConfiguredService(t *testing.T) {
+	conf := &SDConfig{
+		Server: "http://localhost:4646",
+	}
+	_, err := NewDiscovery(conf, nil)
+	testutil.Ok(t, err)
+}
+
+func TestNomadSDRefresh(t *testing.T) {
+	sdmock := &nomadSDTestSuite{}
+	sdmock.SetupTest(t)
+	defer sdmock.TearDownSuite()
+
+	endpoint := sdmock.Mock.Endpoint()
+
+	cfg := DefaultSDConfig
+	cfg.Server = endpoint
+	d, err := NewDiscovery(&cfg, nil)
+	testutil.Ok(t, err)
+
+	ch := make(chan []*targetgroup.Group, 1)
+	testutil.Ok(t, d.updateServices(context.Background(), ch))
+	tgs := <-ch
+	testutil.Equals(t, 1, len(tgs))
+
+	tg := tgs[0]
+	testutil.Equals(t, "nomad", tg.Source)
+	testutil.Equals(t, 1, len(tg.Targets))
+
+	lbls := model.LabelSet{
+		"__address__":                  model.LabelValue("127.0.0.1:30456"),
+		"__meta_nomad_alloc_id":        model.LabelValue("6a1d5f0a-7362-3f5d-9baf-5ed438918e50"),
+		"__meta_nomad_dc":              model.LabelValue("dc1"),
+		"__meta_nomad_job_id":          model.LabelValue("dashboard"),
+		"__meta_nomad_namespace":       model.LabelValue("default"),
+		"__meta_nomad_node_id":         model.LabelValue("d92bf1e0-d8b7-4ae6-b1e0-e9f1a7ef7cd3"),
+		"__meta_nomad_service":         model.LabelValue("hashicups"),
+		"__meta_nomad_service_address": model.LabelValue("127.0.0.1"),
+		"__meta_nomad_service_id":      model.LabelValue("_nomad-task-6a1d5f0a-7362-3f5d-9baf-5ed438918e50-group-hashicups-hashicups-hashicups_ui"),
+		"__meta_nomad_service_port":    model.LabelValue("30456"),
+		"__meta_nomad_tags":            model.LabelValue(",metrics,web,"),
+	}
+	testutil.Equals(t, lbls, tg.Targets[0])
+}
+
+func TestNomadSDRefreshError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer ts.Close()
+
+	cfg := DefaultSDConfig
+	cfg.Server = ts.URL
+	d, err := NewDiscovery(&cfg, nil)
+	testutil.Ok(t, err)
+
+	ch := make(chan []*targetgroup.Group, 1)
+	testutil.NotOk(t, d.updateServices(context.Background(), ch), "")
+	testutil.Equals(t, 0, len(ch))
+}
@@ -26,6 +26,7 @@ import (
 	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/hypervisors"
 	"github.com/gophercloud/gophercloud/pagination"
 	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/discovery/refresh"
 	"github.com/prometheus/prometheus/discovery/targetgroup"
 )
 
@@ -45,6 +46,8 @@ type HypervisorDiscovery struct {
 	interval time.Duration
 	logger   log.Logger
 	port     int
+
+	refresh.ErrorReporter
 }
 
 // NewHypervisorDiscovery returns a new hypervisor discovery.
@@ -58,6 +61,7 @@ func NewHypervisorDiscovery(provider *gophercloud.ProviderClient, opts *gophercl
 func (h *HypervisorDiscovery) Run(ctx context.Context, ch chan<- []*targetgroup.Group) {
 	// Get an initial set right away.
 	tg, err := h.refresh()
+	h.ReportRefresh("", err)
 	if err != nil {
 		level.Error(h.logger).Log("msg", "Unable refresh target groups", "err", err.Error())
 	} else {
@@ -75,6 +79,7 @@ func (h *HypervisorDiscovery) Run(ctx context.Context, ch chan<- []*targetgroup.
 		select {
 		case <-ticker.C:
 			tg, err := h.refresh()
+			h.ReportRefresh("", err)
 			if err != nil {
 				level.Error(h.logger).Log("msg", "Unable refresh target groups", "err", err.Error())
 				continue
@@ -27,6 +27,7 @@ import (
 	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
 	"github.com/gophercloud/gophercloud/pagination"
 	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/discovery/refresh"
 	"github.com/prometheus/prometheus/discovery/targetgroup"
 	"github.com/prometheus/prometheus/util/strutil"
 )
@@ -52,6 +53,8 @@ type InstanceDiscovery struct {
 	logger     log.Logger
 	port       int
 	allTenants bool
+
+	refresh.ErrorReporter
 }
 
 // NewInstanceDiscovery returns a new instance discovery.
@@ -68,6 +71,7 @@ func NewInstanceDiscovery(provider *gophercloud.ProviderClient, opts *gopherclou
 func (i *InstanceDiscovery) Run(ctx context.Context, ch chan<- []*targetgroup.Group) {
 	// Get an initial set right away.
 	tg, err := i.refresh()
+	i.ReportRefresh("", err)
 	if err != nil {
 		level.Error(i.logger).Log("msg", "Unable to refresh target groups", "err", err.Error())
 	} else {
@@ -85,6 +89,7 @@ func (i *InstanceDiscovery) Run(ctx context.Context, ch chan<- []*targetgroup.Gr
 		select {
 		case <-ticker.C:
 			tg, err := i.refresh()
+			i.ReportRefresh("", err)
 			if err != nil {
 				level.Error(i.logger).Log("msg", "Unable to refresh target groups", "err", err.Error())
 				continue
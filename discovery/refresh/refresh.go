@@ -0,0 +1,38 @@
+// Copyright 2019 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package refresh provides helpers shared by the Discoverers refreshing
+// their target groups.
+package refresh
+
+// ErrorReporter reports the refreshes of a Discoverer embedding it to the
+// discovery manager. It implements the discovery.ErrorReporter interface.
+type ErrorReporter struct {
+	handler func(unit string, err error)
+}
+
+// SetErrorHandler sets the function called with the outcome of every
+// refresh.
+func (r *ErrorReporter) SetErrorHandler(f func(unit string, err error)) {
+	r.handler = f
+}
+
+// ReportRefresh reports the outcome of a refresh of the given unit, e.g. a
+// service or a file, which is refreshed independently of the other units of
+// the Discoverer. Discoverers refreshing all their targets at once use an
+// empty unit. The error is nil if the refresh succeeded.
+func (r *ErrorReporter) ReportRefresh(unit string, err error) {
+	if r.handler != nil {
+		r.handler(unit, err)
+	}
+}
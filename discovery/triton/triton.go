@@ -30,6 +30,8 @@ import (
 	"github.com/prometheus/common/model"
 
 	config_util "github.com/prometheus/common/config"
+	sd_config "github.com/prometheus/prometheus/discovery/config"
+	"github.com/prometheus/prometheus/discovery/refresh"
 	"github.com/prometheus/prometheus/discovery/targetgroup"
 )
 
@@ -98,6 +100,9 @@ func (c *SDConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
 }
 
 func init() {
+	sd_config.RegisterSD("triton_sd_configs", &SDConfig{}, func(cfg interface{}, logger log.Logger) (sd_config.Discoverer, error) {
+		return New(logger, cfg.(*SDConfig))
+	})
 	prometheus.MustRegister(refreshFailuresCount)
 	prometheus.MustRegister(refreshDuration)
 }
@@ -121,6 +126,8 @@ type Discovery struct {
 	interval time.Duration
 	logger   log.Logger
 	sdConfig *SDConfig
+
+	refresh.ErrorReporter
 }
 
 // New returns a new Discovery which periodically refreshes its targets.
@@ -160,6 +167,7 @@ func (d *Discovery) Run(ctx context.Context, ch chan<- []*targetgroup.Group) {
 
 	// Get an initial set right away.
 	tg, err := d.refresh()
+	d.ReportRefresh("", err)
 	if err != nil {
 		level.Error(d.logger).Log("msg", "Refreshing targets failed", "err", err)
 	} else {
@@ -170,6 +178,7 @@ func (d *Discovery) Run(ctx context.Context, ch chan<- []*targetgroup.Group) {
 		select {
 		case <-ticker.C:
 			tg, err := d.refresh()
+			d.ReportRefresh("", err)
 			if err != nil {
 				level.Error(d.logger).Log("msg", "Refreshing targets failed", "err", err)
 			} else {
@@ -26,6 +26,7 @@ import (
 	"github.com/prometheus/common/model"
 	"github.com/samuel/go-zookeeper/zk"
 
+	sd_config "github.com/prometheus/prometheus/discovery/config"
 	"github.com/prometheus/prometheus/discovery/targetgroup"
 	"github.com/prometheus/prometheus/util/strutil"
 	"github.com/prometheus/prometheus/util/treecache"
@@ -42,6 +43,15 @@ var (
 	}
 )
 
+func init() {
+	sd_config.RegisterSD("serverset_sd_configs", &ServersetSDConfig{}, func(cfg interface{}, logger log.Logger) (sd_config.Discoverer, error) {
+		return NewServersetDiscovery(cfg.(*ServersetSDConfig), logger)
+	})
+	sd_config.RegisterSD("nerve_sd_configs", &NerveSDConfig{}, func(cfg interface{}, logger log.Logger) (sd_config.Discoverer, error) {
+		return NewNerveDiscovery(cfg.(*NerveSDConfig), logger)
+	})
+}
+
 // ServersetSDConfig is the configuration for Twitter serversets in Zookeeper based discovery.
 type ServersetSDConfig struct {
 	Servers []string       `yaml:"servers"`
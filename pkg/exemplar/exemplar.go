@@ -0,0 +1,47 @@
+// Copyright 2019 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exemplar
+
+import "github.com/prometheus/prometheus/pkg/labels"
+
+// The combined length of the label names and values of an exemplar's label
+// set is limited to 128 UTF-8 characters.
+const ExemplarMaxLabelSetLength = 128
+
+// Exemplar is additional information associated with a sample of a time
+// series, for example the ID of a trace the sample was observed in.
+type Exemplar struct {
+	Labels labels.Labels
+	Value  float64
+	Ts     int64
+	HasTs  bool
+}
+
+// Equals compares if the exemplar e is the same as e2. Two exemplars are the
+// same if their labels, values and timestamps are.
+func (e Exemplar) Equals(e2 Exemplar) bool {
+	if !labels.Equal(e.Labels, e2.Labels) {
+		return false
+	}
+	if (e.HasTs || e2.HasTs) && e.Ts != e2.Ts {
+		return false
+	}
+	return e.Value == e2.Value
+}
+
+// QueryResult holds the exemplars of a series.
+type QueryResult struct {
+	SeriesLabels labels.Labels
+	Exemplars    []Exemplar
+}
@@ -16,11 +16,15 @@ package textparse
 import (
 	"mime"
 
+	"github.com/prometheus/common/expfmt"
+
+	"github.com/prometheus/prometheus/pkg/exemplar"
 	"github.com/prometheus/prometheus/pkg/labels"
 )
 
 // Parser parses samples from a byte slice of samples in the official
-// Prometheus and OpenMetrics text exposition formats.
+// Prometheus and OpenMetrics text exposition formats and the protobuf
+// exposition format.
 type Parser interface {
 	// Series returns the bytes of the series, the timestamp if set, and the value
 	// of the current sample.
@@ -50,6 +54,11 @@ type Parser interface {
 	// It returns the string from which the metric was parsed.
 	Metric(l *labels.Labels) string
 
+	// Exemplar writes the exemplar of the current sample into the passed
+	// exemplar. It returns false if the sample has no exemplar.
+	// Must only be called after Next returned a series entry.
+	Exemplar(l *exemplar.Exemplar) bool
+
 	// Next advances the parser to the next sample. It returns false if no
 	// more samples were read or an error occurred.
 	Next() (Entry, error)
@@ -57,9 +66,15 @@ type Parser interface {
 
 // New returns a new parser of the byte slice.
 func New(b []byte, contentType string) Parser {
-	mediaType, _, err := mime.ParseMediaType(contentType)
-	if err == nil && mediaType == "application/openmetrics-text" {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return NewPromParser(b)
+	}
+	switch {
+	case mediaType == "application/openmetrics-text":
 		return NewOpenMetricsParser(b)
+	case mediaType == expfmt.ProtoType && params["proto"] == expfmt.ProtoProtocol && params["encoding"] == "delimited":
+		return NewProtobufParser(b)
 	}
 	return NewPromParser(b)
 }
@@ -26,6 +26,7 @@ import (
 	"strings"
 	"unicode/utf8"
 
+	"github.com/prometheus/prometheus/pkg/exemplar"
 	"github.com/prometheus/prometheus/pkg/labels"
 	"github.com/prometheus/prometheus/pkg/value"
 )
@@ -83,6 +84,9 @@ type OpenMetricsParser struct {
 	hasTS   bool
 	start   int
 	offsets []int
+
+	hasExemplar bool
+	exemplar    exemplar.Exemplar
 }
 
 // New returns a new parser of the byte slice.
@@ -168,6 +172,17 @@ func (p *OpenMetricsParser) Metric(l *labels.Labels) string {
 	return s
 }
 
+// Exemplar writes the exemplar of the current sample into the passed
+// exemplar. It returns false if the sample has no exemplar.
+func (p *OpenMetricsParser) Exemplar(e *exemplar.Exemplar) bool {
+	if !p.hasExemplar {
+		return false
+	}
+	*e = p.exemplar
+	e.Labels = append(labels.Labels(nil), p.exemplar.Labels...)
+	return true
+}
+
 // nextToken returns the next token from the openMetricsLexer.
 func (p *OpenMetricsParser) nextToken() token {
 	tok := p.l.Lex()
@@ -273,9 +288,12 @@ func (p *OpenMetricsParser) Next() (Entry, error) {
 			p.val = math.Float64frombits(value.NormalNaN)
 		}
 		p.hasTS = false
+		p.hasExemplar = false
 		switch p.nextToken() {
 		case tLinebreak:
-			break
+			if err := p.parseLinebreak(); err != nil {
+				return EntryInvalid, err
+			}
 		case tTimestamp:
 			p.hasTS = true
 			var ts float64
@@ -287,6 +305,9 @@ func (p *OpenMetricsParser) Next() (Entry, error) {
 			if t2 := p.nextToken(); t2 != tLinebreak {
 				return EntryInvalid, parseError("expected next entry after timestamp", t)
 			}
+			if err := p.parseLinebreak(); err != nil {
+				return EntryInvalid, err
+			}
 		default:
 			return EntryInvalid, parseError("expected timestamp or new record", t)
 		}
@@ -298,6 +319,116 @@ func (p *OpenMetricsParser) Next() (Entry, error) {
 	return EntryInvalid, err
 }
 
+// parseLinebreak parses the exemplar of the current sample if the line break
+// token holds one. Plain line breaks are a single newline, ones following
+// an exemplar are " # " followed by the exemplar and a newline.
+func (p *OpenMetricsParser) parseLinebreak() error {
+	b := p.l.buf()
+	if len(b) < 3 || b[0] != ' ' {
+		return nil
+	}
+	e, err := parseExemplar(b[3 : len(b)-1])
+	if err != nil {
+		return fmt.Errorf("invalid exemplar: %s", err)
+	}
+	p.exemplar = e
+	p.hasExemplar = true
+	return nil
+}
+
+// parseExemplar parses an exemplar of the form `{name="value",...} value
+// [timestamp]`.
+func parseExemplar(b []byte) (exemplar.Exemplar, error) {
+	var (
+		e         exemplar.Exemplar
+		setLength int
+	)
+	if len(b) == 0 || b[0] != '{' {
+		return e, fmt.Errorf("expected label set")
+	}
+	b = b[1:]
+	for first := true; ; first = false {
+		if len(b) == 0 {
+			return e, fmt.Errorf("unexpected end of label set")
+		}
+		if b[0] == '}' {
+			b = b[1:]
+			break
+		}
+		if !first {
+			if b[0] != ',' {
+				return e, fmt.Errorf("expected comma in label set")
+			}
+			b = b[1:]
+		}
+
+		i := 0
+		for i < len(b) && (b[i] == '_' || (b[i] >= 'a' && b[i] <= 'z') || (b[i] >= 'A' && b[i] <= 'Z') || (i > 0 && b[i] >= '0' && b[i] <= '9')) {
+			i++
+		}
+		if i == 0 {
+			return e, fmt.Errorf("expected label name")
+		}
+		name := string(b[:i])
+		b = b[i:]
+		if len(b) < 2 || b[0] != '=' || b[1] != '"' {
+			return e, fmt.Errorf("expected quoted label value after %q", name)
+		}
+		b = b[2:]
+
+		// Find the closing quote, skipping escaped characters.
+		i = 0
+		for i < len(b) && b[i] != '"' {
+			if b[i] == '\\' {
+				i++
+			}
+			i++
+		}
+		if i >= len(b) {
+			return e, fmt.Errorf("unterminated label value of %q", name)
+		}
+		if !utf8.Valid(b[:i]) {
+			return e, fmt.Errorf("invalid UTF-8 label value")
+		}
+		lv := string(b[:i])
+		if strings.IndexByte(lv, byte('\\')) >= 0 {
+			lv = lvalReplacer.Replace(lv)
+		}
+		b = b[i+1:]
+
+		setLength += utf8.RuneCountInString(name) + utf8.RuneCountInString(lv)
+		e.Labels = append(e.Labels, labels.Label{Name: name, Value: lv})
+	}
+	if setLength > exemplar.ExemplarMaxLabelSetLength {
+		return e, fmt.Errorf("label set of %d characters exceeds the limit of %d", setLength, exemplar.ExemplarMaxLabelSetLength)
+	}
+	sort.Sort(e.Labels)
+
+	fields := strings.Split(string(b), " ")
+	if len(fields) < 2 || len(fields) > 3 || fields[0] != "" {
+		return e, fmt.Errorf("expected value and optional timestamp after label set")
+	}
+	v, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil {
+		return e, err
+	}
+	// Ensure canonical NaN value.
+	if math.IsNaN(v) {
+		v = math.Float64frombits(value.NormalNaN)
+	}
+	e.Value = v
+	if len(fields) == 3 {
+		// A float is enough to hold what we need for millisecond resolution.
+		ts, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil {
+			return e, err
+		}
+		e.Ts = int64(ts * 1000)
+		e.HasTs = true
+	}
+	return e, nil
+}
+
 func (p *OpenMetricsParser) parseLVals() error {
 	first := true
 	for {
@@ -15,8 +15,10 @@ package textparse
 
 import (
 	"io"
+	"strings"
 	"testing"
 
+	"github.com/prometheus/prometheus/pkg/exemplar"
 	"github.com/prometheus/prometheus/pkg/labels"
 	"github.com/stretchr/testify/require"
 )
@@ -41,6 +43,9 @@ go_goroutines 33 123.123
 hh_bucket{le="+Inf"} 1 # {} 4
 # TYPE gh gaugehistogram
 gh_bucket{le="+Inf"} 1 # {} 4
+# TYPE foo counter
+foo_total 17.0 1520879607.789 # {id="counter-test",path="/a\\b"} 5 1520879607.123
+bar_total 1 # {a="b"} 0.5
 # TYPE ii info
 ii{foo="bar"} 1
 # TYPE ss stateset
@@ -66,6 +71,7 @@ testmetric{label="\"bar\""} 1`
 		help    string
 		unit    string
 		comment string
+		e       *exemplar.Exemplar
 	}{
 		{
 			m:    "go_gc_duration_seconds",
@@ -127,6 +133,7 @@ testmetric{label="\"bar\""} 1`
 			m:    `hh_bucket{le="+Inf"}`,
 			v:    1,
 			lset: labels.FromStrings("__name__", "hh_bucket", "le", "+Inf"),
+			e:    &exemplar.Exemplar{Value: 4},
 		}, {
 			m:   "gh",
 			typ: MetricTypeGaugeHistogram,
@@ -134,6 +141,21 @@ testmetric{label="\"bar\""} 1`
 			m:    `gh_bucket{le="+Inf"}`,
 			v:    1,
 			lset: labels.FromStrings("__name__", "gh_bucket", "le", "+Inf"),
+			e:    &exemplar.Exemplar{Value: 4},
+		}, {
+			m:   "foo",
+			typ: MetricTypeCounter,
+		}, {
+			m:    "foo_total",
+			v:    17,
+			t:    int64p(1520879607789),
+			lset: labels.FromStrings("__name__", "foo_total"),
+			e:    &exemplar.Exemplar{Labels: labels.FromStrings("id", "counter-test", "path", `/a\b`), Value: 5, Ts: 1520879607123, HasTs: true},
+		}, {
+			m:    "bar_total",
+			v:    1,
+			lset: labels.FromStrings("__name__", "bar_total"),
+			e:    &exemplar.Exemplar{Labels: labels.FromStrings("a", "b"), Value: 0.5},
 		}, {
 			m:   "ii",
 			typ: MetricTypeInfo,
@@ -201,6 +223,15 @@ testmetric{label="\"bar\""} 1`
 			require.Equal(t, exp[i].lset, res)
 			res = res[:0]
 
+			var e exemplar.Exemplar
+			found := p.Exemplar(&e)
+			if exp[i].e == nil {
+				require.False(t, found)
+			} else {
+				require.True(t, found)
+				require.Equal(t, *exp[i].e, e)
+			}
+
 		case EntryType:
 			m, typ := p.Type()
 			require.Equal(t, exp[i].m, string(m))
@@ -326,6 +357,38 @@ func TestOpenMetricsParseErrors(t *testing.T) {
 			input: "a 1 1 1\n",
 			err:   "expected next entry after timestamp, got \"MNAME\"",
 		},
+		{
+			input: "a 1 # foo\n",
+			err:   "invalid exemplar: expected label set",
+		},
+		{
+			input: "a 1 # {a=\"b\"\n",
+			err:   "invalid exemplar: unexpected end of label set",
+		},
+		{
+			input: "a 1 # {a=b} 1\n",
+			err:   "invalid exemplar: expected quoted label value after \"a\"",
+		},
+		{
+			input: "a 1 # {a=\"b\" c=\"d\"} 1\n",
+			err:   "invalid exemplar: expected comma in label set",
+		},
+		{
+			input: "a 1 # {a=\"b\"}\n",
+			err:   "invalid exemplar: expected value and optional timestamp after label set",
+		},
+		{
+			input: "a 1 # {a=\"b\"} 1 2 3\n",
+			err:   "invalid exemplar: expected value and optional timestamp after label set",
+		},
+		{
+			input: "a 1 # {a=\"b\"} x\n",
+			err:   "invalid exemplar: strconv.ParseFloat: parsing \"x\": invalid syntax",
+		},
+		{
+			input: "a 1 # {a=\"" + strings.Repeat("b", 128) + "\"} 1\n",
+			err:   "invalid exemplar: label set of 129 characters exceeds the limit of 128",
+		},
 		{
 			input: "a{b='c'} 1\n",
 			err:   "expected label value, got \"INVALID\"",
@@ -27,6 +27,7 @@ import (
 	"unicode/utf8"
 	"unsafe"
 
+	"github.com/prometheus/prometheus/pkg/exemplar"
 	"github.com/prometheus/prometheus/pkg/labels"
 	"github.com/prometheus/prometheus/pkg/value"
 )
@@ -234,6 +235,13 @@ func (p *PromParser) Metric(l *labels.Labels) string {
 	return s
 }
 
+// Exemplar writes the exemplar of the current sample into the passed
+// exemplar. The Prometheus format does not have exemplars, so it always
+// returns false.
+func (p *PromParser) Exemplar(e *exemplar.Exemplar) bool {
+	return false
+}
+
 // nextToken returns the next token from the promlexer. It skips over tabs
 // and spaces.
 func (p *PromParser) nextToken() token {
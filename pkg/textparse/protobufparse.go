@@ -0,0 +1,301 @@
+// Copyright 2019 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package textparse
+
+import (
+	"bytes"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+
+	"github.com/prometheus/prometheus/pkg/exemplar"
+	"github.com/prometheus/prometheus/pkg/labels"
+)
+
+// ProtobufParser parses samples from a byte slice of length-delimited
+// io.prometheus.client.MetricFamily protobuf messages, the protobuf
+// exposition format of the client libraries.
+//
+// Summaries and histograms are exposed as the same samples they have in the
+// text format, i.e. one sample per quantile or bucket plus the _sum and
+// _count samples.
+type ProtobufParser struct {
+	dec expfmt.Decoder
+
+	// The current metric family and the position of the current sample in
+	// it: the index of the metric and, for summaries and histograms, the
+	// index of the sample within the metric.
+	mf        dto.MetricFamily
+	metricPos int
+	samplePos int
+
+	state Entry
+
+	// The name, extra label, value and serialized form of the current
+	// sample.
+	name       string
+	extraName  string
+	extraValue string
+	val        float64
+	series     []byte
+}
+
+// NewProtobufParser returns a parser for the byte slice.
+func NewProtobufParser(b []byte) Parser {
+	return &ProtobufParser{
+		dec:   expfmt.NewDecoder(bytes.NewReader(b), expfmt.FmtProtoDelim),
+		state: EntryInvalid,
+	}
+}
+
+// Series returns the bytes of the series, the timestamp if set, and the value
+// of the current sample.
+func (p *ProtobufParser) Series() ([]byte, *int64, float64) {
+	m := p.mf.Metric[p.metricPos]
+	if m.TimestampMs != nil {
+		ts := m.GetTimestampMs()
+		return p.series, &ts, p.val
+	}
+	return p.series, nil, p.val
+}
+
+// Help returns the metric name and help text in the current entry.
+// Must only be called after Next returned a help entry.
+// The returned byte slices become invalid after the next call to Next.
+func (p *ProtobufParser) Help() ([]byte, []byte) {
+	return []byte(p.mf.GetName()), []byte(p.mf.GetHelp())
+}
+
+// Type returns the metric name and type in the current entry.
+// Must only be called after Next returned a type entry.
+// The returned byte slices become invalid after the next call to Next.
+func (p *ProtobufParser) Type() ([]byte, MetricType) {
+	n := []byte(p.mf.GetName())
+	switch p.mf.GetType() {
+	case dto.MetricType_COUNTER:
+		return n, MetricTypeCounter
+	case dto.MetricType_GAUGE:
+		return n, MetricTypeGauge
+	case dto.MetricType_SUMMARY:
+		return n, MetricTypeSummary
+	case dto.MetricType_HISTOGRAM:
+		return n, MetricTypeHistogram
+	}
+	return n, MetricTypeUnknown
+}
+
+// Unit returns the metric name and unit in the current entry.
+// Must only be called after Next returned a unit entry.
+// The returned byte slices become invalid after the next call to Next.
+func (p *ProtobufParser) Unit() ([]byte, []byte) {
+	// The protobuf format does not have units.
+	return nil, nil
+}
+
+// Comment returns the text of the current comment.
+// Must only be called after Next returned a comment entry.
+// The returned byte slice becomes invalid after the next call to Next.
+func (p *ProtobufParser) Comment() []byte {
+	// The protobuf format does not have comments.
+	return nil
+}
+
+// Metric writes the labels of the current sample into the passed labels.
+// It returns the string from which the metric was parsed.
+func (p *ProtobufParser) Metric(l *labels.Labels) string {
+	*l = append(*l, labels.Label{Name: labels.MetricName, Value: p.name})
+	for _, lp := range p.mf.Metric[p.metricPos].GetLabel() {
+		*l = append(*l, labels.Label{Name: lp.GetName(), Value: lp.GetValue()})
+	}
+	if p.extraName != "" {
+		*l = append(*l, labels.Label{Name: p.extraName, Value: p.extraValue})
+	}
+
+	// Sort labels. We can skip the first entry since the metric name is
+	// already at the right place.
+	sort.Sort((*l)[1:])
+
+	return string(p.series)
+}
+
+// Exemplar writes the exemplar of the current sample into the passed
+// exemplar. The supported protobuf format does not have exemplars, so it
+// always returns false.
+func (p *ProtobufParser) Exemplar(e *exemplar.Exemplar) bool {
+	return false
+}
+
+// Next advances the parser to the next sample. It returns false if no
+// more samples were read or an error occurred.
+func (p *ProtobufParser) Next() (Entry, error) {
+	switch p.state {
+	case EntryInvalid:
+		p.mf.Reset()
+		if err := p.dec.Decode(&p.mf); err != nil {
+			return EntryInvalid, err
+		}
+		p.metricPos, p.samplePos = 0, -1
+		if p.mf.GetHelp() != "" {
+			p.state = EntryHelp
+		} else {
+			p.state = EntryType
+		}
+	case EntryHelp:
+		p.state = EntryType
+	case EntryType, EntrySeries:
+		if !p.advance() {
+			p.state = EntryInvalid
+			return p.Next()
+		}
+		p.state = EntrySeries
+		p.updateSeries()
+	}
+	return p.state, nil
+}
+
+// advance moves to the next sample of the current metric family. It returns
+// false if there is none.
+func (p *ProtobufParser) advance() bool {
+	for p.metricPos < len(p.mf.Metric) {
+		p.samplePos++
+		if p.samplePos < p.sampleCount(p.mf.Metric[p.metricPos]) {
+			return true
+		}
+		p.metricPos++
+		p.samplePos = -1
+	}
+	return false
+}
+
+// sampleCount returns the number of samples exposed for m.
+func (p *ProtobufParser) sampleCount(m *dto.Metric) int {
+	if m == nil {
+		return 0
+	}
+	switch p.mf.GetType() {
+	case dto.MetricType_SUMMARY:
+		return len(m.GetSummary().GetQuantile()) + 2
+	case dto.MetricType_HISTOGRAM:
+		n := len(m.GetHistogram().GetBucket()) + 2
+		if !hasInfBucket(m.GetHistogram()) {
+			n++
+		}
+		return n
+	}
+	return 1
+}
+
+// hasInfBucket returns whether the last bucket of h has an infinite upper
+// bound. Otherwise the +Inf bucket is added from the sample count.
+func hasInfBucket(h *dto.Histogram) bool {
+	b := h.GetBucket()
+	return len(b) > 0 && math.IsInf(b[len(b)-1].GetUpperBound(), +1)
+}
+
+// updateSeries sets the name, labels, value and serialization of the current
+// sample.
+func (p *ProtobufParser) updateSeries() {
+	var (
+		m    = p.mf.Metric[p.metricPos]
+		name = p.mf.GetName()
+	)
+	p.name, p.extraName, p.extraValue = name, "", ""
+
+	switch p.mf.GetType() {
+	case dto.MetricType_COUNTER:
+		p.val = m.GetCounter().GetValue()
+	case dto.MetricType_GAUGE:
+		p.val = m.GetGauge().GetValue()
+	case dto.MetricType_SUMMARY:
+		s := m.GetSummary()
+		switch q := s.GetQuantile(); {
+		case p.samplePos < len(q):
+			p.extraName = model.QuantileLabel
+			p.extraValue = formatFloat(q[p.samplePos].GetQuantile())
+			p.val = q[p.samplePos].GetValue()
+		case p.samplePos == len(q):
+			p.name = name + "_sum"
+			p.val = s.GetSampleSum()
+		default:
+			p.name = name + "_count"
+			p.val = float64(s.GetSampleCount())
+		}
+	case dto.MetricType_HISTOGRAM:
+		h := m.GetHistogram()
+		b := h.GetBucket()
+		numBuckets := len(b)
+		if !hasInfBucket(h) {
+			numBuckets++
+		}
+		switch {
+		case p.samplePos < len(b):
+			p.name = name + "_bucket"
+			p.extraName = model.BucketLabel
+			p.extraValue = formatFloat(b[p.samplePos].GetUpperBound())
+			p.val = float64(b[p.samplePos].GetCumulativeCount())
+		case p.samplePos < numBuckets:
+			p.name = name + "_bucket"
+			p.extraName = model.BucketLabel
+			p.extraValue = "+Inf"
+			p.val = float64(h.GetSampleCount())
+		case p.samplePos == numBuckets:
+			p.name = name + "_sum"
+			p.val = h.GetSampleSum()
+		default:
+			p.name = name + "_count"
+			p.val = float64(h.GetSampleCount())
+		}
+	default:
+		p.val = m.GetUntyped().GetValue()
+	}
+
+	// Serialize the series the way the text format writes it.
+	p.series = append(p.series[:0], p.name...)
+	lps := m.GetLabel()
+	if len(lps) == 0 && p.extraName == "" {
+		return
+	}
+	p.series = append(p.series, '{')
+	for i, lp := range lps {
+		if i > 0 {
+			p.series = append(p.series, ',')
+		}
+		p.series = appendLabel(p.series, lp.GetName(), lp.GetValue())
+	}
+	if p.extraName != "" {
+		if len(lps) > 0 {
+			p.series = append(p.series, ',')
+		}
+		p.series = appendLabel(p.series, p.extraName, p.extraValue)
+	}
+	p.series = append(p.series, '}')
+}
+
+var lvalEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func appendLabel(b []byte, name, value string) []byte {
+	b = append(b, name...)
+	b = append(b, `="`...)
+	b = append(b, lvalEscaper.Replace(value)...)
+	return append(b, '"')
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
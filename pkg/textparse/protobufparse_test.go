@@ -0,0 +1,273 @@
+// Copyright 2019 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package textparse
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/common/expfmt"
+	"github.com/stretchr/testify/require"
+
+	"github.com/prometheus/prometheus/pkg/labels"
+)
+
+// textToProtobuf converts the text format input into length-delimited
+// protobuf messages, ordered by metric family name.
+func textToProtobuf(t testing.TB, input string) []byte {
+	var tp expfmt.TextParser
+	mfs, err := tp.TextToMetricFamilies(strings.NewReader(input))
+	require.NoError(t, err)
+
+	names := make([]string, 0, len(mfs))
+	for n := range mfs {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	enc := expfmt.NewEncoder(&buf, expfmt.FmtProtoDelim)
+	for _, n := range names {
+		require.NoError(t, enc.Encode(mfs[n]))
+	}
+	return buf.Bytes()
+}
+
+func TestProtobufParse(t *testing.T) {
+	input := `# HELP go_gc_duration_seconds A summary of the GC invocation durations.
+# TYPE go_gc_duration_seconds summary
+go_gc_duration_seconds{quantile="0"} 4.9351e-05
+go_gc_duration_seconds{quantile="0.5"} 8.3835e-05
+go_gc_duration_seconds_sum 0.012139815
+go_gc_duration_seconds_count 99
+# HELP go_goroutines Number of goroutines that currently exist.
+# TYPE go_goroutines gauge
+go_goroutines 33 123123
+# TYPE http_requests_total counter
+http_requests_total{code="200",handler="query"} 12
+http_requests_total{code="500",handler="query"} 1
+# TYPE request_duration_seconds histogram
+request_duration_seconds_bucket{path="/a\nb",le="0.5"} 3
+request_duration_seconds_bucket{path="/a\nb",le="1"} 5
+request_duration_seconds_sum{path="/a\nb"} 2.5
+request_duration_seconds_count{path="/a\nb"} 6
+untyped_metric{a="b"} 1
+`
+
+	int64p := func(x int64) *int64 { return &x }
+
+	exp := []struct {
+		lset labels.Labels
+		m    string
+		t    *int64
+		v    float64
+		typ  MetricType
+		help string
+	}{
+		{
+			m:    "go_gc_duration_seconds",
+			help: "A summary of the GC invocation durations.",
+		}, {
+			m:   "go_gc_duration_seconds",
+			typ: MetricTypeSummary,
+		}, {
+			m:    `go_gc_duration_seconds{quantile="0"}`,
+			v:    4.9351e-05,
+			lset: labels.FromStrings("__name__", "go_gc_duration_seconds", "quantile", "0"),
+		}, {
+			m:    `go_gc_duration_seconds{quantile="0.5"}`,
+			v:    8.3835e-05,
+			lset: labels.FromStrings("__name__", "go_gc_duration_seconds", "quantile", "0.5"),
+		}, {
+			m:    `go_gc_duration_seconds_sum`,
+			v:    0.012139815,
+			lset: labels.FromStrings("__name__", "go_gc_duration_seconds_sum"),
+		}, {
+			m:    `go_gc_duration_seconds_count`,
+			v:    99,
+			lset: labels.FromStrings("__name__", "go_gc_duration_seconds_count"),
+		}, {
+			m:    "go_goroutines",
+			help: "Number of goroutines that currently exist.",
+		}, {
+			m:   "go_goroutines",
+			typ: MetricTypeGauge,
+		}, {
+			m:    `go_goroutines`,
+			v:    33,
+			t:    int64p(123123),
+			lset: labels.FromStrings("__name__", "go_goroutines"),
+		}, {
+			m:   "http_requests_total",
+			typ: MetricTypeCounter,
+		}, {
+			m:    `http_requests_total{code="200",handler="query"}`,
+			v:    12,
+			lset: labels.FromStrings("__name__", "http_requests_total", "code", "200", "handler", "query"),
+		}, {
+			m:    `http_requests_total{code="500",handler="query"}`,
+			v:    1,
+			lset: labels.FromStrings("__name__", "http_requests_total", "code", "500", "handler", "query"),
+		}, {
+			m:   "request_duration_seconds",
+			typ: MetricTypeHistogram,
+		}, {
+			m:    `request_duration_seconds_bucket{path="/a\nb",le="0.5"}`,
+			v:    3,
+			lset: labels.FromStrings("__name__", "request_duration_seconds_bucket", "path", "/a\nb", "le", "0.5"),
+		}, {
+			m:    `request_duration_seconds_bucket{path="/a\nb",le="1"}`,
+			v:    5,
+			lset: labels.FromStrings("__name__", "request_duration_seconds_bucket", "path", "/a\nb", "le", "1"),
+		}, {
+			// The +Inf bucket is added from the sample count.
+			m:    `request_duration_seconds_bucket{path="/a\nb",le="+Inf"}`,
+			v:    6,
+			lset: labels.FromStrings("__name__", "request_duration_seconds_bucket", "path", "/a\nb", "le", "+Inf"),
+		}, {
+			m:    `request_duration_seconds_sum{path="/a\nb"}`,
+			v:    2.5,
+			lset: labels.FromStrings("__name__", "request_duration_seconds_sum", "path", "/a\nb"),
+		}, {
+			m:    `request_duration_seconds_count{path="/a\nb"}`,
+			v:    6,
+			lset: labels.FromStrings("__name__", "request_duration_seconds_count", "path", "/a\nb"),
+		}, {
+			m:   "untyped_metric",
+			typ: MetricTypeUnknown,
+		}, {
+			m:    `untyped_metric{a="b"}`,
+			v:    1,
+			lset: labels.FromStrings("__name__", "untyped_metric", "a", "b"),
+		},
+	}
+
+	p := New(textToProtobuf(t, input), "application/vnd.google.protobuf; proto=io.prometheus.client.MetricFamily; encoding=delimited")
+	_, ok := p.(*ProtobufParser)
+	require.True(t, ok, "unexpected parser type %T", p)
+
+	i := 0
+
+	var res labels.Labels
+
+	for {
+		et, err := p.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+
+		switch et {
+		case EntrySeries:
+			m, ts, v := p.Series()
+
+			p.Metric(&res)
+
+			require.Equal(t, exp[i].m, string(m))
+			require.Equal(t, exp[i].t, ts)
+			require.Equal(t, exp[i].v, v)
+			require.Equal(t, exp[i].lset, res)
+			res = res[:0]
+
+		case EntryType:
+			m, typ := p.Type()
+			require.Equal(t, exp[i].m, string(m))
+			require.Equal(t, exp[i].typ, typ)
+
+		case EntryHelp:
+			m, h := p.Help()
+			require.Equal(t, exp[i].m, string(m))
+			require.Equal(t, exp[i].help, string(h))
+		}
+
+		i++
+	}
+	require.Equal(t, len(exp), i)
+}
+
+func TestProtobufParseErrors(t *testing.T) {
+	// A truncated message.
+	b := textToProtobuf(t, "metric 1\n")
+	p := NewProtobufParser(b[:len(b)-1])
+	_, err := p.Next()
+	require.Error(t, err)
+	require.NotEqual(t, io.EOF, err)
+
+	// No messages at all.
+	p = NewProtobufParser(nil)
+	_, err = p.Next()
+	require.Equal(t, io.EOF, err)
+}
+
+// parsedSamples returns the samples, types and help texts read by p. The
+// samples are written in the text format, ordered.
+func parsedSamples(t *testing.T, p Parser) ([]string, map[string]MetricType, map[string]string) {
+	var (
+		samples []string
+		types   = map[string]MetricType{}
+		helps   = map[string]string{}
+	)
+	for {
+		et, err := p.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+
+		switch et {
+		case EntrySeries:
+			var lset labels.Labels
+			_, ts, v := p.Series()
+			p.Metric(&lset)
+			s := lset.String() + " " + strconv.FormatFloat(v, 'g', -1, 64)
+			if ts != nil {
+				s += " " + strconv.FormatInt(*ts, 10)
+			}
+			samples = append(samples, s)
+		case EntryType:
+			m, typ := p.Type()
+			// Untyped metric families are not distinguishable from ones
+			// without type information.
+			if typ != MetricTypeUnknown {
+				types[string(m)] = typ
+			}
+		case EntryHelp:
+			m, h := p.Help()
+			helps[string(m)] = string(h)
+		}
+	}
+	sort.Strings(samples)
+	return samples, types, helps
+}
+
+func TestProtobufParseConformance(t *testing.T) {
+	for _, fn := range []string{"promtestdata.txt", "promtestdata.nometa.txt"} {
+		t.Run(fn, func(t *testing.T) {
+			buf, err := ioutil.ReadFile(fn)
+			require.NoError(t, err)
+
+			expSamples, expTypes, expHelps := parsedSamples(t, NewPromParser(buf))
+			samples, types, helps := parsedSamples(t, NewProtobufParser(textToProtobuf(t, string(buf))))
+
+			require.Equal(t, expSamples, samples)
+			require.Equal(t, expTypes, types)
+			require.Equal(t, expHelps, helps)
+		})
+	}
+}
@@ -20,6 +20,41 @@ var _ = math.Inf
 // proto package needs to be updated.
 const _ = proto.GoGoProtoPackageIsVersion2 // please upgrade the proto package
 
+type ReadRequest_ResponseType int32
+
+const (
+	// Server will return a single ReadResponse message with matched series that includes list of raw samples.
+	// It's recommended to use streamed response types instead.
+	//
+	// Response headers:
+	// Content-Type: "application/x-protobuf"
+	// Content-Encoding: "snappy"
+	ReadRequest_SAMPLES ReadRequest_ResponseType = 0
+	// Server will stream a delimited ChunkedReadResponse message that contains XOR encoded chunks for a single series.
+	// Each message is following varint size and fixed size bigendian uint32 for CRC32 Castagnoli checksum.
+	//
+	// Response headers:
+	// Content-Type: "application/x-streamed-protobuf; proto=prometheus.ChunkedReadResponse"
+	// Content-Encoding: ""
+	ReadRequest_STREAMED_XOR_CHUNKS ReadRequest_ResponseType = 1
+)
+
+var ReadRequest_ResponseType_name = map[int32]string{
+	0: "SAMPLES",
+	1: "STREAMED_XOR_CHUNKS",
+}
+var ReadRequest_ResponseType_value = map[string]int32{
+	"SAMPLES":             0,
+	"STREAMED_XOR_CHUNKS": 1,
+}
+
+func (x ReadRequest_ResponseType) String() string {
+	return proto.EnumName(ReadRequest_ResponseType_name, int32(x))
+}
+func (ReadRequest_ResponseType) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_remote_7faf0846a46288cd, []int{1, 0}
+}
+
 type WriteRequest struct {
 	Timeseries           []TimeSeries `protobuf:"bytes,1,rep,name=timeseries,proto3" json:"timeseries"`
 	XXX_NoUnkeyedLiteral struct{}     `json:"-"`
@@ -31,7 +66,7 @@ func (m *WriteRequest) Reset()         { *m = WriteRequest{} }
 func (m *WriteRequest) String() string { return proto.CompactTextString(m) }
 func (*WriteRequest) ProtoMessage()    {}
 func (*WriteRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_remote_007cb64b4d8cdf66, []int{0}
+	return fileDescriptor_remote_7faf0846a46288cd, []int{0}
 }
 func (m *WriteRequest) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -68,17 +103,23 @@ func (m *WriteRequest) GetTimeseries() []TimeSeries {
 }
 
 type ReadRequest struct {
-	Queries              []*Query `protobuf:"bytes,1,rep,name=queries,proto3" json:"queries,omitempty"`
-	XXX_NoUnkeyedLiteral struct{} `json:"-"`
-	XXX_unrecognized     []byte   `json:"-"`
-	XXX_sizecache        int32    `json:"-"`
+	Queries []*Query `protobuf:"bytes,1,rep,name=queries,proto3" json:"queries,omitempty"`
+	// accepted_response_types allows negotiating the content type of the response.
+	//
+	// Response types are taken from the list in the FIFO order. If no response type in `accepted_response_types` is
+	// implemented by server, error is returned.
+	// For request that do not contain `accepted_response_types` field the SAMPLES response type will be used.
+	AcceptedResponseTypes []ReadRequest_ResponseType `protobuf:"varint,2,rep,packed,name=accepted_response_types,json=acceptedResponseTypes,proto3,enum=prometheus.ReadRequest_ResponseType" json:"accepted_response_types,omitempty"`
+	XXX_NoUnkeyedLiteral  struct{}                   `json:"-"`
+	XXX_unrecognized      []byte                     `json:"-"`
+	XXX_sizecache         int32                      `json:"-"`
 }
 
 func (m *ReadRequest) Reset()         { *m = ReadRequest{} }
 func (m *ReadRequest) String() string { return proto.CompactTextString(m) }
 func (*ReadRequest) ProtoMessage()    {}
 func (*ReadRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_remote_007cb64b4d8cdf66, []int{1}
+	return fileDescriptor_remote_7faf0846a46288cd, []int{1}
 }
 func (m *ReadRequest) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -114,6 +155,13 @@ func (m *ReadRequest) GetQueries() []*Query {
 	return nil
 }
 
+func (m *ReadRequest) GetAcceptedResponseTypes() []ReadRequest_ResponseType {
+	if m != nil {
+		return m.AcceptedResponseTypes
+	}
+	return nil
+}
+
 type ReadResponse struct {
 	// In same order as the request's queries.
 	Results              []*QueryResult `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
@@ -126,7 +174,7 @@ func (m *ReadResponse) Reset()         { *m = ReadResponse{} }
 func (m *ReadResponse) String() string { return proto.CompactTextString(m) }
 func (*ReadResponse) ProtoMessage()    {}
 func (*ReadResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_remote_007cb64b4d8cdf66, []int{2}
+	return fileDescriptor_remote_7faf0846a46288cd, []int{2}
 }
 func (m *ReadResponse) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -176,7 +224,7 @@ func (m *Query) Reset()         { *m = Query{} }
 func (m *Query) String() string { return proto.CompactTextString(m) }
 func (*Query) ProtoMessage()    {}
 func (*Query) Descriptor() ([]byte, []int) {
-	return fileDescriptor_remote_007cb64b4d8cdf66, []int{3}
+	return fileDescriptor_remote_7faf0846a46288cd, []int{3}
 }
 func (m *Query) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -245,7 +293,7 @@ func (m *QueryResult) Reset()         { *m = QueryResult{} }
 func (m *QueryResult) String() string { return proto.CompactTextString(m) }
 func (*QueryResult) ProtoMessage()    {}
 func (*QueryResult) Descriptor() ([]byte, []int) {
-	return fileDescriptor_remote_007cb64b4d8cdf66, []int{4}
+	return fileDescriptor_remote_7faf0846a46288cd, []int{4}
 }
 func (m *QueryResult) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -281,12 +329,74 @@ func (m *QueryResult) GetTimeseries() []*TimeSeries {
 	return nil
 }
 
+// ChunkedReadResponse is a response when response_type equals STREAMED_XOR_CHUNKS.
+// We strictly stream full series after series, optionally split by time. This means that a single frame can contain
+// partition of the single series, but once a new series is started to be streamed it means that no more chunks will
+// be sent for previous one.
+type ChunkedReadResponse struct {
+	ChunkedSeries []*ChunkedSeries `protobuf:"bytes,1,rep,name=chunked_series,json=chunkedSeries,proto3" json:"chunked_series,omitempty"`
+	// query_index represents an index of the query from ReadRequest.queries these chunks relates to.
+	QueryIndex           int64    `protobuf:"varint,2,opt,name=query_index,json=queryIndex,proto3" json:"query_index,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ChunkedReadResponse) Reset()         { *m = ChunkedReadResponse{} }
+func (m *ChunkedReadResponse) String() string { return proto.CompactTextString(m) }
+func (*ChunkedReadResponse) ProtoMessage()    {}
+func (*ChunkedReadResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_remote_7faf0846a46288cd, []int{5}
+}
+func (m *ChunkedReadResponse) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *ChunkedReadResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_ChunkedReadResponse.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalTo(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (dst *ChunkedReadResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ChunkedReadResponse.Merge(dst, src)
+}
+func (m *ChunkedReadResponse) XXX_Size() int {
+	return m.Size()
+}
+func (m *ChunkedReadResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ChunkedReadResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ChunkedReadResponse proto.InternalMessageInfo
+
+func (m *ChunkedReadResponse) GetChunkedSeries() []*ChunkedSeries {
+	if m != nil {
+		return m.ChunkedSeries
+	}
+	return nil
+}
+
+func (m *ChunkedReadResponse) GetQueryIndex() int64 {
+	if m != nil {
+		return m.QueryIndex
+	}
+	return 0
+}
+
 func init() {
 	proto.RegisterType((*WriteRequest)(nil), "prometheus.WriteRequest")
 	proto.RegisterType((*ReadRequest)(nil), "prometheus.ReadRequest")
 	proto.RegisterType((*ReadResponse)(nil), "prometheus.ReadResponse")
 	proto.RegisterType((*Query)(nil), "prometheus.Query")
 	proto.RegisterType((*QueryResult)(nil), "prometheus.QueryResult")
+	proto.RegisterType((*ChunkedReadResponse)(nil), "prometheus.ChunkedReadResponse")
+	proto.RegisterEnum("prometheus.ReadRequest_ResponseType", ReadRequest_ResponseType_name, ReadRequest_ResponseType_value)
 }
 func (m *WriteRequest) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
@@ -348,6 +458,23 @@ func (m *ReadRequest) MarshalTo(dAtA []byte) (int, error) {
 			i += n
 		}
 	}
+	if len(m.AcceptedResponseTypes) > 0 {
+		dAtA2 := make([]byte, len(m.AcceptedResponseTypes)*10)
+		var j1 int
+		for _, num := range m.AcceptedResponseTypes {
+			for num >= 1<<7 {
+				dAtA2[j1] = uint8(uint64(num)&0x7f | 0x80)
+				num >>= 7
+				j1++
+			}
+			dAtA2[j1] = uint8(num)
+			j1++
+		}
+		dAtA[i] = 0x12
+		i++
+		i = encodeVarintRemote(dAtA, i, uint64(j1))
+		i += copy(dAtA[i:], dAtA2[:j1])
+	}
 	if m.XXX_unrecognized != nil {
 		i += copy(dAtA[i:], m.XXX_unrecognized)
 	}
@@ -428,11 +555,11 @@ func (m *Query) MarshalTo(dAtA []byte) (int, error) {
 		dAtA[i] = 0x22
 		i++
 		i = encodeVarintRemote(dAtA, i, uint64(m.Hints.Size()))
-		n1, err := m.Hints.MarshalTo(dAtA[i:])
+		n3, err := m.Hints.MarshalTo(dAtA[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n1
+		i += n3
 	}
 	if m.XXX_unrecognized != nil {
 		i += copy(dAtA[i:], m.XXX_unrecognized)
@@ -473,6 +600,44 @@ func (m *QueryResult) MarshalTo(dAtA []byte) (int, error) {
 	return i, nil
 }
 
+func (m *ChunkedReadResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *ChunkedReadResponse) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.ChunkedSeries) > 0 {
+		for _, msg := range m.ChunkedSeries {
+			dAtA[i] = 0xa
+			i++
+			i = encodeVarintRemote(dAtA, i, uint64(msg.Size()))
+			n, err := msg.MarshalTo(dAtA[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
+	}
+	if m.QueryIndex != 0 {
+		dAtA[i] = 0x10
+		i++
+		i = encodeVarintRemote(dAtA, i, uint64(m.QueryIndex))
+	}
+	if m.XXX_unrecognized != nil {
+		i += copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	return i, nil
+}
+
 func encodeVarintRemote(dAtA []byte, offset int, v uint64) int {
 	for v >= 1<<7 {
 		dAtA[offset] = uint8(v&0x7f | 0x80)
@@ -512,6 +677,13 @@ func (m *ReadRequest) Size() (n int) {
 			n += 1 + l + sovRemote(uint64(l))
 		}
 	}
+	if len(m.AcceptedResponseTypes) > 0 {
+		l = 0
+		for _, e := range m.AcceptedResponseTypes {
+			l += sovRemote(uint64(e))
+		}
+		n += 1 + sovRemote(uint64(l)) + l
+	}
 	if m.XXX_unrecognized != nil {
 		n += len(m.XXX_unrecognized)
 	}
@@ -582,6 +754,27 @@ func (m *QueryResult) Size() (n int) {
 	return n
 }
 
+func (m *ChunkedReadResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if len(m.ChunkedSeries) > 0 {
+		for _, e := range m.ChunkedSeries {
+			l = e.Size()
+			n += 1 + l + sovRemote(uint64(l))
+		}
+	}
+	if m.QueryIndex != 0 {
+		n += 1 + sovRemote(uint64(m.QueryIndex))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
 func sovRemote(x uint64) (n int) {
 	for {
 		n++
@@ -737,6 +930,72 @@ func (m *ReadRequest) Unmarshal(dAtA []byte) error {
 				return err
 			}
 			iNdEx = postIndex
+		case 2:
+			if wireType == 0 {
+				var v ReadRequest_ResponseType
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowRemote
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					v |= (ReadRequest_ResponseType(b) & 0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				m.AcceptedResponseTypes = append(m.AcceptedResponseTypes, v)
+			} else if wireType == 2 {
+				var packedLen int
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowRemote
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					packedLen |= (int(b) & 0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				if packedLen < 0 {
+					return ErrInvalidLengthRemote
+				}
+				postIndex := iNdEx + packedLen
+				if postIndex > l {
+					return io.ErrUnexpectedEOF
+				}
+				var elementCount int
+				if elementCount != 0 && len(m.AcceptedResponseTypes) == 0 {
+					m.AcceptedResponseTypes = make([]ReadRequest_ResponseType, 0, elementCount)
+				}
+				for iNdEx < postIndex {
+					var v ReadRequest_ResponseType
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowRemote
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						v |= (ReadRequest_ResponseType(b) & 0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					m.AcceptedResponseTypes = append(m.AcceptedResponseTypes, v)
+				}
+			} else {
+				return fmt.Errorf("proto: wrong wireType = %d for field AcceptedResponseTypes", wireType)
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipRemote(dAtA[iNdEx:])
@@ -1076,6 +1335,107 @@ func (m *QueryResult) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
+func (m *ChunkedReadResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowRemote
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: ChunkedReadResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: ChunkedReadResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ChunkedSeries", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRemote
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthRemote
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ChunkedSeries = append(m.ChunkedSeries, &ChunkedSeries{})
+			if err := m.ChunkedSeries[len(m.ChunkedSeries)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field QueryIndex", wireType)
+			}
+			m.QueryIndex = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRemote
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.QueryIndex |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipRemote(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthRemote
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
 func skipRemote(dAtA []byte) (n int, err error) {
 	l := len(dAtA)
 	iNdEx := 0
@@ -1181,29 +1541,37 @@ var (
 	ErrIntOverflowRemote   = fmt.Errorf("proto: integer overflow")
 )
 
-func init() { proto.RegisterFile("remote.proto", fileDescriptor_remote_007cb64b4d8cdf66) }
-
-var fileDescriptor_remote_007cb64b4d8cdf66 = []byte{
-	// 333 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x91, 0xbf, 0x4a, 0x2b, 0x41,
-	0x14, 0xc6, 0xef, 0xdc, 0xfc, 0xbb, 0x9c, 0x0d, 0x97, 0xdc, 0x21, 0x57, 0x97, 0x14, 0x31, 0x6c,
-	0xb5, 0x10, 0x89, 0x18, 0xc5, 0x42, 0x6c, 0x0c, 0x08, 0x16, 0x49, 0xe1, 0x18, 0x10, 0x6c, 0xc2,
-	0xc6, 0x1c, 0x92, 0x85, 0xcc, 0xce, 0x66, 0xe6, 0x6c, 0x91, 0xd7, 0xb3, 0x4a, 0xe9, 0x13, 0x88,
-	0xe4, 0x49, 0x64, 0x67, 0x59, 0x1d, 0xb1, 0xb1, 0x1b, 0xe6, 0xf7, 0xfb, 0x3e, 0xce, 0xe1, 0x40,
-	0x53, 0xa3, 0x54, 0x84, 0x83, 0x54, 0x2b, 0x52, 0x1c, 0x52, 0xad, 0x24, 0xd2, 0x0a, 0x33, 0xd3,
-	0xf1, 0x68, 0x9b, 0xa2, 0x29, 0x40, 0xa7, 0xbd, 0x54, 0x4b, 0x65, 0x9f, 0x27, 0xf9, 0xab, 0xf8,
-	0x0d, 0xc6, 0xd0, 0x7c, 0xd0, 0x31, 0xa1, 0xc0, 0x4d, 0x86, 0x86, 0xf8, 0x15, 0x00, 0xc5, 0x12,
-	0x0d, 0xea, 0x18, 0x8d, 0xcf, 0x7a, 0x95, 0xd0, 0x1b, 0x1e, 0x0c, 0x3e, 0x3b, 0x07, 0xd3, 0x58,
-	0xe2, 0xbd, 0xa5, 0xa3, 0xea, 0xee, 0xf5, 0xe8, 0x97, 0x70, 0xfc, 0xe0, 0x12, 0x3c, 0x81, 0xd1,
-	0xa2, 0x2c, 0xeb, 0x43, 0x63, 0x93, 0xb9, 0x4d, 0xff, 0xdc, 0xa6, 0xbb, 0x0c, 0xf5, 0x56, 0x94,
-	0x46, 0x70, 0x0d, 0xcd, 0x22, 0x6b, 0x52, 0x95, 0x18, 0xe4, 0xa7, 0xd0, 0xd0, 0x68, 0xb2, 0x35,
-	0x95, 0xe1, 0xc3, 0xef, 0x61, 0xcb, 0x45, 0xe9, 0x05, 0xcf, 0x0c, 0x6a, 0x16, 0xf0, 0x63, 0xe0,
-	0x86, 0x22, 0x4d, 0x33, 0x3b, 0x1c, 0x45, 0x32, 0x9d, 0xc9, 0xbc, 0x87, 0x85, 0x15, 0xd1, 0xb2,
-	0x64, 0x5a, 0x82, 0x89, 0xe1, 0x21, 0xb4, 0x30, 0x59, 0x7c, 0x75, 0x7f, 0x5b, 0xf7, 0x2f, 0x26,
-	0x0b, 0xd7, 0x3c, 0x87, 0x3f, 0x32, 0xa2, 0xa7, 0x15, 0x6a, 0xe3, 0x57, 0xec, 0x54, 0xbe, 0x3b,
-	0xd5, 0x38, 0x9a, 0xe3, 0x7a, 0x52, 0x08, 0xe2, 0xc3, 0xe4, 0x7d, 0xa8, 0xad, 0xe2, 0x84, 0x8c,
-	0x5f, 0xed, 0xb1, 0xd0, 0x1b, 0xfe, 0x77, 0x23, 0xf9, 0xce, 0xb7, 0x39, 0x14, 0x85, 0x13, 0xdc,
-	0x80, 0xe7, 0x2c, 0xc7, 0x2f, 0x7e, 0x7e, 0x10, 0xf7, 0x14, 0xa3, 0xf6, 0x6e, 0xdf, 0x65, 0x2f,
-	0xfb, 0x2e, 0x7b, 0xdb, 0x77, 0xd9, 0x63, 0x3d, 0x0f, 0xa4, 0xf3, 0x79, 0xdd, 0x5e, 0xfd, 0xec,
-	0x3d, 0x00, 0x00, 0xff, 0xff, 0x9e, 0xb6, 0x05, 0x1c, 0x34, 0x02, 0x00, 0x00,
+func init() { proto.RegisterFile("remote.proto", fileDescriptor_remote_7faf0846a46288cd) }
+
+var fileDescriptor_remote_7faf0846a46288cd = []byte{
+	// 463 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x93, 0xcf, 0x6e, 0xd3, 0x40,
+	0x10, 0xc6, 0xbb, 0x4d, 0xdb, 0xa0, 0x71, 0x88, 0xc2, 0xb6, 0x25, 0xa6, 0x87, 0x34, 0xb2, 0x38,
+	0x58, 0x2a, 0x0a, 0x22, 0x54, 0x9c, 0x38, 0x90, 0x96, 0x48, 0x45, 0x24, 0xfc, 0x59, 0x07, 0x81,
+	0x10, 0x92, 0xe5, 0xd8, 0xa3, 0xc6, 0xa2, 0xb6, 0xb7, 0xbb, 0x6b, 0xa9, 0x79, 0x3d, 0x4e, 0x39,
+	0x21, 0x9e, 0x00, 0xa1, 0x3c, 0x09, 0xf2, 0x3a, 0x86, 0x35, 0x5c, 0x7a, 0x5b, 0x7f, 0xdf, 0x37,
+	0xbf, 0xdd, 0x19, 0x8d, 0xa1, 0x25, 0x30, 0xc9, 0x14, 0x0e, 0xb8, 0xc8, 0x54, 0x46, 0x81, 0x8b,
+	0x2c, 0x41, 0xb5, 0xc0, 0x5c, 0x1e, 0x59, 0x6a, 0xc9, 0x51, 0x96, 0xc6, 0xd1, 0xc1, 0x65, 0x76,
+	0x99, 0xe9, 0xe3, 0xe3, 0xe2, 0x54, 0xaa, 0xce, 0x04, 0x5a, 0x1f, 0x45, 0xac, 0x90, 0xe1, 0x75,
+	0x8e, 0x52, 0xd1, 0xe7, 0x00, 0x2a, 0x4e, 0x50, 0xa2, 0x88, 0x51, 0xda, 0xa4, 0xdf, 0x70, 0xad,
+	0xe1, 0xfd, 0xc1, 0x5f, 0xe6, 0x60, 0x16, 0x27, 0xe8, 0x69, 0xf7, 0x6c, 0x67, 0xf5, 0xf3, 0x78,
+	0x8b, 0x19, 0x79, 0xe7, 0x3b, 0x01, 0x8b, 0x61, 0x10, 0x55, 0xb4, 0x13, 0x68, 0x5e, 0xe7, 0x26,
+	0xea, 0x9e, 0x89, 0x7a, 0x9f, 0xa3, 0x58, 0xb2, 0x2a, 0x41, 0xbf, 0x40, 0x37, 0x08, 0x43, 0xe4,
+	0x0a, 0x23, 0x5f, 0xa0, 0xe4, 0x59, 0x2a, 0xd1, 0xd7, 0x1d, 0xd8, 0xdb, 0xfd, 0x86, 0xdb, 0x1e,
+	0x3e, 0x34, 0x8b, 0x8d, 0x6b, 0x06, 0x6c, 0x93, 0x9e, 0x2d, 0x39, 0xb2, 0xc3, 0x0a, 0x62, 0xaa,
+	0xd2, 0x39, 0x85, 0x96, 0x29, 0x50, 0x0b, 0x9a, 0xde, 0x68, 0xfa, 0x6e, 0x32, 0xf6, 0x3a, 0x5b,
+	0xb4, 0x0b, 0xfb, 0xde, 0x8c, 0x8d, 0x47, 0xd3, 0xf1, 0x4b, 0xff, 0xd3, 0x5b, 0xe6, 0x9f, 0x5f,
+	0x7c, 0x78, 0xf3, 0xda, 0xeb, 0x10, 0x67, 0x04, 0xad, 0xf2, 0xa2, 0xb2, 0x92, 0x3e, 0x81, 0xa6,
+	0x40, 0x99, 0x5f, 0xa9, 0xaa, 0xa1, 0xee, 0xff, 0x0d, 0x69, 0x9f, 0x55, 0x39, 0xe7, 0x1b, 0x81,
+	0x5d, 0x6d, 0xd0, 0x47, 0x40, 0xa5, 0x0a, 0x84, 0xf2, 0xf5, 0xc4, 0x54, 0x90, 0x70, 0x3f, 0x29,
+	0x38, 0xc4, 0x6d, 0xb0, 0x8e, 0x76, 0x66, 0x95, 0x31, 0x95, 0xd4, 0x85, 0x0e, 0xa6, 0x51, 0x3d,
+	0xbb, 0xad, 0xb3, 0x6d, 0x4c, 0x23, 0x33, 0x79, 0x0a, 0x77, 0x92, 0x40, 0x85, 0x0b, 0x14, 0xd2,
+	0x6e, 0xe8, 0x57, 0xd9, 0xe6, 0xab, 0x26, 0xc1, 0x1c, 0xaf, 0xa6, 0x65, 0x80, 0xfd, 0x49, 0xd2,
+	0x13, 0xd8, 0x5d, 0xc4, 0xa9, 0x92, 0xf6, 0x4e, 0x9f, 0xb8, 0xd6, 0xf0, 0xf0, 0xdf, 0xe1, 0x5e,
+	0x14, 0x26, 0x2b, 0x33, 0xce, 0x18, 0x2c, 0xa3, 0x39, 0xfa, 0xec, 0xf6, 0x5b, 0x52, 0xdb, 0x8f,
+	0x1b, 0xd8, 0x3f, 0x5f, 0xe4, 0xe9, 0x57, 0x8c, 0x6a, 0x53, 0x7d, 0x01, 0xed, 0xb0, 0x94, 0xfd,
+	0x1a, 0xf2, 0x81, 0x89, 0xdc, 0x14, 0x6e, 0xa8, 0x77, 0x43, 0xf3, 0x93, 0x1e, 0x83, 0x55, 0xac,
+	0xd1, 0xd2, 0x8f, 0xd3, 0x08, 0x6f, 0x36, 0x73, 0x02, 0x2d, 0xbd, 0x2a, 0x94, 0xb3, 0x83, 0xd5,
+	0xba, 0x47, 0x7e, 0xac, 0x7b, 0xe4, 0xd7, 0xba, 0x47, 0x3e, 0xef, 0x15, 0x5c, 0x3e, 0x9f, 0xef,
+	0xe9, 0x9f, 0xe0, 0xe9, 0xef, 0x01, 0x00, 0x9a, 0xb6, 0x6b, 0xcd, 0x43, 0x03, 0x00, 0x00,
 }
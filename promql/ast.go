@@ -111,6 +111,12 @@ type MatrixSelector struct {
 	Offset        time.Duration
 	LabelMatchers []*labels.Matcher
 
+	// Timestamp is the evaluation time in milliseconds set by the @
+	// modifier, if any. StartOrEnd is itemStart or itemEnd if the modifier
+	// referred to the query's start or end time instead.
+	Timestamp  *int64
+	StartOrEnd ItemType
+
 	// The unexpanded seriesSet populated at query preparation time.
 	unexpandedSeriesSet storage.SeriesSet
 	series              []storage.Series
@@ -122,6 +128,10 @@ type SubqueryExpr struct {
 	Range  time.Duration
 	Offset time.Duration
 	Step   time.Duration
+
+	// Set by the @ modifier, see MatrixSelector.
+	Timestamp  *int64
+	StartOrEnd ItemType
 }
 
 // NumberLiteral represents a number.
@@ -153,6 +163,10 @@ type VectorSelector struct {
 	Offset        time.Duration
 	LabelMatchers []*labels.Matcher
 
+	// Set by the @ modifier, see MatrixSelector.
+	Timestamp  *int64
+	StartOrEnd ItemType
+
 	// The unexpanded seriesSet populated at query preparation time.
 	unexpandedSeriesSet storage.SeriesSet
 	series              []storage.Series
@@ -122,6 +122,8 @@ type Query interface {
 	Statement() Statement
 	// Stats returns statistics about the lifetime of the query.
 	Stats() *stats.QueryTimers
+	// SampleStats returns statistics about the samples loaded by the query.
+	SampleStats() *stats.QuerySamples
 	// Cancel signals that a running query execution should be aborted.
 	Cancel()
 }
@@ -136,6 +138,8 @@ type query struct {
 	stmt Statement
 	// Timer stats for the query execution.
 	stats *stats.QueryTimers
+	// Sample stats for the query execution.
+	sampleStats *stats.QuerySamples
 	// Result matrix for reuse.
 	matrix Matrix
 	// Cancellation function for the query.
@@ -155,6 +159,11 @@ func (q *query) Stats() *stats.QueryTimers {
 	return q.stats
 }
 
+// SampleStats implements the Query interface.
+func (q *query) SampleStats() *stats.QuerySamples {
+	return q.sampleStats
+}
+
 // Cancel implements the Query interface.
 func (q *query) Cancel() {
 	if q.cancel != nil {
@@ -200,6 +209,13 @@ func contextErr(err error, env string) error {
 	}
 }
 
+// QueryLogger is an interface that can be used to log all the queries
+// executed by the engine.
+type QueryLogger interface {
+	Log(...interface{}) error
+	Close() error
+}
+
 // EngineOpts contains configuration options used when creating a new Engine.
 type EngineOpts struct {
 	Logger        log.Logger
@@ -207,6 +223,8 @@ type EngineOpts struct {
 	MaxConcurrent int
 	MaxSamples    int
 	Timeout       time.Duration
+	// ActiveQueryTracker records the queries in flight. Optional.
+	ActiveQueryTracker *ActiveQueryTracker
 }
 
 // Engine handles the lifetime of queries from beginning to end.
@@ -217,6 +235,10 @@ type Engine struct {
 	timeout            time.Duration
 	gate               *gate.Gate
 	maxSamplesPerQuery int
+	activeQueryTracker *ActiveQueryTracker
+
+	queryLogger     QueryLogger
+	queryLoggerLock sync.RWMutex
 }
 
 // NewEngine returns a new engine.
@@ -285,9 +307,27 @@ func NewEngine(opts EngineOpts) *Engine {
 		logger:             opts.Logger,
 		metrics:            metrics,
 		maxSamplesPerQuery: opts.MaxSamples,
+		activeQueryTracker: opts.ActiveQueryTracker,
 	}
 }
 
+// SetQueryLogger sets the query logger of the engine. The previous query
+// logger is closed. A nil logger disables query logging.
+func (ng *Engine) SetQueryLogger(l QueryLogger) {
+	ng.queryLoggerLock.Lock()
+	defer ng.queryLoggerLock.Unlock()
+
+	if ng.queryLogger != nil {
+		// An error closing the old file descriptor should
+		// not make reload fail; only log a warning.
+		if err := ng.queryLogger.Close(); err != nil {
+			level.Warn(ng.logger).Log("msg", "Error while closing the previous query log file", "err", err)
+		}
+	}
+
+	ng.queryLogger = l
+}
+
 // NewInstantQuery returns an evaluation query for the given expression at the given time.
 func (ng *Engine) NewInstantQuery(q storage.Queryable, qs string, ts time.Time) (Query, error) {
 	expr, err := ParseExpr(qs)
@@ -317,6 +357,7 @@ func (ng *Engine) NewRangeQuery(q storage.Queryable, qs string, start, end time.
 }
 
 func (ng *Engine) newQuery(q storage.Queryable, expr Expr, start, end time.Time, interval time.Duration) *query {
+	resolveAtModifiers(expr, start, end)
 	es := &EvalStmt{
 		Expr:     expr,
 		Start:    start,
@@ -324,14 +365,49 @@ func (ng *Engine) newQuery(q storage.Queryable, expr Expr, start, end time.Time,
 		Interval: interval,
 	}
 	qry := &query{
-		stmt:      es,
-		ng:        ng,
-		stats:     stats.NewQueryTimers(),
-		queryable: q,
+		stmt:        es,
+		ng:          ng,
+		stats:       stats.NewQueryTimers(),
+		sampleStats: stats.NewQuerySamples(),
+		queryable:   q,
 	}
 	return qry
 }
 
+// resolveAtModifiers sets the timestamps of all @ start() and @ end()
+// modifiers in the expression to the given start and end time.
+func resolveAtModifiers(expr Expr, start, end time.Time) {
+	resolve := func(startOrEnd ItemType) *int64 {
+		var ts int64
+		switch startOrEnd {
+		case itemStart:
+			ts = timestamp.FromTime(start)
+		case itemEnd:
+			ts = timestamp.FromTime(end)
+		default:
+			return nil
+		}
+		return &ts
+	}
+	Inspect(expr, func(node Node, _ []Node) error {
+		switch n := node.(type) {
+		case *VectorSelector:
+			if ts := resolve(n.StartOrEnd); ts != nil {
+				n.Timestamp = ts
+			}
+		case *MatrixSelector:
+			if ts := resolve(n.StartOrEnd); ts != nil {
+				n.Timestamp = ts
+			}
+		case *SubqueryExpr:
+			if ts := resolve(n.StartOrEnd); ts != nil {
+				n.Timestamp = ts
+			}
+		}
+		return nil
+	})
+}
+
 // testStmt is an internal helper statement that allows execution
 // of an arbitrary function during handling. It is used to test the Engine.
 type testStmt func(context.Context) error
@@ -341,10 +417,11 @@ func (testStmt) stmt()          {}
 
 func (ng *Engine) newTestQuery(f func(context.Context) error) Query {
 	qry := &query{
-		q:     "test statement",
-		stmt:  testStmt(f),
-		ng:    ng,
-		stats: stats.NewQueryTimers(),
+		q:           "test statement",
+		stmt:        testStmt(f),
+		ng:          ng,
+		stats:       stats.NewQueryTimers(),
+		sampleStats: stats.NewQuerySamples(),
 	}
 	return qry
 }
@@ -353,13 +430,18 @@ func (ng *Engine) newTestQuery(f func(context.Context) error) Query {
 //
 // At this point per query only one EvalStmt is evaluated. Alert and record
 // statements are not handled by the Engine.
-func (ng *Engine) exec(ctx context.Context, q *query) (Value, storage.Warnings, error) {
+func (ng *Engine) exec(ctx context.Context, q *query) (v Value, w storage.Warnings, err error) {
 	ng.metrics.currentQueries.Inc()
 	defer ng.metrics.currentQueries.Dec()
 
 	ctx, cancel := context.WithTimeout(ctx, ng.timeout)
 	q.cancel = cancel
 
+	// Deferred first so it runs after all timers below are finished.
+	defer func() {
+		ng.logQuery(ctx, q, err)
+	}()
+
 	execSpanTimer, ctx := q.stats.GetSpanTimer(ctx, stats.ExecTotalTime)
 	defer execSpanTimer.Finish()
 
@@ -370,6 +452,14 @@ func (ng *Engine) exec(ctx context.Context, q *query) (Value, storage.Warnings,
 	}
 	defer ng.gate.Done()
 
+	if ng.activeQueryTracker != nil {
+		queryIndex, err := ng.activeQueryTracker.Insert(ctx, q.q)
+		if err != nil {
+			return nil, nil, contextErr(err, "query queue")
+		}
+		defer ng.activeQueryTracker.Delete(queryIndex)
+	}
+
 	queueSpanTimer.Finish()
 
 	// Cancel when execution is done or an error was raised.
@@ -395,6 +485,49 @@ func (ng *Engine) exec(ctx context.Context, q *query) (Value, storage.Warnings,
 	panic(fmt.Errorf("promql.Engine.exec: unhandled statement of type %T", q.Statement()))
 }
 
+// logQuery writes the query, its parameters, timings and origin to the
+// query logger if one is set.
+func (ng *Engine) logQuery(ctx context.Context, q *query, err error) {
+	ng.queryLoggerLock.RLock()
+	defer ng.queryLoggerLock.RUnlock()
+
+	l := ng.queryLogger
+	if l == nil {
+		return
+	}
+
+	params := map[string]interface{}{
+		"query": q.q,
+	}
+	if eq, ok := q.Statement().(*EvalStmt); ok {
+		params["start"] = eq.Start
+		params["end"] = eq.End
+		// The step provided by the user is in seconds.
+		params["step"] = eq.Interval.Seconds()
+	}
+	f := []interface{}{"params", params}
+	if err != nil {
+		f = append(f, "error", err)
+	}
+	f = append(f, "stats", stats.NewQueryStats(q.Stats(), q.SampleStats()))
+	if origin := ctx.Value(queryOrigin{}); origin != nil {
+		for k, v := range origin.(map[string]interface{}) {
+			f = append(f, k, v)
+		}
+	}
+	if err := l.Log(f...); err != nil {
+		level.Error(ng.logger).Log("msg", "Can't log query", "err", err)
+	}
+}
+
+type queryOrigin struct{}
+
+// NewOriginContext returns a new context with data about the origin of the
+// queries executed with it attached. The data is added to the query log.
+func NewOriginContext(ctx context.Context, data map[string]interface{}) context.Context {
+	return context.WithValue(ctx, queryOrigin{}, data)
+}
+
 func timeMilliseconds(t time.Time) int64 {
 	return t.UnixNano() / int64(time.Millisecond/time.Nanosecond)
 }
@@ -432,6 +565,7 @@ func (ng *Engine) execEvalStmt(ctx context.Context, query *query, s *EvalStmt) (
 			maxSamples:          ng.maxSamplesPerQuery,
 			defaultEvalInterval: GetDefaultEvaluationInterval(),
 			logger:              ng.logger,
+			samplesStats:        query.sampleStats,
 		}
 		val, err := evaluator.Eval(s.Expr)
 		if err != nil {
@@ -474,6 +608,7 @@ func (ng *Engine) execEvalStmt(ctx context.Context, query *query, s *EvalStmt) (
 		maxSamples:          ng.maxSamplesPerQuery,
 		defaultEvalInterval: GetDefaultEvaluationInterval(),
 		logger:              ng.logger,
+		samplesStats:        query.sampleStats,
 	}
 	val, err := evaluator.Eval(s.Expr)
 	if err != nil {
@@ -500,44 +635,54 @@ func (ng *Engine) execEvalStmt(ctx context.Context, query *query, s *EvalStmt) (
 	return mat, warnings, nil
 }
 
-// cumulativeSubqueryOffset returns the sum of range and offset of all subqueries in the path.
-func (ng *Engine) cumulativeSubqueryOffset(path []Node) time.Duration {
-	var subqOffset time.Duration
+// selectorTimestamp returns the time in milliseconds a selector with the
+// given @ modifier and offset refers to when evaluated at ts.
+func selectorTimestamp(ts int64, at *int64, offset time.Duration) int64 {
+	if at != nil {
+		ts = *at
+	}
+	return ts - durationMilliseconds(offset)
+}
+
+// selectorTimeRange returns the time range in milliseconds a selector needs
+// data for. lookback is the range of a matrix selector or the lookback delta
+// of a vector selector. The subqueries in the path shift and widen the range
+// the selector is evaluated over.
+func selectorTimeRange(s *EvalStmt, path []Node, at *int64, offset, lookback time.Duration) (int64, int64) {
+	start, end := timestamp.FromTime(s.Start), timestamp.FromTime(s.End)
 	for _, node := range path {
-		switch n := node.(type) {
-		case *SubqueryExpr:
-			subqOffset += n.Range + n.Offset
+		if n, ok := node.(*SubqueryExpr); ok {
+			start = selectorTimestamp(start, n.Timestamp, n.Offset) - durationMilliseconds(n.Range)
+			end = selectorTimestamp(end, n.Timestamp, n.Offset)
 		}
 	}
-	return subqOffset
+	start = selectorTimestamp(start, at, offset) - durationMilliseconds(lookback)
+	end = selectorTimestamp(end, at, offset)
+	return start, end
 }
 
 func (ng *Engine) populateSeries(ctx context.Context, q storage.Queryable, s *EvalStmt) (storage.Querier, storage.Warnings, error) {
-	var maxOffset time.Duration
+	mint, maxt := timestamp.FromTime(s.Start), timestamp.FromTime(s.End)
 	Inspect(s.Expr, func(node Node, path []Node) error {
-		subqOffset := ng.cumulativeSubqueryOffset(path)
+		var start, end int64
 		switch n := node.(type) {
 		case *VectorSelector:
-			if maxOffset < LookbackDelta+subqOffset {
-				maxOffset = LookbackDelta + subqOffset
-			}
-			if n.Offset+LookbackDelta+subqOffset > maxOffset {
-				maxOffset = n.Offset + LookbackDelta + subqOffset
-			}
+			start, end = selectorTimeRange(s, path, n.Timestamp, n.Offset, LookbackDelta)
 		case *MatrixSelector:
-			if maxOffset < n.Range+subqOffset {
-				maxOffset = n.Range + subqOffset
-			}
-			if n.Offset+n.Range+subqOffset > maxOffset {
-				maxOffset = n.Offset + n.Range + subqOffset
-			}
+			start, end = selectorTimeRange(s, path, n.Timestamp, n.Offset, n.Range)
+		default:
+			return nil
+		}
+		if start < mint {
+			mint = start
+		}
+		if end > maxt {
+			maxt = end
 		}
 		return nil
 	})
 
-	mint := s.Start.Add(-maxOffset)
-
-	querier, err := q.Querier(ctx, timestamp.FromTime(mint), timestamp.FromTime(s.End))
+	querier, err := q.Querier(ctx, mint, maxt)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -548,20 +693,13 @@ func (ng *Engine) populateSeries(ctx context.Context, q storage.Queryable, s *Ev
 		var set storage.SeriesSet
 		var wrn storage.Warnings
 		params := &storage.SelectParams{
-			Start: timestamp.FromTime(s.Start),
-			End:   timestamp.FromTime(s.End),
-			Step:  durationToInt64Millis(s.Interval),
+			Step: durationToInt64Millis(s.Interval),
+			Func: extractFuncFromPath(path),
 		}
 
 		switch n := node.(type) {
 		case *VectorSelector:
-			params.Start = params.Start - durationMilliseconds(LookbackDelta)
-			params.Func = extractFuncFromPath(path)
-			if n.Offset > 0 {
-				offsetMilliseconds := durationMilliseconds(n.Offset)
-				params.Start = params.Start - offsetMilliseconds
-				params.End = params.End - offsetMilliseconds
-			}
+			params.Start, params.End = selectorTimeRange(s, path, n.Timestamp, n.Offset, LookbackDelta)
 
 			set, wrn, err = querier.Select(params, n.LabelMatchers...)
 			warnings = append(warnings, wrn...)
@@ -572,15 +710,9 @@ func (ng *Engine) populateSeries(ctx context.Context, q storage.Queryable, s *Ev
 			n.unexpandedSeriesSet = set
 
 		case *MatrixSelector:
-			params.Func = extractFuncFromPath(path)
 			// For all matrix queries we want to ensure that we have (end-start) + range selected
 			// this way we have `range` data before the start time
-			params.Start = params.Start - durationMilliseconds(n.Range)
-			if n.Offset > 0 {
-				offsetMilliseconds := durationMilliseconds(n.Offset)
-				params.Start = params.Start - offsetMilliseconds
-				params.End = params.End - offsetMilliseconds
-			}
+			params.Start, params.End = selectorTimeRange(s, path, n.Timestamp, n.Offset, n.Range)
 
 			set, wrn, err = querier.Select(params, n.LabelMatchers...)
 			warnings = append(warnings, wrn...)
@@ -664,6 +796,7 @@ type evaluator struct {
 	currentSamples      int
 	defaultEvalInterval int64
 	logger              log.Logger
+	samplesStats        *stats.QuerySamples
 }
 
 // errorf causes a panic with the input formatted into an error.
@@ -825,6 +958,7 @@ func (ev *evaluator) rangeEval(f func([]Value, *EvalNodeHelper) Vector, exprs ..
 		enh.out = result[:0] // Reuse result vector.
 
 		ev.currentSamples += len(result)
+		ev.samplesStats.UpdatePeak(ev.currentSamples)
 		// When we reset currentSamples to tempNumSamples during the next iteration of the loop it also
 		// needs to include the samples from the result here, as they're still in memory.
 		tempNumSamples += len(result)
@@ -881,9 +1015,10 @@ func (ev *evaluator) rangeEval(f func([]Value, *EvalNodeHelper) Vector, exprs ..
 func (ev *evaluator) evalSubquery(subq *SubqueryExpr) *MatrixSelector {
 	val := ev.eval(subq).(Matrix)
 	ms := &MatrixSelector{
-		Range:  subq.Range,
-		Offset: subq.Offset,
-		series: make([]storage.Series, 0, len(val)),
+		Range:     subq.Range,
+		Offset:    subq.Offset,
+		Timestamp: subq.Timestamp,
+		series:    make([]storage.Series, 0, len(val)),
 	}
 	for _, s := range val {
 		ms.series = append(ms.series, NewStorageSeries(s))
@@ -970,7 +1105,6 @@ func (ev *evaluator) eval(expr Expr) Value {
 			ev.error(err)
 		}
 		mat := make(Matrix, 0, len(sel.series)) // Output matrix.
-		offset := durationMilliseconds(sel.Offset)
 		selRange := durationMilliseconds(sel.Range)
 		stepRange := selRange
 		if stepRange > ev.interval {
@@ -1004,7 +1138,7 @@ func (ev *evaluator) eval(expr Expr) Value {
 						otherInArgs[j][0].V = otherArgs[j][0].Points[step].V
 					}
 				}
-				maxt := ts - offset
+				maxt := selectorTimestamp(ts, sel.Timestamp, sel.Offset)
 				mint := maxt - selRange
 				// Evaluate the matrix selector for this series for this step.
 				points = ev.matrixIterSlice(it, mint, maxt, points)
@@ -1026,16 +1160,20 @@ func (ev *evaluator) eval(expr Expr) Value {
 				if ev.currentSamples < ev.maxSamples {
 					mat = append(mat, ss)
 					ev.currentSamples += len(ss.Points)
+					ev.samplesStats.UpdatePeak(ev.currentSamples)
 				} else {
 					ev.error(ErrTooManySamples(env))
 				}
 			}
 		}
+		putPointSlice(points)
+
+		if e.Func.Name == "absent_over_time" {
+			return ev.absentOverTime(mat, e.Args[0])
+		}
 		if mat.ContainsSameLabelset() {
 			ev.errorf("vector cannot contain metrics with the same labelset")
 		}
-
-		putPointSlice(points)
 		return mat
 
 	case *ParenExpr:
@@ -1129,6 +1267,7 @@ func (ev *evaluator) eval(expr Expr) Value {
 			}
 
 		}
+		ev.samplesStats.UpdatePeak(ev.currentSamples)
 		return mat
 
 	case *MatrixSelector:
@@ -1138,16 +1277,16 @@ func (ev *evaluator) eval(expr Expr) Value {
 		return ev.matrixSelector(e)
 
 	case *SubqueryExpr:
-		offsetMillis := durationToInt64Millis(e.Offset)
 		rangeMillis := durationToInt64Millis(e.Range)
 		newEv := &evaluator{
-			endTimestamp:        ev.endTimestamp - offsetMillis,
+			endTimestamp:        selectorTimestamp(ev.endTimestamp, e.Timestamp, e.Offset),
 			interval:            ev.defaultEvalInterval,
 			ctx:                 ev.ctx,
 			currentSamples:      ev.currentSamples,
 			maxSamples:          ev.maxSamples,
 			defaultEvalInterval: ev.defaultEvalInterval,
 			logger:              ev.logger,
+			samplesStats:        ev.samplesStats,
 		}
 
 		if e.Step != 0 {
@@ -1156,8 +1295,9 @@ func (ev *evaluator) eval(expr Expr) Value {
 
 		// Start with the first timestamp after (ev.startTimestamp - offset - range)
 		// that is aligned with the step (multiple of 'newEv.interval').
-		newEv.startTimestamp = newEv.interval * ((ev.startTimestamp - offsetMillis - rangeMillis) / newEv.interval)
-		if newEv.startTimestamp < (ev.startTimestamp - offsetMillis - rangeMillis) {
+		start := selectorTimestamp(ev.startTimestamp, e.Timestamp, e.Offset) - rangeMillis
+		newEv.startTimestamp = newEv.interval * (start / newEv.interval)
+		if newEv.startTimestamp < start {
 			newEv.startTimestamp += newEv.interval
 		}
 
@@ -1169,6 +1309,31 @@ func (ev *evaluator) eval(expr Expr) Value {
 	panic(fmt.Errorf("unhandled expression of type: %T", expr))
 }
 
+// absentOverTime turns the per-series result of absent_over_time into a
+// single series which has the value 1 at all steps where none of the selected
+// series had any points.
+func (ev *evaluator) absentOverTime(mat Matrix, arg Expr) Matrix {
+	found := map[int64]struct{}{}
+	for _, s := range mat {
+		for _, p := range s.Points {
+			found[p.T] = struct{}{}
+		}
+	}
+	ev.currentSamples -= mat.TotalSamples()
+
+	var points []Point
+	for ts := ev.startTimestamp; ts <= ev.endTimestamp; ts += ev.interval {
+		if _, ok := found[ts]; !ok {
+			points = append(points, Point{T: ts, V: 1})
+		}
+	}
+	if len(points) == 0 {
+		return Matrix{}
+	}
+	ev.currentSamples += len(points)
+	return Matrix{Series{Metric: absentLabels(arg), Points: points}}
+}
+
 func durationToInt64Millis(d time.Duration) int64 {
 	return int64(d / time.Millisecond)
 }
@@ -1200,12 +1365,13 @@ func (ev *evaluator) vectorSelector(node *VectorSelector, ts int64) Vector {
 			ev.error(ErrTooManySamples(env))
 		}
 	}
+	ev.samplesStats.UpdatePeak(ev.currentSamples)
 	return vec
 }
 
 // vectorSelectorSingle evaluates a instant vector for the iterator of one time series.
 func (ev *evaluator) vectorSelectorSingle(it *storage.BufferedSeriesIterator, node *VectorSelector, ts int64) (int64, float64, bool) {
-	refTime := ts - durationMilliseconds(node.Offset)
+	refTime := selectorTimestamp(ts, node.Timestamp, node.Offset)
 	var t int64
 	var v float64
 
@@ -1229,6 +1395,7 @@ func (ev *evaluator) vectorSelectorSingle(it *storage.BufferedSeriesIterator, no
 	if value.IsStaleNaN(v) {
 		return 0, 0, false
 	}
+	ev.samplesStats.IncrementSamples(1)
 	return t, v, true
 }
 
@@ -1254,8 +1421,7 @@ func (ev *evaluator) matrixSelector(node *MatrixSelector) Matrix {
 	}
 
 	var (
-		offset = durationMilliseconds(node.Offset)
-		maxt   = ev.startTimestamp - offset
+		maxt   = selectorTimestamp(ev.startTimestamp, node.Timestamp, node.Offset)
 		mint   = maxt - durationMilliseconds(node.Range)
 		matrix = make(Matrix, 0, len(node.series))
 	)
@@ -1329,10 +1495,11 @@ func (ev *evaluator) matrixIterSlice(it *storage.BufferedSeriesIterator, mint, m
 			ev.currentSamples++
 		}
 	}
-	// The seeked sample might also be in the range.
+	// The seeked sample might also be in the range. It was already retained
+	// if the previous step ended at the same maxt, e.g. due to an @ modifier.
 	if ok {
 		t, v := it.Values()
-		if t == maxt && !value.IsStaleNaN(v) {
+		if t == maxt && t >= mint && !value.IsStaleNaN(v) {
 			if ev.currentSamples >= ev.maxSamples {
 				ev.error(ErrTooManySamples(env))
 			}
@@ -1340,6 +1507,8 @@ func (ev *evaluator) matrixIterSlice(it *storage.BufferedSeriesIterator, mint, m
 			ev.currentSamples++
 		}
 	}
+	ev.samplesStats.IncrementSamples(len(out))
+	ev.samplesStats.UpdatePeak(ev.currentSamples)
 	return out
 }
 
@@ -1619,6 +1788,8 @@ func scalarBinop(op ItemType, lhs, rhs float64) float64 {
 		return math.Pow(lhs, rhs)
 	case itemMOD:
 		return math.Mod(lhs, rhs)
+	case itemATAN2:
+		return math.Atan2(lhs, rhs)
 	case itemEQL:
 		return btos(lhs == rhs)
 	case itemNEQ:
@@ -1650,6 +1821,8 @@ func vectorElemBinop(op ItemType, lhs, rhs float64) (float64, bool) {
 		return math.Pow(lhs, rhs), true
 	case itemMOD:
 		return math.Mod(lhs, rhs), true
+	case itemATAN2:
+		return math.Atan2(lhs, rhs), true
 	case itemEQL:
 		return lhs, lhs == rhs
 	case itemNEQ:
@@ -1680,7 +1853,7 @@ func (ev *evaluator) aggregation(op ItemType, grouping []string, without bool, p
 
 	result := map[uint64]*groupedAggregation{}
 	var k int64
-	if op == itemTopK || op == itemBottomK {
+	if op == itemTopK || op == itemBottomK || op == itemLimitK {
 		f := param.(float64)
 		if !convertibleToInt64(f) {
 			ev.errorf("Scalar value %v overflows int64", f)
@@ -1694,6 +1867,14 @@ func (ev *evaluator) aggregation(op ItemType, grouping []string, without bool, p
 	if op == itemQuantile {
 		q = param.(float64)
 	}
+	var ratio float64
+	if op == itemLimitRatio {
+		// Ratios beyond ±1 select all series, as they do at ±1.
+		ratio = math.Max(-1, math.Min(1, param.(float64)))
+		if math.IsNaN(ratio) {
+			ev.errorf("Ratio value %v is NaN", param)
+		}
+	}
 	var valueLabel string
 	if op == itemCountValues {
 		valueLabel = param.(string)
@@ -1708,6 +1889,10 @@ func (ev *evaluator) aggregation(op ItemType, grouping []string, without bool, p
 	for _, s := range vec {
 		metric := s.Metric
 
+		if op == itemLimitRatio && !sampleInRatio(metric, ratio) {
+			continue
+		}
+
 		if op == itemCountValues {
 			lb := labels.NewBuilder(metric)
 			lb.Set(valueLabel, strconv.FormatFloat(s.V, 'f', -1, 64))
@@ -1770,6 +1955,9 @@ func (ev *evaluator) aggregation(op ItemType, grouping []string, without bool, p
 					Point:  Point{V: s.V},
 					Metric: s.Metric,
 				})
+			} else if op == itemLimitK || op == itemLimitRatio {
+				result[groupingKey].heap = make(vectorByValueHeap, 0, resultSize)
+				result[groupingKey].heap = append(result[groupingKey].heap, s)
 			}
 			continue
 		}
@@ -1823,9 +2011,17 @@ func (ev *evaluator) aggregation(op ItemType, grouping []string, without bool, p
 				})
 			}
 
-		case itemQuantile:
+		case itemQuantile, itemLimitRatio:
 			group.heap = append(group.heap, s)
 
+		case itemLimitK:
+			if int64(len(group.heap)) < k {
+				group.heap = append(group.heap, s)
+			}
+
+		case itemGroup:
+			// Nothing to do, the value is always 1.
+
 		default:
 			panic(fmt.Errorf("expected aggregation operator but got %q", op))
 		}
@@ -1871,6 +2067,19 @@ func (ev *evaluator) aggregation(op ItemType, grouping []string, without bool, p
 		case itemQuantile:
 			aggr.value = quantile(q, aggr.heap)
 
+		case itemGroup:
+			aggr.value = 1
+
+		case itemLimitK, itemLimitRatio:
+			// The samples are kept as they are, including their labels.
+			for _, v := range aggr.heap {
+				enh.out = append(enh.out, Sample{
+					Metric: v.Metric,
+					Point:  Point{V: v.V},
+				})
+			}
+			continue // Bypass default append.
+
 		default:
 			// For other aggregations, we already have the right value.
 		}
@@ -1883,6 +2092,25 @@ func (ev *evaluator) aggregation(op ItemType, grouping []string, without bool, p
 	return enh.out
 }
 
+// sampleInRatio returns whether the series with the given labels is part of
+// the subset selected by limit_ratio. Each series is assigned an offset in
+// [0, 1) based on its hash. A positive ratio selects the series with an offset
+// below it, a negative ratio the complementary series with an offset of at
+// least 1+ratio, so that limit_ratio(r, v) and limit_ratio(-(1-r), v) split v
+// into disjoint sets.
+func sampleInRatio(l labels.Labels, ratio float64) bool {
+	offset := float64(l.Hash()) / float64(math.MaxUint64)
+	switch {
+	case ratio >= 1 || ratio <= -1:
+		// Avoid rounding errors of the offset close to 1.
+		return true
+	case ratio >= 0:
+		return offset < ratio
+	default:
+		return offset >= 1+ratio
+	}
+}
+
 // btos returns 1 if b is true, 0 otherwise.
 func btos(b bool) float64 {
 	if b {
@@ -1895,7 +2123,7 @@ func btos(b bool) float64 {
 // result of the op operation.
 func shouldDropMetricName(op ItemType) bool {
 	switch op {
-	case itemADD, itemSUB, itemDIV, itemMUL, itemMOD:
+	case itemADD, itemSUB, itemDIV, itemMUL, itemMOD, itemATAN2:
 		return true
 	default:
 		return false
@@ -16,6 +16,7 @@ package promql
 import (
 	"context"
 	"fmt"
+	"math"
 	"reflect"
 	"testing"
 	"time"
@@ -395,6 +396,111 @@ load 10s
 
 }
 
+func TestAtModifier(t *testing.T) {
+	test, err := NewTest(t, `
+load 10s
+  metric{job="1"} 0+1x100
+  metric{job="2"} 0+2x100
+`)
+	testutil.Ok(t, err)
+	defer test.Close()
+
+	testutil.Ok(t, test.Run())
+
+	lbls1 := labels.FromStrings("__name__", "metric", "job", "1")
+	lbls2 := labels.FromStrings("__name__", "metric", "job", "2")
+
+	cases := []struct {
+		Query  string
+		Result Value
+		Start  time.Time
+		End    time.Time
+	}{
+		{
+			Query: "metric @ start()",
+			Result: Matrix{
+				Series{Points: []Point{{V: 1, T: 10000}, {V: 1, T: 20000}, {V: 1, T: 30000}}, Metric: lbls1},
+				Series{Points: []Point{{V: 2, T: 10000}, {V: 2, T: 20000}, {V: 2, T: 30000}}, Metric: lbls2},
+			},
+			Start: time.Unix(10, 0),
+			End:   time.Unix(30, 0),
+		},
+		{
+			Query: "metric @ end()",
+			Result: Matrix{
+				Series{Points: []Point{{V: 3, T: 10000}, {V: 3, T: 20000}, {V: 3, T: 30000}}, Metric: lbls1},
+				Series{Points: []Point{{V: 6, T: 10000}, {V: 6, T: 20000}, {V: 6, T: 30000}}, Metric: lbls2},
+			},
+			Start: time.Unix(10, 0),
+			End:   time.Unix(30, 0),
+		},
+		{
+			// Only the series with the highest value at the end of the
+			// range is kept for every step.
+			Query: "metric and topk(1, metric @ end())",
+			Result: Matrix{
+				Series{Points: []Point{{V: 2, T: 10000}, {V: 4, T: 20000}, {V: 6, T: 30000}}, Metric: lbls2},
+			},
+			Start: time.Unix(10, 0),
+			End:   time.Unix(30, 0),
+		},
+		{
+			// The end of the range is resolved in the outer query's time
+			// range, the subquery's own start and end are not relevant.
+			Query: "max_over_time(metric{job=\"1\"}[20s:10s] @ end())",
+			Result: Matrix{
+				Series{Points: []Point{{V: 3, T: 10000}, {V: 3, T: 20000}, {V: 3, T: 30000}}, Metric: labels.FromStrings("job", "1")},
+			},
+			Start: time.Unix(10, 0),
+			End:   time.Unix(30, 0),
+		},
+		{
+			// Data after the end of the range query is selected.
+			Query: "metric{job=\"1\"} @ 500",
+			Result: Matrix{
+				Series{Points: []Point{{V: 50, T: 10000}, {V: 50, T: 20000}, {V: 50, T: 30000}}, Metric: lbls1},
+			},
+			Start: time.Unix(10, 0),
+			End:   time.Unix(30, 0),
+		},
+	}
+
+	for _, c := range cases {
+		qry, err := test.QueryEngine().NewRangeQuery(test.Queryable(), c.Query, c.Start, c.End, 10*time.Second)
+		testutil.Ok(t, err)
+
+		res := qry.Exec(test.Context())
+		testutil.Ok(t, res.Err)
+		if !reflect.DeepEqual(res.Value, c.Result) {
+			t.Fatalf("unexpected result for query %q: got %q wanted %q", c.Query, res.Value.String(), c.Result.String())
+		}
+	}
+}
+
+func TestSampleInRatio(t *testing.T) {
+	var series []labels.Labels
+	for i := 0; i < 1000; i++ {
+		series = append(series, labels.FromStrings("__name__", "metric", "i", fmt.Sprint(i)))
+	}
+
+	for _, ratio := range []float64{0, 0.1, 0.5, 0.9, 1} {
+		var selected int
+		for _, l := range series {
+			in, out := sampleInRatio(l, ratio), sampleInRatio(l, -(1-ratio))
+			if in == out {
+				t.Fatalf("series %s must be selected by exactly one of %v and %v", l, ratio, -(1 - ratio))
+			}
+			if in {
+				selected++
+			}
+		}
+		// The hash based selection only approximates the ratio.
+		if math.Abs(float64(selected)/float64(len(series))-ratio) > 0.05 {
+			t.Fatalf("ratio %v selected %d of %d series", ratio, selected, len(series))
+		}
+	}
+}
+
 func TestMaxQuerySamples(t *testing.T) {
 	test, err := NewTest(t, `
 load 10s
@@ -622,6 +728,79 @@ load 10s
 	}
 }
 
+func TestQuerySampleStats(t *testing.T) {
+	test, err := NewTest(t, `
+load 10s
+  metric 1+1x10
+`)
+	testutil.Ok(t, err)
+	defer test.Close()
+	testutil.Ok(t, test.Run())
+
+	cases := []struct {
+		Query    string
+		Start    time.Time
+		End      time.Time
+		Interval time.Duration
+		Total    int
+		Peak     int
+	}{
+		{
+			Query: "metric",
+			Start: time.Unix(20, 0),
+			Total: 1,
+			Peak:  1,
+		},
+		{
+			Query: "metric[30s]",
+			Start: time.Unix(30, 0),
+			Total: 4,
+			Peak:  4,
+		},
+		{
+			Query: "sum_over_time(metric[30s])",
+			Start: time.Unix(30, 0),
+			Total: 4,
+			Peak:  5,
+		},
+		{
+			Query:    "metric",
+			Start:    time.Unix(0, 0),
+			End:      time.Unix(100, 0),
+			Interval: 10 * time.Second,
+			Total:    11,
+			Peak:     11,
+		},
+		{
+			Query:    "metric * 2",
+			Start:    time.Unix(0, 0),
+			End:      time.Unix(40, 0),
+			Interval: 10 * time.Second,
+			Total:    5,
+			Peak:     17,
+		},
+	}
+
+	engine := test.QueryEngine()
+	for _, c := range cases {
+		var (
+			qry Query
+			err error
+		)
+		if c.Interval == 0 {
+			qry, err = engine.NewInstantQuery(test.Queryable(), c.Query, c.Start)
+		} else {
+			qry, err = engine.NewRangeQuery(test.Queryable(), c.Query, c.Start, c.End, c.Interval)
+		}
+		testutil.Ok(t, err)
+
+		res := qry.Exec(test.Context())
+		testutil.Ok(t, res.Err)
+		testutil.Equals(t, c.Total, qry.SampleStats().TotalQueryableSamples)
+		testutil.Equals(t, c.Peak, qry.SampleStats().PeakSamples)
+	}
+}
+
 func TestRecoverEvaluatorRuntime(t *testing.T) {
 	ev := &evaluator{logger: log.NewNopLogger()}
 
@@ -914,3 +1093,78 @@ func TestSubquerySelector(t *testing.T) {
 		}
 	}
 }
+
+type fakeQueryLogger struct {
+	closed bool
+	logs   []interface{}
+}
+
+func (f *fakeQueryLogger) Close() error {
+	f.closed = true
+	return nil
+}
+
+func (f *fakeQueryLogger) Log(l ...interface{}) error {
+	f.logs = append(f.logs, l...)
+	return nil
+}
+
+func TestQueryLogger(t *testing.T) {
+	opts := EngineOpts{
+		Logger:        nil,
+		Reg:           nil,
+		MaxConcurrent: 10,
+		MaxSamples:    10,
+		Timeout:       10 * time.Second,
+	}
+	engine := NewEngine(opts)
+
+	queryExec := func(ctx context.Context) {
+		query := engine.newTestQuery(func(ctx context.Context) error {
+			return contextDone(ctx, "test statement execution")
+		})
+		res := query.Exec(ctx)
+		testutil.Ok(t, res.Err)
+	}
+
+	// Query works without query log initialized.
+	queryExec(context.Background())
+
+	f1 := &fakeQueryLogger{}
+	engine.SetQueryLogger(f1)
+	queryExec(context.Background())
+
+	testutil.Equals(t, 4, len(f1.logs))
+	testutil.Equals(t, "params", f1.logs[0])
+	testutil.Equals(t, map[string]interface{}{"query": "test statement"}, f1.logs[1])
+	testutil.Equals(t, "stats", f1.logs[2])
+
+	// The origin of the query is logged.
+	ctx := NewOriginContext(context.Background(), map[string]interface{}{"foo": "bar"})
+	queryExec(ctx)
+	testutil.Equals(t, 10, len(f1.logs))
+	testutil.Equals(t, "foo", f1.logs[8])
+	testutil.Equals(t, "bar", f1.logs[9])
+
+	// Setting a new logger closes the previous one.
+	f2 := &fakeQueryLogger{}
+	engine.SetQueryLogger(f2)
+	testutil.Assert(t, f1.closed, "expected previous query logger to be closed")
+	queryExec(context.Background())
+	testutil.Equals(t, 10, len(f1.logs))
+	testutil.Equals(t, 4, len(f2.logs))
+
+	// Errors are logged.
+	engine.SetQueryLogger(nil)
+	testutil.Assert(t, f2.closed, "expected previous query logger to be closed")
+	f3 := &fakeQueryLogger{}
+	engine.SetQueryLogger(f3)
+	query := engine.newTestQuery(func(ctx context.Context) error {
+		return fmt.Errorf("failure")
+	})
+	res := query.Exec(context.Background())
+	testutil.NotOk(t, res.Err, "expected error")
+	testutil.Equals(t, 6, len(f3.logs))
+	testutil.Equals(t, "error", f3.logs[2])
+	testutil.Equals(t, "failure", f3.logs[3].(error).Error())
+}
@@ -66,8 +66,8 @@ func extrapolatedRate(vals []Value, args Expressions, enh *EvalNodeHelper, isCou
 
 	var (
 		matrix     = vals[0].(Matrix)
-		rangeStart = enh.ts - durationMilliseconds(ms.Range+ms.Offset)
-		rangeEnd   = enh.ts - durationMilliseconds(ms.Offset)
+		rangeEnd   = selectorTimestamp(enh.ts, ms.Timestamp, ms.Offset)
+		rangeStart = rangeEnd - durationMilliseconds(ms.Range)
 	)
 
 	for _, samples := range matrix {
@@ -292,6 +292,23 @@ func funcSortDesc(vals []Value, args Expressions, enh *EvalNodeHelper) Vector {
 	return Vector(byValueSorter)
 }
 
+// === clamp(Vector ValueTypeVector, min, max Scalar) Vector ===
+func funcClamp(vals []Value, args Expressions, enh *EvalNodeHelper) Vector {
+	vec := vals[0].(Vector)
+	min := vals[1].(Vector)[0].Point.V
+	max := vals[2].(Vector)[0].Point.V
+	if max < min {
+		return enh.out
+	}
+	for _, el := range vec {
+		enh.out = append(enh.out, Sample{
+			Metric: enh.dropMetricName(el.Metric),
+			Point:  Point{V: math.Max(min, math.Min(max, el.V))},
+		})
+	}
+	return enh.out
+}
+
 // === clamp_max(Vector ValueTypeVector, max Scalar) Vector ===
 func funcClampMax(vals []Value, args Expressions, enh *EvalNodeHelper) Vector {
 	vec := vals[0].(Vector)
@@ -425,6 +442,46 @@ func funcSumOverTime(vals []Value, args Expressions, enh *EvalNodeHelper) Vector
 	})
 }
 
+// === last_over_time(Matrix ValueTypeMatrix) Vector ===
+func funcLastOverTime(vals []Value, args Expressions, enh *EvalNodeHelper) Vector {
+	return aggrOverTime(vals, enh, func(values []Point) float64 {
+		return values[len(values)-1].V
+	})
+}
+
+// === present_over_time(Matrix ValueTypeMatrix) Vector ===
+func funcPresentOverTime(vals []Value, args Expressions, enh *EvalNodeHelper) Vector {
+	return aggrOverTime(vals, enh, func(values []Point) float64 {
+		return 1
+	})
+}
+
+// === absent_over_time(Matrix ValueTypeMatrix) Vector ===
+// The engine only calls this function for series with points in the range,
+// so it always returns 1. The engine inverts the result afterwards to return
+// 1 for the steps without any points, see evaluator.absentOverTime.
+func funcAbsentOverTime(vals []Value, args Expressions, enh *EvalNodeHelper) Vector {
+	return append(enh.out, Sample{
+		Point: Point{V: 1},
+	})
+}
+
+// === mad_over_time(Matrix ValueTypeMatrix) Vector ===
+func funcMadOverTime(vals []Value, args Expressions, enh *EvalNodeHelper) Vector {
+	return aggrOverTime(vals, enh, func(values []Point) float64 {
+		samples := make(vectorByValueHeap, 0, len(values))
+		for _, v := range values {
+			samples = append(samples, Sample{Point: Point{V: v.V}})
+		}
+		median := quantile(0.5, samples)
+
+		for i := range samples {
+			samples[i].V = math.Abs(samples[i].V - median)
+		}
+		return quantile(0.5, samples)
+	})
+}
+
 // === quantile_over_time(Matrix ValueTypeMatrix) Vector ===
 func funcQuantileOverTime(vals []Value, args Expressions, enh *EvalNodeHelper) Vector {
 	q := vals[0].(Vector)[0].V
@@ -479,22 +536,34 @@ func funcAbsent(vals []Value, args Expressions, enh *EvalNodeHelper) Vector {
 	if len(vals[0].(Vector)) > 0 {
 		return enh.out
 	}
-	m := []labels.Label{}
-
-	if vs, ok := args[0].(*VectorSelector); ok {
-		for _, ma := range vs.LabelMatchers {
-			if ma.Type == labels.MatchEqual && ma.Name != labels.MetricName {
-				m = append(m, labels.Label{Name: ma.Name, Value: ma.Value})
-			}
-		}
-	}
 	return append(enh.out,
 		Sample{
-			Metric: labels.New(m...),
+			Metric: absentLabels(args[0]),
 			Point:  Point{V: 1},
 		})
 }
 
+// absentLabels returns the labels of the result of absent() and
+// absent_over_time(). They are derived from the equality matchers of the
+// argument if it is a selector.
+func absentLabels(expr Expr) labels.Labels {
+	var matchers []*labels.Matcher
+	switch n := expr.(type) {
+	case *VectorSelector:
+		matchers = n.LabelMatchers
+	case *MatrixSelector:
+		matchers = n.LabelMatchers
+	}
+
+	m := []labels.Label{}
+	for _, ma := range matchers {
+		if ma.Type == labels.MatchEqual && ma.Name != labels.MetricName {
+			m = append(m, labels.Label{Name: ma.Name, Value: ma.Value})
+		}
+	}
+	return labels.New(m...)
+}
+
 func simpleFunc(vals []Value, enh *EvalNodeHelper, f func(float64) float64) Vector {
 	for _, el := range vals[0].(Vector) {
 		enh.out = append(enh.out, Sample{
@@ -545,6 +614,101 @@ func funcLog10(vals []Value, args Expressions, enh *EvalNodeHelper) Vector {
 	return simpleFunc(vals, enh, math.Log10)
 }
 
+// === sgn(Vector ValueTypeVector) Vector ===
+func funcSgn(vals []Value, args Expressions, enh *EvalNodeHelper) Vector {
+	return simpleFunc(vals, enh, func(v float64) float64 {
+		switch {
+		case v < 0:
+			return -1
+		case v > 0:
+			return 1
+		}
+		// Keeps the sign of zero and NaN.
+		return v
+	})
+}
+
+// === sin(Vector ValueTypeVector) Vector ===
+func funcSin(vals []Value, args Expressions, enh *EvalNodeHelper) Vector {
+	return simpleFunc(vals, enh, math.Sin)
+}
+
+// === cos(Vector ValueTypeVector) Vector ===
+func funcCos(vals []Value, args Expressions, enh *EvalNodeHelper) Vector {
+	return simpleFunc(vals, enh, math.Cos)
+}
+
+// === tan(Vector ValueTypeVector) Vector ===
+func funcTan(vals []Value, args Expressions, enh *EvalNodeHelper) Vector {
+	return simpleFunc(vals, enh, math.Tan)
+}
+
+// === asin(Vector ValueTypeVector) Vector ===
+func funcAsin(vals []Value, args Expressions, enh *EvalNodeHelper) Vector {
+	return simpleFunc(vals, enh, math.Asin)
+}
+
+// === acos(Vector ValueTypeVector) Vector ===
+func funcAcos(vals []Value, args Expressions, enh *EvalNodeHelper) Vector {
+	return simpleFunc(vals, enh, math.Acos)
+}
+
+// === atan(Vector ValueTypeVector) Vector ===
+func funcAtan(vals []Value, args Expressions, enh *EvalNodeHelper) Vector {
+	return simpleFunc(vals, enh, math.Atan)
+}
+
+// === sinh(Vector ValueTypeVector) Vector ===
+func funcSinh(vals []Value, args Expressions, enh *EvalNodeHelper) Vector {
+	return simpleFunc(vals, enh, math.Sinh)
+}
+
+// === cosh(Vector ValueTypeVector) Vector ===
+func funcCosh(vals []Value, args Expressions, enh *EvalNodeHelper) Vector {
+	return simpleFunc(vals, enh, math.Cosh)
+}
+
+// === tanh(Vector ValueTypeVector) Vector ===
+func funcTanh(vals []Value, args Expressions, enh *EvalNodeHelper) Vector {
+	return simpleFunc(vals, enh, math.Tanh)
+}
+
+// === asinh(Vector ValueTypeVector) Vector ===
+func funcAsinh(vals []Value, args Expressions, enh *EvalNodeHelper) Vector {
+	return simpleFunc(vals, enh, math.Asinh)
+}
+
+// === acosh(Vector ValueTypeVector) Vector ===
+func funcAcosh(vals []Value, args Expressions, enh *EvalNodeHelper) Vector {
+	return simpleFunc(vals, enh, math.Acosh)
+}
+
+// === atanh(Vector ValueTypeVector) Vector ===
+func funcAtanh(vals []Value, args Expressions, enh *EvalNodeHelper) Vector {
+	return simpleFunc(vals, enh, math.Atanh)
+}
+
+// === deg(Vector ValueTypeVector) Vector ===
+func funcDeg(vals []Value, args Expressions, enh *EvalNodeHelper) Vector {
+	return simpleFunc(vals, enh, func(v float64) float64 {
+		return v * 180 / math.Pi
+	})
+}
+
+// === rad(Vector ValueTypeVector) Vector ===
+func funcRad(vals []Value, args Expressions, enh *EvalNodeHelper) Vector {
+	return simpleFunc(vals, enh, func(v float64) float64 {
+		return v * math.Pi / 180
+	})
+}
+
+// === pi() Scalar ===
+func funcPi(vals []Value, args Expressions, enh *EvalNodeHelper) Vector {
+	return Vector{Sample{Point: Point{
+		V: math.Pi,
+	}}}
+}
+
 // === timestamp(Vector ValueTypeVector) Vector ===
 func funcTimestamp(vals []Value, args Expressions, enh *EvalNodeHelper) Vector {
 	vec := vals[0].(Vector)
@@ -919,6 +1083,48 @@ var functions = map[string]*Function{
 		ReturnType: ValueTypeVector,
 		Call:       funcAbsent,
 	},
+	"absent_over_time": {
+		Name:       "absent_over_time",
+		ArgTypes:   []ValueType{ValueTypeMatrix},
+		ReturnType: ValueTypeVector,
+		Call:       funcAbsentOverTime,
+	},
+	"acos": {
+		Name:       "acos",
+		ArgTypes:   []ValueType{ValueTypeVector},
+		ReturnType: ValueTypeVector,
+		Call:       funcAcos,
+	},
+	"acosh": {
+		Name:       "acosh",
+		ArgTypes:   []ValueType{ValueTypeVector},
+		ReturnType: ValueTypeVector,
+		Call:       funcAcosh,
+	},
+	"asin": {
+		Name:       "asin",
+		ArgTypes:   []ValueType{ValueTypeVector},
+		ReturnType: ValueTypeVector,
+		Call:       funcAsin,
+	},
+	"asinh": {
+		Name:       "asinh",
+		ArgTypes:   []ValueType{ValueTypeVector},
+		ReturnType: ValueTypeVector,
+		Call:       funcAsinh,
+	},
+	"atan": {
+		Name:       "atan",
+		ArgTypes:   []ValueType{ValueTypeVector},
+		ReturnType: ValueTypeVector,
+		Call:       funcAtan,
+	},
+	"atanh": {
+		Name:       "atanh",
+		ArgTypes:   []ValueType{ValueTypeVector},
+		ReturnType: ValueTypeVector,
+		Call:       funcAtanh,
+	},
 	"avg_over_time": {
 		Name:       "avg_over_time",
 		ArgTypes:   []ValueType{ValueTypeMatrix},
@@ -937,6 +1143,12 @@ var functions = map[string]*Function{
 		ReturnType: ValueTypeVector,
 		Call:       funcChanges,
 	},
+	"clamp": {
+		Name:       "clamp",
+		ArgTypes:   []ValueType{ValueTypeVector, ValueTypeScalar, ValueTypeScalar},
+		ReturnType: ValueTypeVector,
+		Call:       funcClamp,
+	},
 	"clamp_max": {
 		Name:       "clamp_max",
 		ArgTypes:   []ValueType{ValueTypeVector, ValueTypeScalar},
@@ -949,6 +1161,18 @@ var functions = map[string]*Function{
 		ReturnType: ValueTypeVector,
 		Call:       funcClampMin,
 	},
+	"cos": {
+		Name:       "cos",
+		ArgTypes:   []ValueType{ValueTypeVector},
+		ReturnType: ValueTypeVector,
+		Call:       funcCos,
+	},
+	"cosh": {
+		Name:       "cosh",
+		ArgTypes:   []ValueType{ValueTypeVector},
+		ReturnType: ValueTypeVector,
+		Call:       funcCosh,
+	},
 	"count_over_time": {
 		Name:       "count_over_time",
 		ArgTypes:   []ValueType{ValueTypeMatrix},
@@ -976,6 +1200,12 @@ var functions = map[string]*Function{
 		ReturnType: ValueTypeVector,
 		Call:       funcDayOfWeek,
 	},
+	"deg": {
+		Name:       "deg",
+		ArgTypes:   []ValueType{ValueTypeVector},
+		ReturnType: ValueTypeVector,
+		Call:       funcDeg,
+	},
 	"delta": {
 		Name:       "delta",
 		ArgTypes:   []ValueType{ValueTypeMatrix},
@@ -1050,6 +1280,12 @@ var functions = map[string]*Function{
 		ReturnType: ValueTypeVector,
 		Call:       funcLabelJoin,
 	},
+	"last_over_time": {
+		Name:       "last_over_time",
+		ArgTypes:   []ValueType{ValueTypeMatrix},
+		ReturnType: ValueTypeVector,
+		Call:       funcLastOverTime,
+	},
 	"ln": {
 		Name:       "ln",
 		ArgTypes:   []ValueType{ValueTypeVector},
@@ -1068,6 +1304,12 @@ var functions = map[string]*Function{
 		ReturnType: ValueTypeVector,
 		Call:       funcLog2,
 	},
+	"mad_over_time": {
+		Name:       "mad_over_time",
+		ArgTypes:   []ValueType{ValueTypeMatrix},
+		ReturnType: ValueTypeVector,
+		Call:       funcMadOverTime,
+	},
 	"max_over_time": {
 		Name:       "max_over_time",
 		ArgTypes:   []ValueType{ValueTypeMatrix},
@@ -1094,18 +1336,36 @@ var functions = map[string]*Function{
 		ReturnType: ValueTypeVector,
 		Call:       funcMonth,
 	},
+	"pi": {
+		Name:       "pi",
+		ArgTypes:   []ValueType{},
+		ReturnType: ValueTypeScalar,
+		Call:       funcPi,
+	},
 	"predict_linear": {
 		Name:       "predict_linear",
 		ArgTypes:   []ValueType{ValueTypeMatrix, ValueTypeScalar},
 		ReturnType: ValueTypeVector,
 		Call:       funcPredictLinear,
 	},
+	"present_over_time": {
+		Name:       "present_over_time",
+		ArgTypes:   []ValueType{ValueTypeMatrix},
+		ReturnType: ValueTypeVector,
+		Call:       funcPresentOverTime,
+	},
 	"quantile_over_time": {
 		Name:       "quantile_over_time",
 		ArgTypes:   []ValueType{ValueTypeScalar, ValueTypeMatrix},
 		ReturnType: ValueTypeVector,
 		Call:       funcQuantileOverTime,
 	},
+	"rad": {
+		Name:       "rad",
+		ArgTypes:   []ValueType{ValueTypeVector},
+		ReturnType: ValueTypeVector,
+		Call:       funcRad,
+	},
 	"rate": {
 		Name:       "rate",
 		ArgTypes:   []ValueType{ValueTypeMatrix},
@@ -1131,6 +1391,24 @@ var functions = map[string]*Function{
 		ReturnType: ValueTypeScalar,
 		Call:       funcScalar,
 	},
+	"sgn": {
+		Name:       "sgn",
+		ArgTypes:   []ValueType{ValueTypeVector},
+		ReturnType: ValueTypeVector,
+		Call:       funcSgn,
+	},
+	"sin": {
+		Name:       "sin",
+		ArgTypes:   []ValueType{ValueTypeVector},
+		ReturnType: ValueTypeVector,
+		Call:       funcSin,
+	},
+	"sinh": {
+		Name:       "sinh",
+		ArgTypes:   []ValueType{ValueTypeVector},
+		ReturnType: ValueTypeVector,
+		Call:       funcSinh,
+	},
 	"sort": {
 		Name:       "sort",
 		ArgTypes:   []ValueType{ValueTypeVector},
@@ -1167,6 +1445,18 @@ var functions = map[string]*Function{
 		ReturnType: ValueTypeVector,
 		Call:       funcSumOverTime,
 	},
+	"tan": {
+		Name:       "tan",
+		ArgTypes:   []ValueType{ValueTypeVector},
+		ReturnType: ValueTypeVector,
+		Call:       funcTan,
+	},
+	"tanh": {
+		Name:       "tanh",
+		ArgTypes:   []ValueType{ValueTypeVector},
+		ReturnType: ValueTypeVector,
+		Call:       funcTanh,
+	},
 	"time": {
 		Name:       "time",
 		ArgTypes:   []ValueType{},
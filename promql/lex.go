@@ -59,7 +59,7 @@ func (i ItemType) isAggregator() bool { return i > aggregatorsStart && i < aggre
 // isAggregator returns true if the item is an aggregator that takes a parameter.
 // Returns false otherwise
 func (i ItemType) isAggregatorWithParam() bool {
-	return i == itemTopK || i == itemBottomK || i == itemCountValues || i == itemQuantile
+	return i == itemTopK || i == itemBottomK || i == itemCountValues || i == itemQuantile || i == itemLimitK || i == itemLimitRatio
 }
 
 // isKeyword returns true if the item corresponds to a keyword.
@@ -102,7 +102,7 @@ func (i ItemType) precedence() int {
 		return 3
 	case itemADD, itemSUB:
 		return 4
-	case itemMUL, itemDIV, itemMOD:
+	case itemMUL, itemDIV, itemMOD, itemATAN2:
 		return 5
 	case itemPOW:
 		return 6
@@ -139,6 +139,7 @@ const (
 	itemAssign
 	itemColon
 	itemSemicolon
+	itemAt
 	itemString
 	itemNumber
 	itemDuration
@@ -146,6 +147,11 @@ const (
 	itemTimes
 	itemSpace
 
+	// Arguments of the @ modifier which are not emitted by the lexer but
+	// recorded in the AST until the query's start and end time are known.
+	itemStart
+	itemEnd
+
 	operatorsStart
 	// Operators.
 	itemSUB
@@ -165,6 +171,7 @@ const (
 	itemEQLRegex
 	itemNEQRegex
 	itemPOW
+	itemATAN2
 	operatorsEnd
 
 	aggregatorsStart
@@ -180,6 +187,9 @@ const (
 	itemBottomK
 	itemCountValues
 	itemQuantile
+	itemGroup
+	itemLimitK
+	itemLimitRatio
 	aggregatorsEnd
 
 	keywordsStart
@@ -200,6 +210,7 @@ var key = map[string]ItemType{
 	"and":    itemLAND,
 	"or":     itemLOR,
 	"unless": itemLUnless,
+	"atan2":  itemATAN2,
 
 	// Aggregators.
 	"sum":          itemSum,
@@ -213,6 +224,9 @@ var key = map[string]ItemType{
 	"bottomk":      itemBottomK,
 	"count_values": itemCountValues,
 	"quantile":     itemQuantile,
+	"group":        itemGroup,
+	"limitk":       itemLimitK,
+	"limit_ratio":  itemLimitRatio,
 
 	// Keywords.
 	"offset":      itemOffset,
@@ -238,9 +252,12 @@ var itemTypeStr = map[ItemType]string{
 	itemAssign:       "=",
 	itemColon:        ":",
 	itemSemicolon:    ";",
+	itemAt:           "@",
 	itemBlank:        "_",
 	itemTimes:        "x",
 	itemSpace:        "<space>",
+	itemStart:        "start",
+	itemEnd:          "end",
 
 	itemSUB:      "-",
 	itemADD:      "+",
@@ -467,6 +484,8 @@ func lexStatements(l *lexer) stateFn {
 		return nil
 	case r == ',':
 		l.emit(itemComma)
+	case r == '@':
+		l.emit(itemAt)
 	case isSpace(r):
 		return lexSpace
 	case r == '*':
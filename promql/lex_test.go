@@ -254,6 +254,9 @@ var tests = []struct {
 			}, {
 				input:    `unless`,
 				expected: []item{{itemLUnless, 0, `unless`}},
+			}, {
+				input:    `atan2`,
+				expected: []item{{itemATAN2, 0, `atan2`}},
 			},
 		},
 	},
@@ -278,6 +281,15 @@ var tests = []struct {
 			}, {
 				input:    `stdvar`,
 				expected: []item{{itemStdvar, 0, `stdvar`}},
+			}, {
+				input:    `group`,
+				expected: []item{{itemGroup, 0, `group`}},
+			}, {
+				input:    `limitk`,
+				expected: []item{{itemLimitK, 0, `limitk`}},
+			}, {
+				input:    `limit_ratio`,
+				expected: []item{{itemLimitRatio, 0, `limit_ratio`}},
 			}, {
 				input:    `stddev`,
 				expected: []item{{itemStddev, 0, `stddev`}},
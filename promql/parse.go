@@ -284,6 +284,13 @@ func (p *parser) backup() {
 	p.peekCount++
 }
 
+// backup2 backs the input stream up two tokens after a peek.
+// The zeroth token is already there.
+func (p *parser) backup2(t1 item) {
+	p.token[1] = t1
+	p.peekCount = 2
+}
+
 // errorf formats the error and terminates processing.
 func (p *parser) errorf(format string, args ...interface{}) {
 	p.error(fmt.Errorf(format, args...))
@@ -354,13 +361,7 @@ func (p *parser) expr() Expr {
 			// Check for subquery.
 			if op == itemLeftBracket {
 				expr = p.subqueryOrRangeSelector(expr, false)
-				if s, ok := expr.(*SubqueryExpr); ok {
-					// Parse optional offset.
-					if p.peek().typ == itemOffset {
-						offset := p.offset()
-						s.Offset = offset
-					}
-				}
+				p.modifiers(expr)
 			}
 			return expr
 		}
@@ -485,23 +486,60 @@ func (p *parser) unaryExpr() Expr {
 		e = p.subqueryOrRangeSelector(e, true)
 	}
 
-	// Parse optional offset.
-	if p.peek().typ == itemOffset {
-		offset := p.offset()
+	p.modifiers(e)
+
+	return e
+}
+
+// modifiers parses the optional offset and @ modifiers of a selector or
+// subquery. Both may be given in any order.
+//
+//		[offset <duration>] [@ <timestamp> | @ start() | @ end()]
+//
+func (p *parser) modifiers(e Expr) {
+	var offsetSet, atSet bool
+	for {
+		switch p.peek().typ {
+		case itemOffset:
+			if offsetSet {
+				p.errorf("offset may not be set multiple times")
+			}
+			offsetSet = true
+			offset := p.offset()
+
+			switch s := e.(type) {
+			case *VectorSelector:
+				s.Offset = offset
+			case *MatrixSelector:
+				s.Offset = offset
+			case *SubqueryExpr:
+				s.Offset = offset
+			default:
+				p.errorf("offset modifier must be preceded by an instant or range selector, but follows a %T instead", e)
+			}
+
+		case itemAt:
+			if atSet {
+				p.errorf("@ <timestamp> may not be set multiple times")
+			}
+			atSet = true
+			ts, startOrEnd := p.at()
+
+			switch s := e.(type) {
+			case *VectorSelector:
+				s.Timestamp, s.StartOrEnd = ts, startOrEnd
+			case *MatrixSelector:
+				s.Timestamp, s.StartOrEnd = ts, startOrEnd
+			case *SubqueryExpr:
+				s.Timestamp, s.StartOrEnd = ts, startOrEnd
+			default:
+				p.errorf("@ modifier must be preceded by an instant or range selector, but follows a %T instead", e)
+			}
 
-		switch s := e.(type) {
-		case *VectorSelector:
-			s.Offset = offset
-		case *MatrixSelector:
-			s.Offset = offset
-		case *SubqueryExpr:
-			s.Offset = offset
 		default:
-			p.errorf("offset modifier must be preceded by an instant or range selector, but follows a %T instead", e)
+			return
 		}
 	}
-
-	return e
 }
 
 // subqueryOrRangeSelector parses a Subquery based on given Expr (or)
@@ -607,6 +645,15 @@ func (p *parser) primaryExpr() Expr {
 		return p.VectorSelector(t.val)
 
 	case t.typ.isAggregator():
+		// The group aggregator was added after metrics named group could be
+		// selected, so it is only an aggregation if followed by one.
+		if t.typ == itemGroup {
+			if nt := p.peek().typ; nt != itemLeftParen && nt != itemBy && nt != itemWithout {
+				return p.VectorSelector(t.val)
+			}
+			p.backup2(t)
+			return p.aggrExpr()
+		}
 		p.backup()
 		return p.aggrExpr()
 
@@ -859,12 +906,17 @@ func (p *parser) metric() labels.Labels {
 
 // offset parses an offset modifier.
 //
-//		offset <duration>
+//		offset [-]<duration>
 //
 func (p *parser) offset() time.Duration {
 	const ctx = "offset"
 
 	p.next()
+	negative := false
+	if p.peek().typ == itemSUB {
+		p.next()
+		negative = true
+	}
 	offi := p.expect(itemDuration, ctx)
 
 	offset, err := parseDuration(offi.val)
@@ -872,9 +924,55 @@ func (p *parser) offset() time.Duration {
 		p.error(err)
 	}
 
+	if negative {
+		return -offset
+	}
 	return offset
 }
 
+// at parses the argument of an @ modifier. It returns either the timestamp
+// in milliseconds or whether it refers to the start or end of the query.
+func (p *parser) at() (*int64, ItemType) {
+	const ctx = "@ modifier"
+
+	p.next()
+	switch t := p.next(); t.typ {
+	case itemIdentifier:
+		var startOrEnd ItemType
+		switch t.val {
+		case "start":
+			startOrEnd = itemStart
+		case "end":
+			startOrEnd = itemEnd
+		default:
+			p.errorf("unexpected %s in %s, expected timestamp, start() or end()", t.desc(), ctx)
+		}
+		p.expect(itemLeftParen, ctx)
+		p.expect(itemRightParen, ctx)
+		return nil, startOrEnd
+
+	case itemADD, itemSUB, itemNumber:
+		sign := 1.0
+		if t.typ != itemNumber {
+			if t.typ == itemSUB {
+				sign = -1
+			}
+			t = p.expect(itemNumber, ctx)
+		}
+		f := sign * p.number(t.val)
+		// Timestamps are stored in milliseconds and must fit into an int64.
+		if math.IsNaN(f) || math.IsInf(f, 0) || f >= float64(math.MaxInt64)/1000 || f <= float64(math.MinInt64)/1000 {
+			p.errorf("timestamp out of bounds for %s: %f", ctx, f)
+		}
+		ts := int64(math.Round(f * 1000))
+		return &ts, 0
+
+	default:
+		p.errorf("unexpected %s in %s, expected timestamp, start() or end()", t.desc(), ctx)
+	}
+	return nil, 0
+}
+
 // VectorSelector parses a new (instant) vector selector.
 //
 //		<metric_identifier> [<label_matchers>]
@@ -970,7 +1068,7 @@ func (p *parser) checkType(node Node) (typ ValueType) {
 			p.errorf("aggregation operator expected in aggregation expression but got %q", n.Op)
 		}
 		p.expectType(n.Expr, ValueTypeVector, "aggregation expression")
-		if n.Op == itemTopK || n.Op == itemBottomK || n.Op == itemQuantile {
+		if n.Op == itemTopK || n.Op == itemBottomK || n.Op == itemQuantile || n.Op == itemLimitK || n.Op == itemLimitRatio {
 			p.expectType(n.Param, ValueTypeScalar, "aggregation parameter")
 		}
 		if n.Op == itemCountValues {
@@ -367,6 +367,35 @@ var testExpr = []struct {
 			},
 			VectorMatching: &VectorMatching{Card: CardManyToMany},
 		},
+	}, {
+		// Test atan2 precedence.
+		input: "foo + bar atan2 baz",
+		expected: &BinaryExpr{
+			Op: itemADD,
+			LHS: &VectorSelector{
+				Name: "foo",
+				LabelMatchers: []*labels.Matcher{
+					mustLabelMatcher(labels.MatchEqual, string(model.MetricNameLabel), "foo"),
+				},
+			},
+			RHS: &BinaryExpr{
+				Op: itemATAN2,
+				LHS: &VectorSelector{
+					Name: "bar",
+					LabelMatchers: []*labels.Matcher{
+						mustLabelMatcher(labels.MatchEqual, string(model.MetricNameLabel), "bar"),
+					},
+				},
+				RHS: &VectorSelector{
+					Name: "baz",
+					LabelMatchers: []*labels.Matcher{
+						mustLabelMatcher(labels.MatchEqual, string(model.MetricNameLabel), "baz"),
+					},
+				},
+				VectorMatching: &VectorMatching{Card: CardOneToOne},
+			},
+			VectorMatching: &VectorMatching{Card: CardOneToOne},
+		},
 	}, {
 		// Test and/or precedence and reassigning of operands.
 		input: "foo + bar or bla and blub",
@@ -839,6 +868,15 @@ var testExpr = []struct {
 				mustLabelMatcher(labels.MatchEqual, string(model.MetricNameLabel), "foo"),
 			},
 		},
+	}, {
+		input: "foo offset -7m",
+		expected: &VectorSelector{
+			Name:   "foo",
+			Offset: -7 * time.Minute,
+			LabelMatchers: []*labels.Matcher{
+				mustLabelMatcher(labels.MatchEqual, string(model.MetricNameLabel), "foo"),
+			},
+		},
 	}, {
 		input: `foo:bar{a="bc"}`,
 		expected: &VectorSelector{
@@ -994,6 +1032,16 @@ var testExpr = []struct {
 				mustLabelMatcher(labels.MatchEqual, string(model.MetricNameLabel), "test"),
 			},
 		},
+	}, {
+		input: "test[5m] offset -1h",
+		expected: &MatrixSelector{
+			Name:   "test",
+			Offset: -time.Hour,
+			Range:  5 * time.Minute,
+			LabelMatchers: []*labels.Matcher{
+				mustLabelMatcher(labels.MatchEqual, string(model.MetricNameLabel), "test"),
+			},
+		},
 	}, {
 		input: `test{a="b"}[5y] OFFSET 3d`,
 		expected: &MatrixSelector{
@@ -1036,6 +1084,14 @@ var testExpr = []struct {
 		input:  `some_metric[5m] OFFSET 1`,
 		fail:   true,
 		errMsg: "unexpected number \"1\" in offset, expected duration",
+	}, {
+		input:  `some_metric[5m] OFFSET -`,
+		fail:   true,
+		errMsg: "unexpected end of input in offset, expected duration",
+	}, {
+		input:  `some_metric[5m] OFFSET --1m`,
+		fail:   true,
+		errMsg: "unexpected <op:-> in offset, expected duration",
 	}, {
 		input:  `some_metric[5m] OFFSET 1mm`,
 		fail:   true,
@@ -1163,6 +1219,69 @@ var testExpr = []struct {
 			},
 			Param: &NumberLiteral{5},
 		},
+	}, {
+		input: "limitk by (foo) (5, some_metric)",
+		expected: &AggregateExpr{
+			Op: itemLimitK,
+			Expr: &VectorSelector{
+				Name: "some_metric",
+				LabelMatchers: []*labels.Matcher{
+					mustLabelMatcher(labels.MatchEqual, string(model.MetricNameLabel), "some_metric"),
+				},
+			},
+			Param:    &NumberLiteral{5},
+			Grouping: []string{"foo"},
+		},
+	}, {
+		input: "limit_ratio(0.5, some_metric)",
+		expected: &AggregateExpr{
+			Op: itemLimitRatio,
+			Expr: &VectorSelector{
+				Name: "some_metric",
+				LabelMatchers: []*labels.Matcher{
+					mustLabelMatcher(labels.MatchEqual, string(model.MetricNameLabel), "some_metric"),
+				},
+			},
+			Param: &NumberLiteral{0.5},
+		},
+	}, {
+		input: "group by (group) (some_metric)",
+		expected: &AggregateExpr{
+			Op: itemGroup,
+			Expr: &VectorSelector{
+				Name: "some_metric",
+				LabelMatchers: []*labels.Matcher{
+					mustLabelMatcher(labels.MatchEqual, string(model.MetricNameLabel), "some_metric"),
+				},
+			},
+			Grouping: []string{"group"},
+		},
+	}, {
+		input: `group{job="x"} + sum(group)`,
+		expected: &BinaryExpr{
+			Op: itemADD,
+			LHS: &VectorSelector{
+				Name: "group",
+				LabelMatchers: []*labels.Matcher{
+					mustLabelMatcher(labels.MatchEqual, "job", "x"),
+					mustLabelMatcher(labels.MatchEqual, string(model.MetricNameLabel), "group"),
+				},
+			},
+			RHS: &AggregateExpr{
+				Op: itemSum,
+				Expr: &VectorSelector{
+					Name: "group",
+					LabelMatchers: []*labels.Matcher{
+						mustLabelMatcher(labels.MatchEqual, string(model.MetricNameLabel), "group"),
+					},
+				},
+			},
+			VectorMatching: &VectorMatching{Card: CardOneToOne},
+		},
+	}, {
+		input:  `limitk("5", some_metric)`,
+		fail:   true,
+		errMsg: "expected type scalar in aggregation parameter, got string",
 	}, {
 		input: "count_values(\"value\", some_metric)",
 		expected: &AggregateExpr{
@@ -1573,6 +1692,90 @@ var testExpr = []struct {
 			Range:  5 * time.Minute,
 			Offset: 10 * time.Minute,
 		},
+	}, {
+		input: `foo[10m:] offset -5m`,
+		expected: &SubqueryExpr{
+			Expr: &VectorSelector{
+				Name: "foo",
+				LabelMatchers: []*labels.Matcher{
+					mustLabelMatcher(labels.MatchEqual, string(model.MetricNameLabel), "foo"),
+				},
+			},
+			Range:  10 * time.Minute,
+			Offset: -5 * time.Minute,
+		},
+	}, {
+		input: `foo @ 1603774568`,
+		expected: &VectorSelector{
+			Name:      "foo",
+			Timestamp: makeInt64Pointer(1603774568000),
+			LabelMatchers: []*labels.Matcher{
+				mustLabelMatcher(labels.MatchEqual, string(model.MetricNameLabel), "foo"),
+			},
+		},
+	}, {
+		input: `foo @ -100.5 offset 5m`,
+		expected: &VectorSelector{
+			Name:      "foo",
+			Offset:    5 * time.Minute,
+			Timestamp: makeInt64Pointer(-100500),
+			LabelMatchers: []*labels.Matcher{
+				mustLabelMatcher(labels.MatchEqual, string(model.MetricNameLabel), "foo"),
+			},
+		},
+	}, {
+		input: `foo[5m] offset 1m @ start()`,
+		expected: &MatrixSelector{
+			Name:       "foo",
+			Range:      5 * time.Minute,
+			Offset:     time.Minute,
+			StartOrEnd: itemStart,
+			LabelMatchers: []*labels.Matcher{
+				mustLabelMatcher(labels.MatchEqual, string(model.MetricNameLabel), "foo"),
+			},
+		},
+	}, {
+		input: `rate(foo[1m])[5m:] @ end()`,
+		expected: &SubqueryExpr{
+			Expr: &Call{
+				Func: mustGetFunction("rate"),
+				Args: Expressions{
+					&MatrixSelector{
+						Name:  "foo",
+						Range: time.Minute,
+						LabelMatchers: []*labels.Matcher{
+							mustLabelMatcher(labels.MatchEqual, string(model.MetricNameLabel), "foo"),
+						},
+					},
+				},
+			},
+			Range:      5 * time.Minute,
+			StartOrEnd: itemEnd,
+		},
+	}, {
+		input:  `foo @ 1 @ 2`,
+		fail:   true,
+		errMsg: "@ <timestamp> may not be set multiple times",
+	}, {
+		input:  `foo offset 1m offset 2m`,
+		fail:   true,
+		errMsg: "offset may not be set multiple times",
+	}, {
+		input:  `sum(foo) @ 100`,
+		fail:   true,
+		errMsg: "@ modifier must be preceded by an instant or range selector, but follows a *promql.AggregateExpr instead",
+	}, {
+		input:  `foo @ now()`,
+		fail:   true,
+		errMsg: "unexpected identifier \"now\" in @ modifier, expected timestamp, start() or end()",
+	}, {
+		input:  `foo @ Inf`,
+		fail:   true,
+		errMsg: "timestamp out of bounds for @ modifier",
+	}, {
+		input:  `foo @ start`,
+		fail:   true,
+		errMsg: "unexpected end of input in @ modifier, expected \"(\"",
 	}, {
 		input:  "test[5d] OFFSET 10s [10m:5s]",
 		fail:   true,
@@ -1584,6 +1787,10 @@ var testExpr = []struct {
 	},
 }
 
+func makeInt64Pointer(val int64) *int64 {
+	return &val
+}
+
 func TestParseExpressions(t *testing.T) {
 	for _, test := range testExpr {
 		expr, err := ParseExpr(test.input)
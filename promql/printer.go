@@ -145,11 +145,7 @@ func (node *MatrixSelector) String() string {
 		Name:          node.Name,
 		LabelMatchers: node.LabelMatchers,
 	}
-	offset := ""
-	if node.Offset != time.Duration(0) {
-		offset = fmt.Sprintf(" offset %s", model.Duration(node.Offset))
-	}
-	return fmt.Sprintf("%s[%s]%s", vecSelector.String(), model.Duration(node.Range), offset)
+	return fmt.Sprintf("%s[%s]%s", vecSelector.String(), model.Duration(node.Range), modifiersString(node.Offset, node.Timestamp, node.StartOrEnd))
 }
 
 func (node *SubqueryExpr) String() string {
@@ -157,7 +153,7 @@ func (node *SubqueryExpr) String() string {
 	if node.Step != 0 {
 		step = model.Duration(node.Step).String()
 	}
-	return fmt.Sprintf("%s[%s:%s]", node.Expr.String(), model.Duration(node.Range), step)
+	return fmt.Sprintf("%s[%s:%s]%s", node.Expr.String(), model.Duration(node.Range), step, modifiersString(node.Offset, node.Timestamp, node.StartOrEnd))
 }
 
 func (node *NumberLiteral) String() string {
@@ -185,14 +181,27 @@ func (node *VectorSelector) String() string {
 		}
 		labelStrings = append(labelStrings, matcher.String())
 	}
-	offset := ""
-	if node.Offset != time.Duration(0) {
-		offset = fmt.Sprintf(" offset %s", model.Duration(node.Offset))
-	}
+	modifiers := modifiersString(node.Offset, node.Timestamp, node.StartOrEnd)
 
 	if len(labelStrings) == 0 {
-		return fmt.Sprintf("%s%s", node.Name, offset)
+		return fmt.Sprintf("%s%s", node.Name, modifiers)
 	}
 	sort.Strings(labelStrings)
-	return fmt.Sprintf("%s{%s}%s", node.Name, strings.Join(labelStrings, ","), offset)
+	return fmt.Sprintf("%s{%s}%s", node.Name, strings.Join(labelStrings, ","), modifiers)
+}
+
+// modifiersString returns the offset and @ modifiers of a selector or
+// subquery, including a leading space if any is set.
+func modifiersString(offset time.Duration, ts *int64, startOrEnd ItemType) string {
+	var s string
+	if offset != time.Duration(0) {
+		s = fmt.Sprintf(" offset %s", model.Duration(offset))
+	}
+	switch {
+	case startOrEnd == itemStart || startOrEnd == itemEnd:
+		s += fmt.Sprintf(" @ %s()", startOrEnd)
+	case ts != nil:
+		s += fmt.Sprintf(" @ %.3f", float64(*ts)/1000)
+	}
+	return s
 }
@@ -81,6 +81,19 @@ func TestExprString(t *testing.T) {
 		{
 			in: `a[5m] offset 1m`,
 		},
+		{
+			in: `a offset -1m`,
+		},
+		{
+			in: `a @ 10.000`,
+		},
+		{
+			in:  `a[5m] @ 10 offset 1m`,
+			out: `a[5m] offset 1m @ 10.000`,
+		},
+		{
+			in: `rate(a[1m])[5m:1m] offset 1m @ start()`,
+		},
 	}
 
 	for _, test := range inputs {
@@ -0,0 +1,190 @@
+// Copyright 2019 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package promql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+)
+
+// ActiveQueryTracker records the queries currently executed by an Engine in
+// a file. Each query occupies a fixed size slot of the file, which is
+// cleared once the query finished. Queries still found in the file on
+// startup were running when the previous process died and are logged.
+type ActiveQueryTracker struct {
+	file         *os.File
+	getNextIndex chan int
+	logger       log.Logger
+}
+
+type activeQueryEntry struct {
+	Query     string `json:"query"`
+	Timestamp int64  `json:"timestamp_sec"`
+}
+
+// activeQueryEntrySize is the size of a single slot in bytes. Longer queries
+// are truncated.
+const activeQueryEntrySize = 1000
+
+// NewActiveQueryTracker creates a tracker for up to maxConcurrent queries
+// which writes to a queries.active file in dir. Queries left over from the
+// previous run are logged first.
+func NewActiveQueryTracker(dir string, maxConcurrent int, logger log.Logger) (*ActiveQueryTracker, error) {
+	if logger == nil {
+		logger = log.NewNopLogger()
+	}
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return nil, errors.Wrap(err, "create directory for active queries")
+	}
+
+	filename := filepath.Join(dir, "queries.active")
+	logUnfinishedQueries(filename, logger)
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, errors.Wrap(err, "create active queries file")
+	}
+	if err := file.Truncate(int64(maxConcurrent * activeQueryEntrySize)); err != nil {
+		file.Close()
+		return nil, errors.Wrap(err, "resize active queries file")
+	}
+
+	getNextIndex := make(chan int, maxConcurrent)
+	for i := 0; i < maxConcurrent; i++ {
+		getNextIndex <- i
+	}
+
+	return &ActiveQueryTracker{
+		file:         file,
+		getNextIndex: getNextIndex,
+		logger:       logger,
+	}, nil
+}
+
+func logUnfinishedQueries(filename string, logger log.Logger) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			level.Error(logger).Log("msg", "Failed to read active queries file", "file", filename, "err", err)
+		}
+		return
+	}
+
+	var queries []activeQueryEntry
+	for len(b) > 0 {
+		n := activeQueryEntrySize
+		if n > len(b) {
+			n = len(b)
+		}
+		slot := bytes.Trim(b[:n], "\x00 ")
+		b = b[n:]
+		if len(slot) == 0 {
+			continue
+		}
+
+		var e activeQueryEntry
+		if err := json.Unmarshal(slot, &e); err != nil {
+			level.Warn(logger).Log("msg", "Skipping malformed entry of active queries file", "err", err)
+			continue
+		}
+		queries = append(queries, e)
+	}
+	if len(queries) == 0 {
+		return
+	}
+
+	level.Info(logger).Log("msg", "These queries didn't finish in Prometheus' last run:")
+	for _, q := range queries {
+		level.Info(logger).Log("query", q.Query, "started", time.Unix(q.Timestamp, 0))
+	}
+}
+
+// newActiveQueryEntry returns the padded JSON encoding of the query,
+// truncating the query on a rune boundary to fit into a slot.
+func newActiveQueryEntry(query string) ([]byte, error) {
+	e := activeQueryEntry{Timestamp: time.Now().Unix()}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+
+	// Escaping can make a rune up to six times longer, so count the encoded
+	// length of each rune to find the longest prefix which fits.
+	free := activeQueryEntrySize - len(b)
+	n := 0
+	for n < len(query) {
+		r, size := utf8.DecodeRuneInString(query[n:])
+		rb, err := json.Marshal(string(r))
+		if err != nil {
+			return nil, err
+		}
+		// Don't count the quotes of the string.
+		if free -= len(rb) - 2; free < 0 {
+			break
+		}
+		n += size
+	}
+
+	e.Query = query[:n]
+	if b, err = json.Marshal(e); err != nil {
+		return nil, err
+	}
+	if len(b) > activeQueryEntrySize {
+		return nil, errors.Errorf("active query entry of %d bytes exceeds slot size", len(b))
+	}
+	return append(b, bytes.Repeat([]byte(" "), activeQueryEntrySize-len(b))...), nil
+}
+
+// Insert records the query in a free slot and returns the slot's index.
+// It blocks until a slot is free or the context is done. The slot is only
+// taken if no error is returned.
+func (t *ActiveQueryTracker) Insert(ctx context.Context, query string) (int, error) {
+	select {
+	case i := <-t.getNextIndex:
+		entry, err := newActiveQueryEntry(query)
+		if err != nil {
+			t.getNextIndex <- i
+			return 0, errors.Wrap(err, "encode active query")
+		}
+		if _, err := t.file.WriteAt(entry, int64(i*activeQueryEntrySize)); err != nil {
+			level.Error(t.logger).Log("msg", "Failed to record active query", "err", err)
+		}
+		return i, nil
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+// Delete clears the slot with the given index and makes it available again.
+func (t *ActiveQueryTracker) Delete(i int) {
+	if _, err := t.file.WriteAt(bytes.Repeat([]byte(" "), activeQueryEntrySize), int64(i*activeQueryEntrySize)); err != nil {
+		level.Error(t.logger).Log("msg", "Failed to clear active query", "err", err)
+	}
+	t.getNextIndex <- i
+}
+
+// Close closes the underlying file. Queries must not be inserted afterwards.
+func (t *ActiveQueryTracker) Close() error {
+	return t.file.Close()
+}
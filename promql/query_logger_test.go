@@ -0,0 +1,99 @@
+// Copyright 2019 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package promql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/prometheus/prometheus/util/testutil"
+)
+
+func TestActiveQueryTracker(t *testing.T) {
+	dir, err := ioutil.TempDir("", "active_queries")
+	testutil.Ok(t, err)
+	defer os.RemoveAll(dir)
+
+	tracker, err := NewActiveQueryTracker(dir, 2, nil)
+	testutil.Ok(t, err)
+
+	ctx := context.Background()
+	i1, err := tracker.Insert(ctx, "up")
+	testutil.Ok(t, err)
+	i2, err := tracker.Insert(ctx, strings.Repeat("a", 2*activeQueryEntrySize))
+	testutil.Ok(t, err)
+	testutil.Assert(t, i1 != i2, "expected different slots")
+
+	// All slots are taken.
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = tracker.Insert(cctx, "down")
+	testutil.NotOk(t, err, "expected insert to fail without free slot")
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "queries.active"))
+	testutil.Ok(t, err)
+	testutil.Equals(t, 2*activeQueryEntrySize, len(b))
+
+	var e activeQueryEntry
+	testutil.Ok(t, json.Unmarshal(bytes.TrimSpace(b[i1*activeQueryEntrySize:(i1+1)*activeQueryEntrySize]), &e))
+	testutil.Equals(t, "up", e.Query)
+	// Long queries are truncated to fit into their slot.
+	testutil.Ok(t, json.Unmarshal(bytes.TrimSpace(b[i2*activeQueryEntrySize:(i2+1)*activeQueryEntrySize]), &e))
+	testutil.Assert(t, strings.HasPrefix(e.Query, "aaa"), "unexpected truncated query %q", e.Query)
+
+	// Queries growing a lot when escaped are truncated as well, on a rune
+	// boundary.
+	tracker.Delete(i1)
+	for _, q := range []string{
+		"up" + strings.Repeat(" > 0", 250),
+		strings.Repeat("<&>", 2*activeQueryEntrySize),
+		strings.Repeat("é<", activeQueryEntrySize),
+	} {
+		i, err := tracker.Insert(ctx, q)
+		testutil.Ok(t, err)
+		b, err := ioutil.ReadFile(filepath.Join(dir, "queries.active"))
+		testutil.Ok(t, err)
+		var e activeQueryEntry
+		testutil.Ok(t, json.Unmarshal(bytes.TrimSpace(b[i*activeQueryEntrySize:(i+1)*activeQueryEntrySize]), &e))
+		testutil.Assert(t, len(e.Query) > 0 && strings.HasPrefix(q, e.Query), "unexpected truncated query %q", e.Query)
+		tracker.Delete(i)
+	}
+	i1, err = tracker.Insert(ctx, "up")
+	testutil.Ok(t, err)
+
+	tracker.Delete(i2)
+	b, err = ioutil.ReadFile(filepath.Join(dir, "queries.active"))
+	testutil.Ok(t, err)
+	testutil.Equals(t, 0, len(bytes.TrimSpace(b[i2*activeQueryEntrySize:(i2+1)*activeQueryEntrySize])))
+	testutil.Ok(t, tracker.Close())
+
+	// A new tracker reports the query left over and starts empty.
+	var buf bytes.Buffer
+	tracker, err = NewActiveQueryTracker(dir, 2, log.NewLogfmtLogger(&buf))
+	testutil.Ok(t, err)
+	defer tracker.Close()
+	testutil.Assert(t, strings.Contains(buf.String(), "query=up"), "expected unfinished query to be logged, got %q", buf.String())
+
+	b, err = ioutil.ReadFile(filepath.Join(dir, "queries.active"))
+	testutil.Ok(t, err)
+	testutil.Equals(t, 0, len(bytes.Trim(b, "\x00 ")))
+}
@@ -277,6 +277,13 @@ func (g *Group) Interval() time.Duration { return g.interval }
 func (g *Group) run(ctx context.Context) {
 	defer close(g.terminated)
 
+	ctx = promql.NewOriginContext(ctx, map[string]interface{}{
+		"ruleGroup": map[string]string{
+			"file": g.File(),
+			"name": g.Name(),
+		},
+	})
+
 	// Wait an initial amount to have consistently slotted intervals.
 	evalTimestamp := g.evalTimestamp().Add(g.interval)
 	select {
@@ -31,13 +31,15 @@ type Appendable interface {
 	Appender() (storage.Appender, error)
 }
 
-// NewManager is the Manager constructor
-func NewManager(logger log.Logger, app Appendable) *Manager {
+// NewManager is the Manager constructor. Exemplars of the scraped samples are
+// appended to exemplars if it is not nil.
+func NewManager(logger log.Logger, app Appendable, exemplars storage.ExemplarAppender) *Manager {
 	if logger == nil {
 		logger = log.NewNopLogger()
 	}
 	return &Manager{
 		append:        app,
+		exemplars:     exemplars,
 		logger:        logger,
 		scrapeConfigs: make(map[string]*config.ScrapeConfig),
 		scrapePools:   make(map[string]*scrapePool),
@@ -51,6 +53,7 @@ func NewManager(logger log.Logger, app Appendable) *Manager {
 type Manager struct {
 	logger    log.Logger
 	append    Appendable
+	exemplars storage.ExemplarAppender
 	graceShut chan struct{}
 
 	mtxScrape     sync.Mutex // Guards the fields below.
@@ -112,7 +115,7 @@ func (m *Manager) reload() {
 				level.Error(m.logger).Log("msg", "error reloading target set", "err", "invalid config id:"+setName)
 				continue
 			}
-			sp = newScrapePool(scrapeConfig, m.append, log.With(m.logger, "scrape_pool", setName))
+			sp = newScrapePool(scrapeConfig, m.append, m.exemplars, log.With(m.logger, "scrape_pool", setName))
 			m.scrapePools[setName] = sp
 		} else {
 			sp = existing
@@ -214,7 +214,7 @@ func TestPopulateLabels(t *testing.T) {
 	for _, c := range cases {
 		in := c.in.Copy()
 
-		res, orig, err := populateLabels(c.in, c.cfg)
+		res, orig, err := PopulateLabels(c.in, c.cfg)
 		testutil.Equals(t, c.err, err)
 		testutil.Equals(t, c.in, in)
 		testutil.Equals(t, c.res, res)
@@ -238,12 +238,12 @@ scrape_configs:
 		t.Fatalf("Unable to load YAML config cfgYaml: %s", err)
 	}
 
-	scrapeManager := NewManager(nil, nil)
+	scrapeManager := NewManager(nil, nil, nil)
 	// Load the current config.
 	scrapeManager.ApplyConfig(cfg)
 
 	// As reload never happens, new loop should never be called.
-	newLoop := func(_ *Target, s scraper, _ int, _ bool, _ []*relabel.Config) loop {
+	newLoop := func(_ *Target, s scraper, _ int, _ *labelLimits, _, _ bool, _ []*relabel.Config) loop {
 		t.Fatal("reload happened")
 		return nil
 	}
@@ -266,7 +266,7 @@ scrape_configs:
 }
 
 func TestManagerTargetsUpdates(t *testing.T) {
-	m := NewManager(nil, nil)
+	m := NewManager(nil, nil, nil)
 
 	ts := make(chan map[string][]*targetgroup.Group)
 	go m.Run(ts)
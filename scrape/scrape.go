@@ -22,6 +22,7 @@ import (
 	"io"
 	"math"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 	"unsafe"
@@ -35,6 +36,7 @@ import (
 
 	"github.com/prometheus/prometheus/config"
 	"github.com/prometheus/prometheus/discovery/targetgroup"
+	"github.com/prometheus/prometheus/pkg/exemplar"
 	"github.com/prometheus/prometheus/pkg/labels"
 	"github.com/prometheus/prometheus/pkg/pool"
 	"github.com/prometheus/prometheus/pkg/relabel"
@@ -82,6 +84,36 @@ var (
 			Help: "Total number of scrapes that hit the sample limit and were rejected.",
 		},
 	)
+	targetScrapePoolExceededTargetLimit = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "prometheus_target_scrape_pool_exceeded_target_limit_total",
+			Help: "Total number of times scrape pools hit the target limit, during sync or config reload.",
+		},
+	)
+	targetScrapeExceededLabelLimit = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "prometheus_target_scrapes_exceeded_label_limit_total",
+			Help: "Total number of scrapes that hit the label limit and were rejected.",
+		},
+	)
+	targetScrapeExceededLabelNameLengthLimit = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "prometheus_target_scrapes_exceeded_label_name_length_limit_total",
+			Help: "Total number of scrapes that hit the label name length limit and were rejected.",
+		},
+	)
+	targetScrapeExceededLabelValueLengthLimit = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "prometheus_target_scrapes_exceeded_label_value_length_limit_total",
+			Help: "Total number of scrapes that hit the label value length limit and were rejected.",
+		},
+	)
+	targetScrapeExceededBodySizeLimit = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "prometheus_target_scrapes_exceeded_body_size_limit_total",
+			Help: "Total number of scrapes that hit the body size limit and were rejected.",
+		},
+	)
 	targetScrapeSampleDuplicate = prometheus.NewCounter(
 		prometheus.CounterOpts{
 			Name: "prometheus_target_scrapes_sample_duplicate_timestamp_total",
@@ -100,6 +132,12 @@ var (
 			Help: "Total number of samples rejected due to timestamp falling outside of the time bounds",
 		},
 	)
+	targetScrapeExemplarOutOfOrder = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "prometheus_target_scrapes_exemplar_out_of_order_total",
+			Help: "Total number of exemplars rejected due to being out of the expected order",
+		},
+	)
 )
 
 func init() {
@@ -108,14 +146,21 @@ func init() {
 	prometheus.MustRegister(targetSyncIntervalLength)
 	prometheus.MustRegister(targetScrapePoolSyncsCounter)
 	prometheus.MustRegister(targetScrapeSampleLimit)
+	prometheus.MustRegister(targetScrapePoolExceededTargetLimit)
+	prometheus.MustRegister(targetScrapeExceededLabelLimit)
+	prometheus.MustRegister(targetScrapeExceededLabelNameLengthLimit)
+	prometheus.MustRegister(targetScrapeExceededLabelValueLengthLimit)
+	prometheus.MustRegister(targetScrapeExceededBodySizeLimit)
 	prometheus.MustRegister(targetScrapeSampleDuplicate)
 	prometheus.MustRegister(targetScrapeSampleOutOfOrder)
 	prometheus.MustRegister(targetScrapeSampleOutOfBounds)
+	prometheus.MustRegister(targetScrapeExemplarOutOfOrder)
 }
 
 // scrapePool manages scrapes for sets of targets.
 type scrapePool struct {
 	appendable Appendable
+	exemplars  storage.ExemplarAppender
 	logger     log.Logger
 
 	mtx    sync.RWMutex
@@ -127,16 +172,33 @@ type scrapePool struct {
 	droppedTargets []*Target
 	loops          map[uint64]loop
 	cancel         context.CancelFunc
+	// Whether the pool exceeded its target limit with its last sync.
+	targetLimitHit bool
 
 	// Constructor for new scrape loops. This is settable for testing convenience.
-	newLoop func(*Target, scraper, int, bool, []*relabel.Config) loop
+	newLoop func(*Target, scraper, int, *labelLimits, bool, bool, []*relabel.Config) loop
+}
+
+// labelLimits holds the limits on the labels of the scraped samples.
+type labelLimits struct {
+	labelLimit            int
+	labelNameLengthLimit  int
+	labelValueLengthLimit int
+}
+
+func newLabelLimits(cfg *config.ScrapeConfig) *labelLimits {
+	return &labelLimits{
+		labelLimit:            int(cfg.LabelLimit),
+		labelNameLengthLimit:  int(cfg.LabelNameLengthLimit),
+		labelValueLengthLimit: int(cfg.LabelValueLengthLimit),
+	}
 }
 
 const maxAheadTime = 10 * time.Minute
 
 type labelsMutator func(labels.Labels) labels.Labels
 
-func newScrapePool(cfg *config.ScrapeConfig, app Appendable, logger log.Logger) *scrapePool {
+func newScrapePool(cfg *config.ScrapeConfig, app Appendable, exemplars storage.ExemplarAppender, logger log.Logger) *scrapePool {
 	if logger == nil {
 		logger = log.NewNopLogger()
 	}
@@ -153,13 +215,14 @@ func newScrapePool(cfg *config.ScrapeConfig, app Appendable, logger log.Logger)
 	sp := &scrapePool{
 		cancel:        cancel,
 		appendable:    app,
+		exemplars:     exemplars,
 		config:        cfg,
 		client:        client,
 		activeTargets: map[uint64]*Target{},
 		loops:         map[uint64]loop{},
 		logger:        logger,
 	}
-	sp.newLoop = func(t *Target, s scraper, limit int, honor bool, mrc []*relabel.Config) loop {
+	sp.newLoop = func(t *Target, s scraper, limit int, ll *labelLimits, honor, honorTimestamps bool, mrc []*relabel.Config) loop {
 		// Update the targets retrieval function for metadata to a new scrape cache.
 		cache := newScrapeCache()
 		t.setMetadataStore(cache)
@@ -179,6 +242,9 @@ func newScrapePool(cfg *config.ScrapeConfig, app Appendable, logger log.Logger)
 				return appender(app, limit)
 			},
 			cache,
+			ll,
+			exemplars,
+			honorTimestamps,
 		)
 	}
 
@@ -242,20 +308,26 @@ func (sp *scrapePool) reload(cfg *config.ScrapeConfig) {
 	sp.client = client
 
 	var (
-		wg       sync.WaitGroup
-		interval = time.Duration(sp.config.ScrapeInterval)
-		timeout  = time.Duration(sp.config.ScrapeTimeout)
-		limit    = int(sp.config.SampleLimit)
-		honor    = sp.config.HonorLabels
-		mrc      = sp.config.MetricRelabelConfigs
+		wg            sync.WaitGroup
+		interval      = time.Duration(sp.config.ScrapeInterval)
+		timeout       = time.Duration(sp.config.ScrapeTimeout)
+		bodySizeLimit = int64(sp.config.BodySizeLimit)
+		accept        = acceptHeader(sp.config.ScrapeProtocols)
+		limit         = int(sp.config.SampleLimit)
+		ll            = newLabelLimits(sp.config)
+		honor         = sp.config.HonorLabels
+		honorTs       = sp.config.HonorTimestamps
+		mrc           = sp.config.MetricRelabelConfigs
 	)
 
+	forcedErr := sp.refreshTargetLimitErr()
 	for fp, oldLoop := range sp.loops {
 		var (
 			t       = sp.activeTargets[fp]
-			s       = &targetScraper{Target: t, client: sp.client, timeout: timeout}
-			newLoop = sp.newLoop(t, s, limit, honor, mrc)
+			s       = &targetScraper{Target: t, client: sp.client, timeout: timeout, bodySizeLimit: bodySizeLimit, acceptHeader: accept}
+			newLoop = sp.newLoop(t, s, limit, ll, honor, honorTs, mrc)
 		)
+		newLoop.setForcedError(forcedErr)
 		wg.Add(1)
 
 		go func(oldLoop, newLoop loop) {
@@ -314,10 +386,15 @@ func (sp *scrapePool) sync(targets []*Target) {
 
 	var (
 		uniqueTargets = map[uint64]struct{}{}
+		newLoops      []loop
 		interval      = time.Duration(sp.config.ScrapeInterval)
 		timeout       = time.Duration(sp.config.ScrapeTimeout)
+		bodySizeLimit = int64(sp.config.BodySizeLimit)
+		accept        = acceptHeader(sp.config.ScrapeProtocols)
 		limit         = int(sp.config.SampleLimit)
+		ll            = newLabelLimits(sp.config)
 		honor         = sp.config.HonorLabels
+		honorTs       = sp.config.HonorTimestamps
 		mrc           = sp.config.MetricRelabelConfigs
 	)
 
@@ -327,13 +404,12 @@ func (sp *scrapePool) sync(targets []*Target) {
 		uniqueTargets[hash] = struct{}{}
 
 		if _, ok := sp.activeTargets[hash]; !ok {
-			s := &targetScraper{Target: t, client: sp.client, timeout: timeout}
-			l := sp.newLoop(t, s, limit, honor, mrc)
+			s := &targetScraper{Target: t, client: sp.client, timeout: timeout, bodySizeLimit: bodySizeLimit, acceptHeader: accept}
+			l := sp.newLoop(t, s, limit, ll, honor, honorTs, mrc)
 
 			sp.activeTargets[hash] = t
 			sp.loops[hash] = l
-
-			go l.run(interval, timeout, nil)
+			newLoops = append(newLoops, l)
 		} else {
 			// Need to keep the most updated labels information
 			// for displaying it in the Service Discovery web page.
@@ -359,6 +435,16 @@ func (sp *scrapePool) sync(targets []*Target) {
 		}
 	}
 
+	// Only start the new loops once they know whether the pool exceeds its
+	// target limit.
+	forcedErr := sp.refreshTargetLimitErr()
+	for _, l := range sp.loops {
+		l.setForcedError(forcedErr)
+	}
+	for _, l := range newLoops {
+		go l.run(interval, timeout, nil)
+	}
+
 	// Wait for all potentially stopped scrapers to terminate.
 	// This covers the case of flapping targets. If the server is under high load, a new scraper
 	// may be active and tries to insert. The old scraper that didn't terminate yet could still
@@ -366,6 +452,45 @@ func (sp *scrapePool) sync(targets []*Target) {
 	wg.Wait()
 }
 
+// refreshTargetLimitErr returns the error all scrapes of the pool fail with
+// if it exceeds its target limit. It must be called with the lock held.
+func (sp *scrapePool) refreshTargetLimitErr() error {
+	limit := int(sp.config.TargetLimit)
+	if limit == 0 || len(sp.activeTargets) <= limit {
+		sp.targetLimitHit = false
+		return nil
+	}
+	if !sp.targetLimitHit {
+		targetScrapePoolExceededTargetLimit.Inc()
+		sp.targetLimitHit = true
+	}
+	return fmt.Errorf("target_limit exceeded (number of targets: %d, limit: %d)", len(sp.activeTargets), limit)
+}
+
+// verifyLabelLimits returns an error if the label set exceeds one of the
+// limits.
+func verifyLabelLimits(lset labels.Labels, limits *labelLimits) error {
+	if limits == nil {
+		return nil
+	}
+	met := lset.Get(labels.MetricName)
+	if limits.labelLimit > 0 && len(lset) > limits.labelLimit {
+		targetScrapeExceededLabelLimit.Inc()
+		return fmt.Errorf("label_limit exceeded (metric: %.50s, number of labels: %d, limit: %d)", met, len(lset), limits.labelLimit)
+	}
+	for _, l := range lset {
+		if limits.labelNameLengthLimit > 0 && len(l.Name) > limits.labelNameLengthLimit {
+			targetScrapeExceededLabelNameLengthLimit.Inc()
+			return fmt.Errorf("label_name_length_limit exceeded (metric: %.50s, label name: %.50s, length: %d, limit: %d)", met, l.Name, len(l.Name), limits.labelNameLengthLimit)
+		}
+		if limits.labelValueLengthLimit > 0 && len(l.Value) > limits.labelValueLengthLimit {
+			targetScrapeExceededLabelValueLengthLimit.Inc()
+			return fmt.Errorf("label_value_length_limit exceeded (metric: %.50s, label name: %.50s, value: %.50q, length: %d, limit: %d)", met, l.Name, l.Value, len(l.Value), limits.labelValueLengthLimit)
+		}
+	}
+	return nil
+}
+
 func mutateSampleLabels(lset labels.Labels, target *Target, honor bool, rc []*relabel.Config) labels.Labels {
 	lb := labels.NewBuilder(lset)
 
@@ -445,12 +570,64 @@ type targetScraper struct {
 	client  *http.Client
 	req     *http.Request
 	timeout time.Duration
+	// The maximum size of the compressed and the uncompressed response
+	// body. 0 means no limit.
+	bodySizeLimit int64
+	// The Accept header of the scrape requests. The header for the default
+	// scrape protocols is used if empty.
+	acceptHeader string
 
 	gzipr *gzip.Reader
 	buf   *bufio.Reader
 }
 
-const acceptHeader = `application/openmetrics-text; version=0.0.1,text/plain;version=0.0.4;q=0.5,*/*;q=0.1`
+// bodySizeLimitReader reads from r and fails with errBodySizeLimit once more
+// than limit bytes were read.
+type bodySizeLimitReader struct {
+	r     io.Reader
+	limit int64
+}
+
+// newBodySizeLimitReader returns r unchanged if limit is not positive.
+func newBodySizeLimitReader(r io.Reader, limit int64) io.Reader {
+	if limit <= 0 {
+		return r
+	}
+	return &bodySizeLimitReader{r: r, limit: limit}
+}
+
+func (l *bodySizeLimitReader) Read(p []byte) (int, error) {
+	// Read at most one byte more than the limit allows to detect whether
+	// the body exceeds it.
+	if int64(len(p)) > l.limit+1 {
+		p = p[:l.limit+1]
+	}
+	n, err := l.r.Read(p)
+	l.limit -= int64(n)
+	if l.limit < 0 {
+		return n + int(l.limit), errBodySizeLimit
+	}
+	return n, err
+}
+
+// acceptHeader returns the Accept header requesting the given protocols in
+// order of preference, or the default protocols if there are none. Any other
+// media type is accepted with the lowest preference.
+func acceptHeader(protocols []config.ScrapeProtocol) string {
+	if len(protocols) == 0 {
+		protocols = config.DefaultScrapeProtocols
+	}
+	vals := make([]string, 0, len(protocols)+1)
+	for i, p := range protocols {
+		if i == 0 {
+			vals = append(vals, config.ScrapeProtocolsHeaders[p])
+			continue
+		}
+		vals = append(vals, fmt.Sprintf("%s;q=0.%d", config.ScrapeProtocolsHeaders[p], 6-i))
+	}
+	vals = append(vals, "*/*;q=0.1")
+	return strings.Join(vals, ",")
+}
 
 var userAgentHeader = fmt.Sprintf("Prometheus/%s", version.Version)
 
@@ -460,7 +637,11 @@ func (s *targetScraper) scrape(ctx context.Context, w io.Writer) (string, error)
 		if err != nil {
 			return "", err
 		}
-		req.Header.Add("Accept", acceptHeader)
+		accept := s.acceptHeader
+		if accept == "" {
+			accept = acceptHeader(nil)
+		}
+		req.Header.Add("Accept", accept)
 		req.Header.Add("Accept-Encoding", "gzip")
 		req.Header.Set("User-Agent", userAgentHeader)
 		req.Header.Set("X-Prometheus-Scrape-Timeout-Seconds", fmt.Sprintf("%f", s.timeout.Seconds()))
@@ -478,38 +659,51 @@ func (s *targetScraper) scrape(ctx context.Context, w io.Writer) (string, error)
 		return "", fmt.Errorf("server returned HTTP status %s", resp.Status)
 	}
 
+	body := newBodySizeLimitReader(resp.Body, s.bodySizeLimit)
+
 	if resp.Header.Get("Content-Encoding") != "gzip" {
-		_, err = io.Copy(w, resp.Body)
+		_, err = io.Copy(w, body)
 		if err != nil {
-			return "", err
+			return "", s.checkBodySizeLimit(err)
 		}
 		return resp.Header.Get("Content-Type"), nil
 	}
 
 	if s.gzipr == nil {
-		s.buf = bufio.NewReader(resp.Body)
+		s.buf = bufio.NewReader(body)
 		s.gzipr, err = gzip.NewReader(s.buf)
 		if err != nil {
 			return "", err
 		}
 	} else {
-		s.buf.Reset(resp.Body)
+		s.buf.Reset(body)
 		if err = s.gzipr.Reset(s.buf); err != nil {
-			return "", err
+			return "", s.checkBodySizeLimit(err)
 		}
 	}
 
-	_, err = io.Copy(w, s.gzipr)
+	_, err = io.Copy(w, newBodySizeLimitReader(s.gzipr, s.bodySizeLimit))
 	s.gzipr.Close()
 	if err != nil {
-		return "", err
+		return "", s.checkBodySizeLimit(err)
 	}
 	return resp.Header.Get("Content-Type"), nil
 }
 
+// checkBodySizeLimit counts errors caused by an exceeded body size limit and
+// adds the limit to their message.
+func (s *targetScraper) checkBodySizeLimit(err error) error {
+	if err != errBodySizeLimit {
+		return err
+	}
+	targetScrapeExceededBodySizeLimit.Inc()
+	return fmt.Errorf("%s (limit: %d bytes)", err, s.bodySizeLimit)
+}
+
 // A loop can run and be stopped again. It must not be reused after it was stopped.
 type loop interface {
 	run(interval, timeout time.Duration, errc chan<- error)
+	setForcedError(err error)
 	stop()
 }
 
@@ -530,6 +724,13 @@ type scrapeLoop struct {
 	appender            func() storage.Appender
 	sampleMutator       labelsMutator
 	reportSampleMutator labelsMutator
+	labelLimits         *labelLimits
+	exemplars           storage.ExemplarAppender
+	honorTimestamps     bool
+
+	// An error all scrapes fail with instead of scraping the target.
+	forcedErr    error
+	forcedErrMtx sync.Mutex
 
 	ctx       context.Context
 	scrapeCtx context.Context
@@ -744,6 +945,9 @@ func newScrapeLoop(ctx context.Context,
 	reportSampleMutator labelsMutator,
 	appender func() storage.Appender,
 	cache *scrapeCache,
+	labelLimits *labelLimits,
+	exemplars storage.ExemplarAppender,
+	honorTimestamps bool,
 ) *scrapeLoop {
 	if l == nil {
 		l = log.NewNopLogger()
@@ -761,6 +965,9 @@ func newScrapeLoop(ctx context.Context,
 		appender:            appender,
 		sampleMutator:       sampleMutator,
 		reportSampleMutator: reportSampleMutator,
+		labelLimits:         labelLimits,
+		exemplars:           exemplars,
+		honorTimestamps:     honorTimestamps,
 		stopped:             make(chan struct{}),
 		l:                   l,
 		ctx:                 ctx,
@@ -796,8 +1003,10 @@ mainLoop:
 		}
 
 		var (
-			start             = time.Now()
-			scrapeCtx, cancel = context.WithTimeout(sl.ctx, timeout)
+			start        = time.Now()
+			total, added int
+			rejected     rejectedSamples
+			scrapeErr    error
 		)
 
 		// Only record after the first scrape.
@@ -807,46 +1016,21 @@ mainLoop:
 			)
 		}
 
-		b := sl.buffers.Get(sl.lastScrapeSize).([]byte)
-		buf := bytes.NewBuffer(b)
-
-		contentType, scrapeErr := sl.scraper.scrape(scrapeCtx, buf)
-		cancel()
-
-		if scrapeErr == nil {
-			b = buf.Bytes()
-			// NOTE: There were issues with misbehaving clients in the past
-			// that occasionally returned empty results. We don't want those
-			// to falsely reset our buffer size.
-			if len(b) > 0 {
-				sl.lastScrapeSize = len(b)
+		if forcedErr := sl.getForcedError(); forcedErr != nil {
+			scrapeErr = forcedErr
+			// Don't scrape the target but trigger stale markers of the
+			// previous scrapes with an empty scrape.
+			if _, _, _, err := sl.append([]byte{}, "", start); err != nil {
+				level.Warn(sl.l).Log("msg", "append failed", "err", err)
 			}
-		} else {
-			level.Debug(sl.l).Log("msg", "Scrape failed", "err", scrapeErr.Error())
 			if errc != nil {
-				errc <- scrapeErr
-			}
-		}
-
-		// A failed scrape is the same as an empty scrape,
-		// we still call sl.append to trigger stale markers.
-		total, added, appErr := sl.append(b, contentType, start)
-		if appErr != nil {
-			level.Warn(sl.l).Log("msg", "append failed", "err", appErr)
-			// The append failed, probably due to a parse error or sample limit.
-			// Call sl.append again with an empty scrape to trigger stale markers.
-			if _, _, err := sl.append([]byte{}, "", start); err != nil {
-				level.Warn(sl.l).Log("msg", "append failed", "err", err)
+				errc <- forcedErr
 			}
+		} else {
+			total, added, rejected, scrapeErr = sl.scrapeAndAppend(start, timeout, errc)
 		}
 
-		sl.buffers.Put(b)
-
-		if scrapeErr == nil {
-			scrapeErr = appErr
-		}
-
-		if err := sl.report(start, time.Since(start), total, added, scrapeErr); err != nil {
+		if err := sl.report(start, time.Since(start), total, added, rejected, scrapeErr); err != nil {
 			level.Warn(sl.l).Log("msg", "appending scrape report failed", "err", err)
 		}
 		last = start
@@ -866,6 +1050,67 @@ mainLoop:
 	sl.endOfRunStaleness(last, ticker, interval)
 }
 
+// scrapeAndAppend scrapes the target and appends the scraped samples. It
+// returns the number of scraped, appended and rejected samples and the error
+// of the scrape or, if the scrape succeeded, of the append.
+func (sl *scrapeLoop) scrapeAndAppend(start time.Time, timeout time.Duration, errc chan<- error) (total, added int, rejected rejectedSamples, scrapeErr error) {
+	scrapeCtx, cancel := context.WithTimeout(sl.ctx, timeout)
+
+	b := sl.buffers.Get(sl.lastScrapeSize).([]byte)
+	buf := bytes.NewBuffer(b)
+
+	contentType, scrapeErr := sl.scraper.scrape(scrapeCtx, buf)
+	cancel()
+
+	if scrapeErr == nil {
+		b = buf.Bytes()
+		// NOTE: There were issues with misbehaving clients in the past
+		// that occasionally returned empty results. We don't want those
+		// to falsely reset our buffer size.
+		if len(b) > 0 {
+			sl.lastScrapeSize = len(b)
+		}
+	} else {
+		level.Debug(sl.l).Log("msg", "Scrape failed", "err", scrapeErr.Error())
+		if errc != nil {
+			errc <- scrapeErr
+		}
+	}
+
+	// A failed scrape is the same as an empty scrape,
+	// we still call sl.append to trigger stale markers.
+	total, added, rejected, appErr := sl.append(b, contentType, start)
+	if appErr != nil {
+		level.Warn(sl.l).Log("msg", "append failed", "err", appErr)
+		// The append failed, probably due to a parse error or sample limit.
+		// Call sl.append again with an empty scrape to trigger stale markers.
+		if _, _, _, err := sl.append([]byte{}, "", start); err != nil {
+			level.Warn(sl.l).Log("msg", "append failed", "err", err)
+		}
+	}
+
+	sl.buffers.Put(b)
+
+	if scrapeErr == nil {
+		scrapeErr = appErr
+	}
+	return total, added, rejected, scrapeErr
+}
+
+// setForcedError sets an error all following scrapes fail with instead of
+// scraping the target. A nil error resumes scraping.
+func (sl *scrapeLoop) setForcedError(err error) {
+	sl.forcedErrMtx.Lock()
+	defer sl.forcedErrMtx.Unlock()
+	sl.forcedErr = err
+}
+
+func (sl *scrapeLoop) getForcedError() error {
+	sl.forcedErrMtx.Lock()
+	defer sl.forcedErrMtx.Unlock()
+	return sl.forcedErr
+}
+
 func (sl *scrapeLoop) endOfRunStaleness(last time.Time, ticker *time.Ticker, interval time.Duration) {
 	// Scraping has stopped. We want to write stale markers but
 	// the target may be recreated, so we wait just over 2 scrape intervals
@@ -906,7 +1151,7 @@ func (sl *scrapeLoop) endOfRunStaleness(last time.Time, ticker *time.Ticker, int
 	// Call sl.append again with an empty scrape to trigger stale markers.
 	// If the target has since been recreated and scraped, the
 	// stale markers will be out of order and ignored.
-	if _, _, err := sl.append([]byte{}, "", staleTime); err != nil {
+	if _, _, _, err := sl.append([]byte{}, "", staleTime); err != nil {
 		level.Error(sl.l).Log("msg", "stale append failed", "err", err)
 	}
 	if err := sl.reportStale(staleTime); err != nil {
@@ -943,14 +1188,21 @@ func (s samples) Less(i, j int) bool {
 	return s[i].t < s[j].t
 }
 
-func (sl *scrapeLoop) append(b []byte, contentType string, ts time.Time) (total, added int, err error) {
+// rejectedSamples counts the samples of a scrape the storage rejected.
+type rejectedSamples struct {
+	outOfOrder  int
+	duplicates  int
+	outOfBounds int
+}
+
+func (sl *scrapeLoop) append(b []byte, contentType string, ts time.Time) (total, added int, rejected rejectedSamples, err error) {
 	var (
-		app            = sl.appender()
-		p              = textparse.New(b, contentType)
-		defTime        = timestamp.FromTime(ts)
-		numOutOfOrder  = 0
-		numDuplicates  = 0
-		numOutOfBounds = 0
+		app     = sl.appender()
+		p       = textparse.New(b, contentType)
+		defTime = timestamp.FromTime(ts)
+		// Exemplars of the added samples, appended once the samples are
+		// committed.
+		exemplars []seriesExemplar
 	)
 	var sampleLimitErr error
 
@@ -981,6 +1233,9 @@ loop:
 
 		t := defTime
 		met, tp, v := p.Series()
+		if !sl.honorTimestamps {
+			tp = nil
+		}
 		if tp != nil {
 			t = *tp
 		}
@@ -988,27 +1243,29 @@ loop:
 		if sl.cache.getDropped(yoloString(met)) {
 			continue
 		}
+		var seriesLabels labels.Labels
 		ce, ok := sl.cache.get(yoloString(met))
 		if ok {
 			switch err = app.AddFast(ce.lset, ce.ref, t, v); err {
 			case nil:
+				seriesLabels = ce.lset
 				if tp == nil {
 					sl.cache.trackStaleness(ce.hash, ce.lset)
 				}
 			case storage.ErrNotFound:
 				ok = false
 			case storage.ErrOutOfOrderSample:
-				numOutOfOrder++
+				rejected.outOfOrder++
 				level.Debug(sl.l).Log("msg", "Out of order sample", "series", string(met))
 				targetScrapeSampleOutOfOrder.Inc()
 				continue
 			case storage.ErrDuplicateSampleForTimestamp:
-				numDuplicates++
+				rejected.duplicates++
 				level.Debug(sl.l).Log("msg", "Duplicate sample for timestamp", "series", string(met))
 				targetScrapeSampleDuplicate.Inc()
 				continue
 			case storage.ErrOutOfBounds:
-				numOutOfBounds++
+				rejected.outOfBounds++
 				level.Debug(sl.l).Log("msg", "Out of bounds metric", "series", string(met))
 				targetScrapeSampleOutOfBounds.Inc()
 				continue
@@ -1038,6 +1295,10 @@ loop:
 				continue
 			}
 
+			if err = verifyLabelLimits(lset, sl.labelLimits); err != nil {
+				break loop
+			}
+
 			var ref uint64
 			ref, err = app.Add(lset, t, v)
 			// TODO(fabxc): also add a dropped-cache?
@@ -1045,19 +1306,19 @@ loop:
 			case nil:
 			case storage.ErrOutOfOrderSample:
 				err = nil
-				numOutOfOrder++
+				rejected.outOfOrder++
 				level.Debug(sl.l).Log("msg", "Out of order sample", "series", string(met))
 				targetScrapeSampleOutOfOrder.Inc()
 				continue
 			case storage.ErrDuplicateSampleForTimestamp:
 				err = nil
-				numDuplicates++
+				rejected.duplicates++
 				level.Debug(sl.l).Log("msg", "Duplicate sample for timestamp", "series", string(met))
 				targetScrapeSampleDuplicate.Inc()
 				continue
 			case storage.ErrOutOfBounds:
 				err = nil
-				numOutOfBounds++
+				rejected.outOfBounds++
 				level.Debug(sl.l).Log("msg", "Out of bounds metric", "series", string(met))
 				targetScrapeSampleOutOfBounds.Inc()
 				continue
@@ -1074,6 +1335,16 @@ loop:
 				sl.cache.trackStaleness(hash, lset)
 			}
 			sl.cache.addRef(mets, ref, lset, hash)
+			seriesLabels = lset
+		}
+		if sl.exemplars != nil {
+			var e exemplar.Exemplar
+			if p.Exemplar(&e) {
+				if !e.HasTs {
+					e.Ts = t
+				}
+				exemplars = append(exemplars, seriesExemplar{lset: seriesLabels, e: e})
+			}
 		}
 		added++
 	}
@@ -1084,14 +1355,14 @@ loop:
 		// We only want to increment this once per scrape, so this is Inc'd outside the loop.
 		targetScrapeSampleLimit.Inc()
 	}
-	if numOutOfOrder > 0 {
-		level.Warn(sl.l).Log("msg", "Error on ingesting out-of-order samples", "num_dropped", numOutOfOrder)
+	if rejected.outOfOrder > 0 {
+		level.Warn(sl.l).Log("msg", "Error on ingesting out-of-order samples", "num_dropped", rejected.outOfOrder)
 	}
-	if numDuplicates > 0 {
-		level.Warn(sl.l).Log("msg", "Error on ingesting samples with different value but same timestamp", "num_dropped", numDuplicates)
+	if rejected.duplicates > 0 {
+		level.Warn(sl.l).Log("msg", "Error on ingesting samples with different value but same timestamp", "num_dropped", rejected.duplicates)
 	}
-	if numOutOfBounds > 0 {
-		level.Warn(sl.l).Log("msg", "Error on ingesting samples that are too old or are too far into the future", "num_dropped", numOutOfBounds)
+	if rejected.outOfBounds > 0 {
+		level.Warn(sl.l).Log("msg", "Error on ingesting samples that are too old or are too far into the future", "num_dropped", rejected.outOfBounds)
 	}
 	if err == nil {
 		sl.cache.forEachStale(func(lset labels.Labels) bool {
@@ -1108,15 +1379,44 @@ loop:
 	}
 	if err != nil {
 		app.Rollback()
-		return total, added, err
+		return total, added, rejected, err
 	}
 	if err := app.Commit(); err != nil {
-		return total, added, err
+		return total, added, rejected, err
 	}
+	sl.appendExemplars(exemplars)
 
 	sl.cache.iterDone()
 
-	return total, added, nil
+	return total, added, rejected, nil
+}
+
+// seriesExemplar is an exemplar of a sample together with the labels of its
+// series.
+type seriesExemplar struct {
+	lset labels.Labels
+	e    exemplar.Exemplar
+}
+
+// appendExemplars adds the exemplars of a scrape to the exemplar storage.
+func (sl *scrapeLoop) appendExemplars(exemplars []seriesExemplar) {
+	numOutOfOrder := 0
+	for _, se := range exemplars {
+		switch err := sl.exemplars.AppendExemplar(se.lset, se.e); err {
+		case nil:
+		case storage.ErrDuplicateExemplar:
+			// Targets expose the same exemplar until they observe a new one.
+		case storage.ErrOutOfOrderExemplar:
+			numOutOfOrder++
+			level.Debug(sl.l).Log("msg", "Out of order exemplar", "series", se.lset.String(), "exemplar", fmt.Sprintf("%+v", se.e))
+			targetScrapeExemplarOutOfOrder.Inc()
+		default:
+			level.Debug(sl.l).Log("msg", "Unexpected error adding exemplar", "series", se.lset.String(), "err", err)
+		}
+	}
+	if numOutOfOrder > 0 {
+		level.Warn(sl.l).Log("msg", "Error on ingesting out-of-order exemplars", "num_dropped", numOutOfOrder)
+	}
 }
 
 func yoloString(b []byte) string {
@@ -1130,9 +1430,12 @@ const (
 	scrapeDurationMetricName     = "scrape_duration_seconds" + "\xff"
 	scrapeSamplesMetricName      = "scrape_samples_scraped" + "\xff"
 	samplesPostRelabelMetricName = "scrape_samples_post_metric_relabeling" + "\xff"
+	samplesOutOfOrderMetricName  = "scrape_samples_out_of_order" + "\xff"
+	samplesDuplicateMetricName   = "scrape_samples_duplicate_timestamp" + "\xff"
+	samplesOutOfBoundsMetricName = "scrape_samples_out_of_bounds" + "\xff"
 )
 
-func (sl *scrapeLoop) report(start time.Time, duration time.Duration, scraped, appended int, err error) error {
+func (sl *scrapeLoop) report(start time.Time, duration time.Duration, scraped, appended int, rejected rejectedSamples, err error) error {
 	sl.scraper.report(start, duration, err)
 
 	ts := timestamp.FromTime(start)
@@ -1159,6 +1462,18 @@ func (sl *scrapeLoop) report(start time.Time, duration time.Duration, scraped, a
 		app.Rollback()
 		return err
 	}
+	if err := sl.addReportSample(app, samplesOutOfOrderMetricName, ts, float64(rejected.outOfOrder)); err != nil {
+		app.Rollback()
+		return err
+	}
+	if err := sl.addReportSample(app, samplesDuplicateMetricName, ts, float64(rejected.duplicates)); err != nil {
+		app.Rollback()
+		return err
+	}
+	if err := sl.addReportSample(app, samplesOutOfBoundsMetricName, ts, float64(rejected.outOfBounds)); err != nil {
+		app.Rollback()
+		return err
+	}
 	return app.Commit()
 }
 
@@ -1184,6 +1499,18 @@ func (sl *scrapeLoop) reportStale(start time.Time) error {
 		app.Rollback()
 		return err
 	}
+	if err := sl.addReportSample(app, samplesOutOfOrderMetricName, ts, stale); err != nil {
+		app.Rollback()
+		return err
+	}
+	if err := sl.addReportSample(app, samplesDuplicateMetricName, ts, stale); err != nil {
+		app.Rollback()
+		return err
+	}
+	if err := sl.addReportSample(app, samplesOutOfBoundsMetricName, ts, stale); err != nil {
+		app.Rollback()
+		return err
+	}
 	return app.Commit()
 }
 
@@ -15,6 +15,7 @@ package scrape
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"fmt"
 	"io"
@@ -31,6 +32,8 @@ import (
 
 	"github.com/prometheus/prometheus/pkg/relabel"
 
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/common/expfmt"
 	"github.com/prometheus/common/model"
 	"github.com/stretchr/testify/require"
 
@@ -38,6 +41,7 @@ import (
 
 	"github.com/prometheus/prometheus/config"
 	"github.com/prometheus/prometheus/discovery/targetgroup"
+	"github.com/prometheus/prometheus/pkg/exemplar"
 	"github.com/prometheus/prometheus/pkg/labels"
 	"github.com/prometheus/prometheus/pkg/textparse"
 	"github.com/prometheus/prometheus/pkg/timestamp"
@@ -50,7 +54,7 @@ func TestNewScrapePool(t *testing.T) {
 	var (
 		app = &nopAppendable{}
 		cfg = &config.ScrapeConfig{}
-		sp  = newScrapePool(cfg, app, nil)
+		sp  = newScrapePool(cfg, app, nil, nil)
 	)
 
 	if a, ok := sp.appendable.(*nopAppendable); !ok || a != app {
@@ -85,7 +89,7 @@ func TestDroppedTargetsList(t *testing.T) {
 				},
 			},
 		}
-		sp                     = newScrapePool(cfg, app, nil)
+		sp                     = newScrapePool(cfg, app, nil, nil)
 		expectedLabelSetString = "{__address__=\"127.0.0.1:9090\", __metrics_path__=\"\", __scheme__=\"\", job=\"dropMe\"}"
 		expectedLength         = 1
 	)
@@ -136,12 +140,26 @@ func TestDiscoveredLabelsUpdate(t *testing.T) {
 type testLoop struct {
 	startFunc func(interval, timeout time.Duration, errc chan<- error)
 	stopFunc  func()
+	forcedErr error
+	mtx       sync.Mutex
 }
 
 func (l *testLoop) run(interval, timeout time.Duration, errc chan<- error) {
 	l.startFunc(interval, timeout, errc)
 }
 
+func (l *testLoop) setForcedError(err error) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	l.forcedErr = err
+}
+
+func (l *testLoop) getForcedError() error {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	return l.forcedErr
+}
+
 func (l *testLoop) stop() {
 	l.stopFunc()
 }
@@ -221,7 +239,7 @@ func TestScrapePoolReload(t *testing.T) {
 	}
 	// On starting to run, new loops created on reload check whether their preceding
 	// equivalents have been stopped.
-	newLoop := func(_ *Target, s scraper, _ int, _ bool, _ []*relabel.Config) loop {
+	newLoop := func(_ *Target, s scraper, _ int, _ *labelLimits, _, _ bool, _ []*relabel.Config) loop {
 		l := &testLoop{}
 		l.startFunc = func(interval, timeout time.Duration, errc chan<- error) {
 			if interval != 3*time.Second {
@@ -304,12 +322,92 @@ func TestScrapePoolReload(t *testing.T) {
 	}
 }
 
+func TestScrapePoolTargetLimit(t *testing.T) {
+	var mtx sync.Mutex
+	var loops []*testLoop
+	newLoop := func(_ *Target, s scraper, _ int, _ *labelLimits, _, _ bool, _ []*relabel.Config) loop {
+		l := &testLoop{}
+		l.startFunc = func(interval, timeout time.Duration, errc chan<- error) {}
+		l.stopFunc = func() {}
+		mtx.Lock()
+		loops = append(loops, l)
+		mtx.Unlock()
+		return l
+	}
+	sp := &scrapePool{
+		appendable:    &nopAppendable{},
+		activeTargets: map[uint64]*Target{},
+		loops:         map[uint64]loop{},
+		newLoop:       newLoop,
+		logger:        log.NewNopLogger(),
+		client:        http.DefaultClient,
+	}
+
+	targets := func(n int) []*Target {
+		var res []*Target
+		for i := 0; i < n; i++ {
+			res = append(res, &Target{
+				labels: labels.FromStrings(model.AddressLabel, fmt.Sprintf("example.com:%d", i)),
+			})
+		}
+		return res
+	}
+	validateErrors := func(expectErr bool) {
+		for h, l := range sp.loops {
+			err := l.(*testLoop).getForcedError()
+			if expectErr && err == nil {
+				t.Fatalf("Expected target_limit error for loop %d, got none", h)
+			}
+			if !expectErr && err != nil {
+				t.Fatalf("Unexpected error for loop %d: %s", h, err)
+			}
+		}
+	}
+
+	reload := func(limit uint) {
+		sp.reload(&config.ScrapeConfig{
+			ScrapeInterval: model.Duration(3 * time.Second),
+			ScrapeTimeout:  model.Duration(2 * time.Second),
+			TargetLimit:    limit,
+		})
+	}
+
+	// Start with a limit higher than the number of targets.
+	reload(10)
+	sp.sync(targets(5))
+	validateErrors(false)
+
+	// Exceed the limit with a sync.
+	sp.sync(targets(15))
+	if len(sp.loops) != 15 {
+		t.Fatalf("Expected 15 loops, got %d", len(sp.loops))
+	}
+	validateErrors(true)
+
+	// Get back under the limit with a reload.
+	reload(20)
+	validateErrors(false)
+
+	// Exceed the limit with a reload.
+	reload(10)
+	validateErrors(true)
+
+	// Disable the limit.
+	reload(0)
+	validateErrors(false)
+
+	// Get back under the limit with a sync.
+	reload(10)
+	sp.sync(targets(10))
+	validateErrors(false)
+}
+
 func TestScrapePoolAppender(t *testing.T) {
 	cfg := &config.ScrapeConfig{}
 	app := &nopAppendable{}
-	sp := newScrapePool(cfg, app, nil)
+	sp := newScrapePool(cfg, app, nil, nil)
 
-	loop := sp.newLoop(&Target{}, nil, 0, false, nil)
+	loop := sp.newLoop(&Target{}, nil, 0, nil, false, false, nil)
 	appl, ok := loop.(*scrapeLoop)
 	if !ok {
 		t.Fatalf("Expected scrapeLoop but got %T", loop)
@@ -324,7 +422,7 @@ func TestScrapePoolAppender(t *testing.T) {
 		t.Fatalf("Expected base appender but got %T", tl.Appender)
 	}
 
-	loop = sp.newLoop(&Target{}, nil, 100, false, nil)
+	loop = sp.newLoop(&Target{}, nil, 100, nil, false, false, nil)
 	appl, ok = loop.(*scrapeLoop)
 	if !ok {
 		t.Fatalf("Expected scrapeLoop but got %T", loop)
@@ -350,7 +448,7 @@ func TestScrapePoolRaces(t *testing.T) {
 	newConfig := func() *config.ScrapeConfig {
 		return &config.ScrapeConfig{ScrapeInterval: interval, ScrapeTimeout: timeout}
 	}
-	sp := newScrapePool(newConfig(), &nopAppendable{}, nil)
+	sp := newScrapePool(newConfig(), &nopAppendable{}, nil, nil)
 	tgts := []*targetgroup.Group{
 		{
 			Targets: []model.LabelSet{
@@ -393,6 +491,9 @@ func TestScrapeLoopStopBeforeRun(t *testing.T) {
 		nopMutator,
 		nopMutator,
 		nil, nil,
+		nil,
+		nil,
+		true,
 	)
 
 	// The scrape pool synchronizes on stopping scrape loops. However, new scrape
@@ -456,6 +557,9 @@ func TestScrapeLoopStop(t *testing.T) {
 		nopMutator,
 		app,
 		nil,
+		nil,
+		nil,
+		true,
 	)
 
 	// Terminate loop after 2 scrapes.
@@ -481,15 +585,15 @@ func TestScrapeLoopStop(t *testing.T) {
 		t.Fatalf("Scrape wasn't stopped.")
 	}
 
-	// We expected 1 actual sample for each scrape plus 4 for report samples.
+	// We expected 1 actual sample for each scrape plus 7 for report samples.
 	// At least 2 scrapes were made, plus the final stale markers.
-	if len(appender.result) < 5*3 || len(appender.result)%5 != 0 {
-		t.Fatalf("Expected at least 3 scrapes with 4 samples each, got %d samples", len(appender.result))
+	if len(appender.result) < 8*3 || len(appender.result)%8 != 0 {
+		t.Fatalf("Expected at least 3 scrapes with 8 samples each, got %d samples", len(appender.result))
 	}
 	// All samples in a scrape must have the same timestamp.
 	var ts int64
 	for i, s := range appender.result {
-		if i%5 == 0 {
+		if i%8 == 0 {
 			ts = s.t
 		} else if s.t != ts {
 			t.Fatalf("Unexpected multiple timestamps within single scrape")
@@ -521,6 +625,9 @@ func TestScrapeLoopRun(t *testing.T) {
 		nopMutator,
 		app,
 		nil,
+		nil,
+		nil,
+		true,
 	)
 
 	// The loop must terminate during the initial offset if the context
@@ -566,6 +673,9 @@ func TestScrapeLoopRun(t *testing.T) {
 		nopMutator,
 		app,
 		nil,
+		nil,
+		nil,
+		true,
 	)
 
 	go func() {
@@ -614,10 +724,13 @@ func TestScrapeLoopMetadata(t *testing.T) {
 		nopMutator,
 		func() storage.Appender { return nopAppender{} },
 		cache,
+		nil,
+		nil,
+		true,
 	)
 	defer cancel()
 
-	total, _, err := sl.append([]byte(`# TYPE test_metric counter
+	total, _, _, err := sl.append([]byte(`# TYPE test_metric counter
 # HELP test_metric some help text
 # UNIT test_metric metric
 test_metric 1
@@ -663,6 +776,9 @@ func TestScrapeLoopRunCreatesStaleMarkersOnFailedScrape(t *testing.T) {
 		nopMutator,
 		app,
 		nil,
+		nil,
+		nil,
+		true,
 	)
 	// Succeed once, several failures, then stop.
 	numScrapes := 0
@@ -690,16 +806,16 @@ func TestScrapeLoopRunCreatesStaleMarkersOnFailedScrape(t *testing.T) {
 		t.Fatalf("Scrape wasn't stopped.")
 	}
 
-	// 1 successfully scraped sample, 1 stale marker after first fail, 4 report samples for
+	// 1 successfully scraped sample, 1 stale marker after first fail, 7 report samples for
 	// each scrape successful or not.
-	if len(appender.result) != 22 {
-		t.Fatalf("Appended samples not as expected. Wanted: %d samples Got: %d", 22, len(appender.result))
+	if len(appender.result) != 37 {
+		t.Fatalf("Appended samples not as expected. Wanted: %d samples Got: %d", 37, len(appender.result))
 	}
 	if appender.result[0].v != 42.0 {
 		t.Fatalf("Appended first sample not as expected. Wanted: %f Got: %f", appender.result[0].v, 42.0)
 	}
-	if !value.IsStaleNaN(appender.result[5].v) {
-		t.Fatalf("Appended second sample not as expected. Wanted: stale NaN Got: %x", math.Float64bits(appender.result[5].v))
+	if !value.IsStaleNaN(appender.result[8].v) {
+		t.Fatalf("Appended second sample not as expected. Wanted: stale NaN Got: %x", math.Float64bits(appender.result[8].v))
 	}
 }
 
@@ -721,6 +837,9 @@ func TestScrapeLoopRunCreatesStaleMarkersOnParseFailure(t *testing.T) {
 		nopMutator,
 		app,
 		nil,
+		nil,
+		nil,
+		true,
 	)
 
 	// Succeed once, several failures, then stop.
@@ -750,16 +869,16 @@ func TestScrapeLoopRunCreatesStaleMarkersOnParseFailure(t *testing.T) {
 		t.Fatalf("Scrape wasn't stopped.")
 	}
 
-	// 1 successfully scraped sample, 1 stale marker after first fail, 4 report samples for
+	// 1 successfully scraped sample, 1 stale marker after first fail, 7 report samples for
 	// each scrape successful or not.
-	if len(appender.result) != 14 {
-		t.Fatalf("Appended samples not as expected. Wanted: %d samples Got: %d", 22, len(appender.result))
+	if len(appender.result) != 23 {
+		t.Fatalf("Appended samples not as expected. Wanted: %d samples Got: %d", 23, len(appender.result))
 	}
 	if appender.result[0].v != 42.0 {
 		t.Fatalf("Appended first sample not as expected. Wanted: %f Got: %f", appender.result[0].v, 42.0)
 	}
-	if !value.IsStaleNaN(appender.result[5].v) {
-		t.Fatalf("Appended second sample not as expected. Wanted: stale NaN Got: %x", math.Float64bits(appender.result[5].v))
+	if !value.IsStaleNaN(appender.result[8].v) {
+		t.Fatalf("Appended second sample not as expected. Wanted: stale NaN Got: %x", math.Float64bits(appender.result[8].v))
 	}
 }
 
@@ -825,11 +944,14 @@ func TestScrapeLoopAppend(t *testing.T) {
 			},
 			func() storage.Appender { return app },
 			nil,
+			nil,
+			nil,
+			true,
 		)
 
 		now := time.Now()
 
-		_, _, err := sl.append([]byte(test.scrapeLabels), "", now)
+		_, _, _, err := sl.append([]byte(test.scrapeLabels), "", now)
 		if err != nil {
 			t.Fatalf("Unexpected append error: %s", err)
 		}
@@ -864,6 +986,9 @@ func TestScrapeLoopAppendSampleLimit(t *testing.T) {
 		nopMutator,
 		func() storage.Appender { return app },
 		nil,
+		nil,
+		nil,
+		true,
 	)
 
 	// Get the value of the Counter before performing the append.
@@ -875,7 +1000,7 @@ func TestScrapeLoopAppendSampleLimit(t *testing.T) {
 	beforeMetricValue := beforeMetric.GetCounter().GetValue()
 
 	now := time.Now()
-	_, _, err = sl.append([]byte("metric_a 1\nmetric_b 1\nmetric_c 1\n"), "", now)
+	_, _, _, err = sl.append([]byte("metric_a 1\nmetric_b 1\nmetric_c 1\n"), "", now)
 	if err != errSampleLimit {
 		t.Fatalf("Did not see expected sample limit error: %s", err)
 	}
@@ -905,6 +1030,78 @@ func TestScrapeLoopAppendSampleLimit(t *testing.T) {
 	}
 }
 
+func TestScrapeLoopAppendLabelLimits(t *testing.T) {
+	cases := []struct {
+		title   string
+		scrape  string
+		limits  *labelLimits
+		wantErr string
+	}{
+		{
+			title:  "Valid number of labels",
+			scrape: `metric{l1="1", l2="2"} 0`,
+			limits: &labelLimits{labelLimit: 5},
+		},
+		{
+			title:   "Too many labels",
+			scrape:  `metric{l1="1", l2="2", l3="3", l4="4", l5="5", l6="6"} 0`,
+			limits:  &labelLimits{labelLimit: 5},
+			wantErr: "label_limit exceeded",
+		},
+		{
+			title:  "Valid length of label names",
+			scrape: `metric{l1="1", l2="2"} 0`,
+			limits: &labelLimits{labelNameLengthLimit: 10},
+		},
+		{
+			title:   "Label name too long",
+			scrape:  `metric{label_name_too_long="0"} 0`,
+			limits:  &labelLimits{labelNameLengthLimit: 10},
+			wantErr: "label_name_length_limit exceeded",
+		},
+		{
+			title:  "Valid length of label values",
+			scrape: `metric{l1="1", l2="2"} 0`,
+			limits: &labelLimits{labelValueLengthLimit: 10},
+		},
+		{
+			title:   "Label value too long",
+			scrape:  `metric{l1="label_value_too_long"} 0`,
+			limits:  &labelLimits{labelValueLengthLimit: 10},
+			wantErr: "label_value_length_limit exceeded",
+		},
+		{
+			title:  "No limits",
+			scrape: `metric{label_name_too_long="label_value_too_long"} 0`,
+			limits: &labelLimits{},
+		},
+	}
+
+	for _, c := range cases {
+		app := &collectResultAppender{}
+		sl := newScrapeLoop(context.Background(),
+			nil, nil, nil,
+			nopMutator,
+			nopMutator,
+			func() storage.Appender { return app },
+			nil,
+			c.limits,
+			nil,
+			true,
+		)
+
+		_, _, _, err := sl.append([]byte(c.scrape+"\n"), "", time.Now())
+		if c.wantErr == "" {
+			testutil.Ok(t, err)
+			testutil.Equals(t, 1, len(app.result))
+			continue
+		}
+		testutil.NotOk(t, err, "%s: expected error", c.title)
+		testutil.Assert(t, strings.Contains(err.Error(), c.wantErr), "%s: unexpected error: %s", c.title, err)
+		testutil.Equals(t, 0, len(app.result))
+	}
+}
+
 func TestScrapeLoop_ChangingMetricString(t *testing.T) {
 	// This is a regression test for the scrape loop cache not properly maintaining
 	// IDs when the string representation of a metric changes across a scrape. Thus
@@ -924,14 +1121,17 @@ func TestScrapeLoop_ChangingMetricString(t *testing.T) {
 		nopMutator,
 		func() storage.Appender { return capp },
 		nil,
+		nil,
+		nil,
+		true,
 	)
 
 	now := time.Now()
-	_, _, err = sl.append([]byte(`metric_a{a="1",b="1"} 1`), "", now)
+	_, _, _, err = sl.append([]byte(`metric_a{a="1",b="1"} 1`), "", now)
 	if err != nil {
 		t.Fatalf("Unexpected append error: %s", err)
 	}
-	_, _, err = sl.append([]byte(`metric_a{b="1",a="1"} 2`), "", now.Add(time.Minute))
+	_, _, _, err = sl.append([]byte(`metric_a{b="1",a="1"} 2`), "", now.Add(time.Minute))
 	if err != nil {
 		t.Fatalf("Unexpected append error: %s", err)
 	}
@@ -954,6 +1154,49 @@ func TestScrapeLoop_ChangingMetricString(t *testing.T) {
 	}
 }
 
+func TestScrapeLoopAppendExemplars(t *testing.T) {
+	app := &collectResultAppender{}
+	es, err := storage.NewCircularExemplarStorage(10, nil)
+	testutil.Ok(t, err)
+
+	sl := newScrapeLoop(context.Background(),
+		nil, nil, nil,
+		nopMutator,
+		nopMutator,
+		func() storage.Appender { return app },
+		nil,
+		nil,
+		es,
+		true,
+	)
+
+	now := time.Unix(1000, 0)
+	scrapes := []string{
+		"metric_total 1 # {trace_id=\"a\"} 1\n# EOF\n",
+		"metric_total 2 # {trace_id=\"b\"} 2 1000.5\n# EOF\n",
+		// The same exemplar is exposed until a new one is observed.
+		"metric_total 3 # {trace_id=\"b\"} 2 1000.5\n# EOF\n",
+	}
+	for i, b := range scrapes {
+		_, _, _, err := sl.append([]byte(b), "application/openmetrics-text", now.Add(time.Duration(i)*time.Second))
+		testutil.Ok(t, err)
+	}
+
+	lset := labels.FromStrings(model.MetricNameLabel, "metric_total")
+	m, err := labels.NewMatcher(labels.MatchEqual, model.MetricNameLabel, "metric_total")
+	testutil.Ok(t, err)
+	res, err := es.Select(0, math.MaxInt64, []*labels.Matcher{m})
+	testutil.Ok(t, err)
+	testutil.Equals(t, []exemplar.QueryResult{{
+		SeriesLabels: lset,
+		Exemplars: []exemplar.Exemplar{
+			// Exemplars without timestamp get the one of the scrape.
+			{Labels: labels.FromStrings("trace_id", "a"), Value: 1, Ts: 1000000},
+			{Labels: labels.FromStrings("trace_id", "b"), Value: 2, Ts: 1000500, HasTs: true},
+		},
+	}}, res)
+}
+
 func TestScrapeLoopAppendStaleness(t *testing.T) {
 	app := &collectResultAppender{}
 
@@ -963,14 +1206,17 @@ func TestScrapeLoopAppendStaleness(t *testing.T) {
 		nopMutator,
 		func() storage.Appender { return app },
 		nil,
+		nil,
+		nil,
+		true,
 	)
 
 	now := time.Now()
-	_, _, err := sl.append([]byte("metric_a 1\n"), "", now)
+	_, _, _, err := sl.append([]byte("metric_a 1\n"), "", now)
 	if err != nil {
 		t.Fatalf("Unexpected append error: %s", err)
 	}
-	_, _, err = sl.append([]byte(""), "", now.Add(time.Second))
+	_, _, _, err = sl.append([]byte(""), "", now.Add(time.Second))
 	if err != nil {
 		t.Fatalf("Unexpected append error: %s", err)
 	}
@@ -1008,14 +1254,17 @@ func TestScrapeLoopAppendNoStalenessIfTimestamp(t *testing.T) {
 		nopMutator,
 		func() storage.Appender { return app },
 		nil,
+		nil,
+		nil,
+		true,
 	)
 
 	now := time.Now()
-	_, _, err := sl.append([]byte("metric_a 1 1000\n"), "", now)
+	_, _, _, err := sl.append([]byte("metric_a 1 1000\n"), "", now)
 	if err != nil {
 		t.Fatalf("Unexpected append error: %s", err)
 	}
-	_, _, err = sl.append([]byte(""), "", now.Add(time.Second))
+	_, _, _, err = sl.append([]byte(""), "", now.Add(time.Second))
 	if err != nil {
 		t.Fatalf("Unexpected append error: %s", err)
 	}
@@ -1047,6 +1296,9 @@ func TestScrapeLoopRunReportsTargetDownOnScrapeError(t *testing.T) {
 		nopMutator,
 		app,
 		nil,
+		nil,
+		nil,
+		true,
 	)
 
 	scraper.scrapeFunc = func(ctx context.Context, w io.Writer) error {
@@ -1076,6 +1328,9 @@ func TestScrapeLoopRunReportsTargetDownOnInvalidUTF8(t *testing.T) {
 		nopMutator,
 		app,
 		nil,
+		nil,
+		nil,
+		true,
 	)
 
 	scraper.scrapeFunc = func(ctx context.Context, w io.Writer) error {
@@ -1112,6 +1367,37 @@ func (app *errorAppender) AddFast(lset labels.Labels, ref uint64, t int64, v flo
 	return app.collectResultAppender.AddFast(lset, ref, t, v)
 }
 
+func TestScrapeLoopAppendIgnoreTimestamps(t *testing.T) {
+	app := &collectResultAppender{}
+	sl := newScrapeLoop(context.Background(),
+		nil, nil, nil,
+		nopMutator,
+		nopMutator,
+		func() storage.Appender { return app },
+		nil,
+		nil,
+		nil,
+		false,
+	)
+
+	now := time.Now()
+	_, _, _, err := sl.append([]byte("metric_a 1 1000\n"), "", now)
+	testutil.Ok(t, err)
+	_, _, _, err = sl.append([]byte(""), "", now.Add(time.Second))
+	testutil.Ok(t, err)
+
+	// The scrape time is used instead of the exposed timestamp, so the series
+	// is marked stale once it disappears.
+	testutil.Equals(t, 2, len(app.result))
+	testutil.Equals(t, sample{
+		metric: labels.FromStrings(model.MetricNameLabel, "metric_a"),
+		t:      timestamp.FromTime(now),
+		v:      1,
+	}, app.result[0])
+	testutil.Equals(t, timestamp.FromTime(now.Add(time.Second)), app.result[1].t)
+	testutil.Assert(t, value.IsStaleNaN(app.result[1].v), "expected stale marker, got %v", app.result[1].v)
+}
+
 func TestScrapeLoopAppendGracefullyIfAmendOrOutOfOrderOrOutOfBounds(t *testing.T) {
 	app := &errorAppender{}
 
@@ -1122,10 +1408,13 @@ func TestScrapeLoopAppendGracefullyIfAmendOrOutOfOrderOrOutOfBounds(t *testing.T
 		nopMutator,
 		func() storage.Appender { return app },
 		nil,
+		nil,
+		nil,
+		true,
 	)
 
 	now := time.Unix(1, 0)
-	_, _, err := sl.append([]byte("out_of_order 1\namend 1\nnormal 1\nout_of_bounds 1\n"), "", now)
+	_, _, rejected, err := sl.append([]byte("out_of_order 1\namend 1\nnormal 1\nout_of_bounds 1\n"), "", now)
 	if err != nil {
 		t.Fatalf("Unexpected append error: %s", err)
 	}
@@ -1139,6 +1428,42 @@ func TestScrapeLoopAppendGracefullyIfAmendOrOutOfOrderOrOutOfBounds(t *testing.T
 	if !reflect.DeepEqual(want, app.result) {
 		t.Fatalf("Appended samples not as expected. Wanted: %+v Got: %+v", want, app.result)
 	}
+	testutil.Equals(t, rejectedSamples{outOfOrder: 1, duplicates: 1, outOfBounds: 1}, rejected)
+}
+
+func TestScrapeLoopReportRejectedSamples(t *testing.T) {
+	app := &collectResultAppender{}
+
+	sl := newScrapeLoop(context.Background(),
+		&testScraper{},
+		nil, nil,
+		nopMutator,
+		nopMutator,
+		func() storage.Appender { return app },
+		nil,
+		nil,
+		nil,
+		true,
+	)
+
+	now := time.Unix(1, 0)
+	rejected := rejectedSamples{outOfOrder: 1, duplicates: 2, outOfBounds: 3}
+	testutil.Ok(t, sl.report(now, time.Second, 10, 4, rejected, nil))
+
+	got := map[string]float64{}
+	for _, s := range app.result {
+		testutil.Equals(t, timestamp.FromTime(now), s.t)
+		got[s.metric.Get(model.MetricNameLabel)] = s.v
+	}
+	testutil.Equals(t, map[string]float64{
+		"up":                                    1,
+		"scrape_duration_seconds":               1,
+		"scrape_samples_scraped":                10,
+		"scrape_samples_post_metric_relabeling": 4,
+		"scrape_samples_out_of_order":           1,
+		"scrape_samples_duplicate_timestamp":    2,
+		"scrape_samples_out_of_bounds":          3,
+	}, got)
 }
 
 func TestScrapeLoopOutOfBoundsTimeError(t *testing.T) {
@@ -1155,10 +1480,13 @@ func TestScrapeLoopOutOfBoundsTimeError(t *testing.T) {
 			}
 		},
 		nil,
+		nil,
+		nil,
+		true,
 	)
 
 	now := time.Now().Add(20 * time.Minute)
-	total, added, err := sl.append([]byte("normal 1\n"), "", now)
+	total, added, _, err := sl.append([]byte("normal 1\n"), "", now)
 	if total != 1 {
 		t.Error("expected 1 metric")
 		return
@@ -1306,6 +1634,169 @@ func TestTargetScrapeScrapeNotFound(t *testing.T) {
 	}
 }
 
+func TestAcceptHeader(t *testing.T) {
+	cases := []struct {
+		protocols []config.ScrapeProtocol
+		expected  string
+	}{
+		{
+			expected: "application/openmetrics-text;version=0.0.1,text/plain;version=0.0.4;q=0.5,*/*;q=0.1",
+		},
+		{
+			protocols: []config.ScrapeProtocol{config.PrometheusProto, config.OpenMetricsText, config.PrometheusText},
+			expected:  "application/vnd.google.protobuf;proto=io.prometheus.client.MetricFamily;encoding=delimited,application/openmetrics-text;version=0.0.1;q=0.5,text/plain;version=0.0.4;q=0.4,*/*;q=0.1",
+		},
+		{
+			protocols: []config.ScrapeProtocol{config.PrometheusText},
+			expected:  "text/plain;version=0.0.4,*/*;q=0.1",
+		},
+	}
+	for _, c := range cases {
+		testutil.Equals(t, c.expected, acceptHeader(c.protocols))
+	}
+}
+
+func TestTargetScraperScrapeProtobuf(t *testing.T) {
+	var tp expfmt.TextParser
+	mfs, err := tp.TextToMetricFamilies(strings.NewReader("# TYPE metric_a counter\nmetric_a{a=\"b\"} 1\n"))
+	testutil.Ok(t, err)
+	var body bytes.Buffer
+	testutil.Ok(t, expfmt.NewEncoder(&body, expfmt.FmtProtoDelim).Encode(mfs["metric_a"]))
+
+	accept := acceptHeader([]config.ScrapeProtocol{config.PrometheusProto, config.PrometheusText})
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if got := r.Header.Get("Accept"); got != accept {
+				t.Errorf("Expected Accept header %q, got %q", accept, got)
+			}
+			w.Header().Set("Content-Type", string(expfmt.FmtProtoDelim))
+			w.Write(body.Bytes())
+		}),
+	)
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		panic(err)
+	}
+
+	ts := &targetScraper{
+		Target: &Target{
+			labels: labels.FromStrings(
+				model.SchemeLabel, serverURL.Scheme,
+				model.AddressLabel, serverURL.Host,
+			),
+		},
+		client:       http.DefaultClient,
+		acceptHeader: accept,
+	}
+	var buf bytes.Buffer
+
+	contentType, err := ts.scrape(context.Background(), &buf)
+	testutil.Ok(t, err)
+
+	app := &collectResultAppender{}
+	sl := newScrapeLoop(context.Background(),
+		nil, nil, nil,
+		nopMutator,
+		nopMutator,
+		func() storage.Appender { return app },
+		nil,
+		nil,
+		nil,
+		true,
+	)
+	now := time.Now()
+	total, added, _, err := sl.append(buf.Bytes(), contentType, now)
+	testutil.Ok(t, err)
+	testutil.Equals(t, 1, total)
+	testutil.Equals(t, 1, added)
+
+	want := []sample{
+		{
+			metric: labels.FromStrings(model.MetricNameLabel, "metric_a", "a", "b"),
+			t:      timestamp.FromTime(now),
+			v:      1,
+		},
+	}
+	testutil.Equals(t, want, app.result)
+}
+
+func TestTargetScraperBodySizeLimit(t *testing.T) {
+	const bodySizeLimit = 100
+	// The compressed body fits into the limit, the uncompressed one doesn't.
+	responseBody := strings.Repeat("metric_a 1\n", 100)
+	var gzipResponse bool
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", `text/plain; version=0.0.4`)
+			if gzipResponse {
+				w.Header().Set("Content-Encoding", "gzip")
+				gw := gzip.NewWriter(w)
+				defer gw.Close()
+				gw.Write([]byte(responseBody))
+				return
+			}
+			w.Write([]byte(responseBody))
+		}),
+	)
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		panic(err)
+	}
+
+	ts := &targetScraper{
+		Target: &Target{
+			labels: labels.FromStrings(
+				model.SchemeLabel, serverURL.Scheme,
+				model.AddressLabel, serverURL.Host,
+			),
+		},
+		client:        http.DefaultClient,
+		bodySizeLimit: bodySizeLimit,
+	}
+	var buf bytes.Buffer
+
+	// Target response uncompressed body, scrape with body size limit.
+	_, err = ts.scrape(context.Background(), &buf)
+	testutil.NotOk(t, err, "expected body size limit error")
+	testutil.Assert(t, strings.Contains(err.Error(), errBodySizeLimit.Error()), "unexpected error: %s", err)
+	testutil.Equals(t, bodySizeLimit, buf.Len())
+
+	// Target response gzip compressed body, scrape with body size limit.
+	gzipResponse = true
+	buf.Reset()
+	_, err = ts.scrape(context.Background(), &buf)
+	testutil.NotOk(t, err, "expected body size limit error")
+	testutil.Assert(t, strings.Contains(err.Error(), errBodySizeLimit.Error()), "unexpected error: %s", err)
+	testutil.Equals(t, bodySizeLimit, buf.Len())
+
+	// Target response gzip compressed body exceeding the limit, too.
+	ts.bodySizeLimit = 5
+	buf.Reset()
+	_, err = ts.scrape(context.Background(), &buf)
+	testutil.NotOk(t, err, "expected body size limit error")
+	testutil.Assert(t, strings.Contains(err.Error(), errBodySizeLimit.Error()), "unexpected error: %s", err)
+
+	// Target response uncompressed body, scrape with body size limit larger than the body.
+	gzipResponse = false
+	ts.bodySizeLimit = int64(len(responseBody))
+	buf.Reset()
+	_, err = ts.scrape(context.Background(), &buf)
+	testutil.Ok(t, err)
+	testutil.Equals(t, responseBody, buf.String())
+
+	// Target response gzip compressed body, scrape without body size limit.
+	gzipResponse = true
+	ts.bodySizeLimit = 0
+	buf.Reset()
+	_, err = ts.scrape(context.Background(), &buf)
+	testutil.Ok(t, err)
+	testutil.Equals(t, responseBody, buf.String())
+}
+
 // testScraper implements the scraper interface and allows setting values
 // returned by its methods. It also allows setting a custom scrape function.
 type testScraper struct {
@@ -0,0 +1,191 @@
+// Copyright 2019 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/prometheus/prometheus/pkg/exemplar"
+	"github.com/prometheus/prometheus/pkg/labels"
+)
+
+// noExemplar marks the absence of a next exemplar of a series in the
+// circular buffer.
+const noExemplar = -1
+
+// CircularExemplarStorage is an in-memory ExemplarStorage keeping a fixed
+// number of exemplars in a circular buffer. Once the buffer is full, adding
+// an exemplar overwrites the oldest one of all series.
+type CircularExemplarStorage struct {
+	mtx       sync.RWMutex
+	exemplars []*circularBufferEntry
+	nextIndex int
+
+	// The first and last exemplar of each series in the buffer, by the
+	// string representation of the series labels.
+	index map[string]*indexEntry
+
+	exemplarsAppended  prometheus.Counter
+	exemplarsInStorage prometheus.Gauge
+	seriesInStorage    prometheus.Gauge
+	outOfOrder         prometheus.Counter
+}
+
+type indexEntry struct {
+	oldest       int
+	newest       int
+	seriesLabels labels.Labels
+}
+
+type circularBufferEntry struct {
+	exemplar exemplar.Exemplar
+	// The position of the next exemplar of the same series in the buffer.
+	next int
+	ref  *indexEntry
+}
+
+// NewCircularExemplarStorage returns a storage keeping up to size exemplars.
+// Its metrics are registered with reg if it is not nil.
+func NewCircularExemplarStorage(size int, reg prometheus.Registerer) (*CircularExemplarStorage, error) {
+	if size <= 0 {
+		return nil, errors.Errorf("invalid exemplar storage size %d, must be positive", size)
+	}
+	c := &CircularExemplarStorage{
+		exemplars: make([]*circularBufferEntry, size),
+		index:     map[string]*indexEntry{},
+
+		exemplarsAppended: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "prometheus_exemplars_appended_total",
+			Help: "Total number of appended exemplars.",
+		}),
+		exemplarsInStorage: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "prometheus_exemplars_in_storage",
+			Help: "Number of exemplars currently in the circular exemplar storage.",
+		}),
+		seriesInStorage: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "prometheus_exemplars_series_in_storage",
+			Help: "Number of series with exemplars currently in the circular exemplar storage.",
+		}),
+		outOfOrder: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "prometheus_exemplars_out_of_order_total",
+			Help: "Total number of exemplars rejected because they were older than the newest exemplar of their series.",
+		}),
+	}
+	if reg != nil {
+		reg.MustRegister(c.exemplarsAppended, c.exemplarsInStorage, c.seriesInStorage, c.outOfOrder)
+	}
+	return c, nil
+}
+
+// AppendExemplar implements ExemplarAppender. An exemplar equal to the newest
+// one of the series is rejected with ErrDuplicateExemplar, one older than it
+// with ErrOutOfOrderExemplar.
+func (c *CircularExemplarStorage) AppendExemplar(l labels.Labels, e exemplar.Exemplar) error {
+	key := l.String()
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	idx, ok := c.index[key]
+	if ok {
+		newest := c.exemplars[idx.newest].exemplar
+		if newest.Equals(e) {
+			return ErrDuplicateExemplar
+		}
+		if e.Ts < newest.Ts {
+			c.outOfOrder.Inc()
+			return ErrOutOfOrderExemplar
+		}
+	}
+
+	// Evict the oldest exemplar of all series if the buffer is full.
+	if prev := c.exemplars[c.nextIndex]; prev != nil {
+		if prev.next == noExemplar {
+			delete(c.index, prev.ref.seriesLabels.String())
+		} else {
+			prev.ref.oldest = prev.next
+		}
+	} else {
+		c.exemplarsInStorage.Inc()
+	}
+
+	// The series might have been evicted above if its only exemplar was
+	// overwritten.
+	if _, ok := c.index[key]; !ok {
+		idx = &indexEntry{oldest: c.nextIndex, seriesLabels: l}
+		c.index[key] = idx
+	} else {
+		c.exemplars[idx.newest].next = c.nextIndex
+	}
+	idx.newest = c.nextIndex
+
+	c.exemplars[c.nextIndex] = &circularBufferEntry{exemplar: e, next: noExemplar, ref: idx}
+	c.nextIndex = (c.nextIndex + 1) % len(c.exemplars)
+
+	c.exemplarsAppended.Inc()
+	c.seriesInStorage.Set(float64(len(c.index)))
+	return nil
+}
+
+// Select implements ExemplarQuerier. The results are ordered by series
+// labels, the exemplars of each series by timestamp.
+func (c *CircularExemplarStorage) Select(start, end int64, matchers ...[]*labels.Matcher) ([]exemplar.QueryResult, error) {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+
+	var res []exemplar.QueryResult
+	for _, idx := range c.index {
+		if !matchesAny(idx.seriesLabels, matchers) {
+			continue
+		}
+		var es []exemplar.Exemplar
+		for i := idx.oldest; i != noExemplar; i = c.exemplars[i].next {
+			e := c.exemplars[i].exemplar
+			if e.Ts > end {
+				break
+			}
+			if e.Ts >= start {
+				es = append(es, e)
+			}
+		}
+		if len(es) > 0 {
+			res = append(res, exemplar.QueryResult{SeriesLabels: idx.seriesLabels, Exemplars: es})
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return labels.Compare(res[i].SeriesLabels, res[j].SeriesLabels) < 0
+	})
+	return res, nil
+}
+
+// matchesAny returns whether l matches all matchers of any of the sets.
+func matchesAny(l labels.Labels, matcherSets [][]*labels.Matcher) bool {
+	for _, ms := range matcherSets {
+		matches := true
+		for _, m := range ms {
+			if !m.Matches(l.Get(m.Name)) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return true
+		}
+	}
+	return false
+}
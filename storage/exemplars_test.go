@@ -0,0 +1,110 @@
+// Copyright 2019 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/prometheus/prometheus/pkg/exemplar"
+	"github.com/prometheus/prometheus/pkg/labels"
+)
+
+func TestCircularExemplarStorageAppend(t *testing.T) {
+	es, err := NewCircularExemplarStorage(5, nil)
+	require.NoError(t, err)
+
+	l := labels.FromStrings("__name__", "test_metric", "service", "asdf")
+	e := exemplar.Exemplar{
+		Labels: labels.FromStrings("traceID", "qwerty"),
+		Value:  0.1,
+		Ts:     1,
+	}
+	require.NoError(t, es.AppendExemplar(l, e))
+	require.Equal(t, ErrDuplicateExemplar, es.AppendExemplar(l, e))
+
+	e.Ts = 0
+	e.Labels = labels.FromStrings("traceID", "zxcvb")
+	require.Equal(t, ErrOutOfOrderExemplar, es.AppendExemplar(l, e))
+
+	e.Ts = 2
+	require.NoError(t, es.AppendExemplar(l, e))
+
+	// Exemplars of other series are not affected.
+	require.NoError(t, es.AppendExemplar(labels.FromStrings("__name__", "other_metric"), e))
+}
+
+func TestCircularExemplarStorageSelect(t *testing.T) {
+	es, err := NewCircularExemplarStorage(4, nil)
+	require.NoError(t, err)
+
+	var (
+		a = labels.FromStrings("__name__", "test_metric", "service", "a")
+		b = labels.FromStrings("__name__", "test_metric", "service", "b")
+	)
+	exemplars := func(tss ...int64) []exemplar.Exemplar {
+		var res []exemplar.Exemplar
+		for _, ts := range tss {
+			res = append(res, exemplar.Exemplar{
+				Labels: labels.FromStrings("traceID", "trace"),
+				Value:  float64(ts),
+				Ts:     ts,
+			})
+		}
+		return res
+	}
+	for _, ts := range []int64{10, 20, 30} {
+		require.NoError(t, es.AppendExemplar(b, exemplars(ts)[0]))
+		require.NoError(t, es.AppendExemplar(a, exemplars(ts)[0]))
+	}
+
+	// The buffer holds the four most recent exemplars.
+	all := []*labels.Matcher{mustNewEqualMatcher(t, "__name__", "test_metric")}
+	res, err := es.Select(0, 100, all)
+	require.NoError(t, err)
+	require.Equal(t, []exemplar.QueryResult{
+		{SeriesLabels: a, Exemplars: exemplars(20, 30)},
+		{SeriesLabels: b, Exemplars: exemplars(20, 30)},
+	}, res)
+
+	res, err = es.Select(25, 100, all)
+	require.NoError(t, err)
+	require.Equal(t, []exemplar.QueryResult{
+		{SeriesLabels: a, Exemplars: exemplars(30)},
+		{SeriesLabels: b, Exemplars: exemplars(30)},
+	}, res)
+
+	res, err = es.Select(0, 100, []*labels.Matcher{mustNewEqualMatcher(t, "service", "b")})
+	require.NoError(t, err)
+	require.Equal(t, []exemplar.QueryResult{{SeriesLabels: b, Exemplars: exemplars(20, 30)}}, res)
+
+	res, err = es.Select(0, 100, []*labels.Matcher{mustNewEqualMatcher(t, "service", "c")})
+	require.NoError(t, err)
+	require.Empty(t, res)
+
+	// Series without exemplars left in the buffer are dropped.
+	for _, ts := range []int64{40, 50, 60, 70} {
+		require.NoError(t, es.AppendExemplar(a, exemplars(ts)[0]))
+	}
+	res, err = es.Select(0, 100, all)
+	require.NoError(t, err)
+	require.Equal(t, []exemplar.QueryResult{{SeriesLabels: a, Exemplars: exemplars(40, 50, 60, 70)}}, res)
+}
+
+func mustNewEqualMatcher(t *testing.T, name, value string) *labels.Matcher {
+	m, err := labels.NewMatcher(labels.MatchEqual, name, value)
+	require.NoError(t, err)
+	return m
+}
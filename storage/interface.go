@@ -17,6 +17,7 @@ import (
 	"context"
 	"errors"
 
+	"github.com/prometheus/prometheus/pkg/exemplar"
 	"github.com/prometheus/prometheus/pkg/labels"
 )
 
@@ -26,6 +27,8 @@ var (
 	ErrOutOfOrderSample            = errors.New("out of order sample")
 	ErrDuplicateSampleForTimestamp = errors.New("duplicate sample for timestamp")
 	ErrOutOfBounds                 = errors.New("out of bounds")
+	ErrOutOfOrderExemplar          = errors.New("out of order exemplar")
+	ErrDuplicateExemplar           = errors.New("duplicate exemplar")
 )
 
 // Storage ingests and manages samples, along with various indexes. All methods
@@ -43,6 +46,12 @@ type Storage interface {
 	Close() error
 }
 
+// Appendable allows creating appenders.
+type Appendable interface {
+	// Appender returns a new appender for the storage.
+	Appender() (Appender, error)
+}
+
 // A Queryable handles queries against a storage.
 type Queryable interface {
 	// Querier returns a new Querier on the storage.
@@ -94,6 +103,27 @@ type Appender interface {
 	Rollback() error
 }
 
+// ExemplarStorage ingests and queries exemplars. All methods are
+// goroutine-safe.
+type ExemplarStorage interface {
+	ExemplarAppender
+	ExemplarQuerier
+}
+
+// ExemplarAppender adds exemplars of series.
+type ExemplarAppender interface {
+	// AppendExemplar adds an exemplar of the series with the given labels.
+	// Exemplars of a series must be added in timestamp order.
+	AppendExemplar(l labels.Labels, e exemplar.Exemplar) error
+}
+
+// ExemplarQuerier provides reading access to exemplars.
+type ExemplarQuerier interface {
+	// Select returns the exemplars in the time range of the series matching
+	// any of the given matcher sets.
+	Select(start, end int64, matchers ...[]*labels.Matcher) ([]exemplar.QueryResult, error)
+}
+
 // SeriesSet contains a set of series.
 type SeriesSet interface {
 	Next() bool
@@ -0,0 +1,154 @@
+// Copyright 2019 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"net/http"
+
+	"github.com/gogo/protobuf/proto"
+)
+
+// DefaultChunkedReadLimit is the default maximum size of a single frame
+// a ChunkedReader accepts.
+const DefaultChunkedReadLimit = 5e+7
+
+// The table gets initialized with sync.Once but may still cause a race
+// with any other use of the crc32 package anywhere. Thus we initialize it
+// before.
+var castagnoliTable *crc32.Table
+
+func init() {
+	castagnoliTable = crc32.MakeTable(crc32.Castagnoli)
+}
+
+// ChunkedWriter is an io.Writer wrapper that allows streaming by prefixing
+// each write with the uvarint encoded length and the CRC32 Castagnoli
+// checksum of the written bytes.
+type ChunkedWriter struct {
+	writer  io.Writer
+	flusher http.Flusher
+
+	crc32 hash.Hash32
+}
+
+// NewChunkedWriter constructs a ChunkedWriter.
+func NewChunkedWriter(w io.Writer, f http.Flusher) *ChunkedWriter {
+	return &ChunkedWriter{writer: w, flusher: f, crc32: crc32.New(castagnoliTable)}
+}
+
+// Write writes given bytes to the stream as a single frame and flushes it
+// to the client. Each frame consists of the uvarint encoded length of the
+// data, the big endian CRC32 Castagnoli checksum of the data and the data
+// itself.
+func (w *ChunkedWriter) Write(b []byte) (int, error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+
+	var buf [binary.MaxVarintLen64]byte
+	v := binary.PutUvarint(buf[:], uint64(len(b)))
+	if _, err := w.writer.Write(buf[:v]); err != nil {
+		return 0, err
+	}
+
+	w.crc32.Reset()
+	if _, err := w.crc32.Write(b); err != nil {
+		return 0, err
+	}
+
+	if err := binary.Write(w.writer, binary.BigEndian, w.crc32.Sum32()); err != nil {
+		return 0, err
+	}
+
+	n, err := w.writer.Write(b)
+	if err != nil {
+		return n, err
+	}
+
+	w.flusher.Flush()
+	return n, nil
+}
+
+// ChunkedReader is a buffered reader that expects the uvarint delimiter and
+// checksum written by ChunkedWriter in front of each frame.
+type ChunkedReader struct {
+	b         *bufio.Reader
+	data      []byte
+	sizeLimit uint64
+
+	crc32 hash.Hash32
+}
+
+// NewChunkedReader constructs a ChunkedReader. Frames bigger than sizeLimit
+// are rejected. The given data slice is reused to hold the frames and may
+// be nil.
+func NewChunkedReader(r io.Reader, sizeLimit uint64, data []byte) *ChunkedReader {
+	return &ChunkedReader{b: bufio.NewReader(r), sizeLimit: sizeLimit, data: data, crc32: crc32.New(castagnoliTable)}
+}
+
+// Next returns the next frame from the stream. It returns io.EOF once the
+// stream ended cleanly. The returned bytes are only valid until the next
+// call to Next.
+func (r *ChunkedReader) Next() ([]byte, error) {
+	size, err := binary.ReadUvarint(r.b)
+	if err != nil {
+		return nil, err
+	}
+
+	if size > r.sizeLimit {
+		return nil, fmt.Errorf("chunkedReader: message size exceeded the limit %v bytes; got: %v bytes", r.sizeLimit, size)
+	}
+
+	if cap(r.data) < int(size) {
+		r.data = make([]byte, size)
+	} else {
+		r.data = r.data[:size]
+	}
+
+	var crc32 uint32
+	if err := binary.Read(r.b, binary.BigEndian, &crc32); err != nil {
+		if err == io.EOF {
+			// Only the size of the frame made it through.
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	r.crc32.Reset()
+	if _, err := io.ReadFull(io.TeeReader(r.b, r.crc32), r.data); err != nil {
+		return nil, err
+	}
+
+	if r.crc32.Sum32() != crc32 {
+		return nil, fmt.Errorf("chunkedReader: CRC32 mismatch; expected %v, got %v", crc32, r.crc32.Sum32())
+	}
+
+	return r.data, nil
+}
+
+// NextProto consumes the next frame from the stream and unmarshals it into
+// the given proto message.
+func (r *ChunkedReader) NextProto(pb proto.Message) error {
+	rec, err := r.Next()
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(rec, pb)
+}
@@ -0,0 +1,93 @@
+// Copyright 2019 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type mockedFlusher struct {
+	flushed int
+}
+
+func (f *mockedFlusher) Flush() {
+	f.flushed++
+}
+
+func TestChunkedReaderCanReadFromChunkedWriter(t *testing.T) {
+	b := &bytes.Buffer{}
+	f := &mockedFlusher{}
+	w := NewChunkedWriter(b, f)
+	r := NewChunkedReader(b, 20, nil)
+
+	msgs := [][]byte{
+		[]byte("test1"),
+		[]byte("test2"),
+		[]byte("test3"),
+		[]byte("test4"),
+		{}, // This is ignored by writer.
+		[]byte("test5-after-empty"),
+	}
+
+	for _, msg := range msgs {
+		n, err := w.Write(msg)
+		require.NoError(t, err)
+		require.Equal(t, len(msg), n)
+	}
+
+	i := 0
+	for ; i < 4; i++ {
+		msg, err := r.Next()
+		require.NoError(t, err)
+		require.True(t, i < len(msgs), "more messages then expected")
+		require.Equal(t, msgs[i], msg)
+	}
+
+	// Empty byte slice is skipped.
+	i++
+
+	msg, err := r.Next()
+	require.NoError(t, err)
+	require.True(t, i < len(msgs), "more messages then expected")
+	require.Equal(t, msgs[i], msg)
+
+	_, err = r.Next()
+	require.Equal(t, io.EOF, err, "expected io.EOF")
+
+	require.Equal(t, 5, f.flushed)
+}
+
+func TestChunkedReaderErrors(t *testing.T) {
+	b := &bytes.Buffer{}
+	w := NewChunkedWriter(b, &mockedFlusher{})
+	_, err := w.Write([]byte("test-message-exceeding-the-limit"))
+	require.NoError(t, err)
+
+	_, err = NewChunkedReader(bytes.NewReader(b.Bytes()), 20, nil).Next()
+	require.Error(t, err, "expected size limit error")
+
+	// Flip a bit of the payload.
+	corrupted := append([]byte{}, b.Bytes()...)
+	corrupted[len(corrupted)-1] ^= 1
+	_, err = NewChunkedReader(bytes.NewReader(corrupted), DefaultChunkedReadLimit, nil).Next()
+	require.Error(t, err, "expected CRC32 mismatch error")
+
+	// Cut the frame right after its size.
+	_, err = NewChunkedReader(bytes.NewReader(b.Bytes()[:1]), DefaultChunkedReadLimit, nil).Next()
+	require.Equal(t, io.ErrUnexpectedEOF, err)
+}
@@ -30,6 +30,7 @@ import (
 
 	config_util "github.com/prometheus/common/config"
 	"github.com/prometheus/prometheus/prompb"
+	"github.com/prometheus/prometheus/storage"
 )
 
 const maxErrMsgLen = 256
@@ -120,14 +121,20 @@ func (c Client) Name() string {
 	return fmt.Sprintf("%d:%s", c.index, c.url)
 }
 
-// Read reads from a remote endpoint.
-func (c *Client) Read(ctx context.Context, query *prompb.Query) (*prompb.QueryResult, error) {
+// Read reads from a remote endpoint. If the endpoint streams the response
+// as chunks, the returned series set reads the series from the response body
+// while it is being iterated, which is closed once the set is exhausted.
+func (c *Client) Read(ctx context.Context, query *prompb.Query) (storage.SeriesSet, error) {
 	req := &prompb.ReadRequest{
 		// TODO: Support batching multiple queries into one read request,
 		// as the protobuf interface allows for it.
 		Queries: []*prompb.Query{
 			query,
 		},
+		AcceptedResponseTypes: []prompb.ReadRequest_ResponseType{
+			prompb.ReadRequest_STREAMED_XOR_CHUNKS,
+			prompb.ReadRequest_SAMPLES,
+		},
 	}
 	data, err := proto.Marshal(req)
 	if err != nil {
@@ -146,17 +153,28 @@ func (c *Client) Read(ctx context.Context, query *prompb.Query) (*prompb.QueryRe
 	httpReq.Header.Set("X-Prometheus-Remote-Read-Version", "0.1.0")
 
 	ctx, cancel := context.WithTimeout(ctx, c.timeout)
-	defer cancel()
 
 	httpResp, err := c.client.Do(httpReq.WithContext(ctx))
 	if err != nil {
+		cancel()
 		return nil, fmt.Errorf("error sending request: %v", err)
 	}
-	defer httpResp.Body.Close()
 	if httpResp.StatusCode/100 != 2 {
+		httpResp.Body.Close()
+		cancel()
 		return nil, fmt.Errorf("server returned HTTP status %s", httpResp.Status)
 	}
 
+	if httpResp.Header.Get("Content-Type") == StreamedChunksContentType {
+		r := NewChunkedReader(httpResp.Body, DefaultChunkedReadLimit, nil)
+		return newChunkedSeriesSet(r, 0, func() {
+			httpResp.Body.Close()
+			cancel()
+		}), nil
+	}
+	defer cancel()
+	defer httpResp.Body.Close()
+
 	compressed, err = ioutil.ReadAll(httpResp.Body)
 	if err != nil {
 		return nil, fmt.Errorf("error reading response: %v", err)
@@ -177,5 +195,5 @@ func (c *Client) Read(ctx context.Context, query *prompb.Query) (*prompb.QueryRe
 		return nil, fmt.Errorf("responses: want %d, got %d", len(req.Queries), len(resp.Results))
 	}
 
-	return resp.Results[0], nil
+	return FromQueryResult(resp.Results[0]), nil
 }
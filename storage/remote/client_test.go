@@ -20,13 +20,18 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"reflect"
+	"sort"
 	"strings"
 	"testing"
 	"time"
 
 	config_util "github.com/prometheus/common/config"
 	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+
+	"github.com/prometheus/prometheus/pkg/labels"
 	"github.com/prometheus/prometheus/prompb"
+	"github.com/prometheus/prometheus/storage"
 )
 
 var longErrMessage = strings.Repeat("error message", maxErrMsgLen)
@@ -82,3 +87,116 @@ func TestStoreHTTPErrorHandling(t *testing.T) {
 		server.Close()
 	}
 }
+
+func TestReadStreamedChunks(t *testing.T) {
+	var samples []prompb.Sample
+	for i := 0; i < 300; i++ {
+		samples = append(samples, prompb.Sample{Timestamp: int64(i), Value: float64(i)})
+	}
+	series := []storage.Series{
+		&concreteSeries{
+			labels:  labels.FromStrings("__name__", "test_metric", "foo", "bar"),
+			samples: samples[:1],
+		},
+		&concreteSeries{
+			labels:  labels.FromStrings("__name__", "test_metric", "foo", "baz"),
+			samples: samples,
+		},
+	}
+
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			req, err := DecodeReadRequest(r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			responseType, err := NegotiateResponseType(req.AcceptedResponseTypes)
+			if err != nil || responseType != prompb.ReadRequest_STREAMED_XOR_CHUNKS {
+				http.Error(w, "unexpected response type", http.StatusBadRequest)
+				return
+			}
+
+			w.Header().Set("Content-Type", StreamedChunksContentType)
+			// A single byte per frame forces every chunk into its own frame.
+			err = StreamChunkedReadResponses(
+				NewChunkedWriter(w, w.(http.Flusher)),
+				0,
+				&concreteSeriesSet{series: series},
+				[]prompb.Label{{Name: "region", Value: "eu"}},
+				1,
+			)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+		}),
+	)
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	c, err := NewClient(0, &ClientConfig{
+		URL:     &config_util.URL{URL: serverURL},
+		Timeout: model.Duration(time.Second),
+	})
+	require.NoError(t, err)
+
+	ss, err := c.Read(context.Background(), &prompb.Query{})
+	require.NoError(t, err)
+
+	var i int
+	for ; ss.Next(); i++ {
+		require.True(t, i < len(series), "more series than expected")
+		expLset := append(series[i].Labels(), labels.Label{Name: "region", Value: "eu"})
+		sort.Sort(expLset)
+		require.Equal(t, expLset, ss.At().Labels())
+
+		var got []prompb.Sample
+		it := ss.At().Iterator()
+		for it.Next() {
+			ts, v := it.At()
+			got = append(got, prompb.Sample{Timestamp: ts, Value: v})
+		}
+		require.NoError(t, it.Err())
+		require.Equal(t, series[i].(*concreteSeries).samples, got)
+	}
+	require.NoError(t, ss.Err())
+	require.Equal(t, len(series), i)
+}
+
+func TestReadSamplesFallback(t *testing.T) {
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			resp := &prompb.ReadResponse{
+				Results: []*prompb.QueryResult{{
+					Timeseries: []*prompb.TimeSeries{{
+						Labels:  []prompb.Label{{Name: "__name__", Value: "test_metric"}},
+						Samples: []prompb.Sample{{Timestamp: 1, Value: 2}},
+					}},
+				}},
+			}
+			if err := EncodeReadResponse(resp, w); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+		}),
+	)
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	c, err := NewClient(0, &ClientConfig{
+		URL:     &config_util.URL{URL: serverURL},
+		Timeout: model.Duration(time.Second),
+	})
+	require.NoError(t, err)
+
+	ss, err := c.Read(context.Background(), &prompb.Query{})
+	require.NoError(t, err)
+
+	require.True(t, ss.Next())
+	require.Equal(t, labels.FromStrings("__name__", "test_metric"), ss.At().Labels())
+	require.False(t, ss.Next())
+	require.NoError(t, ss.Err())
+}
@@ -17,12 +17,14 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math"
 	"net/http"
 	"sort"
 
 	"github.com/gogo/protobuf/proto"
 	"github.com/golang/snappy"
 	"github.com/prometheus/common/model"
+	"github.com/prometheus/tsdb/chunkenc"
 
 	"github.com/prometheus/prometheus/pkg/labels"
 	"github.com/prometheus/prometheus/prompb"
@@ -32,11 +34,26 @@ import (
 // decodeReadLimit is the maximum size of a read request body in bytes.
 const decodeReadLimit = 32 * 1024 * 1024
 
+// decodeWriteLimit is the maximum size of a write request body in bytes.
+const decodeWriteLimit = 32 * 1024 * 1024
+
+// StreamedChunksContentType is the Content-Type of remote read responses
+// of the STREAMED_XOR_CHUNKS response type.
+const StreamedChunksContentType = "application/x-streamed-protobuf; proto=prometheus.ChunkedReadResponse"
+
 type HTTPError struct {
 	msg    string
 	status int
 }
 
+// NewHTTPError returns an HTTPError for the given error and HTTP status code.
+func NewHTTPError(err error, status int) HTTPError {
+	return HTTPError{
+		msg:    err.Error(),
+		status: status,
+	}
+}
+
 func (e HTTPError) Error() string {
 	return e.msg
 }
@@ -65,6 +82,26 @@ func DecodeReadRequest(r *http.Request) (*prompb.ReadRequest, error) {
 	return &req, nil
 }
 
+// DecodeWriteRequest reads a prompb.WriteRequest from an io.Reader.
+func DecodeWriteRequest(r io.Reader) (*prompb.WriteRequest, error) {
+	compressed, err := ioutil.ReadAll(io.LimitReader(r, decodeWriteLimit))
+	if err != nil {
+		return nil, err
+	}
+
+	reqBuf, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		return nil, err
+	}
+
+	var req prompb.WriteRequest
+	if err := proto.Unmarshal(reqBuf, &req); err != nil {
+		return nil, err
+	}
+
+	return &req, nil
+}
+
 // EncodeReadResponse writes a remote.Response to a http.ResponseWriter.
 func EncodeReadResponse(resp *prompb.ReadResponse, w http.ResponseWriter) error {
 	data, err := proto.Marshal(resp)
@@ -80,6 +117,134 @@ func EncodeReadResponse(resp *prompb.ReadResponse, w http.ResponseWriter) error
 	return err
 }
 
+// NegotiateResponseType returns the first response type from the accepted
+// ones that the server supports. Requests not stating any accepted response
+// type get SAMPLES for backwards compatibility.
+func NegotiateResponseType(accepted []prompb.ReadRequest_ResponseType) (prompb.ReadRequest_ResponseType, error) {
+	if len(accepted) == 0 {
+		return prompb.ReadRequest_SAMPLES, nil
+	}
+
+	supported := []prompb.ReadRequest_ResponseType{
+		prompb.ReadRequest_SAMPLES,
+		prompb.ReadRequest_STREAMED_XOR_CHUNKS,
+	}
+
+	for _, resType := range accepted {
+		for _, s := range supported {
+			if resType == s {
+				return resType, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("server does not support any of the requested response types: %v; supported: %v", accepted, supported)
+}
+
+// StreamChunkedReadResponses iterates over the series set, encodes the
+// samples of each series into XOR chunks and writes them to the stream as
+// marshaled ChunkedReadResponse messages. A frame holds chunks of a single
+// series only, series exceeding maxBytesInFrame are split over multiple
+// consecutive frames.
+func StreamChunkedReadResponses(stream io.Writer, queryIndex int64, ss storage.SeriesSet, sortedExternalLabels []prompb.Label, maxBytesInFrame int) error {
+	var (
+		chks []prompb.Chunk
+		err  error
+	)
+
+	for ss.Next() {
+		series := ss.At()
+		iter := series.Iterator()
+		lbls := MergeLabels(labelsToLabelsProto(series.Labels()), sortedExternalLabels)
+
+		lblsSize := 0
+		for _, lbl := range lbls {
+			lblsSize += lbl.Size()
+		}
+
+		for {
+			chks, err = encodeChunks(iter, chks[:0], maxBytesInFrame-lblsSize)
+			if err != nil {
+				return err
+			}
+			if len(chks) == 0 {
+				break
+			}
+
+			b, err := proto.Marshal(&prompb.ChunkedReadResponse{
+				ChunkedSeries: []*prompb.ChunkedSeries{
+					{Labels: lbls, Chunks: chks},
+				},
+				QueryIndex: queryIndex,
+			})
+			if err != nil {
+				return fmt.Errorf("marshal chunked read response: %v", err)
+			}
+
+			if _, err := stream.Write(b); err != nil {
+				return fmt.Errorf("write to stream: %v", err)
+			}
+		}
+	}
+	return ss.Err()
+}
+
+// encodeChunks appends XOR chunks of at most maxSamplesInChunk samples read
+// from the iterator to chks. It stops once the chunks exceed frameBytesLeft,
+// the returned chunks are empty once the iterator is exhausted.
+func encodeChunks(iter storage.SeriesIterator, chks []prompb.Chunk, frameBytesLeft int) ([]prompb.Chunk, error) {
+	const maxSamplesInChunk = 120
+
+	var (
+		chkMint int64
+		chkMaxt int64
+		chk     *chunkenc.XORChunk
+		app     chunkenc.Appender
+		err     error
+	)
+
+	cut := func() {
+		chks = append(chks, prompb.Chunk{
+			MinTimeMs: chkMint,
+			MaxTimeMs: chkMaxt,
+			Type:      prompb.Chunk_Encoding(chk.Encoding()),
+			Data:      chk.Bytes(),
+		})
+		frameBytesLeft -= chks[len(chks)-1].Size()
+		chk = nil
+	}
+
+	for iter.Next() {
+		t, v := iter.At()
+		if chk == nil {
+			chk = chunkenc.NewXORChunk()
+			app, err = chk.Appender()
+			if err != nil {
+				return nil, err
+			}
+			chkMint = t
+		}
+		app.Append(t, v)
+		chkMaxt = t
+
+		if chk.NumSamples() < maxSamplesInChunk {
+			continue
+		}
+		cut()
+
+		// A frame may exceed the limit by at most one chunk.
+		if frameBytesLeft <= 0 {
+			break
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	if chk != nil {
+		cut()
+	}
+	return chks, nil
+}
+
 // ToWriteRequest converts an array of samples into a WriteRequest proto.
 func ToWriteRequest(samples []*model.Sample) *prompb.WriteRequest {
 	req := &prompb.WriteRequest{
@@ -298,7 +463,190 @@ func (c *concreteSeriesIterator) Err() error {
 	return nil
 }
 
-// validateLabelsAndMetricName validates the label names/values and metric names returned from remote read.
+// chunkedSeriesSet implements storage.SeriesSet on top of a stream of
+// ChunkedReadResponse frames. Only the series currently iterated over is
+// held in memory.
+type chunkedSeriesSet struct {
+	reader     *ChunkedReader
+	queryIndex int64
+	close      func()
+
+	// Series read from the stream but not yet returned.
+	pending []*prompb.ChunkedSeries
+	eof     bool
+
+	cur *chunkedSeries
+	err error
+}
+
+// newChunkedSeriesSet returns a storage.SeriesSet reading the series for the
+// query with the given index from the reader. The close function is called
+// once the stream is exhausted or an error occurred.
+func newChunkedSeriesSet(r *ChunkedReader, queryIndex int64, close func()) storage.SeriesSet {
+	return &chunkedSeriesSet{
+		reader:     r,
+		queryIndex: queryIndex,
+		close:      close,
+	}
+}
+
+func (s *chunkedSeriesSet) Next() bool {
+	if s.err != nil {
+		return false
+	}
+
+	var (
+		lbls []prompb.Label
+		chks []prompb.Chunk
+	)
+	// The chunks of a series may be split across consecutive frames.
+	for {
+		if len(s.pending) == 0 && !s.eof {
+			if err := s.readFrame(); err != nil {
+				s.err = err
+				s.finish()
+				return false
+			}
+		}
+		if len(s.pending) == 0 {
+			break
+		}
+		next := s.pending[0]
+		if lbls != nil && !labelProtosEqual(lbls, next.Labels) {
+			break
+		}
+		lbls = next.Labels
+		chks = append(chks, next.Chunks...)
+		s.pending = s.pending[1:]
+	}
+	if lbls == nil {
+		s.finish()
+		return false
+	}
+
+	lset := labelProtosToLabels(lbls)
+	if err := validateLabelsAndMetricName(lset); err != nil {
+		s.err = err
+		s.finish()
+		return false
+	}
+	s.cur = &chunkedSeries{labels: lset, chunks: chks}
+	return true
+}
+
+// readFrame reads the next frame from the stream into the pending series.
+func (s *chunkedSeriesSet) readFrame() error {
+	var res prompb.ChunkedReadResponse
+	if err := s.reader.NextProto(&res); err != nil {
+		if err == io.EOF {
+			s.eof = true
+			s.finish()
+			return nil
+		}
+		return fmt.Errorf("error reading chunked response: %v", err)
+	}
+	if res.QueryIndex != s.queryIndex {
+		return fmt.Errorf("chunked response for query index %d, expected %d", res.QueryIndex, s.queryIndex)
+	}
+	s.pending = res.ChunkedSeries
+	return nil
+}
+
+func (s *chunkedSeriesSet) finish() {
+	if s.close != nil {
+		s.close()
+		s.close = nil
+	}
+}
+
+func (s *chunkedSeriesSet) At() storage.Series {
+	return s.cur
+}
+
+func (s *chunkedSeriesSet) Err() error {
+	return s.err
+}
+
+// chunkedSeries implements storage.Series.
+type chunkedSeries struct {
+	labels labels.Labels
+	chunks []prompb.Chunk
+}
+
+func (c *chunkedSeries) Labels() labels.Labels {
+	return labels.New(c.labels...)
+}
+
+func (c *chunkedSeries) Iterator() storage.SeriesIterator {
+	return &chunkedSeriesIterator{chunks: c.chunks, maxt: math.MinInt64}
+}
+
+// chunkedSeriesIterator implements storage.SeriesIterator by iterating
+// over the samples of all chunks of a series in order.
+type chunkedSeriesIterator struct {
+	chunks []prompb.Chunk
+	idx    int
+	cur    chunkenc.Iterator
+	maxt   int64
+	err    error
+}
+
+// Seek implements storage.SeriesIterator.
+func (c *chunkedSeriesIterator) Seek(t int64) bool {
+	if c.cur != nil && c.maxt >= t {
+		return true
+	}
+	for c.Next() {
+		if c.maxt >= t {
+			return true
+		}
+	}
+	return false
+}
+
+// At implements storage.SeriesIterator.
+func (c *chunkedSeriesIterator) At() (t int64, v float64) {
+	return c.cur.At()
+}
+
+// Next implements storage.SeriesIterator.
+func (c *chunkedSeriesIterator) Next() bool {
+	for c.err == nil {
+		if c.cur != nil && c.cur.Next() {
+			// Chunks may overlap, skip samples we already returned.
+			if t, _ := c.cur.At(); t > c.maxt {
+				c.maxt = t
+				return true
+			}
+			continue
+		}
+		if c.cur != nil {
+			if c.err = c.cur.Err(); c.err != nil {
+				return false
+			}
+		}
+		if c.idx >= len(c.chunks) {
+			return false
+		}
+
+		chk, err := chunkenc.FromData(chunkenc.Encoding(c.chunks[c.idx].Type), c.chunks[c.idx].Data)
+		if err != nil {
+			c.err = err
+			return false
+		}
+		c.cur = chk.Iterator()
+		c.idx++
+	}
+	return false
+}
+
+// Err implements storage.SeriesIterator.
+func (c *chunkedSeriesIterator) Err() error {
+	return c.err
+}
+
+// validateLabelsAndMetricName validates the label names/values and metric names returned from remote read
+// or received through remote write.
 func validateLabelsAndMetricName(ls labels.Labels) error {
 	for _, l := range ls {
 		if l.Name == labels.MetricName && !model.IsValidMetricName(model.LabelValue(l.Value)) {
@@ -411,10 +759,41 @@ func labelsToLabelsProto(labels labels.Labels) []prompb.Label {
 	return result
 }
 
-func labelsToMetric(ls labels.Labels) model.Metric {
-	metric := make(model.Metric, len(ls))
-	for _, l := range ls {
-		metric[model.LabelName(l.Name)] = model.LabelValue(l.Value)
+// MergeLabels merges two sets of sorted proto labels, preferring those in
+// primary to those in secondary when there is an overlap.
+func MergeLabels(primary, secondary []prompb.Label) []prompb.Label {
+	result := make([]prompb.Label, 0, len(primary)+len(secondary))
+	i, j := 0, 0
+	for i < len(primary) && j < len(secondary) {
+		if primary[i].Name < secondary[j].Name {
+			result = append(result, primary[i])
+			i++
+		} else if primary[i].Name > secondary[j].Name {
+			result = append(result, secondary[j])
+			j++
+		} else {
+			result = append(result, primary[i])
+			i++
+			j++
+		}
 	}
-	return metric
+	for ; i < len(primary); i++ {
+		result = append(result, primary[i])
+	}
+	for ; j < len(secondary); j++ {
+		result = append(result, secondary[j])
+	}
+	return result
+}
+
+func labelProtosEqual(a, b []prompb.Label) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Name != b[i].Name || a[i].Value != b[i].Value {
+			return false
+		}
+	}
+	return true
 }
@@ -15,7 +15,10 @@ package remote
 
 import (
 	"context"
+	"crypto/md5"
+	"fmt"
 	"math"
+	"path/filepath"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -27,9 +30,11 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/config"
-	pkgrelabel "github.com/prometheus/prometheus/pkg/relabel"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/pkg/relabel"
 	"github.com/prometheus/prometheus/prompb"
-	"github.com/prometheus/prometheus/relabel"
+	"github.com/prometheus/tsdb"
+	tsdbLabels "github.com/prometheus/tsdb/labels"
 )
 
 // String constants for instrumentation.
@@ -75,7 +80,7 @@ var (
 			Namespace: namespace,
 			Subsystem: subsystem,
 			Name:      "dropped_samples_total",
-			Help:      "Total number of samples which were dropped due to the queue being full.",
+			Help:      "Total number of samples which were dropped after being read from the WAL before being sent via remote write, either via relabelling or because of an unknown series reference.",
 		},
 		[]string{queue},
 	)
@@ -138,17 +143,32 @@ type StorageClient interface {
 }
 
 // QueueManager manages a queue of samples to be sent to the Storage
-// indicated by the provided StorageClient.
+// indicated by the provided StorageClient. The samples are read from the
+// WAL by a WALWatcher, for which QueueManager implements the writeTo
+// interface.
 type QueueManager struct {
 	logger log.Logger
 
 	flushDeadline  time.Duration
 	cfg            config.QueueConfig
 	externalLabels model.LabelSet
-	relabelConfigs []*pkgrelabel.Config
+	relabelConfigs []*relabel.Config
 	client         StorageClient
 	queueName      string
 	logLimiter     *rate.Limiter
+	watcher        *WALWatcher
+	progressFile   string
+
+	seriesMtx            sync.Mutex
+	seriesLabels         map[uint64][]prompb.Label
+	seriesSegmentIndexes map[uint64]int
+	droppedSeries        map[uint64]struct{}
+
+	// The segments whose samples were not all stored by the remote storage
+	// yet, oldest first. The last one collects the samples of the segment
+	// currently read.
+	segmentsMtx sync.Mutex
+	segments    []*segmentProgress
 
 	shardsMtx   sync.RWMutex
 	shards      *shards
@@ -161,13 +181,42 @@ type QueueManager struct {
 	integralAccumulator                       float64
 }
 
-// NewQueueManager builds a new QueueManager.
-func NewQueueManager(logger log.Logger, cfg config.QueueConfig, externalLabels model.LabelSet, relabelConfigs []*pkgrelabel.Config, client StorageClient, flushDeadline time.Duration) *QueueManager {
+// segmentProgress tracks the samples of a WAL segment that were enqueued but
+// not handled by the remote storage yet.
+type segmentProgress struct {
+	// The segment number, set once all samples of the segment were enqueued.
+	segment int
+	marked  bool
+
+	// Number of enqueued samples which were neither stored nor rejected by
+	// the remote storage yet. Accessed atomically.
+	pending int64
+}
+
+func (p *segmentProgress) sent() bool {
+	return p.marked && atomic.LoadInt64(&p.pending) == 0
+}
+
+// queuedSample is a sample enqueued on a shard together with the segment it
+// was read from.
+type queuedSample struct {
+	ref      uint64
+	sample   prompb.TimeSeries
+	progress *segmentProgress
+}
+
+// NewQueueManager builds a new QueueManager reading from the WAL of the
+// local storage in dir. samplesIn is the rate of samples appended to the
+// local storage, shared between all queues.
+func NewQueueManager(logger log.Logger, dir string, samplesIn *ewmaRate, cfg config.QueueConfig, externalLabels model.LabelSet, relabelConfigs []*relabel.Config, client StorageClient, flushDeadline time.Duration) *QueueManager {
 	if logger == nil {
 		logger = log.NewNopLogger()
 	} else {
 		logger = log.With(logger, "queue", client.Name())
 	}
+	if cfg.MaxRetries != 0 {
+		level.Warn(logger).Log("msg", "The max_retries setting of remote write queues is deprecated and ignored, recoverable errors are retried until the samples are sent")
+	}
 	t := &QueueManager{
 		logger:         logger,
 		flushDeadline:  flushDeadline,
@@ -176,16 +225,24 @@ func NewQueueManager(logger log.Logger, cfg config.QueueConfig, externalLabels m
 		relabelConfigs: relabelConfigs,
 		client:         client,
 		queueName:      client.Name(),
+		progressFile:   filepath.Join(dir, "remote_write", fmt.Sprintf("%x", md5.Sum([]byte(client.Name())))),
+
+		seriesLabels:         make(map[uint64][]prompb.Label),
+		seriesSegmentIndexes: make(map[uint64]int),
+		droppedSeries:        make(map[uint64]struct{}),
+
+		segments: []*segmentProgress{{}},
 
 		logLimiter:  rate.NewLimiter(logRateLimit, logBurst),
 		numShards:   cfg.MinShards,
 		reshardChan: make(chan int),
 		quit:        make(chan struct{}),
 
-		samplesIn:          newEWMARate(ewmaWeight, shardUpdateDuration),
+		samplesIn:          samplesIn,
 		samplesOut:         newEWMARate(ewmaWeight, shardUpdateDuration),
 		samplesOutDuration: newEWMARate(ewmaWeight, shardUpdateDuration),
 	}
+	t.watcher = NewWALWatcher(logger, client.Name(), t, filepath.Join(dir, "wal"), t.progressFile)
 	t.shards = t.newShards(t.numShards)
 	numShards.WithLabelValues(t.queueName).Set(float64(t.numShards))
 	shardCapacity.WithLabelValues(t.queueName).Set(float64(t.cfg.Capacity))
@@ -199,58 +256,181 @@ func NewQueueManager(logger log.Logger, cfg config.QueueConfig, externalLabels m
 	return t
 }
 
-// Append queues a sample to be sent to the remote storage. It drops the
-// sample on the floor if the queue is full.
-// Always returns nil.
-func (t *QueueManager) Append(s *model.Sample) error {
-	snew := *s
-	snew.Metric = s.Metric.Clone()
-
-	for ln, lv := range t.externalLabels {
-		if _, ok := s.Metric[ln]; !ok {
-			snew.Metric[ln] = lv
+// Append queues samples to be sent to the remote storage. It blocks until
+// all samples are enqueued on their shards and only returns false if the
+// QueueManager is stopped.
+func (t *QueueManager) Append(s []tsdb.RefSample) bool {
+	t.segmentsMtx.Lock()
+	progress := t.segments[len(t.segments)-1]
+	t.segmentsMtx.Unlock()
+
+	tempSamples := make([]queuedSample, 0, len(s))
+	t.seriesMtx.Lock()
+	for _, sample := range s {
+		// If we have no labels for the series, due to relabelling or otherwise,
+		// don't send the sample.
+		lbls, ok := t.seriesLabels[sample.Ref]
+		if !ok {
+			droppedSamplesTotal.WithLabelValues(t.queueName).Inc()
+			if _, ok := t.droppedSeries[sample.Ref]; !ok && t.logLimiter.Allow() {
+				level.Info(t.logger).Log("msg", "Dropped sample for series that was not explicitly dropped via relabelling", "ref", sample.Ref)
+			}
+			continue
 		}
+		tempSamples = append(tempSamples, queuedSample{
+			ref: sample.Ref,
+			sample: prompb.TimeSeries{
+				Labels: lbls,
+				Samples: []prompb.Sample{
+					{
+						Value:     float64(sample.V),
+						Timestamp: sample.T,
+					},
+				},
+			},
+			progress: progress,
+		})
 	}
+	t.seriesMtx.Unlock()
+
+	for _, sample := range tempSamples {
+		// Count the sample as pending before a shard can send it. Samples
+		// that are never enqueued keep their segment from being recorded as
+		// sent.
+		atomic.AddInt64(&progress.pending, 1)
+
+		// This will only loop if the queues are being resharded.
+		backoff := t.cfg.MinBackoff
+		for {
+			select {
+			case <-t.quit:
+				return false
+			default:
+			}
 
-	snew.Metric = model.Metric(
-		relabel.Process(model.LabelSet(snew.Metric), t.relabelConfigs...))
+			t.shardsMtx.RLock()
+			s := t.shards
+			t.shardsMtx.RUnlock()
 
-	if snew.Metric == nil {
-		return nil
+			if s.enqueue(sample) {
+				queueLength.WithLabelValues(t.queueName).Inc()
+				break
+			}
+
+			time.Sleep(time.Duration(backoff))
+			backoff = backoff * 2
+			if backoff > t.cfg.MaxBackoff {
+				backoff = t.cfg.MaxBackoff
+			}
+		}
 	}
+	return true
+}
 
-	t.shardsMtx.RLock()
-	enqueued := t.shards.enqueue(&snew)
-	t.shardsMtx.RUnlock()
+// StoreSeries keeps track of which series we know about for lookups when
+// sending samples to remote.
+func (t *QueueManager) StoreSeries(series []tsdb.RefSeries, index int) {
+	temp := make(map[uint64][]prompb.Label, len(series))
+	dropped := make([]uint64, 0)
+	for _, s := range series {
+		ls := processExternalLabels(s.Labels, t.externalLabels)
+		rl := relabel.Process(ls, t.relabelConfigs...)
+		if len(rl) == 0 {
+			dropped = append(dropped, s.Ref)
+			continue
+		}
+		temp[s.Ref] = labelsToLabelsProto(rl)
+	}
 
-	if enqueued {
-		queueLength.WithLabelValues(t.queueName).Inc()
-	} else {
-		droppedSamplesTotal.WithLabelValues(t.queueName).Inc()
-		if t.logLimiter.Allow() {
-			level.Warn(t.logger).Log("msg", "Remote storage queue full, discarding sample. Multiple subsequent messages of this kind may be suppressed.")
+	t.seriesMtx.Lock()
+	defer t.seriesMtx.Unlock()
+
+	for ref, lbls := range temp {
+		t.seriesSegmentIndexes[ref] = index
+		t.seriesLabels[ref] = lbls
+	}
+	for _, ref := range dropped {
+		t.seriesSegmentIndexes[ref] = index
+		t.droppedSeries[ref] = struct{}{}
+	}
+}
+
+// SeriesReset is used when reading a checkpoint. WAL Watcher should have
+// stored series records with the checkpoints index number, so we can now
+// delete any ref ID's lower than that # from the two maps.
+func (t *QueueManager) SeriesReset(index int) {
+	t.seriesMtx.Lock()
+	defer t.seriesMtx.Unlock()
+
+	// Check for series that are in segments older than the checkpoint
+	// that were not also present in the checkpoint.
+	for k, v := range t.seriesSegmentIndexes {
+		if v < index {
+			delete(t.seriesLabels, k)
+			delete(t.seriesSegmentIndexes, k)
+			delete(t.droppedSeries, k)
 		}
 	}
-	return nil
 }
 
-// NeedsThrottling implements storage.SampleAppender. It will always return
-// false as a remote storage drops samples on the floor if backlogging instead
-// of asking for throttling.
-func (*QueueManager) NeedsThrottling() bool {
-	return false
+// MarkSegment records that all samples of the given segment were enqueued.
+// Once the remote storage stored all of them, the progress file is advanced
+// past the segment.
+func (t *QueueManager) MarkSegment(segment int) {
+	t.segmentsMtx.Lock()
+	defer t.segmentsMtx.Unlock()
+
+	p := t.segments[len(t.segments)-1]
+	p.segment = segment
+	p.marked = true
+	t.segments = append(t.segments, &segmentProgress{})
+}
+
+// updateProgress persists the first segment which still has samples that
+// were not handled by the remote storage. Samples it rejected as invalid
+// count as handled, as sending them again would fail as well.
+func (t *QueueManager) updateProgress() {
+	t.segmentsMtx.Lock()
+	segment := -1
+	for len(t.segments) > 1 && t.segments[0].sent() {
+		segment = t.segments[0].segment
+		t.segments = t.segments[1:]
+	}
+	t.segmentsMtx.Unlock()
+
+	if segment < 0 {
+		return
+	}
+	if err := writeProgress(t.progressFile, segment+1); err != nil {
+		level.Error(t.logger).Log("msg", "Failed to persist remote write progress", "err", err)
+	}
+}
+
+func processExternalLabels(ls tsdbLabels.Labels, externalLabels model.LabelSet) labels.Labels {
+	b := labels.NewBuilder(nil)
+	for _, l := range ls {
+		b.Set(l.Name, l.Value)
+	}
+	for ln, lv := range externalLabels {
+		if ls.Get(string(ln)) == "" {
+			b.Set(string(ln), string(lv))
+		}
+	}
+	return b.Labels()
 }
 
 // Start the queue manager sending samples to the remote storage.
 // Does not block.
 func (t *QueueManager) Start() {
+	t.shardsMtx.Lock()
+	t.shards.start()
+	t.shardsMtx.Unlock()
+
+	t.watcher.Start()
+
 	t.wg.Add(2)
 	go t.updateShardsLoop()
 	go t.reshardLoop()
-
-	t.shardsMtx.Lock()
-	defer t.shardsMtx.Unlock()
-	t.shards.start()
 }
 
 // Stop stops sending samples to the remote storage and waits for pending
@@ -258,11 +438,16 @@ func (t *QueueManager) Start() {
 func (t *QueueManager) Stop() {
 	level.Info(t.logger).Log("msg", "Stopping remote storage...")
 	close(t.quit)
+	t.watcher.Stop()
 	t.wg.Wait()
 
 	t.shardsMtx.Lock()
-	defer t.shardsMtx.Unlock()
 	t.shards.stop(t.flushDeadline)
+	t.shardsMtx.Unlock()
+
+	// Samples which could not be flushed before the deadline keep their
+	// segments from being recorded as sent.
+	t.updateProgress()
 
 	level.Info(t.logger).Log("msg", "Remote storage stopped.")
 }
@@ -276,6 +461,7 @@ func (t *QueueManager) updateShardsLoop() {
 		select {
 		case <-ticker.C:
 			t.calculateDesiredShards()
+			t.updateProgress()
 		case <-t.quit:
 			return
 		}
@@ -283,7 +469,6 @@ func (t *QueueManager) updateShardsLoop() {
 }
 
 func (t *QueueManager) calculateDesiredShards() {
-	t.samplesIn.tick()
 	t.samplesOut.tick()
 	t.samplesOutDuration.tick()
 
@@ -371,13 +556,23 @@ func (t *QueueManager) reshard(n int) {
 
 	// We start the newShards after we have stopped (the therefore completely
 	// flushed) the oldShards, to guarantee we only every deliver samples in
-	// order.
-	newShards.start()
+	// order. Samples the old shards could not send before the deadline are
+	// sent first by the new ones.
+	newShards.start(oldShards.unsent()...)
 }
 
 type shards struct {
-	qm      *QueueManager
-	queues  []chan *model.Sample
+	qm     *QueueManager
+	queues []chan queuedSample
+
+	// mtx protects the queues against being closed while samples are
+	// enqueued.
+	mtx          sync.RWMutex
+	softShutdown chan struct{}
+
+	// The samples each shard did not send before it was stopped.
+	unsentSamples [][]queuedSample
+
 	done    chan struct{}
 	running int32
 	ctx     context.Context
@@ -385,33 +580,46 @@ type shards struct {
 }
 
 func (t *QueueManager) newShards(numShards int) *shards {
-	queues := make([]chan *model.Sample, numShards)
+	queues := make([]chan queuedSample, numShards)
 	for i := 0; i < numShards; i++ {
-		queues[i] = make(chan *model.Sample, t.cfg.Capacity)
+		queues[i] = make(chan queuedSample, t.cfg.Capacity)
 	}
 	ctx, cancel := context.WithCancel(context.Background())
 	s := &shards{
-		qm:      t,
-		queues:  queues,
-		done:    make(chan struct{}),
-		running: int32(numShards),
-		ctx:     ctx,
-		cancel:  cancel,
+		qm:            t,
+		queues:        queues,
+		unsentSamples: make([][]queuedSample, numShards),
+		softShutdown:  make(chan struct{}),
+		done:          make(chan struct{}),
+		running:       int32(numShards),
+		ctx:           ctx,
+		cancel:        cancel,
 	}
 	return s
 }
 
-func (s *shards) start() {
+// start runs the shards. The given samples are sent before the enqueued ones.
+func (s *shards) start(samples ...queuedSample) {
+	pending := make([][]queuedSample, len(s.queues))
+	for _, sample := range samples {
+		shard := sample.ref % uint64(len(s.queues))
+		pending[shard] = append(pending[shard], sample)
+	}
 	for i := 0; i < len(s.queues); i++ {
-		go s.runShard(i)
+		go s.runShard(i, pending[i])
 	}
 }
 
 func (s *shards) stop(deadline time.Duration) {
-	// Attempt a clean shutdown.
+	// Attempt a clean shutdown. Closing softShutdown unblocks pending
+	// enqueue calls so that we can close the queues.
+	close(s.softShutdown)
+	s.mtx.Lock()
 	for _, shard := range s.queues {
 		close(shard)
 	}
+	s.mtx.Unlock()
+
 	select {
 	case <-s.done:
 		return
@@ -424,21 +632,38 @@ func (s *shards) stop(deadline time.Duration) {
 	<-s.done
 }
 
-func (s *shards) enqueue(sample *model.Sample) bool {
-	s.qm.samplesIn.incr(1)
+// unsent returns the samples the stopped shards did not send, in the order
+// they were enqueued in.
+func (s *shards) unsent() []queuedSample {
+	var res []queuedSample
+	for _, samples := range s.unsentSamples {
+		res = append(res, samples...)
+	}
+	return res
+}
+
+// enqueue blocks until the sample was added to its shard. It returns false
+// if the shards are being stopped.
+func (s *shards) enqueue(sample queuedSample) bool {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
 
-	fp := sample.Metric.FastFingerprint()
-	shard := uint64(fp) % uint64(len(s.queues))
+	select {
+	case <-s.softShutdown:
+		return false
+	default:
+	}
 
+	shard := sample.ref % uint64(len(s.queues))
 	select {
+	case <-s.softShutdown:
+		return false
 	case s.queues[shard] <- sample:
 		return true
-	default:
-		return false
 	}
 }
 
-func (s *shards) runShard(i int) {
+func (s *shards) runShard(i int, pendingSamples []queuedSample) {
 	defer func() {
 		if atomic.AddInt32(&s.running, -1) == 0 {
 			close(s.done)
@@ -447,11 +672,19 @@ func (s *shards) runShard(i int) {
 
 	queue := s.queues[i]
 
+	// Keep the samples that were not sent when the shard is stopped, so
+	// that they can be handed over to the next shards.
+	defer func() {
+		for sample := range queue {
+			queueLength.WithLabelValues(s.qm.queueName).Dec()
+			pendingSamples = append(pendingSamples, sample)
+		}
+		s.unsentSamples[i] = pendingSamples
+	}()
+
 	// Send batches of at most MaxSamplesPerSend samples to the remote storage.
 	// If we have fewer samples than that, flush them out after a deadline
 	// anyways.
-	pendingSamples := model.Samples{}
-
 	timer := time.NewTimer(time.Duration(s.qm.cfg.BatchSendDeadline))
 	stop := func() {
 		if !timer.Stop() {
@@ -463,6 +696,16 @@ func (s *shards) runShard(i int) {
 	}
 	defer stop()
 
+	// send sends the first n pending samples and removes them unless the
+	// shard was stopped before they were sent.
+	send := func(n int) bool {
+		if !s.sendSamples(pendingSamples[:n]) {
+			return false
+		}
+		pendingSamples = pendingSamples[n:]
+		return true
+	}
+
 	for {
 		select {
 		case <-s.ctx.Done():
@@ -472,7 +715,15 @@ func (s *shards) runShard(i int) {
 			if !ok {
 				if len(pendingSamples) > 0 {
 					level.Debug(s.qm.logger).Log("msg", "Flushing samples to remote storage...", "count", len(pendingSamples))
-					s.sendSamples(pendingSamples)
+					for len(pendingSamples) > 0 {
+						n := len(pendingSamples)
+						if n > s.qm.cfg.MaxSamplesPerSend {
+							n = s.qm.cfg.MaxSamplesPerSend
+						}
+						if !send(n) {
+							return
+						}
+					}
 					level.Debug(s.qm.logger).Log("msg", "Done flushing.")
 				}
 				return
@@ -481,59 +732,85 @@ func (s *shards) runShard(i int) {
 			queueLength.WithLabelValues(s.qm.queueName).Dec()
 			pendingSamples = append(pendingSamples, sample)
 
-			if len(pendingSamples) >= s.qm.cfg.MaxSamplesPerSend {
-				s.sendSamples(pendingSamples[:s.qm.cfg.MaxSamplesPerSend])
-				pendingSamples = pendingSamples[s.qm.cfg.MaxSamplesPerSend:]
-
+			for len(pendingSamples) >= s.qm.cfg.MaxSamplesPerSend {
+				if !send(s.qm.cfg.MaxSamplesPerSend) {
+					return
+				}
 				stop()
 				timer.Reset(time.Duration(s.qm.cfg.BatchSendDeadline))
 			}
 
 		case <-timer.C:
-			if len(pendingSamples) > 0 {
-				s.sendSamples(pendingSamples)
-				pendingSamples = pendingSamples[:0]
+			if len(pendingSamples) > 0 && !send(len(pendingSamples)) {
+				return
 			}
 			timer.Reset(time.Duration(s.qm.cfg.BatchSendDeadline))
 		}
 	}
 }
 
-func (s *shards) sendSamples(samples model.Samples) {
+// sendSamples sends the samples and records them as handled for the progress
+// of their segments. It returns false if the shard was stopped before the
+// samples were handled.
+func (s *shards) sendSamples(samples []queuedSample) bool {
 	begin := time.Now()
-	s.sendSamplesWithBackoff(samples)
+	ok := s.sendSamplesWithBackoff(samples)
 
 	// These counters are used to calculate the dynamic sharding, and as such
 	// should be maintained irrespective of success or failure.
 	s.qm.samplesOut.incr(int64(len(samples)))
 	s.qm.samplesOutDuration.incr(int64(time.Since(begin)))
+
+	if !ok {
+		return false
+	}
+	for _, sample := range samples {
+		atomic.AddInt64(&sample.progress.pending, -1)
+	}
+	return true
 }
 
-// sendSamples to the remote storage with backoff for recoverable errors.
-func (s *shards) sendSamplesWithBackoff(samples model.Samples) {
+// sendSamplesWithBackoff sends the samples to the remote storage. As the
+// samples are still in the WAL, recoverable errors are retried until the
+// shard is stopped, samples rejected with a non-recoverable error are
+// dropped. It returns false if the shard was stopped before the samples were
+// stored or dropped.
+func (s *shards) sendSamplesWithBackoff(samples []queuedSample) bool {
 	backoff := s.qm.cfg.MinBackoff
-	req := ToWriteRequest(samples)
+	req := &prompb.WriteRequest{
+		Timeseries: make([]prompb.TimeSeries, 0, len(samples)),
+	}
+	for _, sample := range samples {
+		req.Timeseries = append(req.Timeseries, sample.sample)
+	}
 
-	for retries := s.qm.cfg.MaxRetries; retries > 0; retries-- {
+	for {
 		begin := time.Now()
 		err := s.qm.client.Store(s.ctx, req)
 
 		sentBatchDuration.WithLabelValues(s.qm.queueName).Observe(time.Since(begin).Seconds())
 		if err == nil {
 			succeededSamplesTotal.WithLabelValues(s.qm.queueName).Add(float64(len(samples)))
-			return
+			return true
 		}
 
+		if s.ctx.Err() != nil {
+			return false
+		}
 		level.Warn(s.qm.logger).Log("msg", "Error sending samples to remote storage", "count", len(samples), "err", err)
 		if _, ok := err.(recoverableError); !ok {
-			break
+			failedSamplesTotal.WithLabelValues(s.qm.queueName).Add(float64(len(samples)))
+			return true
+		}
+
+		select {
+		case <-s.ctx.Done():
+			return false
+		case <-time.After(time.Duration(backoff)):
 		}
-		time.Sleep(time.Duration(backoff))
 		backoff = backoff * 2
 		if backoff > s.qm.cfg.MaxBackoff {
 			backoff = s.qm.cfg.MaxBackoff
 		}
 	}
-
-	failedSamplesTotal.WithLabelValues(s.qm.queueName).Add(float64(len(samples)))
 }
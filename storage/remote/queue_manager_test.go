@@ -16,15 +16,21 @@ package remote
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
+	"os"
 	"reflect"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/pkg/errors"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/config"
 	"github.com/prometheus/prometheus/prompb"
+	"github.com/prometheus/prometheus/util/testutil"
+	"github.com/prometheus/tsdb"
+	tsdbLabels "github.com/prometheus/tsdb/labels"
 )
 
 const defaultFlushDeadline = 1 * time.Minute
@@ -43,7 +49,7 @@ func NewTestStorageClient() *TestStorageClient {
 	}
 }
 
-func (c *TestStorageClient) expectSamples(ss model.Samples) {
+func (c *TestStorageClient) expectSamples(ss []tsdb.RefSample, series []tsdb.RefSeries) {
 	c.mtx.Lock()
 	defer c.mtx.Unlock()
 
@@ -51,10 +57,10 @@ func (c *TestStorageClient) expectSamples(ss model.Samples) {
 	c.receivedSamples = map[string][]prompb.Sample{}
 
 	for _, s := range ss {
-		ts := labelProtosToLabels(MetricToLabelProtos(s.Metric)).String()
+		ts := labelProtosToLabels(labelsToLabelsProto(processExternalLabels(series[s.Ref].Labels, nil))).String()
 		c.expectedSamples[ts] = append(c.expectedSamples[ts], prompb.Sample{
-			Timestamp: int64(s.Timestamp),
-			Value:     float64(s.Value),
+			Timestamp: s.T,
+			Value:     s.V,
 		})
 	}
 	c.wg.Add(len(ss))
@@ -95,105 +101,279 @@ func TestSampleDelivery(t *testing.T) {
 	// Let's create an even number of send batches so we don't run into the
 	// batch timeout case.
 	n := config.DefaultQueueConfig.Capacity * 2
-
-	samples := make(model.Samples, 0, n)
-	for i := 0; i < n; i++ {
-		name := model.LabelValue(fmt.Sprintf("test_metric_%d", i))
-		samples = append(samples, &model.Sample{
-			Metric: model.Metric{
-				model.MetricNameLabel: name,
-			},
-			Value: model.SampleValue(i),
-		})
-	}
+	samples, series := createTimeseries(n)
 
 	c := NewTestStorageClient()
-	c.expectSamples(samples[:len(samples)/2])
+	c.expectSamples(samples[:len(samples)/2], series)
 
 	cfg := config.DefaultQueueConfig
+	cfg.BatchSendDeadline = model.Duration(100 * time.Millisecond)
 	cfg.MaxShards = 1
-	m := NewQueueManager(nil, cfg, nil, nil, c, defaultFlushDeadline)
+
+	dir, err := ioutil.TempDir("", "TestSampleDelivery")
+	testutil.Ok(t, err)
+	defer os.RemoveAll(dir)
+
+	m := NewQueueManager(nil, dir, newEWMARate(ewmaWeight, shardUpdateDuration), cfg, nil, nil, c, defaultFlushDeadline)
+	m.StoreSeries(series, 0)
 
 	// These should be received by the client.
-	for _, s := range samples[:len(samples)/2] {
-		m.Append(s)
-	}
-	// These will be dropped because the queue is full.
-	for _, s := range samples[len(samples)/2:] {
-		m.Append(s)
-	}
 	m.Start()
+	m.Append(samples[:len(samples)/2])
 	defer m.Stop()
 
 	c.waitForExpectedSamples(t)
+
+	// The queue doesn't drop samples when full but blocks until there is
+	// space again.
+	c.expectSamples(samples[len(samples)/2:], series)
+	m.Append(samples[len(samples)/2:])
+	c.waitForExpectedSamples(t)
 }
 
 func TestSampleDeliveryTimeout(t *testing.T) {
 	// Let's send one less sample than batch size, and wait the timeout duration
-	n := config.DefaultQueueConfig.Capacity - 1
-
-	samples := make(model.Samples, 0, n)
-	for i := 0; i < n; i++ {
-		name := model.LabelValue(fmt.Sprintf("test_metric_%d", i))
-		samples = append(samples, &model.Sample{
-			Metric: model.Metric{
-				model.MetricNameLabel: name,
-			},
-			Value: model.SampleValue(i),
-		})
-	}
+	n := config.DefaultQueueConfig.MaxSamplesPerSend - 1
+	samples, series := createTimeseries(n)
 
 	c := NewTestStorageClient()
 
 	cfg := config.DefaultQueueConfig
 	cfg.MaxShards = 1
 	cfg.BatchSendDeadline = model.Duration(100 * time.Millisecond)
-	m := NewQueueManager(nil, cfg, nil, nil, c, defaultFlushDeadline)
+
+	dir, err := ioutil.TempDir("", "TestSampleDeliveryTimeout")
+	testutil.Ok(t, err)
+	defer os.RemoveAll(dir)
+
+	m := NewQueueManager(nil, dir, newEWMARate(ewmaWeight, shardUpdateDuration), cfg, nil, nil, c, defaultFlushDeadline)
+	m.StoreSeries(series, 0)
 	m.Start()
 	defer m.Stop()
 
 	// Send the samples twice, waiting for the samples in the meantime.
-	c.expectSamples(samples)
-	for _, s := range samples {
-		m.Append(s)
-	}
+	c.expectSamples(samples, series)
+	m.Append(samples)
 	c.waitForExpectedSamples(t)
 
-	c.expectSamples(samples)
-	for _, s := range samples {
-		m.Append(s)
-	}
+	c.expectSamples(samples, series)
+	m.Append(samples)
 	c.waitForExpectedSamples(t)
 }
 
 func TestSampleDeliveryOrder(t *testing.T) {
 	ts := 10
 	n := config.DefaultQueueConfig.MaxSamplesPerSend * ts
-
-	samples := make(model.Samples, 0, n)
+	samples := make([]tsdb.RefSample, 0, n)
+	series := make([]tsdb.RefSeries, 0, n)
 	for i := 0; i < n; i++ {
-		name := model.LabelValue(fmt.Sprintf("test_metric_%d", i%ts))
-		samples = append(samples, &model.Sample{
-			Metric: model.Metric{
-				model.MetricNameLabel: name,
-			},
-			Value:     model.SampleValue(i),
-			Timestamp: model.Time(i),
+		name := fmt.Sprintf("test_metric_%d", i%ts)
+		samples = append(samples, tsdb.RefSample{
+			Ref: uint64(i),
+			T:   int64(i),
+			V:   float64(i),
+		})
+		series = append(series, tsdb.RefSeries{
+			Ref:    uint64(i),
+			Labels: tsdbLabels.Labels{tsdbLabels.Label{Name: "__name__", Value: name}},
 		})
 	}
 
 	c := NewTestStorageClient()
-	c.expectSamples(samples)
-	m := NewQueueManager(nil, config.DefaultQueueConfig, nil, nil, c, defaultFlushDeadline)
+	c.expectSamples(samples, series)
+
+	dir, err := ioutil.TempDir("", "TestSampleDeliveryOrder")
+	testutil.Ok(t, err)
+	defer os.RemoveAll(dir)
 
+	m := NewQueueManager(nil, dir, newEWMARate(ewmaWeight, shardUpdateDuration), config.DefaultQueueConfig, nil, nil, c, defaultFlushDeadline)
+	m.StoreSeries(series, 0)
+
+	m.Start()
+	defer m.Stop()
 	// These should be received by the client.
-	for _, s := range samples {
-		m.Append(s)
+	m.Append(samples)
+	c.waitForExpectedSamples(t)
+}
+
+func TestSeriesReset(t *testing.T) {
+	c := NewTestBlockedStorageClient()
+	deadline := 5 * time.Second
+	numSegments := 4
+	numSeries := 25
+
+	dir, err := ioutil.TempDir("", "TestSeriesReset")
+	testutil.Ok(t, err)
+	defer os.RemoveAll(dir)
+
+	m := NewQueueManager(nil, dir, newEWMARate(ewmaWeight, shardUpdateDuration), config.DefaultQueueConfig, nil, nil, c, deadline)
+	for i := 0; i < numSegments; i++ {
+		series := []tsdb.RefSeries{}
+		for j := 0; j < numSeries; j++ {
+			series = append(series, tsdb.RefSeries{Ref: uint64((i * 100) + j), Labels: tsdbLabels.Labels{{Name: "a", Value: "a"}}})
+		}
+		m.StoreSeries(series, i)
+	}
+	testutil.Equals(t, numSegments*numSeries, len(m.seriesLabels))
+	m.SeriesReset(2)
+	testutil.Equals(t, numSegments*numSeries/2, len(m.seriesLabels))
+}
+
+func TestProgress(t *testing.T) {
+	samples, series := createTimeseries(config.DefaultQueueConfig.MaxSamplesPerSend)
+
+	c := NewTestStorageClient()
+	c.expectSamples(samples, series)
+
+	dir, err := ioutil.TempDir("", "TestProgress")
+	testutil.Ok(t, err)
+	defer os.RemoveAll(dir)
+
+	m := NewQueueManager(nil, dir, newEWMARate(ewmaWeight, shardUpdateDuration), config.DefaultQueueConfig, nil, nil, c, defaultFlushDeadline)
+	m.StoreSeries(series, 0)
+	m.Start()
+	defer m.Stop()
+
+	m.Append(samples)
+	m.MarkSegment(3)
+	c.waitForExpectedSamples(t)
+
+	// The counters are updated after Store returned, wait for them to catch up.
+	var (
+		segment int
+		ok      bool
+	)
+	for i := 0; i < 100 && !ok; i++ {
+		m.updateProgress()
+		segment, ok, err = readProgress(m.progressFile)
+		testutil.Ok(t, err)
+		time.Sleep(10 * time.Millisecond)
+	}
+	testutil.Assert(t, ok, "progress file was not written")
+	testutil.Equals(t, 4, segment)
+}
+
+// TestFailingStorageClient is a queue_manager StorageClient which fails the
+// first failures calls to Store() with err.
+type TestFailingStorageClient struct {
+	*TestStorageClient
+	failures int32
+	err      error
+}
+
+func (c *TestFailingStorageClient) Store(ctx context.Context, req *prompb.WriteRequest) error {
+	if atomic.AddInt32(&c.failures, -1) >= 0 {
+		return c.err
+	}
+	return c.TestStorageClient.Store(ctx, req)
+}
+
+func TestProgressRecoverableError(t *testing.T) {
+	samples, series := createTimeseries(config.DefaultQueueConfig.MaxSamplesPerSend)
+
+	c := &TestFailingStorageClient{
+		TestStorageClient: NewTestStorageClient(),
+		// More failures than the retries that used to be allowed.
+		failures: 5,
+		err:      recoverableError{errors.New("unavailable")},
 	}
+	c.expectSamples(samples, series)
+
+	dir, err := ioutil.TempDir("", "TestProgressRecoverableError")
+	testutil.Ok(t, err)
+	defer os.RemoveAll(dir)
+
+	cfg := config.DefaultQueueConfig
+	cfg.MinBackoff = model.Duration(time.Millisecond)
+	cfg.MaxBackoff = model.Duration(time.Millisecond)
+	m := NewQueueManager(nil, dir, newEWMARate(ewmaWeight, shardUpdateDuration), cfg, nil, nil, c, defaultFlushDeadline)
+	m.StoreSeries(series, 0)
 	m.Start()
 	defer m.Stop()
 
+	m.Append(samples)
+	m.MarkSegment(3)
 	c.waitForExpectedSamples(t)
+
+	var (
+		segment int
+		ok      bool
+	)
+	for i := 0; i < 100 && !ok; i++ {
+		m.updateProgress()
+		segment, ok, err = readProgress(m.progressFile)
+		testutil.Ok(t, err)
+		time.Sleep(10 * time.Millisecond)
+	}
+	testutil.Assert(t, ok, "progress file was not written")
+	testutil.Equals(t, 4, segment)
+}
+
+func TestProgressNonRecoverableError(t *testing.T) {
+	samples, series := createTimeseries(2 * config.DefaultQueueConfig.MaxSamplesPerSend)
+
+	c := &TestFailingStorageClient{
+		TestStorageClient: NewTestStorageClient(),
+		failures:          1,
+		err:               errors.New("bad request"),
+	}
+	c.expectSamples(samples[config.DefaultQueueConfig.MaxSamplesPerSend:], series)
+
+	dir, err := ioutil.TempDir("", "TestProgressNonRecoverableError")
+	testutil.Ok(t, err)
+	defer os.RemoveAll(dir)
+
+	m := NewQueueManager(nil, dir, newEWMARate(ewmaWeight, shardUpdateDuration), config.DefaultQueueConfig, nil, nil, c, defaultFlushDeadline)
+	m.StoreSeries(series, 0)
+	m.Start()
+	defer m.Stop()
+
+	// The first batch of segment 3 is rejected, segment 4 is stored.
+	m.Append(samples[:config.DefaultQueueConfig.MaxSamplesPerSend])
+	m.MarkSegment(3)
+	m.Append(samples[config.DefaultQueueConfig.MaxSamplesPerSend:])
+	m.MarkSegment(4)
+	c.waitForExpectedSamples(t)
+
+	// The rejected samples count as handled, sending them again would fail
+	// as well.
+	var (
+		segment int
+		ok      bool
+	)
+	for i := 0; i < 100 && segment != 5; i++ {
+		m.updateProgress()
+		segment, ok, err = readProgress(m.progressFile)
+		testutil.Ok(t, err)
+		time.Sleep(10 * time.Millisecond)
+	}
+	testutil.Assert(t, ok, "progress file was not written")
+	testutil.Equals(t, 5, segment)
+}
+
+func TestProgressUnsentOnShutdown(t *testing.T) {
+	samples, series := createTimeseries(config.DefaultQueueConfig.MaxSamplesPerSend)
+
+	c := NewTestBlockedStorageClient()
+
+	dir, err := ioutil.TempDir("", "TestProgressUnsentOnShutdown")
+	testutil.Ok(t, err)
+	defer os.RemoveAll(dir)
+
+	m := NewQueueManager(nil, dir, newEWMARate(ewmaWeight, shardUpdateDuration), config.DefaultQueueConfig, nil, nil, c, 10*time.Millisecond)
+	m.StoreSeries(series, 0)
+	m.Start()
+
+	m.Append(samples)
+	m.MarkSegment(3)
+	for i := 0; i < 100 && c.NumCalls() == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	m.Stop()
+
+	_, ok, err := readProgress(m.progressFile)
+	testutil.Ok(t, err)
+	testutil.Assert(t, !ok, "progress file was advanced past samples not sent on shutdown")
 }
 
 // TestBlockingStorageClient is a queue_manager StorageClient which will block
@@ -217,6 +397,7 @@ func (c *TestBlockingStorageClient) Store(ctx context.Context, _ *prompb.WriteRe
 	select {
 	case <-c.block:
 	case <-ctx.Done():
+		return ctx.Err()
 	}
 	return nil
 }
@@ -249,23 +430,19 @@ func TestSpawnNotMoreThanMaxConcurrentSendsGoroutines(t *testing.T) {
 	// per-shard goroutines, and then another `MaxSamplesPerSend`
 	// should be left on the queue.
 	n := config.DefaultQueueConfig.MaxSamplesPerSend * 2
-
-	samples := make(model.Samples, 0, n)
-	for i := 0; i < n; i++ {
-		name := model.LabelValue(fmt.Sprintf("test_metric_%d", i))
-		samples = append(samples, &model.Sample{
-			Metric: model.Metric{
-				model.MetricNameLabel: name,
-			},
-			Value: model.SampleValue(i),
-		})
-	}
+	samples, series := createTimeseries(n)
 
 	c := NewTestBlockedStorageClient()
 	cfg := config.DefaultQueueConfig
 	cfg.MaxShards = 1
 	cfg.Capacity = n
-	m := NewQueueManager(nil, cfg, nil, nil, c, defaultFlushDeadline)
+
+	dir, err := ioutil.TempDir("", "TestSpawnNotMoreThanMaxConcurrentSendsGoroutines")
+	testutil.Ok(t, err)
+	defer os.RemoveAll(dir)
+
+	m := NewQueueManager(nil, dir, newEWMARate(ewmaWeight, shardUpdateDuration), cfg, nil, nil, c, defaultFlushDeadline)
+	m.StoreSeries(series, 0)
 
 	m.Start()
 
@@ -274,9 +451,7 @@ func TestSpawnNotMoreThanMaxConcurrentSendsGoroutines(t *testing.T) {
 		m.Stop()
 	}()
 
-	for _, s := range samples {
-		m.Append(s)
-	}
+	m.Append(samples)
 
 	// Wait until the runShard() loops drain the queue.  If things went right, it
 	// should then immediately block in sendSamples(), but, in case of error,
@@ -307,19 +482,18 @@ func TestSpawnNotMoreThanMaxConcurrentSendsGoroutines(t *testing.T) {
 }
 
 func TestShutdown(t *testing.T) {
-	deadline := 10 * time.Second
+	deadline := 5 * time.Second
 	c := NewTestBlockedStorageClient()
-	m := NewQueueManager(nil, config.DefaultQueueConfig, nil, nil, c, deadline)
-	for i := 0; i < config.DefaultQueueConfig.MaxSamplesPerSend; i++ {
-		m.Append(&model.Sample{
-			Metric: model.Metric{
-				model.MetricNameLabel: model.LabelValue(fmt.Sprintf("test_metric_%d", i)),
-			},
-			Value:     model.SampleValue(i),
-			Timestamp: model.Time(i),
-		})
-	}
+
+	dir, err := ioutil.TempDir("", "TestShutdown")
+	testutil.Ok(t, err)
+	defer os.RemoveAll(dir)
+
+	m := NewQueueManager(nil, dir, newEWMARate(ewmaWeight, shardUpdateDuration), config.DefaultQueueConfig, nil, nil, c, deadline)
+	samples, series := createTimeseries(config.DefaultQueueConfig.MaxSamplesPerSend)
+	m.StoreSeries(series, 0)
 	m.Start()
+	m.Append(samples)
 
 	start := time.Now()
 	m.Stop()
@@ -328,3 +502,21 @@ func TestShutdown(t *testing.T) {
 		t.Errorf("Took too long to shutdown: %s > %s", duration, deadline)
 	}
 }
+
+func createTimeseries(n int) ([]tsdb.RefSample, []tsdb.RefSeries) {
+	samples := make([]tsdb.RefSample, 0, n)
+	series := make([]tsdb.RefSeries, 0, n)
+	for i := 0; i < n; i++ {
+		name := fmt.Sprintf("test_metric_%d", i)
+		samples = append(samples, tsdb.RefSample{
+			Ref: uint64(i),
+			T:   int64(i),
+			V:   float64(i),
+		})
+		series = append(series, tsdb.RefSeries{
+			Ref:    uint64(i),
+			Labels: tsdbLabels.Labels{{Name: "__name__", Value: name}},
+		})
+	}
+	return samples, series
+}
@@ -41,8 +41,10 @@ func init() {
 func QueryableClient(c *Client) storage.Queryable {
 	remoteReadQueries.WithLabelValues(c.Name())
 	return storage.QueryableFunc(func(ctx context.Context, mint, maxt int64) (storage.Querier, error) {
+		ctx, cancel := context.WithCancel(ctx)
 		return &querier{
 			ctx:    ctx,
+			cancel: cancel,
 			mint:   mint,
 			maxt:   maxt,
 			client: c,
@@ -53,6 +55,7 @@ func QueryableClient(c *Client) storage.Queryable {
 // querier is an adapter to make a Client usable as a storage.Querier.
 type querier struct {
 	ctx        context.Context
+	cancel     context.CancelFunc
 	mint, maxt int64
 	client     *Client
 }
@@ -69,12 +72,12 @@ func (q *querier) Select(p *storage.SelectParams, matchers ...*labels.Matcher) (
 	remoteReadGauge.Inc()
 	defer remoteReadGauge.Dec()
 
-	res, err := q.client.Read(q.ctx, query)
+	ss, err := q.client.Read(q.ctx, query)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	return FromQueryResult(res), nil, nil
+	return ss, nil, nil
 }
 
 // LabelValues implements storage.Querier and is a noop.
@@ -89,8 +92,10 @@ func (q *querier) LabelNames() ([]string, error) {
 	return nil, nil
 }
 
-// Close implements storage.Querier and is a noop.
+// Close implements storage.Querier. It aborts reading any series sets
+// still streamed from the Client.
 func (q *querier) Close() error {
+	q.cancel()
 	return nil
 }
 
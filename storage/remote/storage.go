@@ -35,7 +35,10 @@ type Storage struct {
 	mtx    sync.RWMutex
 
 	// For writes
-	queues []*QueueManager
+	dir       string
+	queues    []*QueueManager
+	samplesIn *ewmaRate
+	quit      chan struct{}
 
 	// For reads
 	queryables             []storage.Queryable
@@ -43,15 +46,34 @@ type Storage struct {
 	flushDeadline          time.Duration
 }
 
-// NewStorage returns a remote.Storage.
-func NewStorage(l log.Logger, stCallback startTimeCallback, flushDeadline time.Duration) *Storage {
+// NewStorage returns a remote.Storage. Samples are sent to the remote write
+// endpoints from the WAL of the local storage in dir.
+func NewStorage(l log.Logger, stCallback startTimeCallback, dir string, flushDeadline time.Duration) *Storage {
 	if l == nil {
 		l = log.NewNopLogger()
 	}
-	return &Storage{
+	s := &Storage{
 		logger:                 l,
 		localStartTimeCallback: stCallback,
 		flushDeadline:          flushDeadline,
+		samplesIn:              newEWMARate(ewmaWeight, shardUpdateDuration),
+		dir:                    dir,
+		quit:                   make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+func (s *Storage) run() {
+	ticker := time.NewTicker(shardUpdateDuration)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.samplesIn.tick()
+		case <-s.quit:
+			return
+		}
 	}
 }
 
@@ -76,6 +98,8 @@ func (s *Storage) ApplyConfig(conf *config.Config) error {
 		}
 		newQueues = append(newQueues, NewQueueManager(
 			s.logger,
+			s.dir,
+			s.samplesIn,
 			rwConf.QueueConfig,
 			conf.GlobalConfig.ExternalLabels,
 			rwConf.WriteRelabelConfigs,
@@ -148,6 +172,7 @@ func (s *Storage) Close() error {
 	s.mtx.Lock()
 	defer s.mtx.Unlock()
 
+	close(s.quit)
 	for _, q := range s.queues {
 		q.Stop()
 	}
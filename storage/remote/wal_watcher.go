@@ -0,0 +1,548 @@
+// Copyright 2018 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/tsdb"
+	"github.com/prometheus/tsdb/fileutil"
+	"github.com/prometheus/tsdb/wal"
+
+	"github.com/prometheus/prometheus/pkg/timestamp"
+)
+
+const (
+	readPeriod         = 10 * time.Millisecond
+	checkpointPeriod   = 5 * time.Second
+	segmentCheckPeriod = 100 * time.Millisecond
+	retryPeriod        = 5 * time.Second
+)
+
+var (
+	watcherRecordsRead = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "prometheus",
+			Subsystem: "wal_watcher",
+			Name:      "records_read_total",
+			Help:      "Number of records read by the WAL watcher from the WAL.",
+		},
+		[]string{queue, "type"},
+	)
+	watcherRecordDecodeFails = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "prometheus",
+			Subsystem: "wal_watcher",
+			Name:      "record_decode_failures_total",
+			Help:      "Number of records read by the WAL watcher that resulted in an error when decoding.",
+		},
+		[]string{queue},
+	)
+	watcherCurrentSegment = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "prometheus",
+			Subsystem: "wal_watcher",
+			Name:      "current_segment",
+			Help:      "Current segment the WAL watcher is reading records from.",
+		},
+		[]string{queue},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(watcherRecordsRead)
+	prometheus.MustRegister(watcherRecordDecodeFails)
+	prometheus.MustRegister(watcherCurrentSegment)
+}
+
+// writeTo is the interface the WALWatcher hands the records it reads to.
+type writeTo interface {
+	// Append queues samples for sending. It blocks until all samples are
+	// queued and only returns false if the receiver is shutting down.
+	Append([]tsdb.RefSample) bool
+	// StoreSeries records the series found in the given segment.
+	StoreSeries([]tsdb.RefSeries, int)
+	// SeriesReset drops all series last seen in a segment before the given one.
+	SeriesReset(int)
+	// MarkSegment signals that all samples of the given segment have been
+	// handed to Append.
+	MarkSegment(int)
+}
+
+// WALWatcher watches the TSDB WAL for a given WriteTo.
+type WALWatcher struct {
+	name         string
+	writer       writeTo
+	logger       log.Logger
+	walDir       string
+	progressFile string
+
+	startTime int64
+
+	recordsReadMetric       *prometheus.CounterVec
+	recordDecodeFailsMetric prometheus.Counter
+	currentSegmentMetric    prometheus.Gauge
+
+	quit chan struct{}
+	done chan struct{}
+}
+
+// NewWALWatcher creates a new WAL watcher for the WAL in walDir. Samples are
+// replayed from the segment recorded in progressFile, or tailed from the
+// most recent segment if no progress was recorded yet.
+func NewWALWatcher(logger log.Logger, name string, writer writeTo, walDir, progressFile string) *WALWatcher {
+	if logger == nil {
+		logger = log.NewNopLogger()
+	}
+	return &WALWatcher{
+		logger:       logger,
+		writer:       writer,
+		walDir:       walDir,
+		progressFile: progressFile,
+		name:         name,
+		quit:         make(chan struct{}),
+		done:         make(chan struct{}),
+
+		recordsReadMetric:       watcherRecordsRead.MustCurryWith(prometheus.Labels{queue: name}),
+		recordDecodeFailsMetric: watcherRecordDecodeFails.WithLabelValues(name),
+		currentSegmentMetric:    watcherCurrentSegment.WithLabelValues(name),
+	}
+}
+
+// Start the WALWatcher.
+func (w *WALWatcher) Start() {
+	level.Info(w.logger).Log("msg", "starting WAL watcher", "queue", w.name)
+	go w.loop()
+}
+
+// Stop the WALWatcher.
+func (w *WALWatcher) Stop() {
+	close(w.quit)
+	<-w.done
+	level.Info(w.logger).Log("msg", "WAL watcher stopped", "queue", w.name)
+}
+
+func (w *WALWatcher) loop() {
+	defer close(w.done)
+
+	// We may encounter failures processing the WAL; we should wait and retry.
+	for !isClosed(w.quit) {
+		w.startTime = timestamp.FromTime(time.Now())
+		if err := w.run(); err != nil {
+			level.Error(w.logger).Log("msg", "error tailing WAL", "err", err)
+		}
+
+		select {
+		case <-w.quit:
+			return
+		case <-time.After(retryPeriod):
+		}
+	}
+}
+
+func (w *WALWatcher) run() error {
+	_, lastSegment, err := w.segments()
+	if err != nil {
+		return errors.Wrap(err, "wal.Segments")
+	}
+
+	// Backfill from the checkpoint first if it exists.
+	lastCheckpoint, checkpointIndex, err := tsdb.LastCheckpoint(w.walDir)
+	if err != nil && err != tsdb.ErrNotFound {
+		return errors.Wrap(err, "tsdb.LastCheckpoint")
+	}
+	if err == nil {
+		if err = w.readCheckpoint(lastCheckpoint); err != nil {
+			return errors.Wrap(err, "readCheckpoint")
+		}
+	} else {
+		checkpointIndex = -1
+	}
+
+	// Resume sending from the segment recorded in the progress file. Without
+	// one we only send samples written after startup from the last segment.
+	startSegment, ok, err := readProgress(w.progressFile)
+	if err != nil {
+		level.Warn(w.logger).Log("msg", "error reading remote write progress, starting from the last segment", "err", err)
+	}
+	if !ok || startSegment > lastSegment+1 {
+		startSegment = lastSegment
+	} else {
+		// Samples from segments removed by a WAL truncation can't be replayed.
+		// The checkpoint holds all series those segments referenced.
+		if startSegment <= checkpointIndex {
+			startSegment = checkpointIndex + 1
+		}
+		w.startTime = math.MinInt64
+		level.Info(w.logger).Log("msg", "replaying WAL", "queue", w.name, "from_segment", startSegment, "to_segment", lastSegment)
+	}
+
+	currentSegment, err := w.findSegmentForIndex(checkpointIndex)
+	if err != nil {
+		return err
+	}
+
+	level.Debug(w.logger).Log("msg", "tailing WAL", "lastCheckpoint", lastCheckpoint, "checkpointIndex", checkpointIndex, "currentSegment", currentSegment, "lastSegment", lastSegment)
+	for !isClosed(w.quit) {
+		w.currentSegmentMetric.Set(float64(currentSegment))
+		level.Debug(w.logger).Log("msg", "processing segment", "currentSegment", currentSegment)
+
+		// On start, we have a pointer to what we've already sent. Segments
+		// before it are only read for series records.
+		if err := w.watch(currentSegment, currentSegment >= startSegment); err != nil {
+			return err
+		}
+		if isClosed(w.quit) {
+			return nil
+		}
+
+		nextSegment, err := w.findSegmentForIndex(currentSegment)
+		if err != nil {
+			return err
+		}
+		if nextSegment != currentSegment+1 {
+			// The segments we skipped were truncated and their series
+			// records moved into a checkpoint.
+			level.Warn(w.logger).Log("msg", "WAL segments were truncated before they were sent", "from_segment", currentSegment+1, "to_segment", nextSegment-1)
+			if dir, _, err := tsdb.LastCheckpoint(w.walDir); err == nil {
+				if err := w.readCheckpoint(dir); err != nil {
+					return errors.Wrap(err, "readCheckpoint")
+				}
+			}
+		}
+		currentSegment = nextSegment
+	}
+
+	return nil
+}
+
+// findSegmentForIndex returns the first segment after index, skipping over
+// segments that were removed by a WAL truncation.
+func (w *WALWatcher) findSegmentForIndex(index int) (int, error) {
+	refs, err := w.segmentRefs()
+	if err != nil {
+		return -1, err
+	}
+
+	for _, r := range refs {
+		if r > index {
+			return r, nil
+		}
+	}
+
+	return -1, errors.New("failed to find segment for index")
+}
+
+func (w *WALWatcher) segmentRefs() ([]int, error) {
+	files, err := fileutil.ReadDir(w.walDir)
+	if err != nil {
+		return nil, err
+	}
+	var refs []int
+	for _, fn := range files {
+		k, err := strconv.Atoi(fn)
+		if err != nil {
+			continue
+		}
+		refs = append(refs, k)
+	}
+	sort.Ints(refs)
+	return refs, nil
+}
+
+// segments returns the range [first, last] of currently existing segments.
+func (w *WALWatcher) segments() (first, last int, err error) {
+	refs, err := w.segmentRefs()
+	if err != nil {
+		return -1, -1, err
+	}
+	if len(refs) == 0 {
+		return -1, -1, errors.New("no segments found in WAL")
+	}
+	return refs[0], refs[len(refs)-1], nil
+}
+
+// watch reads the given segment until it is superseded by a newer one. If
+// sendSamples is false only series records are processed.
+func (w *WALWatcher) watch(segmentNum int, sendSamples bool) error {
+	segment, err := wal.OpenReadSegment(wal.SegmentName(w.walDir, segmentNum))
+	if err != nil {
+		return err
+	}
+	defer segment.Close()
+
+	reader := wal.NewLiveReader(segment)
+
+	readTicker := time.NewTicker(readPeriod)
+	defer readTicker.Stop()
+
+	checkpointTicker := time.NewTicker(checkpointPeriod)
+	defer checkpointTicker.Stop()
+
+	segmentTicker := time.NewTicker(segmentCheckPeriod)
+	defer segmentTicker.Stop()
+
+	for {
+		select {
+		case <-w.quit:
+			return nil
+
+		case <-checkpointTicker.C:
+			// Periodically check if there is a new checkpoint so we can
+			// garbage collect series that are no longer referenced.
+			if err := w.garbageCollectSeries(segmentNum); err != nil {
+				level.Warn(w.logger).Log("msg", "error process checkpoint", "err", err)
+			}
+
+		case <-segmentTicker.C:
+			_, last, err := w.segments()
+			if err != nil {
+				return errors.Wrap(err, "segments")
+			}
+
+			// Check if new segments exist.
+			if last <= segmentNum {
+				continue
+			}
+
+			// The segment was completed by the writer, read what's left
+			// and move on to the next one.
+			if err := w.readSegment(reader, segmentNum, sendSamples); err != nil {
+				return err
+			}
+			if sendSamples {
+				w.writer.MarkSegment(segmentNum)
+			}
+			return nil
+
+		case <-readTicker.C:
+			if err := w.readSegment(reader, segmentNum, sendSamples); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// garbageCollectSeries drops series that are no longer present in the most
+// recent checkpoint once the watcher has moved past it.
+func (w *WALWatcher) garbageCollectSeries(segmentNum int) error {
+	dir, _, err := tsdb.LastCheckpoint(w.walDir)
+	if err != nil && err != tsdb.ErrNotFound {
+		return errors.Wrap(err, "tsdb.LastCheckpoint")
+	}
+
+	if dir == "" || err == tsdb.ErrNotFound {
+		// No checkpoint, nothing to do.
+		return nil
+	}
+
+	index, err := checkpointNum(dir)
+	if err != nil {
+		return errors.Wrap(err, "error parsing checkpoint filename")
+	}
+
+	if index >= segmentNum {
+		// Samples of the segments being read may still reference series
+		// which are missing from the checkpoint.
+		return nil
+	}
+
+	level.Debug(w.logger).Log("msg", "new checkpoint detected", "new", dir, "currentSegment", segmentNum)
+
+	if err = w.readCheckpoint(dir); err != nil {
+		return errors.Wrap(err, "readCheckpoint")
+	}
+
+	// Clear series with a checkpoint or segment index < checkpoint index.
+	w.writer.SeriesReset(index)
+	return nil
+}
+
+func (w *WALWatcher) readSegment(r *wal.LiveReader, segmentNum int, sendSamples bool) error {
+	var (
+		dec     tsdb.RecordDecoder
+		series  []tsdb.RefSeries
+		samples []tsdb.RefSample
+		err     error
+	)
+
+	for r.Next() && !isClosed(w.quit) {
+		rec := r.Record()
+		w.recordsReadMetric.WithLabelValues(recordType(dec.Type(rec))).Inc()
+
+		switch dec.Type(rec) {
+		case tsdb.RecordSeries:
+			series, err = dec.Series(rec, series[:0])
+			if err != nil {
+				w.recordDecodeFailsMetric.Inc()
+				return err
+			}
+			w.writer.StoreSeries(series, segmentNum)
+
+		case tsdb.RecordSamples:
+			if !sendSamples {
+				break
+			}
+			samples, err = dec.Samples(rec, samples[:0])
+			if err != nil {
+				w.recordDecodeFailsMetric.Inc()
+				return err
+			}
+			var send []tsdb.RefSample
+			for _, s := range samples {
+				if s.T > w.startTime {
+					send = append(send, s)
+				}
+			}
+			if len(send) > 0 {
+				// Blocks until the queue accepted all samples, which holds
+				// back reading while the remote end is unavailable.
+				w.writer.Append(send)
+			}
+
+		case tsdb.RecordTombstones:
+			// noop
+		case tsdb.RecordInvalid:
+			return errors.New("invalid record")
+
+		default:
+			w.recordDecodeFailsMetric.Inc()
+			return errors.New("unknown TSDB record type")
+		}
+	}
+	if err := r.Err(); err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+func recordType(rt tsdb.RecordType) string {
+	switch rt {
+	case tsdb.RecordInvalid:
+		return "invalid"
+	case tsdb.RecordSeries:
+		return "series"
+	case tsdb.RecordSamples:
+		return "samples"
+	case tsdb.RecordTombstones:
+		return "tombstones"
+	default:
+		return "unknown"
+	}
+}
+
+// readCheckpoint reads the series records of a checkpoint directory.
+func (w *WALWatcher) readCheckpoint(checkpointDir string) error {
+	index, err := checkpointNum(checkpointDir)
+	if err != nil {
+		return errors.Wrap(err, "checkpointNum")
+	}
+
+	sr, err := wal.NewSegmentsReader(checkpointDir)
+	if err != nil {
+		return errors.Wrap(err, "NewSegmentsReader")
+	}
+	defer sr.Close()
+
+	var (
+		dec    tsdb.RecordDecoder
+		series []tsdb.RefSeries
+		r      = wal.NewReader(sr)
+	)
+	for r.Next() && !isClosed(w.quit) {
+		rec := r.Record()
+		if dec.Type(rec) != tsdb.RecordSeries {
+			continue
+		}
+		series, err = dec.Series(rec, series[:0])
+		if err != nil {
+			w.recordDecodeFailsMetric.Inc()
+			return err
+		}
+		w.writer.StoreSeries(series, index)
+	}
+	if err := r.Err(); err != nil {
+		return errors.Wrapf(err, "readCheckpoint: %s", checkpointDir)
+	}
+
+	level.Debug(w.logger).Log("msg", "read series references from checkpoint", "checkpoint", checkpointDir)
+	return nil
+}
+
+func checkpointNum(dir string) (int, error) {
+	// Checkpoint dir names are in the format checkpoint.000001
+	chunks := strings.Split(filepath.Base(dir), ".")
+	if len(chunks) != 2 {
+		return 0, errors.Errorf("invalid checkpoint dir string: %s", dir)
+	}
+
+	result, err := strconv.Atoi(chunks[1])
+	if err != nil {
+		return 0, errors.Errorf("invalid checkpoint dir string: %s", dir)
+	}
+
+	return result, nil
+}
+
+// readProgress returns the first segment which still has to be sent, as
+// recorded in the given progress file.
+func readProgress(fn string) (int, bool, error) {
+	b, err := ioutil.ReadFile(fn)
+	if os.IsNotExist(err) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	segment, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil {
+		return 0, false, errors.Wrapf(err, "invalid progress file %s", fn)
+	}
+	return segment, true, nil
+}
+
+// writeProgress atomically records the first segment which still has to be
+// sent in the given progress file.
+func writeProgress(fn string, segment int) error {
+	if err := os.MkdirAll(filepath.Dir(fn), 0777); err != nil {
+		return err
+	}
+	tmp := fn + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(fmt.Sprintf("%d\n", segment)), 0666); err != nil {
+		return err
+	}
+	return fileutil.Rename(tmp, fn)
+}
+
+func isClosed(c chan struct{}) bool {
+	select {
+	case <-c:
+		return true
+	default:
+		return false
+	}
+}
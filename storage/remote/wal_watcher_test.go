@@ -0,0 +1,239 @@
+// Copyright 2018 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/pkg/timestamp"
+	"github.com/prometheus/prometheus/util/testutil"
+	"github.com/prometheus/tsdb"
+	"github.com/prometheus/tsdb/labels"
+	"github.com/prometheus/tsdb/wal"
+)
+
+type writeToMock struct {
+	mtx                  sync.Mutex
+	samplesAppended      []tsdb.RefSample
+	seriesSegmentIndexes map[uint64]int
+	markedSegments       []int
+}
+
+func newWriteToMock() *writeToMock {
+	return &writeToMock{
+		seriesSegmentIndexes: make(map[uint64]int),
+	}
+}
+
+func (wtm *writeToMock) Append(s []tsdb.RefSample) bool {
+	wtm.mtx.Lock()
+	defer wtm.mtx.Unlock()
+	wtm.samplesAppended = append(wtm.samplesAppended, s...)
+	return true
+}
+
+func (wtm *writeToMock) StoreSeries(series []tsdb.RefSeries, index int) {
+	wtm.mtx.Lock()
+	defer wtm.mtx.Unlock()
+	for _, s := range series {
+		wtm.seriesSegmentIndexes[s.Ref] = index
+	}
+}
+
+func (wtm *writeToMock) SeriesReset(index int) {
+	wtm.mtx.Lock()
+	defer wtm.mtx.Unlock()
+	// Check for series that are in segments older than the checkpoint
+	// that were not also present in the checkpoint.
+	for k, v := range wtm.seriesSegmentIndexes {
+		if v < index {
+			delete(wtm.seriesSegmentIndexes, k)
+		}
+	}
+}
+
+func (wtm *writeToMock) MarkSegment(segment int) {
+	wtm.mtx.Lock()
+	defer wtm.mtx.Unlock()
+	wtm.markedSegments = append(wtm.markedSegments, segment)
+}
+
+func (wtm *writeToMock) state() (samples []tsdb.RefSample, series int, marked []int) {
+	wtm.mtx.Lock()
+	defer wtm.mtx.Unlock()
+	return append([]tsdb.RefSample(nil), wtm.samplesAppended...), len(wtm.seriesSegmentIndexes), append([]int(nil), wtm.markedSegments...)
+}
+
+// writeSegments writes one WAL segment per entry of segments, each with a
+// series record for the given series refs and a samples record with one
+// sample per series at the given timestamp. An empty segment is appended
+// at the end, so that all written segments are complete.
+func writeSegments(t *testing.T, dir string, segments [][]uint64, ts int64) {
+	var enc tsdb.RecordEncoder
+	for i, refs := range segments {
+		if i > 0 {
+			s, err := wal.CreateSegment(dir, i)
+			testutil.Ok(t, err)
+			testutil.Ok(t, s.Close())
+		}
+		w, err := wal.NewSize(nil, nil, dir, 128*1024)
+		testutil.Ok(t, err)
+
+		var (
+			series  []tsdb.RefSeries
+			samples []tsdb.RefSample
+		)
+		for _, ref := range refs {
+			series = append(series, tsdb.RefSeries{
+				Ref:    ref,
+				Labels: labels.Labels{labels.Label{Name: "__name__", Value: fmt.Sprintf("metric_%d", ref)}},
+			})
+			samples = append(samples, tsdb.RefSample{Ref: ref, T: ts, V: float64(ref)})
+		}
+		testutil.Ok(t, w.Log(enc.Series(series, nil)))
+		testutil.Ok(t, w.Log(enc.Samples(samples, nil)))
+		testutil.Ok(t, w.Close())
+	}
+	s, err := wal.CreateSegment(dir, len(segments))
+	testutil.Ok(t, err)
+	testutil.Ok(t, s.Close())
+}
+
+func waitForSamples(t *testing.T, wt *writeToMock, expected int) []tsdb.RefSample {
+	for i := 0; i < 500; i++ {
+		samples, _, _ := wt.state()
+		if len(samples) >= expected {
+			return samples
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	samples, _, _ := wt.state()
+	t.Fatalf("expected %d samples, got %d", expected, len(samples))
+	return nil
+}
+
+func TestTailSamples(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tailSamples")
+	testutil.Ok(t, err)
+	defer os.RemoveAll(dir)
+	walDir := filepath.Join(dir, "wal")
+
+	// Samples older than the start of the watcher are not sent without
+	// recorded progress.
+	writeSegments(t, walDir, [][]uint64{{1, 2}}, 0)
+
+	wt := newWriteToMock()
+	watcher := NewWALWatcher(nil, "", wt, walDir, filepath.Join(dir, "progress"))
+	watcher.Start()
+	defer watcher.Stop()
+
+	// Wait for the watcher to pick up the series of the existing segment.
+	for i := 0; i < 500; i++ {
+		if _, series, _ := wt.state(); series == 2 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	w, err := wal.NewSize(nil, nil, walDir, 128*1024)
+	testutil.Ok(t, err)
+	defer w.Close()
+
+	var (
+		enc tsdb.RecordEncoder
+		ts  = timestamp.FromTime(time.Now().Add(time.Hour))
+	)
+	testutil.Ok(t, w.Log(enc.Samples([]tsdb.RefSample{{Ref: 1, T: ts, V: 1}, {Ref: 2, T: ts, V: 2}}, nil)))
+
+	samples := waitForSamples(t, wt, 2)
+	testutil.Equals(t, []tsdb.RefSample{{Ref: 1, T: ts, V: 1}, {Ref: 2, T: ts, V: 2}}, samples)
+}
+
+func TestResumeFromProgress(t *testing.T) {
+	dir, err := ioutil.TempDir("", "resumeFromProgress")
+	testutil.Ok(t, err)
+	defer os.RemoveAll(dir)
+	walDir := filepath.Join(dir, "wal")
+	progressFile := filepath.Join(dir, "progress")
+
+	writeSegments(t, walDir, [][]uint64{{1}, {2}, {3}}, 0)
+	testutil.Ok(t, writeProgress(progressFile, 1))
+
+	wt := newWriteToMock()
+	watcher := NewWALWatcher(nil, "", wt, walDir, progressFile)
+	watcher.Start()
+	defer watcher.Stop()
+
+	// Segment 0 was already sent, only its series are read.
+	samples := waitForSamples(t, wt, 2)
+	testutil.Equals(t, []tsdb.RefSample{{Ref: 2, T: 0, V: 2}, {Ref: 3, T: 0, V: 3}}, samples)
+
+	// The last segment is still tailed and not marked yet.
+	var (
+		series int
+		marked []int
+	)
+	for i := 0; i < 500 && len(marked) < 2; i++ {
+		_, series, marked = wt.state()
+		time.Sleep(10 * time.Millisecond)
+	}
+	testutil.Equals(t, 3, series)
+	testutil.Equals(t, []int{1, 2}, marked)
+}
+
+func TestCheckpointSeriesReset(t *testing.T) {
+	dir, err := ioutil.TempDir("", "seriesReset")
+	testutil.Ok(t, err)
+	defer os.RemoveAll(dir)
+	walDir := filepath.Join(dir, "wal")
+
+	writeSegments(t, walDir, [][]uint64{{1, 2}, {3}, {4}}, 0)
+
+	wt := newWriteToMock()
+	watcher := NewWALWatcher(nil, "", wt, walDir, filepath.Join(dir, "progress"))
+	watcher.Start()
+	defer watcher.Stop()
+
+	for i := 0; i < 500; i++ {
+		if _, series, _ := wt.state(); series == 4 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	_, series, _ := wt.state()
+	testutil.Equals(t, 4, series)
+
+	w, err := wal.NewSize(nil, nil, walDir, 128*1024)
+	testutil.Ok(t, err)
+	defer w.Close()
+
+	// Series 1 was removed from the head and is not kept in the checkpoint.
+	_, err = tsdb.Checkpoint(w, 0, 1, func(ref uint64) bool { return ref != 1 }, 0)
+	testutil.Ok(t, err)
+	testutil.Ok(t, w.Truncate(2))
+
+	for i := 0; i < 1000; i++ {
+		if _, series, _ = wt.state(); series == 3 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	testutil.Equals(t, 3, series)
+}
@@ -14,7 +14,6 @@
 package remote
 
 import (
-	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/pkg/labels"
 	"github.com/prometheus/prometheus/storage"
 )
@@ -24,19 +23,11 @@ func (s *Storage) Appender() (storage.Appender, error) {
 	return s, nil
 }
 
-// Add implements storage.Appender.
+// Add implements storage.Appender. Samples are not queued here but read
+// from the WAL of the local storage, we only track the rate of incoming
+// samples to calculate the number of shards.
 func (s *Storage) Add(l labels.Labels, t int64, v float64) (uint64, error) {
-	s.mtx.RLock()
-	defer s.mtx.RUnlock()
-	for _, q := range s.queues {
-		if err := q.Append(&model.Sample{
-			Metric:    labelsToMetric(l),
-			Timestamp: model.Time(t),
-			Value:     model.SampleValue(v),
-		}); err != nil {
-			panic(err) // QueueManager.Append() should always return nil as per doc string.
-		}
-	}
+	s.samplesIn.incr(1)
 	return 0, nil
 }
 
@@ -0,0 +1,156 @@
+// Copyright 2019 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"net/http"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/prometheus/prometheus/storage"
+)
+
+var (
+	receivedSamplesTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "received_samples_total",
+			Help:      "Total number of samples received through the remote write receiver.",
+		},
+	)
+	rejectedSamplesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "rejected_samples_total",
+			Help:      "Total number of samples received through the remote write receiver which were rejected by the local storage.",
+		},
+		[]string{"reason"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(receivedSamplesTotal)
+	prometheus.MustRegister(rejectedSamplesTotal)
+
+	// Initialize metric vectors.
+	for _, reason := range []string{"out_of_order", "duplicate", "out_of_bounds"} {
+		rejectedSamplesTotal.WithLabelValues(reason)
+	}
+}
+
+type writeHandler struct {
+	logger     log.Logger
+	appendable storage.Appendable
+}
+
+// NewWriteHandler creates a http.Handler that accepts remote write requests
+// and appends the contained samples to the given storage.
+func NewWriteHandler(logger log.Logger, appendable storage.Appendable) http.Handler {
+	if logger == nil {
+		logger = log.NewNopLogger()
+	}
+	return &writeHandler{
+		logger:     logger,
+		appendable: appendable,
+	}
+}
+
+func (h *writeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req, err := DecodeWriteRequest(r.Body)
+	if err != nil {
+		level.Debug(h.logger).Log("msg", "Error decoding remote write request", "err", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = h.write(req)
+	switch err {
+	case nil:
+	case storage.ErrOutOfOrderSample, storage.ErrOutOfBounds, storage.ErrDuplicateSampleForTimestamp:
+		// Samples the storage rejects will be rejected again on a retry,
+		// so they are a bad request instead of a server error.
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	default:
+		if httpErr, ok := err.(HTTPError); ok {
+			http.Error(w, httpErr.Error(), httpErr.Status())
+			return
+		}
+		level.Error(h.logger).Log("msg", "Error appending remote write", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// write appends all samples of the request. Samples rejected by the storage
+// are skipped, the remaining ones are committed and the first rejection is
+// returned.
+func (h *writeHandler) write(req *prompb.WriteRequest) error {
+	app, err := h.appendable.Appender()
+	if err != nil {
+		return err
+	}
+
+	var (
+		rejectErr error
+		committed bool
+	)
+	defer func() {
+		if !committed {
+			app.Rollback()
+		}
+	}()
+
+	for _, ts := range req.Timeseries {
+		lset := labelProtosToLabels(ts.Labels)
+		if err := validateLabelsAndMetricName(lset); err != nil {
+			return HTTPError{msg: err.Error(), status: http.StatusBadRequest}
+		}
+
+		for _, s := range ts.Samples {
+			receivedSamplesTotal.Inc()
+
+			_, err := app.Add(lset, s.Timestamp, s.Value)
+			switch err {
+			case nil:
+				continue
+			case storage.ErrOutOfOrderSample:
+				rejectedSamplesTotal.WithLabelValues("out_of_order").Inc()
+			case storage.ErrDuplicateSampleForTimestamp:
+				rejectedSamplesTotal.WithLabelValues("duplicate").Inc()
+			case storage.ErrOutOfBounds:
+				rejectedSamplesTotal.WithLabelValues("out_of_bounds").Inc()
+			default:
+				return err
+			}
+			level.Debug(h.logger).Log("msg", "Rejected sample from remote write", "err", err, "series", lset.String(), "timestamp", s.Timestamp)
+			if rejectErr == nil {
+				rejectErr = err
+			}
+		}
+	}
+
+	committed = true
+	if err := app.Commit(); err != nil {
+		return err
+	}
+	return rejectErr
+}
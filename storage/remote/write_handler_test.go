@@ -0,0 +1,157 @@
+// Copyright 2019 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/util/testutil"
+)
+
+var writeRequestFixture = &prompb.WriteRequest{
+	Timeseries: []prompb.TimeSeries{
+		{
+			Labels:  []prompb.Label{{Name: "__name__", Value: "test_metric1"}, {Name: "b", Value: "c"}, {Name: "baz", Value: "qux"}},
+			Samples: []prompb.Sample{{Value: 1, Timestamp: 0}},
+		},
+		{
+			Labels:  []prompb.Label{{Name: "__name__", Value: "test_metric1"}, {Name: "d", Value: "e"}},
+			Samples: []prompb.Sample{{Value: 2, Timestamp: 1}, {Value: 3, Timestamp: 2}},
+		},
+	},
+}
+
+type mockSample struct {
+	l labels.Labels
+	t int64
+	v float64
+}
+
+type mockAppendable struct {
+	latest    int64
+	samples   []mockSample
+	committed []mockSample
+}
+
+func (m *mockAppendable) Appender() (storage.Appender, error) {
+	return m, nil
+}
+
+func (m *mockAppendable) Add(l labels.Labels, t int64, v float64) (uint64, error) {
+	if t < m.latest {
+		return 0, storage.ErrOutOfOrderSample
+	}
+	m.latest = t
+	m.samples = append(m.samples, mockSample{l, t, v})
+	return 0, nil
+}
+
+func (m *mockAppendable) AddFast(l labels.Labels, _ uint64, t int64, v float64) error {
+	_, err := m.Add(l, t, v)
+	return err
+}
+
+func (m *mockAppendable) Commit() error {
+	m.committed = append(m.committed, m.samples...)
+	m.samples = nil
+	return nil
+}
+
+func (m *mockAppendable) Rollback() error {
+	m.samples = nil
+	return nil
+}
+
+func encodeWriteRequest(t *testing.T, req *prompb.WriteRequest) *bytes.Reader {
+	data, err := proto.Marshal(req)
+	testutil.Ok(t, err)
+	return bytes.NewReader(snappy.Encode(nil, data))
+}
+
+func TestRemoteWriteHandler(t *testing.T) {
+	req, err := http.NewRequest("", "", encodeWriteRequest(t, writeRequestFixture))
+	testutil.Ok(t, err)
+
+	appendable := &mockAppendable{}
+	handler := NewWriteHandler(nil, appendable)
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	testutil.Equals(t, http.StatusNoContent, recorder.Code)
+
+	i := 0
+	for _, ts := range writeRequestFixture.Timeseries {
+		lset := labelProtosToLabels(ts.Labels)
+		for _, s := range ts.Samples {
+			testutil.Equals(t, mockSample{lset, s.Timestamp, s.Value}, appendable.committed[i])
+			i++
+		}
+	}
+	testutil.Equals(t, i, len(appendable.committed))
+}
+
+func TestRemoteWriteHandlerOutOfOrder(t *testing.T) {
+	req, err := http.NewRequest("", "", encodeWriteRequest(t, writeRequestFixture))
+	testutil.Ok(t, err)
+
+	appendable := &mockAppendable{latest: 1}
+	handler := NewWriteHandler(nil, appendable)
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	testutil.Equals(t, http.StatusBadRequest, recorder.Code)
+
+	// Only the out of order sample is rejected.
+	testutil.Equals(t, 2, len(appendable.committed))
+}
+
+func TestRemoteWriteHandlerInvalidLabels(t *testing.T) {
+	req, err := http.NewRequest("", "", encodeWriteRequest(t, &prompb.WriteRequest{
+		Timeseries: []prompb.TimeSeries{
+			{
+				Labels:  []prompb.Label{{Name: "__name__", Value: "test-metric"}},
+				Samples: []prompb.Sample{{Value: 1, Timestamp: 0}},
+			},
+		},
+	}))
+	testutil.Ok(t, err)
+
+	appendable := &mockAppendable{}
+	handler := NewWriteHandler(nil, appendable)
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	testutil.Equals(t, http.StatusBadRequest, recorder.Code)
+	testutil.Equals(t, 0, len(appendable.committed))
+}
+
+func TestRemoteWriteHandlerInvalidRequest(t *testing.T) {
+	req, err := http.NewRequest("", "", bytes.NewReader([]byte("not snappy")))
+	testutil.Ok(t, err)
+
+	handler := NewWriteHandler(nil, &mockAppendable{})
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	testutil.Equals(t, http.StatusBadRequest, recorder.Code)
+}
@@ -84,14 +84,49 @@ type queryTimings struct {
 	ExecTotalTime        float64 `json:"execTotalTime"`
 }
 
-// QueryStats currently only holding query timings.
+// QuerySamples counts the samples a query loads from the storage and the
+// maximum number of samples it holds in memory at once.
+type QuerySamples struct {
+	// TotalQueryableSamples is the number of samples read by the selectors of
+	// the query, summed over all evaluation steps.
+	TotalQueryableSamples int `json:"totalQueryableSamples"`
+	// PeakSamples is the highest number of samples accounted against the
+	// engine's maximum number of samples per query.
+	PeakSamples int `json:"peakSamples"`
+}
+
+// NewQuerySamples returns empty QuerySamples.
+func NewQuerySamples() *QuerySamples {
+	return &QuerySamples{}
+}
+
+// IncrementSamples adds to the number of samples read from the storage.
+func (qs *QuerySamples) IncrementSamples(n int) {
+	if qs == nil {
+		return
+	}
+	qs.TotalQueryableSamples += n
+}
+
+// UpdatePeak raises the peak number of samples if samples exceeds it.
+func (qs *QuerySamples) UpdatePeak(samples int) {
+	if qs == nil {
+		return
+	}
+	if samples > qs.PeakSamples {
+		qs.PeakSamples = samples
+	}
+}
+
+// QueryStats holds query timings and optionally sample statistics.
 type QueryStats struct {
-	Timings queryTimings `json:"timings,omitempty"`
+	Timings queryTimings  `json:"timings,omitempty"`
+	Samples *QuerySamples `json:"samples,omitempty"`
 }
 
 // NewQueryStats makes a QueryStats struct with all QueryTimings found in the
-// given TimerGroup.
-func NewQueryStats(tg *QueryTimers) *QueryStats {
+// given TimerGroup. Sample statistics are only included if samples is not nil.
+func NewQueryStats(tg *QueryTimers, samples *QuerySamples) *QueryStats {
 	var qt queryTimings
 
 	for s, timer := range tg.TimerGroup.timers {
@@ -111,7 +146,7 @@ func NewQueryStats(tg *QueryTimers) *QueryStats {
 		}
 	}
 
-	qs := QueryStats{Timings: qt}
+	qs := QueryStats{Timings: qt, Samples: samples}
 	return &qs
 }
 
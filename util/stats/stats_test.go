@@ -47,7 +47,7 @@ func TestQueryStatsWithTimers(t *testing.T) {
 	time.Sleep(2 * time.Millisecond)
 	timer.Stop()
 
-	qs := NewQueryStats(qt)
+	qs := NewQueryStats(qt, nil)
 	actual, err := json.Marshal(qs)
 	if err != nil {
 		t.Fatalf("Unexpected error during serialization: %v", err)
@@ -68,7 +68,7 @@ func TestQueryStatsWithSpanTimers(t *testing.T) {
 	qst, _ := qt.GetSpanTimer(ctx, ExecQueueTime, prometheus.NewSummary(prometheus.SummaryOpts{}))
 	time.Sleep(5 * time.Millisecond)
 	qst.Finish()
-	qs := NewQueryStats(qt)
+	qs := NewQueryStats(qt, nil)
 	actual, err := json.Marshal(qs)
 	if err != nil {
 		t.Fatalf("Unexpected error during serialization: %v", err)
@@ -83,6 +83,34 @@ func TestQueryStatsWithSpanTimers(t *testing.T) {
 	}
 }
 
+func TestQueryStatsWithSamples(t *testing.T) {
+	samples := NewQuerySamples()
+	samples.IncrementSamples(5)
+	samples.IncrementSamples(3)
+	samples.UpdatePeak(4)
+	samples.UpdatePeak(2)
+
+	actual, err := json.Marshal(NewQueryStats(NewQueryTimers(), samples))
+	if err != nil {
+		t.Fatalf("Unexpected error during serialization: %v", err)
+	}
+	match, err := regexp.MatchString(`"samples":{"totalQueryableSamples":8,"peakSamples":4}`, string(actual))
+	if err != nil {
+		t.Fatalf("Unexpected error while matching string: %v", err)
+	}
+	if !match {
+		t.Fatalf("Expected sample statistics, but got %s.", actual)
+	}
+
+	actual, err = json.Marshal(NewQueryStats(NewQueryTimers(), nil))
+	if err != nil {
+		t.Fatalf("Unexpected error during serialization: %v", err)
+	}
+	if regexp.MustCompile(`"samples"`).Match(actual) {
+		t.Fatalf("Expected no sample statistics, but got %s.", actual)
+	}
+}
+
 func TestTimerGroup(t *testing.T) {
 	tg := NewTimerGroup()
 	execTotalTimer := tg.GetTimer(ExecTotalTime)
@@ -38,6 +38,8 @@ import (
 	tsdbLabels "github.com/prometheus/tsdb/labels"
 
 	"github.com/prometheus/prometheus/config"
+	"github.com/prometheus/prometheus/discovery"
+	"github.com/prometheus/prometheus/discovery/targetgroup"
 	"github.com/prometheus/prometheus/pkg/gate"
 	"github.com/prometheus/prometheus/pkg/labels"
 	"github.com/prometheus/prometheus/pkg/textparse"
@@ -98,6 +100,11 @@ type targetRetriever interface {
 	TargetsDropped() map[string][]*scrape.Target
 }
 
+type discoveryRetriever interface {
+	DiscoveredGroups() map[string]map[string][]*targetgroup.Group
+	ProviderStatus() []discovery.ProviderStatus
+}
+
 type alertmanagerRetriever interface {
 	Alertmanagers() []*url.URL
 	DroppedAlertmanagers() []*url.URL
@@ -136,10 +143,12 @@ type TSDBAdmin interface {
 // API can register a set of endpoints in a router and handle
 // them using the provided storage and query engine.
 type API struct {
-	Queryable   storage.Queryable
-	QueryEngine *promql.Engine
+	Queryable         storage.Queryable
+	QueryEngine       *promql.Engine
+	ExemplarQueryable storage.ExemplarQuerier
 
 	targetRetriever       targetRetriever
+	discoveryRetriever    discoveryRetriever
 	alertmanagerRetriever alertmanagerRetriever
 	rulesRetriever        rulesRetriever
 	now                   func() time.Time
@@ -147,12 +156,14 @@ type API struct {
 	flagsMap              map[string]string
 	ready                 func(http.HandlerFunc) http.HandlerFunc
 
-	db                    func() TSDBAdmin
-	enableAdmin           bool
-	logger                log.Logger
-	remoteReadSampleLimit int
-	remoteReadGate        *gate.Gate
-	CORSOrigin            *regexp.Regexp
+	db                        func() TSDBAdmin
+	enableAdmin               bool
+	logger                    log.Logger
+	remoteReadSampleLimit     int
+	remoteReadMaxBytesInFrame int
+	remoteReadGate            *gate.Gate
+	remoteWriteHandler        http.Handler
+	CORSOrigin                *regexp.Regexp
 }
 
 func init() {
@@ -164,7 +175,10 @@ func init() {
 func NewAPI(
 	qe *promql.Engine,
 	q storage.Queryable,
+	ap storage.Appendable,
+	eq storage.ExemplarQuerier,
 	tr targetRetriever,
+	dr discoveryRetriever,
 	ar alertmanagerRetriever,
 	configFunc func() config.Config,
 	flagsMap map[string]string,
@@ -175,26 +189,37 @@ func NewAPI(
 	rr rulesRetriever,
 	remoteReadSampleLimit int,
 	remoteReadConcurrencyLimit int,
+	remoteReadMaxBytesInFrame int,
+	enableRemoteWriteReceiver bool,
 	CORSOrigin *regexp.Regexp,
 ) *API {
-	return &API{
+	a := &API{
 		QueryEngine:           qe,
 		Queryable:             q,
+		ExemplarQueryable:     eq,
 		targetRetriever:       tr,
+		discoveryRetriever:    dr,
 		alertmanagerRetriever: ar,
 
-		now:                   time.Now,
-		config:                configFunc,
-		flagsMap:              flagsMap,
-		ready:                 readyFunc,
-		db:                    db,
-		enableAdmin:           enableAdmin,
-		rulesRetriever:        rr,
-		remoteReadSampleLimit: remoteReadSampleLimit,
-		remoteReadGate:        gate.New(remoteReadConcurrencyLimit),
-		logger:                logger,
-		CORSOrigin:            CORSOrigin,
+		now:                       time.Now,
+		config:                    configFunc,
+		flagsMap:                  flagsMap,
+		ready:                     readyFunc,
+		db:                        db,
+		enableAdmin:               enableAdmin,
+		rulesRetriever:            rr,
+		remoteReadSampleLimit:     remoteReadSampleLimit,
+		remoteReadGate:            gate.New(remoteReadConcurrencyLimit),
+		remoteReadMaxBytesInFrame: remoteReadMaxBytesInFrame,
+		logger:                    logger,
+		CORSOrigin:                CORSOrigin,
 	}
+
+	if enableRemoteWriteReceiver {
+		a.remoteWriteHandler = remote.NewWriteHandler(logger, ap)
+	}
+
+	return a
 }
 
 // Register the API's endpoints in the given router.
@@ -225,6 +250,8 @@ func (api *API) Register(r *route.Router) {
 	r.Post("/query", wrap(api.query))
 	r.Get("/query_range", wrap(api.queryRange))
 	r.Post("/query_range", wrap(api.queryRange))
+	r.Get("/query_exemplars", wrap(api.queryExemplars))
+	r.Post("/query_exemplars", wrap(api.queryExemplars))
 
 	r.Get("/labels", wrap(api.labelNames))
 	r.Post("/labels", wrap(api.labelNames))
@@ -235,11 +262,16 @@ func (api *API) Register(r *route.Router) {
 
 	r.Get("/targets", wrap(api.targets))
 	r.Get("/targets/metadata", wrap(api.targetMetadata))
+	r.Get("/targets/discovered", wrap(api.discoveredTargets))
 	r.Get("/alertmanagers", wrap(api.alertmanagers))
 
 	r.Get("/status/config", wrap(api.serveConfig))
 	r.Get("/status/flags", wrap(api.serveFlags))
+	r.Get("/status/discovery", wrap(api.serveDiscoveryStatus))
 	r.Post("/read", api.ready(http.HandlerFunc(api.remoteRead)))
+	if api.remoteWriteHandler != nil {
+		r.Post("/write", api.ready(api.remoteWriteHandler.ServeHTTP))
+	}
 
 	r.Get("/alerts", wrap(api.alerts))
 	r.Get("/rules", wrap(api.rules))
@@ -289,21 +321,16 @@ func (api *API) query(r *http.Request) apiFuncResult {
 		return apiFuncResult{nil, &apiError{errorBadData, err}, nil, nil}
 	}
 
+	ctx = httputil.ContextFromRequest(ctx, r)
 	res := qry.Exec(ctx)
 	if res.Err != nil {
 		return apiFuncResult{nil, returnAPIError(res.Err), res.Warnings, qry.Close}
 	}
 
-	// Optional stats field in response if parameter "stats" is not empty.
-	var qs *stats.QueryStats
-	if r.FormValue("stats") != "" {
-		qs = stats.NewQueryStats(qry.Stats())
-	}
-
 	return apiFuncResult{&queryData{
 		ResultType: res.Value.Type(),
 		Result:     res.Value,
-		Stats:      qs,
+		Stats:      queryStats(qry, r.FormValue("stats")),
 	}, nil, res.Warnings, qry.Close}
 }
 
@@ -355,24 +382,32 @@ func (api *API) queryRange(r *http.Request) apiFuncResult {
 		return apiFuncResult{nil, &apiError{errorBadData, err}, nil, nil}
 	}
 
+	ctx = httputil.ContextFromRequest(ctx, r)
 	res := qry.Exec(ctx)
 	if res.Err != nil {
 		return apiFuncResult{nil, returnAPIError(res.Err), res.Warnings, qry.Close}
 	}
 
-	// Optional stats field in response if parameter "stats" is not empty.
-	var qs *stats.QueryStats
-	if r.FormValue("stats") != "" {
-		qs = stats.NewQueryStats(qry.Stats())
-	}
-
 	return apiFuncResult{&queryData{
 		ResultType: res.Value.Type(),
 		Result:     res.Value,
-		Stats:      qs,
+		Stats:      queryStats(qry, r.FormValue("stats")),
 	}, nil, res.Warnings, qry.Close}
 }
 
+// queryStats returns the optional stats field of query responses. Timings
+// are returned if the parameter is not empty, "all" adds sample statistics.
+func queryStats(qry promql.Query, param string) *stats.QueryStats {
+	switch param {
+	case "":
+		return nil
+	case "all":
+		return stats.NewQueryStats(qry.Stats(), qry.SampleStats())
+	default:
+		return stats.NewQueryStats(qry.Stats(), nil)
+	}
+}
+
 func returnAPIError(err error) *apiError {
 	if err == nil {
 		return nil
@@ -430,6 +465,82 @@ var (
 	maxTime = time.Unix(math.MaxInt64/1000-62135596801, 999999999)
 )
 
+// exemplarQueryResult holds the exemplars of a series returned by the
+// exemplar query endpoint.
+type exemplarQueryResult struct {
+	SeriesLabels labels.Labels  `json:"seriesLabels"`
+	Exemplars    []exemplarData `json:"exemplars"`
+}
+
+type exemplarData struct {
+	Labels    labels.Labels `json:"labels"`
+	Value     string        `json:"value"`
+	Timestamp float64       `json:"timestamp"`
+}
+
+func (api *API) queryExemplars(r *http.Request) apiFuncResult {
+	if api.ExemplarQueryable == nil {
+		return apiFuncResult{nil, &apiError{errorUnavailable, errors.New("exemplar storage disabled")}, nil, nil}
+	}
+
+	start := minTime
+	if t := r.FormValue("start"); t != "" {
+		var err error
+		start, err = parseTime(t)
+		if err != nil {
+			return apiFuncResult{nil, &apiError{errorBadData, err}, nil, nil}
+		}
+	}
+	end := maxTime
+	if t := r.FormValue("end"); t != "" {
+		var err error
+		end, err = parseTime(t)
+		if err != nil {
+			return apiFuncResult{nil, &apiError{errorBadData, err}, nil, nil}
+		}
+	}
+	if end.Before(start) {
+		err := errors.New("end timestamp must not be before start timestamp")
+		return apiFuncResult{nil, &apiError{errorBadData, err}, nil, nil}
+	}
+
+	expr, err := promql.ParseExpr(r.FormValue("query"))
+	if err != nil {
+		return apiFuncResult{nil, &apiError{errorBadData, err}, nil, nil}
+	}
+
+	// Return the exemplars of all series selected anywhere in the query.
+	var matcherSets [][]*labels.Matcher
+	promql.Inspect(expr, func(node promql.Node, _ []promql.Node) error {
+		switch n := node.(type) {
+		case *promql.VectorSelector:
+			matcherSets = append(matcherSets, n.LabelMatchers)
+		case *promql.MatrixSelector:
+			matcherSets = append(matcherSets, n.LabelMatchers)
+		}
+		return nil
+	})
+
+	res, err := api.ExemplarQueryable.Select(timestamp.FromTime(start), timestamp.FromTime(end), matcherSets...)
+	if err != nil {
+		return apiFuncResult{nil, &apiError{errorExec, err}, nil, nil}
+	}
+
+	data := make([]exemplarQueryResult, 0, len(res))
+	for _, qr := range res {
+		es := make([]exemplarData, 0, len(qr.Exemplars))
+		for _, e := range qr.Exemplars {
+			es = append(es, exemplarData{
+				Labels:    e.Labels,
+				Value:     strconv.FormatFloat(e.Value, 'f', -1, 64),
+				Timestamp: float64(e.Ts) / 1000,
+			})
+		}
+		data = append(data, exemplarQueryResult{SeriesLabels: qr.SeriesLabels, Exemplars: es})
+	}
+	return apiFuncResult{data, nil, nil, nil}
+}
+
 func (api *API) series(r *http.Request) apiFuncResult {
 	if err := r.ParseForm(); err != nil {
 		return apiFuncResult{nil, &apiError{errorBadData, fmt.Errorf("error parsing form values: %v", err)}, nil, nil}
@@ -573,6 +684,70 @@ func (api *API) targets(r *http.Request) apiFuncResult {
 	return apiFuncResult{res, nil, nil, nil}
 }
 
+// DiscoveredTargetGroups holds the target groups a service discovery provider
+// sent for a scrape pool, before any relabeling.
+type DiscoveredTargetGroups struct {
+	ScrapePool string                   `json:"scrapePool"`
+	Provider   string                   `json:"provider"`
+	Groups     []*DiscoveredTargetGroup `json:"groups"`
+}
+
+// DiscoveredTargetGroup has the information for one discovered target group.
+type DiscoveredTargetGroup struct {
+	Source  string              `json:"source"`
+	Labels  map[string]string   `json:"labels"`
+	Targets []map[string]string `json:"targets"`
+}
+
+func (api *API) discoveredTargets(r *http.Request) apiFuncResult {
+	if api.discoveryRetriever == nil {
+		return apiFuncResult{nil, &apiError{errorUnavailable, errors.New("service discovery disabled")}, nil, nil}
+	}
+	scrapePool := r.FormValue("scrapePool")
+
+	discovered := api.discoveryRetriever.DiscoveredGroups()
+	res := []*DiscoveredTargetGroups{}
+	for pool, providers := range discovered {
+		if scrapePool != "" && pool != scrapePool {
+			continue
+		}
+		for provider, tgs := range providers {
+			dtgs := &DiscoveredTargetGroups{
+				ScrapePool: pool,
+				Provider:   provider,
+				Groups:     make([]*DiscoveredTargetGroup, 0, len(tgs)),
+			}
+			for _, tg := range tgs {
+				dtg := &DiscoveredTargetGroup{
+					Source:  tg.Source,
+					Labels:  labelSetToMap(tg.Labels),
+					Targets: make([]map[string]string, 0, len(tg.Targets)),
+				}
+				for _, t := range tg.Targets {
+					dtg.Targets = append(dtg.Targets, labelSetToMap(t))
+				}
+				dtgs.Groups = append(dtgs.Groups, dtg)
+			}
+			res = append(res, dtgs)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].ScrapePool != res[j].ScrapePool {
+			return res[i].ScrapePool < res[j].ScrapePool
+		}
+		return res[i].Provider < res[j].Provider
+	})
+	return apiFuncResult{res, nil, nil, nil}
+}
+
+func labelSetToMap(ls model.LabelSet) map[string]string {
+	m := make(map[string]string, len(ls))
+	for k, v := range ls {
+		m[string(k)] = string(v)
+	}
+	return m
+}
+
 func matchLabels(lset labels.Labels, matchers []*labels.Matcher) bool {
 	for _, m := range matchers {
 		if !m.Matches(lset.Get(m.Name)) {
@@ -824,6 +999,47 @@ func (api *API) serveFlags(r *http.Request) apiFuncResult {
 	return apiFuncResult{api.flagsMap, nil, nil, nil}
 }
 
+// DiscoveryProviderStatus has the information for one service discovery
+// provider.
+type DiscoveryProviderStatus struct {
+	Provider      string     `json:"provider"`
+	ScrapePools   []string   `json:"scrapePools"`
+	LastUpdate    *time.Time `json:"lastUpdate,omitempty"`
+	LastError     string     `json:"lastError"`
+	LastErrorTime *time.Time `json:"lastErrorTime,omitempty"`
+	TargetGroups  int        `json:"targetGroups"`
+	Targets       int        `json:"targets"`
+}
+
+func (api *API) serveDiscoveryStatus(r *http.Request) apiFuncResult {
+	if api.discoveryRetriever == nil {
+		return apiFuncResult{nil, &apiError{errorUnavailable, errors.New("service discovery disabled")}, nil, nil}
+	}
+	providers := api.discoveryRetriever.ProviderStatus()
+	res := make([]*DiscoveryProviderStatus, 0, len(providers))
+	for _, p := range providers {
+		st := &DiscoveryProviderStatus{
+			Provider:     p.Name,
+			ScrapePools:  p.Subs,
+			TargetGroups: p.TargetGroups,
+			Targets:      p.Targets,
+		}
+		if !p.LastUpdate.IsZero() {
+			t := p.LastUpdate
+			st.LastUpdate = &t
+		}
+		if p.LastError != nil {
+			st.LastError = p.LastError.Error()
+		}
+		if !p.LastErrorTime.IsZero() {
+			t := p.LastErrorTime
+			st.LastErrorTime = &t
+		}
+		res = append(res, st)
+	}
+	return apiFuncResult{res, nil, nil, nil}
+}
+
 func (api *API) remoteRead(w http.ResponseWriter, r *http.Request) {
 	api.remoteReadGate.Start(r.Context())
 	remoteReadQueries.Inc()
@@ -837,49 +1053,90 @@ func (api *API) remoteRead(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	externalLabels := api.config().GlobalConfig.ExternalLabels.Clone()
+
+	// Add external labels back in, in sorted order.
+	sortedExternalLabels := make([]prompb.Label, 0, len(externalLabels))
+	for name, value := range externalLabels {
+		sortedExternalLabels = append(sortedExternalLabels, prompb.Label{
+			Name:  string(name),
+			Value: string(value),
+		})
+	}
+	sort.Slice(sortedExternalLabels, func(i, j int) bool {
+		return sortedExternalLabels[i].Name < sortedExternalLabels[j].Name
+	})
+
+	responseType, err := remote.NegotiateResponseType(req.AcceptedResponseTypes)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch responseType {
+	case prompb.ReadRequest_STREAMED_XOR_CHUNKS:
+		api.remoteReadStreamedXORChunks(w, r, req, externalLabels, sortedExternalLabels)
+	default:
+		// On empty or unknown types in req.AcceptedResponseTypes we default to non streamed, raw samples response.
+		api.remoteReadSamples(w, r, req, externalLabels, sortedExternalLabels)
+	}
+}
+
+func (api *API) remoteReadSamples(w http.ResponseWriter, r *http.Request, req *prompb.ReadRequest, externalLabels model.LabelSet, sortedExternalLabels []prompb.Label) {
 	resp := prompb.ReadResponse{
 		Results: make([]*prompb.QueryResult, len(req.Queries)),
 	}
 	for i, query := range req.Queries {
-		from, through, matchers, selectParams, err := remote.FromQuery(query)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
+		err := api.remoteReadQuery(r.Context(), query, externalLabels, func(set storage.SeriesSet) error {
+			var err error
+			resp.Results[i], err = remote.ToQueryResult(set, api.remoteReadSampleLimit)
+			if err != nil {
+				return err
+			}
 
-		querier, err := api.Queryable.Querier(r.Context(), from, through)
+			for _, ts := range resp.Results[i].Timeseries {
+				ts.Labels = remote.MergeLabels(ts.Labels, sortedExternalLabels)
+			}
+			return nil
+		})
 		if err != nil {
+			if httpErr, ok := err.(remote.HTTPError); ok {
+				http.Error(w, httpErr.Error(), httpErr.Status())
+				return
+			}
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		defer querier.Close()
+	}
 
-		// Change equality matchers which match external labels
-		// to a matcher that looks for an empty label,
-		// as that label should not be present in the storage.
-		externalLabels := api.config().GlobalConfig.ExternalLabels.Clone()
-		filteredMatchers := make([]*labels.Matcher, 0, len(matchers))
-		for _, m := range matchers {
-			value := externalLabels[model.LabelName(m.Name)]
-			if m.Type == labels.MatchEqual && value == model.LabelValue(m.Value) {
-				matcher, err := labels.NewMatcher(labels.MatchEqual, m.Name, "")
-				if err != nil {
-					http.Error(w, err.Error(), http.StatusInternalServerError)
-					return
-				}
-				filteredMatchers = append(filteredMatchers, matcher)
-			} else {
-				filteredMatchers = append(filteredMatchers, m)
-			}
-		}
+	if err := remote.EncodeReadResponse(&resp, w); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
 
-		set, _, err := querier.Select(selectParams, filteredMatchers...)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		resp.Results[i], err = remote.ToQueryResult(set, api.remoteReadSampleLimit)
+func (api *API) remoteReadStreamedXORChunks(w http.ResponseWriter, r *http.Request, req *prompb.ReadRequest, externalLabels model.LabelSet, sortedExternalLabels []prompb.Label) {
+	f, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "internal http.ResponseWriter does not implement http.Flusher interface", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", remote.StreamedChunksContentType)
+
+	for i, query := range req.Queries {
+		err := api.remoteReadQuery(r.Context(), query, externalLabels, func(set storage.SeriesSet) error {
+			return remote.StreamChunkedReadResponses(
+				remote.NewChunkedWriter(w, f),
+				int64(i),
+				set,
+				sortedExternalLabels,
+				api.remoteReadMaxBytesInFrame,
+			)
+		})
 		if err != nil {
+			// Headers and parts of the stream may already be sent, the error
+			// ends up in the body and breaks the stream for the client.
 			if httpErr, ok := err.(remote.HTTPError); ok {
 				http.Error(w, httpErr.Error(), httpErr.Status())
 				return
@@ -887,28 +1144,45 @@ func (api *API) remoteRead(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+	}
+}
 
-		// Add external labels back in, in sorted order.
-		sortedExternalLabels := make([]prompb.Label, 0, len(externalLabels))
-		for name, value := range externalLabels {
-			sortedExternalLabels = append(sortedExternalLabels, prompb.Label{
-				Name:  string(name),
-				Value: string(value),
-			})
-		}
-		sort.Slice(sortedExternalLabels, func(i, j int) bool {
-			return sortedExternalLabels[i].Name < sortedExternalLabels[j].Name
-		})
+// remoteReadQuery selects the series matching the query from the local
+// storage and passes them to seriesHandleFn.
+func (api *API) remoteReadQuery(ctx context.Context, query *prompb.Query, externalLabels model.LabelSet, seriesHandleFn func(storage.SeriesSet) error) error {
+	from, through, matchers, selectParams, err := remote.FromQuery(query)
+	if err != nil {
+		return remote.NewHTTPError(err, http.StatusBadRequest)
+	}
+
+	querier, err := api.Queryable.Querier(ctx, from, through)
+	if err != nil {
+		return err
+	}
+	defer querier.Close()
 
-		for _, ts := range resp.Results[i].Timeseries {
-			ts.Labels = mergeLabels(ts.Labels, sortedExternalLabels)
+	// Change equality matchers which match external labels
+	// to a matcher that looks for an empty label,
+	// as that label should not be present in the storage.
+	filteredMatchers := make([]*labels.Matcher, 0, len(matchers))
+	for _, m := range matchers {
+		value := externalLabels[model.LabelName(m.Name)]
+		if m.Type == labels.MatchEqual && value == model.LabelValue(m.Value) {
+			matcher, err := labels.NewMatcher(labels.MatchEqual, m.Name, "")
+			if err != nil {
+				return err
+			}
+			filteredMatchers = append(filteredMatchers, matcher)
+		} else {
+			filteredMatchers = append(filteredMatchers, m)
 		}
 	}
 
-	if err := remote.EncodeReadResponse(&resp, w); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	set, _, err := querier.Select(selectParams, filteredMatchers...)
+	if err != nil {
+		return err
 	}
+	return seriesHandleFn(set)
 }
 
 func (api *API) deleteSeries(r *http.Request) apiFuncResult {
@@ -1048,33 +1322,6 @@ func convertMatcher(m *labels.Matcher) tsdbLabels.Matcher {
 	panic("storage.convertMatcher: invalid matcher type")
 }
 
-// mergeLabels merges two sets of sorted proto labels, preferring those in
-// primary to those in secondary when there is an overlap.
-func mergeLabels(primary, secondary []prompb.Label) []prompb.Label {
-	result := make([]prompb.Label, 0, len(primary)+len(secondary))
-	i, j := 0, 0
-	for i < len(primary) && j < len(secondary) {
-		if primary[i].Name < secondary[j].Name {
-			result = append(result, primary[i])
-			i++
-		} else if primary[i].Name > secondary[j].Name {
-			result = append(result, secondary[j])
-			j++
-		} else {
-			result = append(result, primary[i])
-			i++
-			j++
-		}
-	}
-	for ; i < len(primary); i++ {
-		result = append(result, primary[i])
-	}
-	for ; j < len(secondary); j++ {
-		result = append(result, secondary[j])
-	}
-	return result
-}
-
 func (api *API) respond(w http.ResponseWriter, data interface{}, warnings storage.Warnings) {
 	statusMessage := statusSuccess
 	var warningStrings []string
@@ -19,6 +19,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"math"
 	"net/http"
@@ -39,6 +40,9 @@ import (
 	"github.com/prometheus/common/route"
 
 	"github.com/prometheus/prometheus/config"
+	"github.com/prometheus/prometheus/discovery"
+	"github.com/prometheus/prometheus/discovery/targetgroup"
+	"github.com/prometheus/prometheus/pkg/exemplar"
 	"github.com/prometheus/prometheus/pkg/gate"
 	"github.com/prometheus/prometheus/pkg/labels"
 	"github.com/prometheus/prometheus/pkg/timestamp"
@@ -99,6 +103,48 @@ func (t testTargetRetriever) TargetsDropped() map[string][]*scrape.Target {
 	}
 }
 
+type testDiscoveryRetriever struct{}
+
+func (t testDiscoveryRetriever) DiscoveredGroups() map[string]map[string][]*targetgroup.Group {
+	return map[string]map[string][]*targetgroup.Group{
+		"test": {
+			"*file.SDConfig/0": {
+				{
+					Source:  "targets.json:0",
+					Labels:  model.LabelSet{"env": "prod"},
+					Targets: []model.LabelSet{{model.AddressLabel: "example.com:8080"}},
+				},
+			},
+		},
+		"blackbox": {
+			"string/1": {
+				{
+					Source:  "0",
+					Targets: []model.LabelSet{{model.AddressLabel: "localhost:9115"}},
+				},
+			},
+		},
+	}
+}
+
+func (t testDiscoveryRetriever) ProviderStatus() []discovery.ProviderStatus {
+	return []discovery.ProviderStatus{
+		{
+			Name:         "*file.SDConfig/0",
+			Subs:         []string{"test"},
+			LastUpdate:   time.Unix(1000, 0).UTC(),
+			TargetGroups: 1,
+			Targets:      1,
+		},
+		{
+			Name:          "*consul.SDConfig/1",
+			Subs:          []string{"blackbox", "test"},
+			LastError:     errors.New("connection refused"),
+			LastErrorTime: time.Unix(2000, 0).UTC(),
+		},
+	}
+}
+
 type testAlertmanagerRetriever struct{}
 
 func (t testAlertmanagerRetriever) Alertmanagers() []*url.URL {
@@ -247,6 +293,7 @@ func TestEndpoints(t *testing.T) {
 			Queryable:             suite.Storage(),
 			QueryEngine:           suite.QueryEngine(),
 			targetRetriever:       testTargetRetriever{},
+			discoveryRetriever:    testDiscoveryRetriever{},
 			alertmanagerRetriever: testAlertmanagerRetriever{},
 			flagsMap:              sampleFlagMap,
 			now:                   func() time.Time { return now },
@@ -279,9 +326,13 @@ func TestEndpoints(t *testing.T) {
 			Format: &af,
 		}
 
+		dbDir, err := ioutil.TempDir("", "remote-storage")
+		testutil.Ok(t, err)
+		defer os.RemoveAll(dbDir)
+
 		remote := remote.NewStorage(promlog.New(&promlogConfig), func() (int64, error) {
 			return 0, nil
-		}, 1*time.Second)
+		}, dbDir, 1*time.Second)
 
 		err = remote.ApplyConfig(&config.Config{
 			RemoteReadConfigs: []*config.RemoteReadConfig{
@@ -307,6 +358,7 @@ func TestEndpoints(t *testing.T) {
 			Queryable:             remote,
 			QueryEngine:           suite.QueryEngine(),
 			targetRetriever:       testTargetRetriever{},
+			discoveryRetriever:    testDiscoveryRetriever{},
 			alertmanagerRetriever: testAlertmanagerRetriever{},
 			flagsMap:              sampleFlagMap,
 			now:                   func() time.Time { return now },
@@ -402,6 +454,8 @@ func setupRemote(s storage.Storage) *httptest.Server {
 
 func testEndpoints(t *testing.T, api *API, testLabelAPI bool) {
 	start := time.Unix(0, 0)
+	lastUpdate := time.Unix(1000, 0).UTC()
+	lastErrorTime := time.Unix(2000, 0).UTC()
 
 	type test struct {
 		endpoint apiFunc
@@ -715,6 +769,70 @@ func testEndpoints(t *testing.T, api *API, testLabelAPI bool) {
 				},
 			},
 		},
+		{
+			endpoint: api.discoveredTargets,
+			response: []*DiscoveredTargetGroups{
+				{
+					ScrapePool: "blackbox",
+					Provider:   "string/1",
+					Groups: []*DiscoveredTargetGroup{
+						{
+							Source:  "0",
+							Labels:  map[string]string{},
+							Targets: []map[string]string{{"__address__": "localhost:9115"}},
+						},
+					},
+				},
+				{
+					ScrapePool: "test",
+					Provider:   "*file.SDConfig/0",
+					Groups: []*DiscoveredTargetGroup{
+						{
+							Source:  "targets.json:0",
+							Labels:  map[string]string{"env": "prod"},
+							Targets: []map[string]string{{"__address__": "example.com:8080"}},
+						},
+					},
+				},
+			},
+		},
+		{
+			endpoint: api.discoveredTargets,
+			query: url.Values{
+				"scrapePool": []string{"test"},
+			},
+			response: []*DiscoveredTargetGroups{
+				{
+					ScrapePool: "test",
+					Provider:   "*file.SDConfig/0",
+					Groups: []*DiscoveredTargetGroup{
+						{
+							Source:  "targets.json:0",
+							Labels:  map[string]string{"env": "prod"},
+							Targets: []map[string]string{{"__address__": "example.com:8080"}},
+						},
+					},
+				},
+			},
+		},
+		{
+			endpoint: api.serveDiscoveryStatus,
+			response: []*DiscoveryProviderStatus{
+				{
+					Provider:     "*file.SDConfig/0",
+					ScrapePools:  []string{"test"},
+					LastUpdate:   &lastUpdate,
+					TargetGroups: 1,
+					Targets:      1,
+				},
+				{
+					Provider:      "*consul.SDConfig/1",
+					ScrapePools:   []string{"blackbox", "test"},
+					LastError:     "connection refused",
+					LastErrorTime: &lastErrorTime,
+				},
+			},
+		},
 		{
 			endpoint: api.alertmanagers,
 			response: &AlertmanagerDiscovery{
@@ -901,6 +1019,162 @@ func assertAPIResponse(t *testing.T, got interface{}, exp interface{}) {
 	}
 }
 
+func TestQueryStats(t *testing.T) {
+	suite, err := promql.NewTest(t, `
+		load 1m
+			test_metric1{foo="bar"} 0+100x100
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer suite.Close()
+
+	if err := suite.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	api := &API{
+		Queryable:   suite.Storage(),
+		QueryEngine: suite.QueryEngine(),
+		now:         func() time.Time { return time.Unix(120, 0) },
+	}
+
+	for _, c := range []struct {
+		stats       string
+		timings     bool
+		samples     bool
+		totalSample int
+	}{
+		{stats: ""},
+		{stats: "true", timings: true},
+		{stats: "all", timings: true, samples: true, totalSample: 3},
+	} {
+		for name, endpoint := range map[string]apiFunc{"query": api.query, "query_range": api.queryRange} {
+			q := url.Values{
+				"query": []string{"test_metric1"},
+				"start": []string{"0"},
+				"end":   []string{"120"},
+				"step":  []string{"60"},
+				"stats": []string{c.stats},
+			}
+			req, err := http.NewRequest("GET", "http://example.com?"+q.Encode(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res := endpoint(req)
+			if res.err != nil {
+				t.Fatalf("%s: unexpected error: %s", name, res.err)
+			}
+			qs := res.data.(*queryData).Stats
+			if (qs != nil) != c.timings {
+				t.Fatalf("%s with stats=%q: expected timings %v, got %v", name, c.stats, c.timings, qs)
+			}
+			if qs == nil {
+				continue
+			}
+			if (qs.Samples != nil) != c.samples {
+				t.Fatalf("%s with stats=%q: expected samples %v, got %v", name, c.stats, c.samples, qs.Samples)
+			}
+			if name == "query_range" && qs.Samples != nil && qs.Samples.TotalQueryableSamples != c.totalSample {
+				t.Fatalf("%s: expected %d samples, got %d", name, c.totalSample, qs.Samples.TotalQueryableSamples)
+			}
+		}
+	}
+}
+
+func TestQueryExemplars(t *testing.T) {
+	es, err := storage.NewCircularExemplarStorage(10, nil)
+	testutil.Ok(t, err)
+
+	var (
+		lset1 = labels.FromStrings("__name__", "test_metric", "job", "a")
+		lset2 = labels.FromStrings("__name__", "test_metric", "job", "b")
+		lset3 = labels.FromStrings("__name__", "other_metric")
+	)
+	for _, s := range []struct {
+		lset labels.Labels
+		e    exemplar.Exemplar
+	}{
+		{lset1, exemplar.Exemplar{Labels: labels.FromStrings("trace_id", "abc"), Value: 0.5, Ts: 1500}},
+		{lset1, exemplar.Exemplar{Labels: labels.FromStrings("trace_id", "def"), Value: 1, Ts: 5000}},
+		{lset2, exemplar.Exemplar{Labels: labels.FromStrings("trace_id", "ghi"), Value: 2, Ts: 3000}},
+		{lset3, exemplar.Exemplar{Labels: labels.FromStrings("trace_id", "jkl"), Value: 3, Ts: 2000}},
+	} {
+		testutil.Ok(t, es.AppendExemplar(s.lset, s.e))
+	}
+
+	api := &API{ExemplarQueryable: es}
+
+	for i, c := range []struct {
+		query    url.Values
+		response interface{}
+		errType  errorType
+	}{
+		{
+			query: url.Values{"query": []string{`rate(test_metric{job="a"}[5m])`}},
+			response: []exemplarQueryResult{{
+				SeriesLabels: lset1,
+				Exemplars: []exemplarData{
+					{Labels: labels.FromStrings("trace_id", "abc"), Value: "0.5", Timestamp: 1.5},
+					{Labels: labels.FromStrings("trace_id", "def"), Value: "1", Timestamp: 5},
+				},
+			}},
+		}, {
+			query: url.Values{
+				"query": []string{"test_metric + other_metric"},
+				"start": []string{"2"},
+				"end":   []string{"4"},
+			},
+			response: []exemplarQueryResult{
+				{
+					SeriesLabels: lset3,
+					Exemplars:    []exemplarData{{Labels: labels.FromStrings("trace_id", "jkl"), Value: "3", Timestamp: 2}},
+				}, {
+					SeriesLabels: lset2,
+					Exemplars:    []exemplarData{{Labels: labels.FromStrings("trace_id", "ghi"), Value: "2", Timestamp: 3}},
+				},
+			},
+		}, {
+			query:    url.Values{"query": []string{"unknown_metric"}},
+			response: []exemplarQueryResult{},
+		}, {
+			query:   url.Values{"query": []string{"test_metric{"}},
+			errType: errorBadData,
+		}, {
+			query: url.Values{
+				"query": []string{"test_metric"},
+				"start": []string{"4"},
+				"end":   []string{"2"},
+			},
+			errType: errorBadData,
+		},
+	} {
+		req, err := http.NewRequest("GET", "http://example.com?"+c.query.Encode(), nil)
+		testutil.Ok(t, err)
+
+		t.Logf("run %d:\t%s", i, c.query.Encode())
+		res := api.queryExemplars(req)
+		assertAPIError(t, res.err, c.errType)
+		if c.errType == errorNone {
+			assertAPIResponse(t, res.data, c.response)
+		}
+	}
+
+	// The endpoint is unavailable without exemplar storage.
+	req, err := http.NewRequest("GET", "http://example.com?query=test_metric", nil)
+	testutil.Ok(t, err)
+	assertAPIError(t, (&API{}).queryExemplars(req).err, errorUnavailable)
+}
+
+func TestDiscoveryEndpointsUnavailable(t *testing.T) {
+	// The endpoints are unavailable without a discovery manager.
+	api := &API{}
+	req, err := http.NewRequest("GET", "http://example.com", nil)
+	testutil.Ok(t, err)
+	assertAPIError(t, api.discoveredTargets(req).err, errorUnavailable)
+	assertAPIError(t, api.serveDiscoveryStatus(req).err, errorUnavailable)
+}
+
 func TestReadEndpoint(t *testing.T) {
 	suite, err := promql.NewTest(t, `
 		load 1m
@@ -1003,6 +1277,152 @@ func TestReadEndpoint(t *testing.T) {
 	}
 }
 
+func TestStreamReadEndpoint(t *testing.T) {
+	// 3 series.
+	// First with one sample. We expect 1 frame with 1 chunk.
+	// Second with 121 samples, We expect 1 frame with 2 chunks.
+	// Third with 241 samples. We expect 1 frame with 2 chunks, and 1 frame with 1 chunk for the same series due to bytes limit.
+	suite, err := promql.NewTest(t, `
+		load 1m
+			test_metric1{foo="bar",baz="qux"} 1
+			test_metric1{foo="bar2",baz="qux"} 0+100x120
+			test_metric1{foo="bar3",baz="qux"} 0+100x240
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer suite.Close()
+
+	if err := suite.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	api := &API{
+		Queryable:   suite.Storage(),
+		QueryEngine: suite.QueryEngine(),
+		config: func() config.Config {
+			return config.Config{
+				GlobalConfig: config.GlobalConfig{
+					ExternalLabels: model.LabelSet{
+						"baz": "a",
+						"b":   "c",
+						"d":   "e",
+					},
+				},
+			}
+		},
+		remoteReadSampleLimit: 1e6,
+		remoteReadGate:        gate.New(1),
+		// Leaves room for at most two full chunks of the test data per frame.
+		remoteReadMaxBytesInFrame: 57 + 480,
+	}
+
+	// Encode the request.
+	matcher1, err := labels.NewMatcher(labels.MatchEqual, "__name__", "test_metric1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	matcher2, err := labels.NewMatcher(labels.MatchEqual, "d", "e")
+	if err != nil {
+		t.Fatal(err)
+	}
+	matcher3, err := labels.NewMatcher(labels.MatchEqual, "foo", "bar1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query1, err := remote.ToQuery(0, 14400001, []*labels.Matcher{matcher1, matcher2}, &storage.SelectParams{Step: 0, Func: "avg"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	query2, err := remote.ToQuery(0, 14400001, []*labels.Matcher{matcher1, matcher3}, &storage.SelectParams{Step: 0, Func: "avg"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := &prompb.ReadRequest{
+		Queries:               []*prompb.Query{query1, query2},
+		AcceptedResponseTypes: []prompb.ReadRequest_ResponseType{prompb.ReadRequest_STREAMED_XOR_CHUNKS},
+	}
+	data, err := proto.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compressed := snappy.Encode(nil, data)
+	request, err := http.NewRequest("POST", "", bytes.NewBuffer(compressed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := httptest.NewRecorder()
+	api.remoteRead(recorder, request)
+
+	if recorder.Code/100 != 2 {
+		t.Fatal(recorder.Code)
+	}
+	if ct := recorder.Result().Header.Get("Content-Type"); ct != remote.StreamedChunksContentType {
+		t.Fatalf("Unexpected content type %q", ct)
+	}
+
+	var results []*prompb.ChunkedReadResponse
+	stream := remote.NewChunkedReader(recorder.Result().Body, remote.DefaultChunkedReadLimit, nil)
+	for {
+		res := &prompb.ChunkedReadResponse{}
+		err := stream.NextProto(res)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		results = append(results, res)
+	}
+
+	if len(results) != 4 {
+		t.Fatalf("Expected 4 frames, got %d", len(results))
+	}
+
+	expectedLabels := func(foo string) []prompb.Label {
+		return []prompb.Label{
+			{Name: "__name__", Value: "test_metric1"},
+			{Name: "b", Value: "c"},
+			{Name: "baz", Value: "qux"},
+			{Name: "d", Value: "e"},
+			{Name: "foo", Value: foo},
+		}
+	}
+	expected := []struct {
+		labels []prompb.Label
+		chunks [][2]int64 // Min and max time of each chunk.
+	}{
+		{labels: expectedLabels("bar"), chunks: [][2]int64{{0, 0}}},
+		{labels: expectedLabels("bar2"), chunks: [][2]int64{{0, 7140000}, {7200000, 7200000}}},
+		{labels: expectedLabels("bar3"), chunks: [][2]int64{{0, 7140000}, {7200000, 14340000}}},
+		{labels: expectedLabels("bar3"), chunks: [][2]int64{{14400000, 14400000}}},
+	}
+	for i, res := range results {
+		if res.QueryIndex != 0 {
+			t.Fatalf("%d. Expected query index 0, got %d", i, res.QueryIndex)
+		}
+		if len(res.ChunkedSeries) != 1 {
+			t.Fatalf("%d. Expected 1 series, got %d", i, len(res.ChunkedSeries))
+		}
+		series := res.ChunkedSeries[0]
+		if !reflect.DeepEqual(series.Labels, expected[i].labels) {
+			t.Fatalf("%d. Expected labels %v, got %v", i, expected[i].labels, series.Labels)
+		}
+		var chunks [][2]int64
+		for _, c := range series.Chunks {
+			if c.Type != prompb.Chunk_XOR {
+				t.Fatalf("%d. Unexpected chunk encoding %v", i, c.Type)
+			}
+			chunks = append(chunks, [2]int64{c.MinTimeMs, c.MaxTimeMs})
+		}
+		if !reflect.DeepEqual(chunks, expected[i].chunks) {
+			t.Fatalf("%d. Expected chunks %v, got %v", i, expected[i].chunks, chunks)
+		}
+	}
+}
+
 type fakeDB struct {
 	err    error
 	closer func()
@@ -55,6 +55,8 @@ import (
 	"golang.org/x/net/netutil"
 
 	"github.com/prometheus/prometheus/config"
+	"github.com/prometheus/prometheus/discovery"
+	"github.com/prometheus/prometheus/discovery/targetgroup"
 	"github.com/prometheus/prometheus/notifier"
 	"github.com/prometheus/prometheus/promql"
 	"github.com/prometheus/prometheus/rules"
@@ -163,15 +165,17 @@ type PrometheusVersion struct {
 
 // Options for the web Handler.
 type Options struct {
-	Context       context.Context
-	TSDB          func() *tsdb.DB
-	Storage       storage.Storage
-	QueryEngine   *promql.Engine
-	ScrapeManager *scrape.Manager
-	RuleManager   *rules.Manager
-	Notifier      *notifier.Manager
-	Version       *PrometheusVersion
-	Flags         map[string]string
+	Context          context.Context
+	TSDB             func() *tsdb.DB
+	Storage          storage.Storage
+	ExemplarStorage  storage.ExemplarStorage
+	QueryEngine      *promql.Engine
+	ScrapeManager    *scrape.Manager
+	DiscoveryManager *discovery.Manager
+	RuleManager      *rules.Manager
+	Notifier         *notifier.Manager
+	Version          *PrometheusVersion
+	Flags            map[string]string
 
 	ListenAddress              string
 	CORSOrigin                 *regexp.Regexp
@@ -188,6 +192,8 @@ type Options struct {
 	PageTitle                  string
 	RemoteReadSampleLimit      int
 	RemoteReadConcurrencyLimit int
+	RemoteReadBytesInFrame     int
+	EnableRemoteWriteReceiver  bool
 }
 
 func instrumentHandlerWithPrefix(prefix string) func(handlerName string, handler http.HandlerFunc) http.HandlerFunc {
@@ -242,7 +248,17 @@ func New(logger log.Logger, o *Options) *Handler {
 		ready: 0,
 	}
 
-	h.apiV1 = api_v1.NewAPI(h.queryEngine, h.storage, h.scrapeManager, h.notifier,
+	// Without a discovery manager, the API must get a nil interface rather
+	// than a nil manager to report the discovery endpoints as unavailable.
+	var discoveryRetriever interface {
+		DiscoveredGroups() map[string]map[string][]*targetgroup.Group
+		ProviderStatus() []discovery.ProviderStatus
+	}
+	if o.DiscoveryManager != nil {
+		discoveryRetriever = o.DiscoveryManager
+	}
+
+	h.apiV1 = api_v1.NewAPI(h.queryEngine, h.storage, h.storage, o.ExemplarStorage, h.scrapeManager, discoveryRetriever, h.notifier,
 		func() config.Config {
 			h.mtx.RLock()
 			defer h.mtx.RUnlock()
@@ -261,6 +277,8 @@ func New(logger log.Logger, o *Options) *Handler {
 		h.ruleManager,
 		h.options.RemoteReadSampleLimit,
 		h.options.RemoteReadConcurrencyLimit,
+		h.options.RemoteReadBytesInFrame,
+		h.options.EnableRemoteWriteReceiver,
 		h.options.CORSOrigin,
 	)
 
@@ -657,18 +675,24 @@ func (h *Handler) serviceDiscovery(w http.ResponseWriter, r *http.Request) {
 		index = append(index, job)
 	}
 	sort.Strings(index)
+	var providers []discovery.ProviderStatus
+	if h.options.DiscoveryManager != nil {
+		providers = h.options.DiscoveryManager.ProviderStatus()
+	}
 	scrapeConfigData := struct {
-		Index   []string
-		Targets map[string][]*scrape.Target
-		Active  []int
-		Dropped []int
-		Total   []int
+		Index     []string
+		Targets   map[string][]*scrape.Target
+		Active    []int
+		Dropped   []int
+		Total     []int
+		Providers []discovery.ProviderStatus
 	}{
-		Index:   index,
-		Targets: make(map[string][]*scrape.Target),
-		Active:  make([]int, len(index)),
-		Dropped: make([]int, len(index)),
-		Total:   make([]int, len(index)),
+		Index:     index,
+		Targets:   make(map[string][]*scrape.Target),
+		Active:    make([]int, len(index)),
+		Dropped:   make([]int, len(index)),
+		Total:     make([]int, len(index)),
+		Providers: providers,
 	}
 	for i, job := range scrapeConfigData.Index {
 		scrapeConfigData.Targets[job] = make([]*scrape.Target, 0, len(targets[job]))